@@ -4,11 +4,15 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"net/url"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"hestia/pkg/auth"
+	"hestia/pkg/services"
+	"hestia/pkg/utils/parsers"
 )
 
 // httpConfig is the configuration for the HTTP server.
@@ -18,6 +22,10 @@ type httpConfig struct {
 	writeTimeout    time.Duration
 	idleTimeout     time.Duration
 	shutdownTimeout time.Duration
+	// corsOrigins may import listing pages from the browser, "*" allows all.
+	corsOrigins []string
+	// publicURL is the address hestia is reached at, used to link flats in emails.
+	publicURL string
 }
 
 // dbConfig is the database configuration.
@@ -26,11 +34,60 @@ type dbConfig struct {
 	migrate    bool
 }
 
+// parserConfig is the configuration for the listing parsers.
+type parserConfig struct {
+	genericFallback bool
+	// requestDelay is the pause between two requests to the same host.
+	requestDelay time.Duration
+	// parallelism is the number of requests to the same host at the same time.
+	parallelism int
+	// hostLimits replace requestDelay and parallelism for single hosts.
+	hostLimits map[string]parsers.HostLimit
+	// requestTimeout limits a single request, including reading the body.
+	requestTimeout time.Duration
+	// maxBodySize is the largest page parsed, in bytes.
+	maxBodySize int
+	// respectRobots makes the collector skip pages robots.txt forbids.
+	respectRobots bool
+	// transport configures the user agent, retries, proxies and response cache.
+	transport parsers.TransportConfig
+	// health configures the required fields and when drift alerts are raised.
+	health parsers.HealthConfig
+	// districtFile replaces the bundled district dictionary addresses are parsed with.
+	districtFile string
+}
+
+// mailConfig is the configuration for sending email.
+type mailConfig struct {
+	// smtpAddr is the "host:port" of the SMTP server. Emails are only
+	// logged if empty.
+	smtpAddr     string
+	smtpUsername string
+	smtpPassword string
+	from         string
+}
+
+// blobConfig is the configuration for the blob store.
+type blobConfig struct {
+	// dir is the directory the blobs are stored in.
+	dir string
+}
+
 // config is the configuration for the server command.
 type config struct {
-	http httpConfig
-	db   dbConfig
-	auth auth.JWTConfig
+	http    httpConfig
+	db      dbConfig
+	auth    auth.JWTConfig
+	parser  parserConfig
+	scrape  services.ScrapeConfig
+	crawl   services.CrawlConfig
+	refresh services.RefreshConfig
+	blob    blobConfig
+	photo   services.PhotoConfig
+	archive services.ArchiveConfig
+	rules   services.RulesConfig
+	search  services.SearchConfig
+	mail    mailConfig
 }
 
 // defaultConfig returns a config with sane default values.
@@ -42,6 +99,7 @@ func defaultConfig() config {
 			writeTimeout:    time.Second * 10,
 			idleTimeout:     time.Second * 120,
 			shutdownTimeout: time.Second * 15,
+			publicURL:       "http://localhost:8080",
 		},
 		db: dbConfig{
 			connection: "host=localhost port=5432 user=test password=password dbname=hestia sslmode=disable",
@@ -51,6 +109,72 @@ func defaultConfig() config {
 			SecretKey:     "secret",
 			TokenDuration: time.Minute * 120,
 		},
+		parser: parserConfig{
+			genericFallback: false,
+			requestDelay:    time.Second,
+			parallelism:     1,
+			requestTimeout:  time.Second * 30,
+			maxBodySize:     10 << 20,
+			respectRobots:   true,
+			transport: parsers.TransportConfig{
+				UserAgent:    "Mozilla/5.0 (compatible; hestia/1.0)",
+				MaxRetries:   2,
+				RetryBackoff: time.Second,
+				MaxBackoff:   time.Second * 30,
+				CacheTTL:     time.Hour,
+			},
+			health: parsers.HealthConfig{
+				Required:       []parsers.Field{parsers.FieldTitle, parsers.FieldPrice, parsers.FieldAddress},
+				Window:         time.Hour * 6,
+				MinSamples:     20,
+				AlertThreshold: 0.5,
+			},
+		},
+		scrape: services.ScrapeConfig{
+			Workers:      4,
+			MaxAttempts:  3,
+			PollInterval: time.Second * 5,
+			RetryDelay:   time.Second * 30,
+		},
+		crawl: services.CrawlConfig{
+			MaxPages:    10,
+			MaxListings: 200,
+			Timeout:     time.Minute * 2,
+		},
+		refresh: services.RefreshConfig{
+			Interval:     time.Hour * 24,
+			PollInterval: time.Minute,
+			BatchSize:    50,
+		},
+		blob: blobConfig{
+			dir: "data/blobs",
+		},
+		photo: services.PhotoConfig{
+			Workers:       4,
+			MaxAttempts:   3,
+			PollInterval:  time.Second * 5,
+			RetryDelay:    time.Minute,
+			Timeout:       time.Second * 30,
+			MaxSize:       10 << 20,
+			MaxPixels:     50_000_000,
+			ThumbnailSize: 320,
+		},
+		archive: services.ArchiveConfig{
+			Retention:     time.Hour * 24 * 30,
+			PruneInterval: time.Hour,
+			ReparseLimit:  500,
+			Timeout:       time.Minute * 5,
+		},
+		rules: services.RulesConfig{
+			ReloadInterval: time.Minute,
+		},
+		search: services.SearchConfig{
+			PollInterval:   time.Minute,
+			DigestInterval: time.Hour * 24,
+		},
+		mail: mailConfig{
+			from: "hestia@localhost",
+		},
 	}
 }
 
@@ -87,6 +211,11 @@ var envMap = map[string]envVariable{
 			return confDuration(v, &c.http.shutdownTimeout, 0, math.MaxInt64)
 		},
 	},
+	"HTTP_CORS_ORIGINS": {
+		mapFunc: func(v string, c *config) error {
+			return confList(v, &c.http.corsOrigins)
+		},
+	},
 	"DB_CONNECTION": {
 		mapFunc: func(v string, c *config) error {
 			return confString(v, &c.db.connection, 1, math.MaxInt64)
@@ -107,6 +236,270 @@ var envMap = map[string]envVariable{
 			return confDuration(v, &c.auth.TokenDuration, 0, math.MaxInt64)
 		},
 	},
+	"PARSER_GENERIC_FALLBACK": {
+		mapFunc: func(v string, c *config) error {
+			return confBool(v, &c.parser.genericFallback)
+		},
+	},
+	"PARSER_REQUEST_DELAY": {
+		mapFunc: func(v string, c *config) error {
+			return confDuration(v, &c.parser.requestDelay, 0, math.MaxInt64)
+		},
+	},
+	"PARSER_PARALLELISM": {
+		mapFunc: func(v string, c *config) error {
+			return confInt(v, &c.parser.parallelism, 1, 16)
+		},
+	},
+	"PARSER_HOST_LIMITS": {
+		mapFunc: func(v string, c *config) error {
+			limits, err := parsers.ParseHostLimits(v)
+			if err != nil {
+				return err
+			}
+			c.parser.hostLimits = limits
+			return nil
+		},
+	},
+	"PARSER_RESPECT_ROBOTS": {
+		mapFunc: func(v string, c *config) error {
+			return confBool(v, &c.parser.respectRobots)
+		},
+	},
+	"PARSER_USER_AGENT": {
+		mapFunc: func(v string, c *config) error {
+			return confString(v, &c.parser.transport.UserAgent, 1, 256)
+		},
+	},
+	"PARSER_MAX_RETRIES": {
+		mapFunc: func(v string, c *config) error {
+			return confInt(v, &c.parser.transport.MaxRetries, 0, 10)
+		},
+	},
+	"PARSER_RETRY_BACKOFF": {
+		mapFunc: func(v string, c *config) error {
+			return confDuration(v, &c.parser.transport.RetryBackoff, 0, math.MaxInt64)
+		},
+	},
+	"PARSER_MAX_BACKOFF": {
+		mapFunc: func(v string, c *config) error {
+			return confDuration(v, &c.parser.transport.MaxBackoff, 0, math.MaxInt64)
+		},
+	},
+	"PARSER_PROXIES": {
+		mapFunc: func(v string, c *config) error {
+			return confURLs(v, &c.parser.transport.Proxies)
+		},
+	},
+	"PARSER_CACHE_DIR": {
+		mapFunc: func(v string, c *config) error {
+			c.parser.transport.CacheDir = v
+			return nil
+		},
+	},
+	"PARSER_CACHE_TTL": {
+		mapFunc: func(v string, c *config) error {
+			return confDuration(v, &c.parser.transport.CacheTTL, 0, math.MaxInt64)
+		},
+	},
+	"PARSER_REQUEST_TIMEOUT": {
+		mapFunc: func(v string, c *config) error {
+			return confDuration(v, &c.parser.requestTimeout, time.Second, math.MaxInt64)
+		},
+	},
+	"PARSER_MAX_BODY_SIZE": {
+		mapFunc: func(v string, c *config) error {
+			return confInt(v, &c.parser.maxBodySize, 1<<10, math.MaxInt32)
+		},
+	},
+	"PARSER_REQUIRED_FIELDS": {
+		mapFunc: func(v string, c *config) error {
+			fields, err := parsers.ParseFields(v)
+			if err != nil {
+				return err
+			}
+			c.parser.health.Required = fields
+			return nil
+		},
+	},
+	"PARSER_HEALTH_WINDOW": {
+		mapFunc: func(v string, c *config) error {
+			return confDuration(v, &c.parser.health.Window, time.Minute, math.MaxInt64)
+		},
+	},
+	"PARSER_HEALTH_MIN_SAMPLES": {
+		mapFunc: func(v string, c *config) error {
+			return confInt(v, &c.parser.health.MinSamples, 1, 100000)
+		},
+	},
+	"PARSER_HEALTH_ALERT_THRESHOLD": {
+		mapFunc: func(v string, c *config) error {
+			return confFloat(v, &c.parser.health.AlertThreshold, 0, 1)
+		},
+	},
+	"SCRAPE_WORKERS": {
+		mapFunc: func(v string, c *config) error {
+			return confInt(v, &c.scrape.Workers, 1, 64)
+		},
+	},
+	"SCRAPE_MAX_ATTEMPTS": {
+		mapFunc: func(v string, c *config) error {
+			return confInt(v, &c.scrape.MaxAttempts, 1, 100)
+		},
+	},
+	"SCRAPE_POLL_INTERVAL": {
+		mapFunc: func(v string, c *config) error {
+			return confDuration(v, &c.scrape.PollInterval, time.Millisecond*100, math.MaxInt64)
+		},
+	},
+	"SCRAPE_RETRY_DELAY": {
+		mapFunc: func(v string, c *config) error {
+			return confDuration(v, &c.scrape.RetryDelay, 0, math.MaxInt64)
+		},
+	},
+	"CRAWL_MAX_PAGES": {
+		mapFunc: func(v string, c *config) error {
+			return confInt(v, &c.crawl.MaxPages, 1, 1000)
+		},
+	},
+	"CRAWL_MAX_LISTINGS": {
+		mapFunc: func(v string, c *config) error {
+			return confInt(v, &c.crawl.MaxListings, 1, 100000)
+		},
+	},
+	"CRAWL_TIMEOUT": {
+		mapFunc: func(v string, c *config) error {
+			return confDuration(v, &c.crawl.Timeout, time.Second, math.MaxInt64)
+		},
+	},
+	"REFRESH_INTERVAL": {
+		mapFunc: func(v string, c *config) error {
+			return confDuration(v, &c.refresh.Interval, 0, math.MaxInt64)
+		},
+	},
+	"REFRESH_POLL_INTERVAL": {
+		mapFunc: func(v string, c *config) error {
+			return confDuration(v, &c.refresh.PollInterval, time.Second, math.MaxInt64)
+		},
+	},
+	"REFRESH_BATCH_SIZE": {
+		mapFunc: func(v string, c *config) error {
+			return confInt(v, &c.refresh.BatchSize, 1, 1000)
+		},
+	},
+	"DISTRICT_DICTIONARY": {
+		mapFunc: func(v string, c *config) error {
+			return confString(v, &c.parser.districtFile, 1, math.MaxInt64)
+		},
+	},
+	"BLOB_DIR": {
+		mapFunc: func(v string, c *config) error {
+			return confString(v, &c.blob.dir, 1, math.MaxInt64)
+		},
+	},
+	"PHOTO_WORKERS": {
+		mapFunc: func(v string, c *config) error {
+			return confInt(v, &c.photo.Workers, 1, 64)
+		},
+	},
+	"PHOTO_MAX_ATTEMPTS": {
+		mapFunc: func(v string, c *config) error {
+			return confInt(v, &c.photo.MaxAttempts, 1, 100)
+		},
+	},
+	"PHOTO_POLL_INTERVAL": {
+		mapFunc: func(v string, c *config) error {
+			return confDuration(v, &c.photo.PollInterval, time.Millisecond*100, math.MaxInt64)
+		},
+	},
+	"PHOTO_RETRY_DELAY": {
+		mapFunc: func(v string, c *config) error {
+			return confDuration(v, &c.photo.RetryDelay, 0, math.MaxInt64)
+		},
+	},
+	"PHOTO_TIMEOUT": {
+		mapFunc: func(v string, c *config) error {
+			return confDuration(v, &c.photo.Timeout, time.Second, math.MaxInt64)
+		},
+	},
+	"PHOTO_MAX_SIZE": {
+		mapFunc: func(v string, c *config) error {
+			return confInt(v, &c.photo.MaxSize, 1024, 1<<30)
+		},
+	},
+	"PHOTO_MAX_PIXELS": {
+		mapFunc: func(v string, c *config) error {
+			return confInt(v, &c.photo.MaxPixels, 0, 1<<30)
+		},
+	},
+	"PHOTO_THUMBNAIL_SIZE": {
+		mapFunc: func(v string, c *config) error {
+			return confInt(v, &c.photo.ThumbnailSize, 16, 4096)
+		},
+	},
+	"ARCHIVE_RETENTION": {
+		mapFunc: func(v string, c *config) error {
+			return confDuration(v, &c.archive.Retention, 0, math.MaxInt64)
+		},
+	},
+	"ARCHIVE_PRUNE_INTERVAL": {
+		mapFunc: func(v string, c *config) error {
+			return confDuration(v, &c.archive.PruneInterval, time.Minute, math.MaxInt64)
+		},
+	},
+	"ARCHIVE_REPARSE_LIMIT": {
+		mapFunc: func(v string, c *config) error {
+			return confInt(v, &c.archive.ReparseLimit, 1, 100000)
+		},
+	},
+	"ARCHIVE_REPARSE_TIMEOUT": {
+		mapFunc: func(v string, c *config) error {
+			return confDuration(v, &c.archive.Timeout, time.Second, math.MaxInt64)
+		},
+	},
+	"RULES_RELOAD_INTERVAL": {
+		mapFunc: func(v string, c *config) error {
+			return confDuration(v, &c.rules.ReloadInterval, 0, math.MaxInt64)
+		},
+	},
+	"SEARCH_POLL_INTERVAL": {
+		mapFunc: func(v string, c *config) error {
+			return confDuration(v, &c.search.PollInterval, 0, math.MaxInt64)
+		},
+	},
+	"SEARCH_DIGEST_INTERVAL": {
+		mapFunc: func(v string, c *config) error {
+			return confDuration(v, &c.search.DigestInterval, time.Minute, math.MaxInt64)
+		},
+	},
+	"PUBLIC_URL": {
+		mapFunc: func(v string, c *config) error {
+			return confURL(v, &c.http.publicURL)
+		},
+	},
+	"SMTP_ADDR": {
+		mapFunc: func(v string, c *config) error {
+			c.mail.smtpAddr = v
+			return nil
+		},
+	},
+	"SMTP_USERNAME": {
+		mapFunc: func(v string, c *config) error {
+			c.mail.smtpUsername = v
+			return nil
+		},
+	},
+	"SMTP_PASSWORD": {
+		mapFunc: func(v string, c *config) error {
+			c.mail.smtpPassword = v
+			return nil
+		},
+	},
+	"SMTP_FROM": {
+		mapFunc: func(v string, c *config) error {
+			return confString(v, &c.mail.from, 3, 256)
+		},
+	},
 }
 
 // configFromEnv returns a config with values from the environment.
@@ -158,6 +551,95 @@ func confString(v string, tgt *string, minLen, maxLen int) error {
 	return nil
 }
 
+// confInt attempts to parse v into tgt and checks if the result is in
+// the provided range (inclusive).
+func confInt(v string, tgt *int, min, max int) error {
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		return err
+	}
+
+	if i < min || i > max {
+		return fmt.Errorf("integer %d not in range [%d, %d] (inclusive)", i, min, max)
+	}
+
+	*tgt = i
+
+	return nil
+}
+
+// confFloat attempts to parse v into tgt and checks if the result is in
+// the provided range (inclusive).
+func confFloat(v string, tgt *float64, min, max float64) error {
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return err
+	}
+
+	if math.IsNaN(f) || f < min || f > max {
+		return fmt.Errorf("float %g not in range [%g, %g] (inclusive)", f, min, max)
+	}
+
+	*tgt = f
+
+	return nil
+}
+
+// confList splits the comma separated values in v into tgt, leaving out empty ones.
+func confList(v string, tgt *[]string) error {
+	var out []string
+	for _, s := range strings.Split(v, ",") {
+		s = strings.TrimSpace(s)
+		if s != "" {
+			out = append(out, s)
+		}
+	}
+
+	*tgt = out
+
+	return nil
+}
+
+// confURL checks that v is an absolute URL and stores it in tgt.
+func confURL(v string, tgt *string) error {
+	u, err := url.Parse(strings.TrimSpace(v))
+	if err != nil {
+		return err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("url %s is not absolute", u.Redacted())
+	}
+
+	*tgt = u.String()
+
+	return nil
+}
+
+// confURLs parses the comma separated absolute URLs in v into tgt.
+func confURLs(v string, tgt *[]*url.URL) error {
+	var urls []*url.URL
+	for _, raw := range strings.Split(v, ",") {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+
+		u, err := url.Parse(raw)
+		if err != nil {
+			return err
+		}
+		if u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("url %s is not absolute", u.Redacted())
+		}
+
+		urls = append(urls, u)
+	}
+
+	*tgt = urls
+
+	return nil
+}
+
 func confBool(v string, tgt *bool) error {
 	b, err := strconv.ParseBool(v)
 	if err != nil {
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+
+	"hestia/pkg"
+	"hestia/pkg/blobs"
+	"hestia/pkg/models"
+	"hestia/pkg/services"
+)
+
+// runCrawl runs the crawl command, which queues a scrape job for each
+// listing of a portal search. The jobs are picked up by the workers of
+// the server. The report is written to stdout as JSON.
+//
+//	hestia crawl [-max-pages n] [-max-listings n] <search url>
+func runCrawl(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	logger := slog.New(slog.NewTextHandler(stderr, nil))
+	logger = logger.With("revision", pkg.BuildRevision, "time", pkg.BuildRevisionTime)
+
+	var req models.CrawlRequest
+	fs := flag.NewFlagSet("crawl", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.IntVar(&req.MaxPages, "max-pages", 0, "maximum number of search results pages to visit")
+	fs.IntVar(&req.MaxListings, "max-listings", 0, "maximum number of listings to queue")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: hestia crawl [-max-pages n] [-max-listings n] <search url>")
+		fs.PrintDefaults()
+	}
+
+	err := fs.Parse(args)
+	if err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+	req.URL = fs.Arg(0)
+
+	cfg, err := configFromEnv()
+	if err != nil {
+		logger.Error("failed to get config from environment", "error", err)
+		return 1
+	}
+
+	dbPG, err := connectPGSQL(cfg)
+	if err != nil {
+		logger.Error("failed to connect to database", "error", err)
+		return 1
+	}
+
+	defer func() {
+		err := dbPG.Close()
+		if err != nil {
+			logger.Error("failed to close database handles", "error", err)
+			return
+		}
+	}()
+
+	collector, err := newCollector(cfg, logger, nil)
+	if err != nil {
+		logger.Error("failed to create collector", "error", err)
+		return 1
+	}
+
+	err = loadDistricts(cfg)
+	if err != nil {
+		logger.Error("failed to load district dictionary", "error", err)
+		return 1
+	}
+
+	blobStore, err := blobs.NewFS(cfg.blob.dir)
+	if err != nil {
+		logger.Error("failed to create blob store", "error", err)
+		return 1
+	}
+
+	errHandler := func(err error) {
+		logger.Error("crawl error", "error", err)
+	}
+	archiveSvc := services.NewArchiveService(dbPG, blobStore, collector, cfg.archive, errHandler)
+	scrapeSvc := services.NewScrapeService(dbPG, collector, archiveSvc, cfg.scrape, errHandler)
+	crawlSvc := services.NewCrawlService(dbPG, collector, scrapeSvc, cfg.crawl, errHandler)
+
+	// Parse with the rules admins published, like the server does.
+	err = services.NewParserRuleService(dbPG, collector, archiveSvc, cfg.rules, errHandler).Reload(ctx)
+	if err != nil {
+		logger.Error("failed to load parser rules", "error", err)
+		return 1
+	}
+
+	report, crawlErr := crawlSvc.Crawl(ctx, req, "")
+
+	enc := json.NewEncoder(stdout)
+	enc.SetIndent("", "  ")
+	err = enc.Encode(report)
+	if err != nil {
+		logger.Error("failed to write report", "error", err)
+		return 1
+	}
+
+	if crawlErr != nil {
+		logger.Error("crawl stopped with error", "error", crawlErr)
+		return 1
+	}
+
+	return 0
+}
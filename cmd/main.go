@@ -17,9 +17,13 @@ import (
 
 	"hestia/pkg"
 	"hestia/pkg/auth"
+	"hestia/pkg/blobs"
 	"hestia/pkg/db"
+	"hestia/pkg/email"
 	"hestia/pkg/middlewares"
+	"hestia/pkg/models"
 	"hestia/pkg/services"
+	"hestia/pkg/utils/normalize"
 	"hestia/pkg/web"
 
 	_ "github.com/lib/pq"
@@ -31,6 +35,14 @@ func main() {
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
+	if len(os.Args) > 1 && os.Args[1] == "crawl" {
+		os.Exit(runCrawl(ctx, os.Args[2:], os.Stdout, os.Stderr))
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "reparse" {
+		os.Exit(runReparse(ctx, os.Args[2:], os.Stdout, os.Stderr))
+	}
+
 	os.Exit(run(ctx, os.Stderr))
 }
 
@@ -69,31 +81,128 @@ func run(ctx context.Context, w io.Writer) int {
 	emailErrHandler := func(err error) {
 		logger.Error("email service error", "error", err)
 	}
-	emailSvc := services.NewEmailService(dbPG, emailErrHandler)
+	mailer, err := newMailer(cfg, logger)
+	if err != nil {
+		logger.Error("failed to create mailer", "error", err)
+		return 1
+	}
+	emailSvc := services.NewEmailService(dbPG, mailer, emailErrHandler)
 
 	authErrHandler := func(err error) {
 		logger.Error("auth service error", "error", err)
 	}
 	authSvc := services.NewAuthServer(dbPG, jwtC, authErrHandler)
 
-	collectorErrHandler := func(err error) {
-		logger.Error("collector util error", "error", err)
+	collector, err := newCollector(cfg, logger, func(h models.ParserHealth) {
+		emailSvc.NotifyAdmins("parser-drift-alert", h)
+	})
+	if err != nil {
+		logger.Error("failed to create collector", "error", err)
+		return 1
 	}
-	collector := parsers.NewCollector(colly.NewCollector(), collectorErrHandler)
+
+	err = loadDistricts(cfg)
+	if err != nil {
+		logger.Error("failed to load district dictionary", "error", err)
+		return 1
+	}
+
+	blobStore, err := blobs.NewFS(cfg.blob.dir)
+	if err != nil {
+		logger.Error("failed to create blob store", "error", err)
+		return 1
+	}
+
+	archiveErrHandler := func(err error) {
+		logger.Error("archive service error", "error", err)
+	}
+	archiveSvc := services.NewArchiveService(dbPG, blobStore, collector, cfg.archive, archiveErrHandler)
 
 	flatErrHandler := func(err error) {
 		logger.Error("flat service error", "error", err)
 	}
-	flatSvc := services.NewFlatService(dbPG, collector, flatErrHandler)
+	scrapeErrHandler := func(err error) {
+		logger.Error("scrape service error", "error", err)
+	}
+	scrapeSvc := services.NewScrapeService(dbPG, collector, archiveSvc, cfg.scrape, scrapeErrHandler)
+
+	photoErrHandler := func(err error) {
+		logger.Error("photo service error", "error", err)
+	}
+	photoSvc := services.NewPhotoService(dbPG, blobStore, collector, cfg.photo, photoErrHandler)
+
+	workspaceErrHandler := func(err error) {
+		logger.Error("workspace service error", "error", err)
+	}
+	workspaceSvc := services.NewWorkspaceService(dbPG, mailer, cfg.http.publicURL, workspaceErrHandler)
+
+	flatSvc := services.NewFlatService(dbPG, scrapeSvc, photoSvc, workspaceSvc, flatErrHandler)
+
+	crawlErrHandler := func(err error) {
+		logger.Error("crawl service error", "error", err)
+	}
+	crawlSvc := services.NewCrawlService(dbPG, collector, scrapeSvc, cfg.crawl, crawlErrHandler)
+
+	refreshErrHandler := func(err error) {
+		logger.Error("refresh service error", "error", err)
+	}
+	refreshSvc := services.NewRefreshService(dbPG, collector, archiveSvc, cfg.refresh, refreshErrHandler)
+
+	rulesErrHandler := func(err error) {
+		logger.Error("parser rule service error", "error", err)
+	}
+	rulesSvc := services.NewParserRuleService(dbPG, collector, archiveSvc, cfg.rules, rulesErrHandler)
+	err = rulesSvc.Reload(ctx)
+	if err != nil {
+		logger.Error("failed to load parser rules", "error", err)
+		return 1
+	}
+
+	addressErrHandler := func(err error) {
+		logger.Error("address service error", "error", err)
+	}
+	addressSvc := services.NewAddressService(dbPG, addressErrHandler)
+
+	shortlistErrHandler := func(err error) {
+		logger.Error("shortlist service error", "error", err)
+	}
+	shortlistSvc := services.NewShortlistService(dbPG, workspaceSvc, shortlistErrHandler)
+
+	searchErrHandler := func(err error) {
+		logger.Error("saved search service error", "error", err)
+	}
+	searchSvc := services.NewSavedSearchService(dbPG, mailer, cfg.http.publicURL, cfg.search, searchErrHandler)
+
+	pipelineErrHandler := func(err error) {
+		logger.Error("pipeline service error", "error", err)
+	}
+	pipelineSvc := services.NewPipelineService(dbPG, workspaceSvc, pipelineErrHandler)
+
+	commentErrHandler := func(err error) {
+		logger.Error("comment service error", "error", err)
+	}
+	commentSvc := services.NewCommentService(dbPG, workspaceSvc, mailer, cfg.http.publicURL, commentErrHandler)
 
 	serverDeps := &web.ServerDeps{
-		Logger:       logger,
-		AuthService:  authSvc,
-		UserService:  userSvc,
-		FlatService:  flatSvc,
-		EmailService: emailSvc,
-		JWT:          jwtC,
-		Interceptor:  interceptor,
+		Logger:           logger,
+		AuthService:      authSvc,
+		UserService:      userSvc,
+		FlatService:      flatSvc,
+		ScrapeService:    scrapeSvc,
+		CrawlService:     crawlSvc,
+		PhotoService:     photoSvc,
+		ArchiveService:   archiveSvc,
+		EmailService:     emailSvc,
+		RulesService:     rulesSvc,
+		AddressService:   addressSvc,
+		ShortlistService: shortlistSvc,
+		SearchService:    searchSvc,
+		PipelineService:  pipelineSvc,
+		CommentService:   commentSvc,
+		WorkspaceService: workspaceSvc,
+		JWT:              jwtC,
+		Interceptor:      interceptor,
+		CORSOrigins:      cfg.http.corsOrigins,
 	}
 
 	srv := &http.Server{
@@ -111,6 +220,36 @@ func run(ctx context.Context, w io.Writer) int {
 		return srv.ListenAndServe()
 	})
 
+	g.Go(func() error {
+		logger.Info("starting scrape workers", "workers", cfg.scrape.Workers)
+		return scrapeSvc.Run(gCtx)
+	})
+
+	g.Go(func() error {
+		logger.Info("starting photo workers", "workers", cfg.photo.Workers)
+		return photoSvc.Run(gCtx)
+	})
+
+	g.Go(func() error {
+		logger.Info("starting flat refresher", "interval", cfg.refresh.Interval)
+		return refreshSvc.Run(gCtx)
+	})
+
+	g.Go(func() error {
+		logger.Info("starting page archive pruning", "retention", cfg.archive.Retention)
+		return archiveSvc.Run(gCtx)
+	})
+
+	g.Go(func() error {
+		logger.Info("starting parser rule reloads", "interval", cfg.rules.ReloadInterval)
+		return rulesSvc.Run(gCtx)
+	})
+
+	g.Go(func() error {
+		logger.Info("starting saved search alerts", "interval", cfg.search.PollInterval)
+		return searchSvc.Run(gCtx)
+	})
+
 	g.Go(func() error {
 		<-gCtx.Done()
 		logger.Info("stopping http server")
@@ -132,6 +271,98 @@ func run(ctx context.Context, w io.Writer) int {
 	return 0
 }
 
+// newMailer creates the mailer that sends email through the configured
+// SMTP server, or logs it if there is none.
+func newMailer(cfg config, logger *slog.Logger) (*email.Service, error) {
+	templates, err := email.NewTemplates()
+	if err != nil {
+		return nil, err
+	}
+
+	var sender email.Sender = email.NewLogSender(logger)
+	if cfg.mail.smtpAddr != "" {
+		sender = email.NewSMTPSender(cfg.mail.smtpAddr, cfg.mail.smtpUsername, cfg.mail.smtpPassword)
+	}
+
+	return email.NewService(cfg.mail.from, templates, sender), nil
+}
+
+// loadDistricts replaces the bundled district dictionary with the
+// configured one, if any.
+func loadDistricts(cfg config) error {
+	if cfg.parser.districtFile == "" {
+		return nil
+	}
+
+	f, err := os.Open(cfg.parser.districtFile)
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	d, err := normalize.LoadDictionary(f)
+	if err != nil {
+		return fmt.Errorf("%s: %w", cfg.parser.districtFile, err)
+	}
+	normalize.SetDistricts(d)
+
+	return nil
+}
+
+// newCollector creates the collector used to scrape listings and search
+// results. Parser drift alerts are logged and passed on to alert, if set.
+func newCollector(cfg config, logger *slog.Logger, alert parsers.AlertFunc) (*parsers.Collector, error) {
+	collectorErrHandler := func(err error) {
+		logger.Error("collector util error", "error", err)
+	}
+	registry := parsers.DefaultRegistry(cfg.parser.genericFallback)
+
+	c := colly.NewCollector(
+		colly.AllowURLRevisit(),
+		colly.MaxBodySize(cfg.parser.maxBodySize),
+		colly.UserAgent(cfg.parser.transport.UserAgent),
+	)
+	c.IgnoreRobotsTxt = !cfg.parser.respectRobots
+
+	def := parsers.HostLimit{Delay: cfg.parser.requestDelay, Parallelism: cfg.parser.parallelism}
+	err := c.Limits(registry.LimitRules(def, cfg.parser.hostLimits))
+	if err != nil {
+		return nil, fmt.Errorf("failed to set rate limits: %w", err)
+	}
+
+	collector := parsers.NewCollector(c, registry, collectorErrHandler)
+	collector.RequestTimeout = cfg.parser.requestTimeout
+	transport := cfg.parser.transport
+	transport.MaxCacheSize = cfg.parser.maxBodySize
+	collector.WithTransport(parsers.NewTransport(transport, logger))
+	collector.WithMonitor(parsers.NewMonitor(cfg.parser.health, func(h models.ParserHealth) {
+		if h.Alerting {
+			logger.Warn("parser misses required fields", "portal", h.Portal, "incomplete", h.Incomplete, "samples", h.Samples, "rate", h.IncompleteRate)
+		} else {
+			logger.Info("parser recovered", "portal", h.Portal, "incomplete", h.Incomplete, "samples", h.Samples, "rate", h.IncompleteRate)
+		}
+
+		if alert != nil {
+			alert(h)
+		}
+	}))
+
+	logger.Info("configured collector",
+		"user_agent", cfg.parser.transport.UserAgent,
+		"request_delay", cfg.parser.requestDelay,
+		"parallelism", cfg.parser.parallelism,
+		"host_limits", len(cfg.parser.hostLimits),
+		"respect_robots", cfg.parser.respectRobots,
+		"max_retries", cfg.parser.transport.MaxRetries,
+		"proxies", len(cfg.parser.transport.Proxies),
+		"cache_dir", cfg.parser.transport.CacheDir,
+		"required_fields", cfg.parser.health.Required,
+	)
+
+	return collector, nil
+}
+
 // connectPGSQL connects to the database.
 func connectPGSQL(cfg config) (*sql.DB, error) {
 	dbPG, err := db.OpenPGSQL(cfg.db.connection)
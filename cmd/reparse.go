@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"hestia/pkg"
+	"hestia/pkg/blobs"
+	"hestia/pkg/models"
+	"hestia/pkg/services"
+)
+
+// runReparse runs the reparse command, which runs the current parsers over
+// the archived pages of listings and updates the flats. Without URLs or
+// flats the latest page of every listing is used, up to the limit. The
+// report with the changes per flat is written to stdout as JSON.
+//
+//	hestia reparse [-dry-run] [-limit n] [-flats id,...] [listing url...]
+func runReparse(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	logger := slog.New(slog.NewTextHandler(stderr, nil))
+	logger = logger.With("revision", pkg.BuildRevision, "time", pkg.BuildRevisionTime)
+
+	var (
+		req   models.ReparseRequest
+		flats string
+	)
+	fs := flag.NewFlagSet("reparse", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.BoolVar(&req.DryRun, "dry-run", false, "report the changes without applying them")
+	fs.IntVar(&req.Limit, "limit", 0, "maximum number of pages to re-parse")
+	fs.StringVar(&flats, "flats", "", "comma separated ids of the flats to re-parse")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: hestia reparse [-dry-run] [-limit n] [-flats id,...] [listing url...]")
+		fs.PrintDefaults()
+	}
+
+	err := fs.Parse(args)
+	if err != nil {
+		return 2
+	}
+	req.URLs = fs.Args()
+	for _, id := range strings.Split(flats, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			req.FlatIDs = append(req.FlatIDs, id)
+		}
+	}
+
+	cfg, err := configFromEnv()
+	if err != nil {
+		logger.Error("failed to get config from environment", "error", err)
+		return 1
+	}
+
+	dbPG, err := connectPGSQL(cfg)
+	if err != nil {
+		logger.Error("failed to connect to database", "error", err)
+		return 1
+	}
+
+	defer func() {
+		err := dbPG.Close()
+		if err != nil {
+			logger.Error("failed to close database handles", "error", err)
+			return
+		}
+	}()
+
+	collector, err := newCollector(cfg, logger, nil)
+	if err != nil {
+		logger.Error("failed to create collector", "error", err)
+		return 1
+	}
+
+	err = loadDistricts(cfg)
+	if err != nil {
+		logger.Error("failed to load district dictionary", "error", err)
+		return 1
+	}
+
+	blobStore, err := blobs.NewFS(cfg.blob.dir)
+	if err != nil {
+		logger.Error("failed to create blob store", "error", err)
+		return 1
+	}
+
+	errHandler := func(err error) {
+		logger.Error("reparse error", "error", err)
+	}
+	archiveSvc := services.NewArchiveService(dbPG, blobStore, collector, cfg.archive, errHandler)
+
+	// Parse with the rules admins published, like the server does.
+	err = services.NewParserRuleService(dbPG, collector, archiveSvc, cfg.rules, errHandler).Reload(ctx)
+	if err != nil {
+		logger.Error("failed to load parser rules", "error", err)
+		return 1
+	}
+
+	report, reparseErr := archiveSvc.ReparseAll(ctx, req)
+
+	enc := json.NewEncoder(stdout)
+	enc.SetIndent("", "  ")
+	err = enc.Encode(report)
+	if err != nil {
+		logger.Error("failed to write report", "error", err)
+		return 1
+	}
+
+	if reparseErr != nil {
+		logger.Error("reparse stopped with error", "error", reparseErr)
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,16 @@
+ALTER TABLE flats
+    ADD COLUMN price_amount          BIGINT,
+    ADD COLUMN price_currency        TEXT,
+    ADD COLUMN rent_amount           BIGINT,
+    ADD COLUMN rent_currency         TEXT,
+    ADD COLUMN deposit_amount        BIGINT,
+    ADD COLUMN deposit_currency      TEXT,
+    ADD COLUMN surface_m2            NUMERIC(8, 2),
+    ADD COLUMN room_count            INTEGER,
+    ADD COLUMN floor_number          INTEGER,
+    ADD COLUMN total_floors          INTEGER,
+    ADD COLUMN available_from_date   DATE,
+    ADD COLUMN parse_warnings        TEXT[];
+
+CREATE INDEX flats_price_amount_idx ON flats (price_amount);
+CREATE INDEX flats_surface_m2_idx ON flats (surface_m2);
@@ -0,0 +1,66 @@
+CREATE EXTENSION IF NOT EXISTS unaccent;
+
+-- hestia_pl folds Polish diacritics, so "garaz" matches "garaż".
+CREATE TEXT SEARCH CONFIGURATION hestia_pl (COPY = simple);
+ALTER TEXT SEARCH CONFIGURATION hestia_pl
+    ALTER MAPPING FOR word, hword, hword_part WITH unaccent, simple;
+
+-- hestia_pl_stem reduces inflected words to their base form, so "balkonem"
+-- matches "balkon". PostgreSQL does not ship a Polish stemmer; the ispell
+-- dictionary is used when the polish.dict and polish.affix files are
+-- installed on the server, otherwise the configuration behaves like simple.
+CREATE TEXT SEARCH CONFIGURATION hestia_pl_stem (COPY = simple);
+
+DO
+$$
+BEGIN
+    CREATE TEXT SEARCH DICTIONARY polish_ispell (
+        TEMPLATE = ispell,
+        DictFile = polish,
+        AffFile = polish
+    );
+    ALTER TEXT SEARCH CONFIGURATION hestia_pl_stem
+        ALTER MAPPING FOR asciiword, asciihword, hword_asciipart, word, hword, hword_part
+        WITH polish_ispell, simple;
+EXCEPTION
+    WHEN OTHERS THEN
+        RAISE NOTICE 'polish ispell dictionary not available, searching without stemming: %', SQLERRM;
+END
+$$;
+
+ALTER TABLE flats
+    ADD COLUMN search_vector TSVECTOR;
+
+CREATE FUNCTION flats_search_vector(title TEXT, address TEXT, description TEXT) RETURNS TSVECTOR
+    LANGUAGE sql
+    STABLE
+AS
+$$
+SELECT setweight(to_tsvector('hestia_pl', coalesce(title, '')) ||
+                 to_tsvector('hestia_pl_stem', coalesce(title, '')), 'A') ||
+       setweight(to_tsvector('hestia_pl', coalesce(address, '')) ||
+                 to_tsvector('hestia_pl_stem', coalesce(address, '')), 'B') ||
+       setweight(to_tsvector('hestia_pl', coalesce(description, '')) ||
+                 to_tsvector('hestia_pl_stem', coalesce(description, '')), 'C')
+$$;
+
+CREATE FUNCTION flats_search_vector_update() RETURNS TRIGGER
+    LANGUAGE plpgsql
+AS
+$$
+BEGIN
+    NEW.search_vector := flats_search_vector(NEW.title, NEW.address, NEW.description);
+    RETURN NEW;
+END
+$$;
+
+CREATE TRIGGER flats_search_vector_update
+    BEFORE INSERT OR UPDATE OF title, address, description
+    ON flats
+    FOR EACH ROW
+EXECUTE FUNCTION flats_search_vector_update();
+
+UPDATE flats
+SET search_vector = flats_search_vector(title, address, description);
+
+CREATE INDEX flats_search_vector_idx ON flats USING GIN (search_vector);
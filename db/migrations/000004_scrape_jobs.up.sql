@@ -0,0 +1,18 @@
+CREATE TABLE scrape_jobs
+(
+    id           BIGSERIAL PRIMARY KEY,
+    url          TEXT      NOT NULL,
+    status       TEXT      NOT NULL,
+    attempts     INTEGER   NOT NULL DEFAULT 0,
+    max_attempts INTEGER   NOT NULL,
+    last_error   TEXT,
+    flat_id      BIGINT REFERENCES flats (id) ON DELETE SET NULL,
+    requested_by TEXT,
+    run_after    TIMESTAMP NOT NULL,
+    created_at   TIMESTAMP NOT NULL,
+    updated_at   TIMESTAMP NOT NULL,
+    started_at   TIMESTAMP,
+    finished_at  TIMESTAMP
+);
+
+CREATE INDEX scrape_jobs_queued_idx ON scrape_jobs (run_after, id) WHERE status = 'queued';
@@ -0,0 +1,6 @@
+ALTER TABLE flats
+    ADD COLUMN source_url TEXT;
+
+CREATE UNIQUE INDEX flats_source_url_idx ON flats (source_url);
+
+CREATE INDEX scrape_jobs_url_idx ON scrape_jobs (url);
@@ -0,0 +1,18 @@
+ALTER TABLE flats
+    ADD COLUMN last_checked_at TIMESTAMP,
+    ADD COLUMN withdrawn_at    TIMESTAMP;
+
+CREATE INDEX flats_last_checked_at_idx ON flats (last_checked_at NULLS FIRST, id)
+    WHERE source_url IS NOT NULL AND withdrawn_at IS NULL;
+
+CREATE TABLE flat_changes
+(
+    id          BIGSERIAL PRIMARY KEY,
+    flat_id     BIGINT    NOT NULL REFERENCES flats (id) ON DELETE CASCADE,
+    field       TEXT      NOT NULL,
+    old_value   TEXT      NOT NULL,
+    new_value   TEXT      NOT NULL,
+    detected_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX flat_changes_flat_id_idx ON flat_changes (flat_id, detected_at);
@@ -0,0 +1,28 @@
+CREATE TABLE flat_price_history
+(
+    id          BIGSERIAL PRIMARY KEY,
+    flat_id     BIGINT    NOT NULL REFERENCES flats (id) ON DELETE CASCADE,
+    kind        TEXT      NOT NULL,
+    amount      BIGINT,
+    currency    TEXT,
+    raw         TEXT      NOT NULL,
+    recorded_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX flat_price_history_flat_id_idx ON flat_price_history (flat_id, kind, recorded_at);
+
+-- Flats stored before the history existed were first seen at their current values.
+INSERT INTO flat_price_history (flat_id, kind, amount, currency, raw, recorded_at)
+SELECT id, 'price', price_amount, price_currency, price, created_at
+FROM flats
+WHERE price <> '';
+
+INSERT INTO flat_price_history (flat_id, kind, amount, currency, raw, recorded_at)
+SELECT id, 'rent', rent_amount, rent_currency, rent, created_at
+FROM flats
+WHERE coalesce(rent, '') <> '';
+
+INSERT INTO flat_price_history (flat_id, kind, amount, currency, raw, recorded_at)
+SELECT id, 'deposit', deposit_amount, deposit_currency, deposit, created_at
+FROM flats
+WHERE coalesce(deposit, '') <> '';
@@ -0,0 +1,9 @@
+-- Flats with a canonical_id are duplicates of that flat, e.g. the same
+-- apartment posted on another portal. The fingerprint groups the candidates
+-- compared on import; existing flats get one the next time they are saved.
+ALTER TABLE flats
+    ADD COLUMN canonical_id BIGINT REFERENCES flats (id) ON DELETE SET NULL,
+    ADD COLUMN fingerprint  TEXT;
+
+CREATE INDEX flats_canonical_id_idx ON flats (canonical_id);
+CREATE INDEX flats_fingerprint_idx ON flats (fingerprint);
@@ -0,0 +1,23 @@
+CREATE TABLE flat_photos
+(
+    id            BIGSERIAL PRIMARY KEY,
+    flat_id       BIGINT    NOT NULL REFERENCES flats (id) ON DELETE CASCADE,
+    source_url    TEXT      NOT NULL,
+    position      INTEGER   NOT NULL,
+    status        TEXT      NOT NULL,
+    attempts      INTEGER   NOT NULL DEFAULT 0,
+    last_error    TEXT,
+    content_type  TEXT,
+    width         INTEGER,
+    height        INTEGER,
+    size          BIGINT,
+    blob_key      TEXT,
+    thumbnail_key TEXT,
+    run_after     TIMESTAMP NOT NULL,
+    created_at    TIMESTAMP NOT NULL,
+    updated_at    TIMESTAMP NOT NULL,
+    UNIQUE (flat_id, source_url)
+);
+
+CREATE INDEX flat_photos_flat_id_idx ON flat_photos (flat_id, position);
+CREATE INDEX flat_photos_pending_idx ON flat_photos (run_after, id) WHERE status = 'pending';
@@ -0,0 +1,18 @@
+CREATE TABLE page_snapshots
+(
+    id           BIGSERIAL PRIMARY KEY,
+    url          TEXT      NOT NULL,
+    final_url    TEXT      NOT NULL,
+    status_code  INTEGER   NOT NULL,
+    content_type TEXT,
+    size         BIGINT    NOT NULL,
+    blob_key     TEXT      NOT NULL,
+    flat_id      BIGINT    REFERENCES flats (id) ON DELETE SET NULL,
+    parse_error  TEXT,
+    fetched_at   TIMESTAMP NOT NULL,
+    created_at   TIMESTAMP NOT NULL
+);
+
+CREATE INDEX page_snapshots_url_idx ON page_snapshots (url, fetched_at DESC);
+CREATE INDEX page_snapshots_flat_id_idx ON page_snapshots (flat_id, fetched_at DESC);
+CREATE INDEX page_snapshots_failed_idx ON page_snapshots (fetched_at) WHERE parse_error IS NOT NULL;
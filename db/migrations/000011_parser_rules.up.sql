@@ -0,0 +1,14 @@
+CREATE TABLE parser_rules
+(
+    id           BIGSERIAL PRIMARY KEY,
+    portal       TEXT      NOT NULL,
+    version      INTEGER   NOT NULL,
+    rules        JSONB     NOT NULL,
+    note         TEXT,
+    created_by   TEXT,
+    created_at   TIMESTAMP NOT NULL,
+    published_at TIMESTAMP,
+    UNIQUE (portal, version)
+);
+
+CREATE INDEX parser_rules_published_idx ON parser_rules (portal, published_at DESC) WHERE published_at IS NOT NULL;
@@ -0,0 +1,10 @@
+ALTER TABLE flats
+    ADD COLUMN city              TEXT,
+    ADD COLUMN district          TEXT,
+    ADD COLUMN sub_district      TEXT,
+    ADD COLUMN street            TEXT,
+    ADD COLUMN region            TEXT,
+    ADD COLUMN address_unmatched TEXT[];
+
+CREATE INDEX flats_city_district_idx ON flats (city, district);
+CREATE INDEX flats_city_sub_district_idx ON flats (city, sub_district);
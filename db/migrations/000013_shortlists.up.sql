@@ -0,0 +1,20 @@
+CREATE TABLE shortlists
+(
+    id         BIGSERIAL PRIMARY KEY,
+    user_id    BIGINT    NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name       TEXT      NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    UNIQUE (user_id, name)
+);
+
+CREATE TABLE shortlist_flats
+(
+    shortlist_id BIGINT    NOT NULL REFERENCES shortlists (id) ON DELETE CASCADE,
+    flat_id      BIGINT    NOT NULL REFERENCES flats (id) ON DELETE CASCADE,
+    position     INTEGER   NOT NULL,
+    added_at     TIMESTAMP NOT NULL,
+    PRIMARY KEY (shortlist_id, flat_id)
+);
+
+CREATE INDEX shortlist_flats_flat_id_idx ON shortlist_flats (flat_id);
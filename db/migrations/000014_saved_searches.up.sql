@@ -0,0 +1,29 @@
+CREATE TABLE saved_searches
+(
+    id            BIGSERIAL PRIMARY KEY,
+    user_id       BIGINT    NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name          TEXT      NOT NULL,
+    query         TEXT      NOT NULL,
+    delivery      TEXT      NOT NULL,
+    paused        BOOLEAN   NOT NULL DEFAULT FALSE,
+    checked_until TIMESTAMP NOT NULL,
+    last_sent_at  TIMESTAMP,
+    created_at    TIMESTAMP NOT NULL,
+    updated_at    TIMESTAMP NOT NULL,
+    UNIQUE (user_id, name)
+);
+
+CREATE INDEX saved_searches_active_idx ON saved_searches (id) WHERE NOT paused;
+
+CREATE TABLE saved_search_alerts
+(
+    search_id  BIGINT    NOT NULL REFERENCES saved_searches (id) ON DELETE CASCADE,
+    flat_id    BIGINT    NOT NULL REFERENCES flats (id) ON DELETE CASCADE,
+    matched_at TIMESTAMP NOT NULL,
+    sent_at    TIMESTAMP,
+    PRIMARY KEY (search_id, flat_id)
+);
+
+CREATE INDEX saved_search_alerts_pending_idx ON saved_search_alerts (search_id) WHERE sent_at IS NULL;
+
+CREATE INDEX flats_updated_at_idx ON flats (updated_at);
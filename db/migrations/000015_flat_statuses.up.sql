@@ -0,0 +1,25 @@
+CREATE TABLE flat_statuses
+(
+    user_id    BIGINT    NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    flat_id    BIGINT    NOT NULL REFERENCES flats (id) ON DELETE CASCADE,
+    stage      TEXT      NOT NULL,
+    reason     TEXT,
+    updated_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, flat_id)
+);
+
+CREATE INDEX flat_statuses_stage_idx ON flat_statuses (user_id, stage, updated_at DESC);
+
+CREATE TABLE flat_status_transitions
+(
+    id         BIGSERIAL PRIMARY KEY,
+    user_id    BIGINT    NOT NULL,
+    flat_id    BIGINT    NOT NULL,
+    from_stage TEXT,
+    to_stage   TEXT      NOT NULL,
+    reason     TEXT,
+    created_at TIMESTAMP NOT NULL,
+    FOREIGN KEY (user_id, flat_id) REFERENCES flat_statuses (user_id, flat_id) ON DELETE CASCADE
+);
+
+CREATE INDEX flat_status_transitions_flat_idx ON flat_status_transitions (user_id, flat_id, created_at);
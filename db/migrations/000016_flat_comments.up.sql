@@ -0,0 +1,34 @@
+CREATE TABLE flat_notes
+(
+    user_id    BIGINT    NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    flat_id    BIGINT    NOT NULL REFERENCES flats (id) ON DELETE CASCADE,
+    body       TEXT      NOT NULL DEFAULT '',
+    rating     SMALLINT CHECK (rating BETWEEN 1 AND 5),
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, flat_id)
+);
+
+CREATE TABLE flat_comments
+(
+    id         BIGSERIAL PRIMARY KEY,
+    flat_id    BIGINT    NOT NULL REFERENCES flats (id) ON DELETE CASCADE,
+    user_id    BIGINT    NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    body       TEXT      NOT NULL,
+    mentions   TEXT[]    NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    deleted_at TIMESTAMP
+);
+
+CREATE INDEX flat_comments_flat_idx ON flat_comments (flat_id, created_at);
+
+CREATE TABLE flat_comment_revisions
+(
+    id         BIGSERIAL PRIMARY KEY,
+    comment_id BIGINT    NOT NULL REFERENCES flat_comments (id) ON DELETE CASCADE,
+    body       TEXT      NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX flat_comment_revisions_comment_idx ON flat_comment_revisions (comment_id, created_at);
@@ -0,0 +1,122 @@
+CREATE TABLE workspaces
+(
+    id         BIGSERIAL PRIMARY KEY,
+    name       TEXT      NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    -- seed_user_id links the workspaces created below to their users and is dropped once they are.
+    seed_user_id BIGINT
+);
+
+-- A user is a member of a single workspace.
+CREATE TABLE workspace_members
+(
+    workspace_id BIGINT    NOT NULL REFERENCES workspaces (id) ON DELETE CASCADE,
+    user_id      BIGINT    NOT NULL UNIQUE REFERENCES users (id) ON DELETE CASCADE,
+    role         TEXT      NOT NULL,
+    joined_at    TIMESTAMP NOT NULL,
+    PRIMARY KEY (workspace_id, user_id)
+);
+
+-- Listings are scraped once, the flats of a listing are shared by the workspaces hunting it.
+CREATE TABLE workspace_flats
+(
+    workspace_id BIGINT    NOT NULL REFERENCES workspaces (id) ON DELETE CASCADE,
+    flat_id      BIGINT    NOT NULL REFERENCES flats (id) ON DELETE CASCADE,
+    added_by     BIGINT REFERENCES users (id) ON DELETE SET NULL,
+    added_at     TIMESTAMP NOT NULL,
+    PRIMARY KEY (workspace_id, flat_id)
+);
+
+CREATE INDEX workspace_flats_flat_id_idx ON workspace_flats (flat_id);
+
+-- Every user gets a workspace of their own, holding all flats stored so far.
+INSERT INTO workspaces (name, created_at, updated_at, seed_user_id)
+SELECT email, created_at, created_at, id
+FROM users;
+
+INSERT INTO workspace_members (workspace_id, user_id, role, joined_at)
+SELECT id, seed_user_id, 'owner', created_at
+FROM workspaces;
+
+INSERT INTO workspace_flats (workspace_id, flat_id, added_at)
+SELECT w.id, f.id, f.created_at
+FROM workspaces w
+         CROSS JOIN flats f;
+
+ALTER TABLE workspaces
+    DROP COLUMN seed_user_id;
+
+-- Shortlists are shared by the members of a workspace, user_id is who made the list.
+ALTER TABLE shortlists
+    ADD COLUMN workspace_id BIGINT REFERENCES workspaces (id) ON DELETE CASCADE;
+
+UPDATE shortlists s
+SET workspace_id = m.workspace_id
+FROM workspace_members m
+WHERE m.user_id = s.user_id;
+
+ALTER TABLE shortlists
+    ALTER COLUMN workspace_id SET NOT NULL,
+    ALTER COLUMN user_id DROP NOT NULL,
+    DROP CONSTRAINT shortlists_user_id_name_key,
+    DROP CONSTRAINT shortlists_user_id_fkey,
+    ADD FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE SET NULL,
+    ADD UNIQUE (workspace_id, name);
+
+-- The pipeline is shared by the members of a workspace, user_id is who moved the flat last.
+ALTER TABLE flat_statuses
+    ADD COLUMN workspace_id BIGINT REFERENCES workspaces (id) ON DELETE CASCADE;
+
+UPDATE flat_statuses s
+SET workspace_id = m.workspace_id
+FROM workspace_members m
+WHERE m.user_id = s.user_id;
+
+ALTER TABLE flat_status_transitions
+    ADD COLUMN workspace_id BIGINT;
+
+UPDATE flat_status_transitions t
+SET workspace_id = m.workspace_id
+FROM workspace_members m
+WHERE m.user_id = t.user_id;
+
+ALTER TABLE flat_status_transitions
+    DROP CONSTRAINT flat_status_transitions_user_id_flat_id_fkey;
+
+ALTER TABLE flat_statuses
+    ALTER COLUMN workspace_id SET NOT NULL,
+    ALTER COLUMN user_id DROP NOT NULL,
+    DROP CONSTRAINT flat_statuses_pkey,
+    DROP CONSTRAINT flat_statuses_user_id_fkey,
+    ADD FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE SET NULL,
+    ADD PRIMARY KEY (workspace_id, flat_id);
+
+ALTER TABLE flat_status_transitions
+    ALTER COLUMN workspace_id SET NOT NULL,
+    ADD FOREIGN KEY (workspace_id, flat_id) REFERENCES flat_statuses (workspace_id, flat_id) ON DELETE CASCADE;
+
+DROP INDEX flat_statuses_stage_idx;
+CREATE INDEX flat_statuses_stage_idx ON flat_statuses (workspace_id, stage, updated_at DESC);
+
+DROP INDEX flat_status_transitions_flat_idx;
+CREATE INDEX flat_status_transitions_flat_idx ON flat_status_transitions (workspace_id, flat_id, created_at);
+
+-- Comments are visible to the members of the workspace they were posted in.
+ALTER TABLE flat_comments
+    ADD COLUMN workspace_id BIGINT REFERENCES workspaces (id) ON DELETE CASCADE;
+
+UPDATE flat_comments c
+SET workspace_id = m.workspace_id
+FROM workspace_members m
+WHERE m.user_id = c.user_id;
+
+ALTER TABLE flat_comments
+    ALTER COLUMN workspace_id SET NOT NULL;
+
+DROP INDEX flat_comments_flat_idx;
+CREATE INDEX flat_comments_flat_idx ON flat_comments (workspace_id, flat_id, created_at);
+
+-- Invitations to a workspace are email tokens.
+ALTER TABLE email_tokens
+    ADD COLUMN workspace_id BIGINT REFERENCES workspaces (id) ON DELETE CASCADE;
@@ -0,0 +1,15 @@
+-- Pages remember who they were fetched for, so that a flat parsed from them
+-- later on lands in the workspace of that user.
+ALTER TABLE page_snapshots
+    ADD COLUMN requested_by BIGINT REFERENCES users (id) ON DELETE SET NULL;
+
+-- Pages that did not turn into a flat yet take the user of the latest scrape job of their URL.
+UPDATE page_snapshots s
+SET requested_by = u.id
+FROM (SELECT DISTINCT ON (url) url, requested_by
+      FROM scrape_jobs
+      WHERE requested_by IS NOT NULL
+      ORDER BY url, id DESC) j
+         JOIN users u ON u.id::text = j.requested_by
+WHERE s.url = j.url
+  AND s.flat_id IS NULL;
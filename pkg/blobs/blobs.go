@@ -0,0 +1,18 @@
+// Package blobs stores binary objects, such as the photos of flats, by key.
+package blobs
+
+import (
+	"context"
+	"io"
+)
+
+// Store keeps blobs under slash separated keys, e.g. "flats/7/photos/12.jpg".
+type Store interface {
+	// Put stores the contents of r under key, replacing any previous blob.
+	Put(ctx context.Context, key string, r io.Reader) error
+	// Get opens the blob stored under key. It errors with
+	// custerrors.ErrNotFound if there is none.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the blob stored under key. Deleting a missing blob is not an error.
+	Delete(ctx context.Context, key string) error
+}
@@ -0,0 +1,111 @@
+package blobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"hestia/pkg/custerrors"
+)
+
+// FS is a Store that keeps blobs as files below a root directory.
+type FS struct {
+	root string
+}
+
+// NewFS creates a FS rooted at dir, creating the directory if needed.
+func NewFS(dir string) (*FS, error) {
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create blob directory: %w", err)
+	}
+
+	return &FS{root: dir}, nil
+}
+
+// Put writes the blob to a temporary file first, so that readers never see
+// a partially written blob.
+func (s *FS) Put(ctx context.Context, key string, r io.Reader) error {
+	name, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(name), 0o755)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(name), ".blob-*")
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(f, r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), name)
+	}
+	if err != nil {
+		removeErr := os.Remove(f.Name())
+		if removeErr != nil {
+			err = errors.Join(err, removeErr)
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (s *FS) Get(_ context.Context, key string) (io.ReadCloser, error) {
+	name, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("blob %s not found: %w", key, custerrors.ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+func (s *FS) Delete(_ context.Context, key string) error {
+	name, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(name)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+// path returns the file name of the blob stored under key. Keys must not
+// point outside of the root directory.
+func (s *FS) path(key string) (string, error) {
+	clean := path.Clean("/" + key)
+	if clean == "/" || clean != "/"+key || strings.Contains(key, "\\") {
+		return "", fmt.Errorf("%w: blob key %q", custerrors.ErrInvalidInput, key)
+	}
+
+	return filepath.Join(s.root, filepath.FromSlash(clean)), nil
+}
@@ -0,0 +1,64 @@
+package blobs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"hestia/pkg/custerrors"
+)
+
+func Test_FS(t *testing.T) {
+	ctx := context.Background()
+
+	s, err := NewFS(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+
+	err = s.Put(ctx, "flats/7/photos/1.jpg", strings.NewReader("jpeg"))
+	if err != nil {
+		t.Fatalf("failed to put blob: %v", err)
+	}
+
+	r, err := s.Get(ctx, "flats/7/photos/1.jpg")
+	if err != nil {
+		t.Fatalf("failed to get blob: %v", err)
+	}
+	got, err := io.ReadAll(r)
+	r.Close()
+	if err != nil {
+		t.Fatalf("failed to read blob: %v", err)
+	}
+	if string(got) != "jpeg" {
+		t.Errorf("got %q want %q", got, "jpeg")
+	}
+
+	for _, key := range []string{"flats/7/photos/1.jpg", "flats/7/photos/missing.jpg"} {
+		err = s.Delete(ctx, key)
+		if err != nil {
+			t.Fatalf("failed to delete blob %s: %v", key, err)
+		}
+	}
+
+	_, err = s.Get(ctx, "flats/7/photos/1.jpg")
+	if !errors.Is(err, custerrors.ErrNotFound) {
+		t.Errorf("expected errors to be %v got %v (via errors.Is)", custerrors.ErrNotFound, err)
+	}
+}
+
+func Test_FS_InvalidKey(t *testing.T) {
+	s, err := NewFS(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+
+	for _, key := range []string{"", "../secret", "flats/../../secret", "/flats/1", "flats//1", `flats\1`} {
+		err := s.Put(context.Background(), key, strings.NewReader("x"))
+		if !errors.Is(err, custerrors.ErrInvalidInput) {
+			t.Errorf("key %q: expected errors to be %v got %v (via errors.Is)", key, custerrors.ErrInvalidInput, err)
+		}
+	}
+}
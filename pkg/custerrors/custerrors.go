@@ -14,6 +14,8 @@ const (
 var (
 	ErrNotFound           = errors.New("not found")
 	ErrConstraintViolated = errors.New("already exists")
+	ErrInvalidInput       = errors.New("invalid input")
+	ErrForbidden          = errors.New("forbidden")
 )
 
 // MapDBErr maps database errors to appropriate custom errors errors.
@@ -0,0 +1,70 @@
+package email
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// SMTPSender sends email through an SMTP server.
+type SMTPSender struct {
+	addr string
+	auth smtp.Auth
+}
+
+// NewSMTPSender creates a sender for the SMTP server at addr ("host:port").
+// PLAIN authentication is used if username is not empty.
+func NewSMTPSender(addr, username, password string) *SMTPSender {
+	s := &SMTPSender{addr: addr}
+	if username != "" {
+		host, _, _ := net.SplitHostPort(addr)
+		s.auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	return s
+}
+
+// Send sends a plain text email. net/smtp does not support contexts, ctx
+// is only checked before the email is sent.
+func (s *SMTPSender) Send(ctx context.Context, from, recipient string, subject, body string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return smtp.SendMail(s.addr, s.auth, from, []string{recipient}, message(from, recipient, subject, body))
+}
+
+// message formats a plain text email.
+func message(from, recipient, subject, body string) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", recipient)
+	fmt.Fprintf(&b, "Subject: %s\r\n", strings.NewReplacer("\r", "", "\n", " ").Replace(subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(body, "\r\n", "\n"), "\n", "\r\n"))
+
+	return []byte(b.String())
+}
+
+// LogSender writes emails to a logger instead of sending them. It is used
+// when no SMTP server is configured.
+type LogSender struct {
+	logger *slog.Logger
+}
+
+// NewLogSender creates a sender that logs emails to logger.
+func NewLogSender(logger *slog.Logger) *LogSender {
+	return &LogSender{logger: logger}
+}
+
+func (s *LogSender) Send(ctx context.Context, from, recipient string, subject, body string) error {
+	s.logger.InfoContext(ctx, "email not sent, no smtp server configured", "to", recipient, "subject", subject, "body", body)
+	return nil
+}
@@ -0,0 +1,70 @@
+package email
+
+import (
+	"embed"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+	"text/template"
+)
+
+//go:embed templates/*.tmpl
+var templateFS embed.FS
+
+// Templates renders the email templates shipped with hestia. Every template
+// file defines a "subject" and a "body" template.
+type Templates struct {
+	templates map[string]*template.Template
+}
+
+// NewTemplates parses the email templates.
+func NewTemplates() (*Templates, error) {
+	files, err := fs.Glob(templateFS, "templates/*.tmpl")
+	if err != nil {
+		return nil, err
+	}
+
+	funcs := template.FuncMap{
+		"percent": func(f float64) string {
+			return fmt.Sprintf("%.0f%%", f*100)
+		},
+	}
+
+	t := &Templates{templates: make(map[string]*template.Template, len(files))}
+	for _, f := range files {
+		name := strings.TrimSuffix(path.Base(f), ".tmpl")
+		tmpl, err := template.New(name).Funcs(funcs).ParseFS(templateFS, f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse email template %s: %w", name, err)
+		}
+
+		t.templates[name] = tmpl
+	}
+
+	return t, nil
+}
+
+// Render writes element of the template name filled with data to w.
+func (t *Templates) Render(w io.Writer, name string, element TemplateElement, data any) error {
+	tmpl, ok := t.templates[name]
+	if !ok {
+		return fmt.Errorf("unknown email template %q", name)
+	}
+
+	var sb strings.Builder
+	err := tmpl.ExecuteTemplate(&sb, string(element), data)
+	if err != nil {
+		return err
+	}
+
+	// Subjects are written on one line, leading blank lines come from the template layout.
+	out := strings.TrimLeft(sb.String(), "\n")
+	if element == ElementSubject {
+		out = strings.TrimSpace(out)
+	}
+
+	_, err = io.WriteString(w, out)
+	return err
+}
@@ -0,0 +1,122 @@
+package email
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"hestia/pkg/models"
+)
+
+func Test_Templates_Render(t *testing.T) {
+	tests := map[string]struct {
+		name        string
+		data        any
+		wantSubject string
+		wantBody    string
+		wantErr     bool
+	}{
+		"ok, password reset": {
+			name:        "password-reset-request",
+			data:        models.EmailTokenRaw{ID: "abc", Token: "secret"},
+			wantSubject: "Reset your hestia password",
+			wantBody:    "Token:    secret",
+		},
+		"ok, parser drift alert": {
+			name: "parser-drift-alert",
+			data: models.ParserHealth{
+				Portal:         "otodom",
+				Samples:        40,
+				Incomplete:     30,
+				IncompleteRate: 0.75,
+				Alerting:       true,
+				Fields:         []models.FieldHealth{{Field: "price", Required: true, Filled: 10, FillRate: 0.25}},
+			},
+			wantSubject: "Parser for otodom misses required fields",
+			wantBody:    "price            25% (required)",
+		},
+		"ok, parser recovered": {
+			name:        "parser-drift-alert",
+			data:        models.ParserHealth{Portal: "olx", Samples: 40, Incomplete: 2, IncompleteRate: 0.05},
+			wantSubject: "Parser for olx recovered",
+			wantBody:    "(5%)",
+		},
+		"ok, saved search alert": {
+			name: "saved-search-alert",
+			data: models.SearchAlert{
+				Search: models.SavedSearch{Name: "Mokotów 2 pokoje"},
+				Flats: []models.SearchAlertFlat{
+					{ID: "7", Title: "Dwa pokoje z balkonem", Price: "3 200 zł", URL: "https://hestia.example/api/v1/flats/7"},
+					{ID: "9", Title: "Mieszkanie przy metrze", URL: "https://hestia.example/api/v1/flats/9", SourceURL: "https://www.olx.pl/d/oferta/9.html"},
+				},
+			},
+			wantSubject: "2 new flats for Mokotów 2 pokoje",
+			wantBody:    "  https://hestia.example/api/v1/flats/7\n",
+		},
+		"ok, saved search alert with one flat": {
+			name: "saved-search-alert",
+			data: models.SearchAlert{
+				Search: models.SavedSearch{Name: "Kraków"},
+				Flats:  []models.SearchAlertFlat{{ID: "3", Title: "Kawalerka", URL: "https://hestia.example/api/v1/flats/3"}},
+			},
+			wantSubject: "1 new flat for Kraków",
+			wantBody:    "A flat newly matches your saved search \"Kraków\"",
+		},
+		"ok, comment mention": {
+			name: "comment-mention",
+			data: models.CommentMention{
+				Comment: models.Comment{AuthorEmail: "ola@example.com", Body: "@jan@example.com zobacz balkon"},
+				Flat:    models.Flat{ID: "7", Title: "Dwa pokoje z balkonem"},
+				URL:     "https://hestia.example/api/v1/flats/7",
+			},
+			wantSubject: "ola@example.com mentioned you on Dwa pokoje z balkonem",
+			wantBody:    "@jan@example.com zobacz balkon\n\nSee the flat and reply in hestia:\n  https://hestia.example/api/v1/flats/7\n",
+		},
+		"ok, workspace invitation": {
+			name: "workspace-invitation",
+			data: models.WorkspaceInvitation{
+				Workspace: models.Workspace{ID: "3", Name: "Mieszkanie na Kazimierzu"},
+				InvitedBy: "ola@example.com",
+				Token:     models.EmailTokenRaw{ID: "abc", Token: "secret"},
+				ExpiresAt: time.Date(2024, 5, 8, 12, 0, 0, 0, time.UTC),
+				URL:       "https://hestia.example/api/v1/me/workspace/join",
+			},
+			wantSubject: "ola@example.com invited you to Mieszkanie na Kazimierzu on hestia",
+			wantBody:    "https://hestia.example/api/v1/me/workspace/join\nwith the following token before 8 May 2024 12:00 UTC:\n\nToken ID: abc\nToken:    secret\n",
+		},
+		"fail, unknown template": {
+			name:    "welcome",
+			wantErr: true,
+		},
+	}
+
+	templates, err := NewTemplates()
+	if err != nil {
+		t.Fatalf("failed to parse templates: %v", err)
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var subject, body strings.Builder
+			err := templates.Render(&subject, tc.name, ElementSubject, tc.data)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("got error %v want error %v", err, tc.wantErr)
+			}
+			if tc.wantErr {
+				return
+			}
+
+			err = templates.Render(&body, tc.name, ElementBody, tc.data)
+			if err != nil {
+				t.Fatalf("failed to render body: %v", err)
+			}
+
+			if subject.String() != tc.wantSubject {
+				t.Errorf("got subject %q want %q", subject.String(), tc.wantSubject)
+			}
+			if !strings.Contains(body.String(), tc.wantBody) {
+				t.Errorf("got body %q want it to contain %q", body.String(), tc.wantBody)
+			}
+		})
+	}
+}
@@ -29,7 +29,7 @@ func CheckJWT(i *auth.Interceptor, handler func(w http.ResponseWriter, r *http.R
 		}
 		tokenString = tokenString[len("Bearer "):]
 
-		method := combineURL(r.RequestURI)
+		method := combineURL(r.URL.Path)
 		userID, err := i.Authorize(method, tokenString)
 		if err != nil {
 			w.WriteHeader(http.StatusUnauthorized)
@@ -42,6 +42,37 @@ func CheckJWT(i *auth.Interceptor, handler func(w http.ResponseWriter, r *http.R
 	})
 }
 
+// CORS lets pages from origins call the wrapped handler from the browser,
+// such as a bookmarklet sending the page it runs on. An origin of "*"
+// allows all origins. Preflight requests are answered without calling the
+// handler, so it must also be registered for OPTIONS.
+func CORS(origins []string) Middleware {
+	allowed := make(map[string]bool, len(origins))
+	for _, o := range origins {
+		allowed[strings.TrimSuffix(o, "/")] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			w.Header().Add("Vary", "Origin")
+			if origin != "" && (allowed["*"] || allowed[origin]) {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+				w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+				w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+				w.Header().Set("Access-Control-Max-Age", "600")
+			}
+
+			if r.Method == http.MethodOptions {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 func ContextWithUserID(ctx context.Context, userID string) context.Context {
 	return context.WithValue(ctx, authUser, userID)
 }
@@ -55,14 +86,18 @@ func UserIDFromContext(ctx context.Context) (string, bool) {
 	return userID, true
 }
 
+// combineURL drops the numeric ids from url, so that /api/v1/flats/5/history
+// is checked against the rules of /api/v1/flats/history.
 func combineURL(url string) string {
 	split := strings.Split(url, string(os.PathSeparator))
-	lastEl := split[len(split)-1]
-	if isInt(lastEl) {
-		split = split[:len(split)-1]
-		return strings.Join(split, string(os.PathSeparator))
+	kept := split[:1]
+	for _, el := range split[1:] {
+		if isInt(el) {
+			continue
+		}
+		kept = append(kept, el)
 	}
-	return strings.Join(split, string(os.PathSeparator))
+	return strings.Join(kept, string(os.PathSeparator))
 }
 
 func isInt(s string) bool {
@@ -2,7 +2,37 @@ package middlewares
 
 func AccessibleRoles() map[string][]string {
 	return map[string][]string{
-		"/api/v1/users": {"admin"},
-		"/api/v1/flats": {"admin", "user"},
+		"/api/v1/users":                    {"admin"},
+		"/api/v1/flats":                    {"admin", "user"},
+		"/api/v1/flats/history":            {"admin", "user"},
+		"/api/v1/flats/duplicates":         {"admin", "user"},
+		"/api/v1/flats/photos":             {"admin", "user"},
+		"/api/v1/flats/photos/thumbnail":   {"admin", "user"},
+		"/api/v1/flats/preview":            {"admin", "user"},
+		"/api/v1/flats/import":             {"admin", "user"},
+		"/api/v1/flats/merge":              {"admin"},
+		"/api/v1/flats/split":              {"admin"},
+		"/api/v1/flats/snapshots":          {"admin"},
+		"/api/v1/flats/notes":              {"admin", "user"},
+		"/api/v1/flats/comments":           {"admin", "user"},
+		"/api/v1/snapshots":                {"admin"},
+		"/api/v1/snapshots/reparse":        {"admin"},
+		"/api/v1/parsers/health":           {"admin"},
+		"/api/v1/parser-rules":             {"admin"},
+		"/api/v1/parser-rules/publish":     {"admin"},
+		"/api/v1/parser-rules/test":        {"admin"},
+		"/api/v1/districts":                {"admin", "user"},
+		"/api/v1/addresses/unrecognized":   {"admin"},
+		"/api/v1/addresses/reparse":        {"admin"},
+		"/api/v1/me/shortlists":            {"admin", "user"},
+		"/api/v1/me/shortlists/flats":      {"admin", "user"},
+		"/api/v1/me/searches":              {"admin", "user"},
+		"/api/v1/me/pipeline":              {"admin", "user"},
+		"/api/v1/me/workspace":             {"admin", "user"},
+		"/api/v1/me/workspace/invitations": {"admin", "user"},
+		"/api/v1/me/workspace/join":        {"admin", "user"},
+		"/api/v1/me/workspace/members":     {"admin", "user"},
+		"/api/v1/scrape-jobs":              {"admin", "user"},
+		"/api/v1/crawls":                   {"admin", "user"},
 	}
 }
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"time"
+)
+
+// FlatNote is the private note and rating a user keeps on a flat.
+type FlatNote struct {
+	UserID string `json:"user_id"`
+	FlatID string `json:"flat_id"`
+	Body   string `json:"body"`
+	// Rating is from 1 to 5 stars, nil when the flat is not rated.
+	Rating    *int      `json:"rating"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Comment is a comment on a flat, visible to the members of the workspace
+// it was posted in.
+type Comment struct {
+	ID          string `json:"id"`
+	WorkspaceID string `json:"workspace_id"`
+	FlatID      string `json:"flat_id"`
+	UserID      string `json:"user_id"`
+	AuthorEmail string `json:"author_email"`
+	// Body is empty once the comment is deleted.
+	Body string `json:"body"`
+	// Mentions are the emails of the users mentioned in the body.
+	Mentions  []string   `json:"mentions"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	// Revisions are the earlier bodies of the comment, oldest first, only
+	// set when a single comment is read.
+	Revisions []CommentRevision `json:"revisions,omitempty"`
+}
+
+// CommentRevision is a body a comment had before it was edited.
+type CommentRevision struct {
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CommentBody is a request to post or edit a comment.
+type CommentBody struct {
+	Body string `json:"body"`
+}
+
+// CommentMention is the data of the email sent to a user mentioned in a comment.
+type CommentMention struct {
+	Comment Comment
+	Flat    Flat
+	// URL links to the flat in hestia.
+	URL string
+}
@@ -0,0 +1,25 @@
+package models
+
+// CrawlRequest asks to import the listings of a portal search.
+type CrawlRequest struct {
+	// URL is the first search results page.
+	URL string `json:"url"`
+	// MaxPages and MaxListings lower the configured limits. Zero keeps them.
+	MaxPages    int `json:"max_pages"`
+	MaxListings int `json:"max_listings"`
+}
+
+// CrawlReport summarises a crawl. A scrape job is queued for each new and
+// updated listing.
+type CrawlReport struct {
+	URL   string `json:"url"`
+	Pages int    `json:"pages"`
+	// New counts listings not stored as flats yet.
+	New int `json:"new"`
+	// Updated counts listings stored before, which are scraped again.
+	Updated int `json:"updated"`
+	// Skipped counts listings already waiting for a scrape job and links
+	// to portals without a parser.
+	Skipped int      `json:"skipped"`
+	JobIDs  []string `json:"job_ids"`
+}
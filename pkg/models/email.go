@@ -6,13 +6,15 @@ import (
 
 // EmailToken contains the state of a token that was sent via email.
 type EmailToken struct {
-	ID         string
-	TokenHash  string
-	UserID     string
-	Email      string
-	Purpose    TokenPurpose
-	CreatedAt  time.Time
-	ConsumedAt *time.Time
+	ID        string
+	TokenHash string
+	UserID    string
+	Email     string
+	Purpose   TokenPurpose
+	// WorkspaceID is the workspace a user is invited to.
+	WorkspaceID string
+	CreatedAt   time.Time
+	ConsumedAt  *time.Time
 }
 
 // TokenPurpose is the purpose of an email token.
@@ -23,6 +25,8 @@ const (
 	TokenPurposeActivate TokenPurpose = "activate"
 	// TokenPurposePasswordReset indicates a token should be used to reset a password.
 	TokenPurposePasswordReset TokenPurpose = "password_reset"
+	// TokenPurposeWorkspaceInvite indicates a token should be used to join a workspace.
+	TokenPurposeWorkspaceInvite TokenPurpose = "workspace_invite"
 )
 
 // EmailTokenRaw is the raw data that will be send to the user via email.
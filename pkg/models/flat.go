@@ -4,13 +4,54 @@ import (
 	"time"
 )
 
+// FlatFilter narrows down the flats returned by a query.
+// Nil and empty values are ignored, ranges are inclusive.
 type FlatFilter struct {
+	IDs        []string
+	SourceURLs []string
+	// Groups matches the flats with these ids and their duplicates.
+	Groups       []string
+	Fingerprints []string
+	// CanonicalOnly leaves out flats that are duplicates of another flat.
+	CanonicalOnly bool
+	// MinPrice and MaxPrice are in minor units, like Money.Amount.
+	MinPrice   *int64
+	MaxPrice   *int64
+	MinRent    *int64
+	MaxRent    *int64
+	MinSurface *float64
+	MaxSurface *float64
+	Rooms      []int
+	MinFloor   *int
+	MaxFloor   *int
+	// City and District match the parts of the address, District also
+	// matches the sub-district.
+	City     string
+	District string
+	// AvailableFrom matches flats that are available on or before the date.
+	AvailableFrom *time.Time
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	// UpdatedAfter matches flats stored or changed after the time. With
+	// WorkspaceID, flats the workspace added after the time match too.
+	UpdatedAfter *time.Time
+	// Query is a full-text search over the title, address and description.
+	Query string
+	// WorkspaceID matches the flats of the workspace. With CanonicalOnly,
+	// duplicates of a flat the workspace does not hold are kept.
+	WorkspaceID string
 }
 
 type Url struct {
 	Url string
 }
 
+// Money is an amount of money in minor units (e.g. grosze) and its ISO 4217 currency code.
+type Money struct {
+	Amount   int64
+	Currency string
+}
+
 // Flat contains the data for a flat.
 type Flat struct {
 	ID            string
@@ -26,4 +67,75 @@ type Flat struct {
 	Description   string
 	CreatedAt     time.Time
 	UpdatedAt     time.Time
+
+	// SourceURL is the listing the flat was scraped from.
+	SourceURL string
+	// LastCheckedAt is when the listing was last scraped again.
+	LastCheckedAt *time.Time
+	// WithdrawnAt is when the listing was found to be taken down.
+	WithdrawnAt *time.Time
+	// CanonicalID is the flat this one is a duplicate of, empty for canonical flats.
+	CanonicalID string
+	// Fingerprint groups the flats that may be duplicates of each other.
+	Fingerprint string
+	// Sources are the listings of the flat and its duplicates.
+	Sources []string
+	// PriceChange is set once the current and the first seen price are known.
+	PriceChange *PriceChange `json:",omitempty"`
+
+	// Typed values normalized from the raw text fields above.
+	// A nil value means the raw text was empty or could not be parsed.
+	PriceValue        *Money
+	RentValue         *Money
+	DepositValue      *Money
+	SurfaceM2         *float64
+	RoomCount         *int
+	FloorNumber       *int
+	TotalFloors       *int
+	AvailableFromDate *time.Time
+	// ParseWarnings lists the raw values that could not be normalized.
+	ParseWarnings []string
+	// Location is the address split into its parts.
+	Location Location
+
+	// Saved is set if the flat is on a shortlist of the user reading it,
+	// Shortlists are the ids of those shortlists.
+	Saved      bool
+	Shortlists []string `json:",omitempty"`
+
+	// Match is set on flats returned by a full-text search.
+	Match *FlatMatch `json:",omitempty"`
+}
+
+// Location is an address split into its parts. City, District, SubDistrict
+// and Region use the names of the district dictionary, whatever the spelling
+// of the listing.
+type Location struct {
+	City        string
+	District    string
+	SubDistrict string
+	Street      string
+	Region      string
+	// Unmatched are the parts of the address that were not recognized.
+	Unmatched []string
+}
+
+// Recognized reports whether the city and all other parts of the address were recognized.
+func (l Location) Recognized() bool {
+	return l.City != "" && len(l.Unmatched) == 0
+}
+
+// FlatMatch describes how a flat matched a full-text search.
+// Title, Address and Description hold fragments of those fields as HTML,
+// escaped and with the matching words wrapped in <mark> tags.
+type FlatMatch struct {
+	Rank        float64
+	Title       string
+	Address     string
+	Description string
+}
+
+// FlatMerge lists the flats to link into the duplicate group of another flat.
+type FlatMerge struct {
+	IDs []string `json:"ids"`
 }
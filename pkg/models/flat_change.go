@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+)
+
+// FlatChange is a change of a single field of a flat.
+type FlatChange struct {
+	ID     string `json:"id"`
+	FlatID string `json:"flat_id"`
+	// Field is the name of the changed field, e.g. "price" or "withdrawn_at".
+	Field      string    `json:"field"`
+	OldValue   string    `json:"old_value"`
+	NewValue   string    `json:"new_value"`
+	DetectedAt time.Time `json:"detected_at"`
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"math"
+	"time"
+)
+
+// PricePoint is the price, rent or deposit of a flat from the time it was recorded on.
+type PricePoint struct {
+	ID     string `json:"id"`
+	FlatID string `json:"flat_id"`
+	// Kind is the field the amount belongs to: "price", "rent" or "deposit".
+	Kind string `json:"kind"`
+	// Value is nil if Raw could not be normalized.
+	Value      *Money    `json:"value"`
+	Raw        string    `json:"raw"`
+	RecordedAt time.Time `json:"recorded_at"`
+}
+
+// FlatHistory is the timeline of a flat, oldest entries first.
+type FlatHistory struct {
+	FlatID  string       `json:"flat_id"`
+	Prices  []PricePoint `json:"prices"`
+	Changes []FlatChange `json:"changes"`
+}
+
+// PriceChange compares the current price of a flat with the price it was first seen at.
+type PriceChange struct {
+	FirstPrice  Money
+	FirstSeenAt time.Time
+	// Percent is the change relative to FirstPrice, rounded to one decimal.
+	Percent float64
+}
+
+// ComparePrice returns the change from first to current. It returns nil if
+// current is unknown or the currencies differ.
+func ComparePrice(first Money, firstSeenAt time.Time, current *Money) *PriceChange {
+	if current == nil || first.Amount == 0 || first.Currency != current.Currency {
+		return nil
+	}
+
+	pct := float64(current.Amount-first.Amount) / float64(first.Amount) * 100
+
+	return &PriceChange{
+		FirstPrice:  first,
+		FirstSeenAt: firstSeenAt,
+		Percent:     math.Round(pct*10) / 10,
+	}
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+)
+
+// DistrictStats summarizes the listed flats of a district. Duplicates and
+// withdrawn listings are not counted.
+type DistrictStats struct {
+	City string `json:"city"`
+	// District is empty for the flats of the city without a known district.
+	District string `json:"district"`
+	Flats    int    `json:"flats"`
+	// MedianPrice and MedianPricePerM2 only cover the flats priced in Currency.
+	MedianPrice      *int64 `json:"median_price,omitempty"`
+	MedianPricePerM2 *int64 `json:"median_price_per_m2,omitempty"`
+	Currency         string `json:"currency"`
+}
+
+// UnrecognizedAddress is an address the district dictionary does not cover,
+// listed for curation.
+type UnrecognizedAddress struct {
+	Address string `json:"address"`
+	// Unmatched are the parts that were not recognized, empty if the
+	// address was not parsed yet.
+	Unmatched []string  `json:"unmatched"`
+	Flats     int       `json:"flats"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// AddressReparseReport is the outcome of parsing the addresses of all flats again.
+type AddressReparseReport struct {
+	Flats        int `json:"flats"`
+	Updated      int `json:"updated"`
+	Unrecognized int `json:"unrecognized"`
+}
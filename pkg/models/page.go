@@ -0,0 +1,27 @@
+package models
+
+const (
+	// DefaultPageLimit is the number of items on a page if no limit is requested.
+	DefaultPageLimit = 20
+	// MaxPageLimit is the maximum number of items on a page.
+	MaxPageLimit = 100
+)
+
+// PageRequest selects a page of a sorted list.
+type PageRequest struct {
+	Limit int
+	// Cursor is the opaque cursor returned with the previous page.
+	// An empty cursor selects the first page.
+	Cursor string
+	// Sort is the name of the sort key, prefixed with "-" for descending order.
+	Sort string
+}
+
+// Page is a page of a sorted list.
+type Page[T any] struct {
+	Items []T `json:"items"`
+	// NextCursor selects the next page. It is empty on the last page.
+	NextCursor string `json:"next_cursor,omitempty"`
+	// Total is the number of items matching the filter across all pages.
+	Total int `json:"total"`
+}
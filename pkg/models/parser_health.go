@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+)
+
+// ParserHealth describes how well the parser of a portal extracted the
+// listings scraped within the monitoring window.
+type ParserHealth struct {
+	Portal string `json:"portal"`
+	// Samples is the number of listings parsed within the window.
+	Samples int `json:"samples"`
+	// Incomplete is the number of listings that missed a required field.
+	Incomplete     int     `json:"incomplete"`
+	IncompleteRate float64 `json:"incomplete_rate"`
+	// Alerting is true while the incomplete rate is above the alert threshold.
+	Alerting bool          `json:"alerting"`
+	Fields   []FieldHealth `json:"fields"`
+	// LastSeen is when the last listing of the portal was parsed.
+	LastSeen time.Time `json:"last_seen"`
+}
+
+// FieldHealth describes how often a single field was extracted.
+type FieldHealth struct {
+	Field    string  `json:"field"`
+	Required bool    `json:"required"`
+	Filled   int     `json:"filled"`
+	FillRate float64 `json:"fill_rate"`
+}
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"time"
+)
+
+// ParserRuleSet is a version of the extraction rules of a portal, edited by
+// admins. The published version with the latest PublishedAt replaces the
+// built-in rules of the portal, rolling back means publishing an older one.
+type ParserRuleSet struct {
+	ID      string `json:"id"`
+	Portal  string `json:"portal"`
+	Version int    `json:"version"`
+	// Fields holds the rules of each field, tried in order.
+	Fields map[string][]ExtractionRule `json:"fields"`
+	// Photos are the rules for the gallery images of a listing.
+	Photos      []ExtractionRule `json:"photos"`
+	Note        string           `json:"note,omitempty"`
+	CreatedBy   string           `json:"created_by,omitempty"`
+	CreatedAt   time.Time        `json:"created_at"`
+	PublishedAt *time.Time       `json:"published_at,omitempty"`
+	// Active is set for the version in use.
+	Active bool `json:"active"`
+}
+
+// ExtractionRule describes how to extract a value from a listing page with
+// either a CSS selector or an XPath expression.
+type ExtractionRule struct {
+	Selector string `json:"selector,omitempty"`
+	XPath    string `json:"xpath,omitempty"`
+	// Attr is the attribute to read instead of the text of the element.
+	Attr string `json:"attr,omitempty"`
+	// Label picks the element whose text starts with it and keeps the rest.
+	Label string `json:"label,omitempty"`
+	// Pattern is a regular expression applied to the value, its first
+	// capture group or whole match is kept.
+	Pattern string `json:"pattern,omitempty"`
+}
+
+// ParserRuleFilter selects rule sets. Only the active version of each
+// portal is returned if ActiveOnly is set.
+type ParserRuleFilter struct {
+	IDs        []string
+	Portals    []string
+	ActiveOnly bool
+}
+
+// RuleTestRequest selects the archived page a rule set is tried on, either
+// by snapshot or as the latest page of a listing URL.
+type RuleTestRequest struct {
+	SnapshotID string `json:"snapshot_id"`
+	URL        string `json:"url"`
+}
+
+// RuleTestResult compares the flat parsed from an archived page with a
+// draft rule set to the one parsed with the rules in use.
+type RuleTestResult struct {
+	SnapshotID string      `json:"snapshot_id"`
+	URL        string      `json:"url"`
+	Draft      FlatPreview `json:"draft"`
+	Active     FlatPreview `json:"active"`
+	// Changes are the fields the draft would change.
+	Changes []FlatChange `json:"changes"`
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+)
+
+// Photo is an image from the gallery of the listing of a flat.
+type Photo struct {
+	ID        string `json:"id"`
+	FlatID    string `json:"flat_id"`
+	SourceURL string `json:"source_url"`
+	// Position is the place of the photo in the gallery of the listing.
+	Position  int         `json:"position"`
+	Status    PhotoStatus `json:"status"`
+	Attempts  int         `json:"attempts"`
+	LastError string      `json:"last_error,omitempty"`
+	// ContentType, Width, Height and Size describe the stored image.
+	ContentType string `json:"content_type,omitempty"`
+	Width       int    `json:"width,omitempty"`
+	Height      int    `json:"height,omitempty"`
+	Size        int64  `json:"size,omitempty"`
+	// BlobKey and ThumbnailKey locate the image and its thumbnail in the blob store.
+	BlobKey      string `json:"-"`
+	ThumbnailKey string `json:"-"`
+	// URL and ThumbnailURL are where the stored image and its thumbnail are served.
+	URL          string `json:"url,omitempty"`
+	ThumbnailURL string `json:"thumbnail_url,omitempty"`
+	// RunAfter delays the next download attempt of a pending photo.
+	RunAfter  time.Time `json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// PhotoStatus is the state of the download of a photo.
+type PhotoStatus string
+
+const (
+	// PhotoStatusPending indicates the photo is waiting to be downloaded.
+	PhotoStatusPending PhotoStatus = "pending"
+	// PhotoStatusDownloading indicates a worker is downloading the photo.
+	PhotoStatusDownloading PhotoStatus = "downloading"
+	// PhotoStatusStored indicates the image and its thumbnail are in the blob store.
+	PhotoStatusStored PhotoStatus = "stored"
+	// PhotoStatusFailed indicates the download gave up after its last attempt.
+	PhotoStatusFailed PhotoStatus = "failed"
+)
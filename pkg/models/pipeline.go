@@ -0,0 +1,66 @@
+package models
+
+import (
+	"time"
+)
+
+// Stage is where a user is with a flat in the flat hunt.
+type Stage string
+
+const (
+	StageNew       Stage = "new"
+	StageContacted Stage = "contacted"
+	StageViewing   Stage = "viewing"
+	StageApplied   Stage = "applied"
+	StageRejected  Stage = "rejected"
+)
+
+// Stages lists the stages in the order of the flat hunt.
+var Stages = []Stage{StageNew, StageContacted, StageViewing, StageApplied, StageRejected}
+
+// FlatStatus is the stage the members of a workspace put a flat in.
+type FlatStatus struct {
+	WorkspaceID string `json:"workspace_id"`
+	FlatID      string `json:"flat_id"`
+	Stage       Stage  `json:"stage"`
+	// UserID is the member who moved the flat last.
+	UserID string `json:"user_id,omitempty"`
+	// Reason is why the flat was rejected.
+	Reason    string    `json:"reason,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// Transitions are the moves of the flat between stages, oldest first,
+	// only set when a single status is read.
+	Transitions []StageTransition `json:"transitions,omitempty"`
+	// Flat is set on the statuses of a board.
+	Flat *Flat `json:"flat,omitempty"`
+}
+
+// FlatStatusFilter selects the statuses of a workspace.
+type FlatStatusFilter struct {
+	WorkspaceID string
+	FlatIDs     []string
+	Stages      []Stage
+}
+
+// StageTransition is a move of a flat from one stage to another. From is
+// empty for the move that put the flat in the pipeline.
+type StageTransition struct {
+	From   Stage  `json:"from,omitempty"`
+	To     Stage  `json:"to"`
+	Reason string `json:"reason,omitempty"`
+	// UserID is the member who moved the flat.
+	UserID string    `json:"user_id"`
+	At     time.Time `json:"at"`
+}
+
+// StageMove is a request to move a flat to a stage.
+type StageMove struct {
+	Stage  Stage  `json:"stage"`
+	Reason string `json:"reason"`
+}
+
+// PipelineColumn holds the flats in a stage, the latest moved first.
+type PipelineColumn struct {
+	Stage    Stage        `json:"stage"`
+	Statuses []FlatStatus `json:"statuses"`
+}
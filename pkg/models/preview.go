@@ -0,0 +1,33 @@
+package models
+
+// PageUpload is a listing page sent to be previewed or imported. The page
+// is fetched from URL unless HTML is set, URL is needed either way to pick
+// the parser.
+type PageUpload struct {
+	URL  string `json:"url"`
+	HTML string `json:"html"`
+}
+
+// FlatPreview is a listing parsed without storing anything.
+type FlatPreview struct {
+	// Portal is the name of the parser that was used.
+	Portal string `json:"portal"`
+	// Flat is the parsed flat with its normalized typed values.
+	Flat Flat `json:"flat"`
+	// Fields lists every field and where its value came from.
+	Fields   []FieldSource `json:"fields"`
+	Photos   []string      `json:"photos"`
+	Warnings []string      `json:"warnings"`
+	// ExistingID is the flat the listing is already stored as, if any.
+	ExistingID string `json:"existing_id,omitempty"`
+}
+
+// FieldSource describes the value of a field and the strategy that produced
+// it. Strategy and Detail are empty if the field was not found.
+type FieldSource struct {
+	Field    string `json:"field"`
+	Value    string `json:"value"`
+	Strategy string `json:"strategy,omitempty"`
+	// Detail is the selector or the path in the structured data that matched.
+	Detail string `json:"detail,omitempty"`
+}
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"time"
+)
+
+// SavedSearch is a flat filter a user saved to be emailed the flats that
+// newly match it.
+type SavedSearch struct {
+	ID     string `json:"id"`
+	UserID string `json:"user_id"`
+	Name   string `json:"name"`
+	// Query holds the filter in the query parameters of the flat list,
+	// such as "price_max=3000&rooms=2,3&district=Mokotów&q=balkon".
+	Query    string         `json:"query"`
+	Delivery SearchDelivery `json:"delivery"`
+	// Paused searches are neither matched nor sent.
+	Paused bool `json:"paused"`
+	// CheckedUntil is when the flats were last matched against the search.
+	CheckedUntil time.Time  `json:"checked_until"`
+	LastSentAt   *time.Time `json:"last_sent_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// SearchDelivery is how the matches of a saved search are sent.
+type SearchDelivery string
+
+const (
+	// SearchDeliveryInstant sends new matches as soon as they are found.
+	SearchDeliveryInstant SearchDelivery = "instant"
+	// SearchDeliveryDaily sends the matches of a day in one digest.
+	SearchDeliveryDaily SearchDelivery = "daily"
+)
+
+// Valid reports whether d is a known delivery.
+func (d SearchDelivery) Valid() bool {
+	return d == SearchDeliveryInstant || d == SearchDeliveryDaily
+}
+
+// SavedSearchFilter selects saved searches. An empty UserID matches the
+// searches of all users.
+type SavedSearchFilter struct {
+	UserID     string
+	IDs        []string
+	ActiveOnly bool
+}
+
+// SearchAlert is the email sent for the new matches of a saved search.
+type SearchAlert struct {
+	Search SavedSearch
+	Flats  []SearchAlertFlat
+}
+
+// SearchAlertFlat is a flat in a search alert.
+type SearchAlertFlat struct {
+	ID      string
+	Title   string
+	Address string
+	Price   string
+	// URL links to the flat in hestia, SourceURL to its listing.
+	URL       string
+	SourceURL string
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+)
+
+// ScrapeJob is a request to scrape a listing and store it as a flat.
+type ScrapeJob struct {
+	ID          string       `json:"id"`
+	URL         string       `json:"url"`
+	Status      ScrapeStatus `json:"status"`
+	Attempts    int          `json:"attempts"`
+	MaxAttempts int          `json:"max_attempts"`
+	LastError   string       `json:"last_error,omitempty"`
+	// FlatID is the flat created by the job, set once the job succeeded.
+	FlatID      string `json:"flat_id,omitempty"`
+	RequestedBy string `json:"requested_by,omitempty"`
+	// RunAfter delays the next attempt of a queued job.
+	RunAfter   time.Time  `json:"run_after"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// ScrapeStatus is the state of a scrape job.
+type ScrapeStatus string
+
+const (
+	// ScrapeStatusQueued indicates a job is waiting for a worker.
+	ScrapeStatusQueued ScrapeStatus = "queued"
+	// ScrapeStatusRunning indicates a worker is scraping the listing.
+	ScrapeStatusRunning ScrapeStatus = "running"
+	// ScrapeStatusSucceeded indicates the flat was stored.
+	ScrapeStatusSucceeded ScrapeStatus = "succeeded"
+	// ScrapeStatusFailed indicates the job gave up after its last attempt.
+	ScrapeStatusFailed ScrapeStatus = "failed"
+)
+
+// ScrapeJobFilter narrows down the scrape jobs returned by a query.
+type ScrapeJobFilter struct {
+	URLs     []string
+	Statuses []ScrapeStatus
+	// RequestedBy matches the jobs queued by the user.
+	RequestedBy string
+}
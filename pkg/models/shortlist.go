@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+)
+
+// Shortlist is a named list of flats the members of a workspace are
+// interested in, in the order they put them.
+type Shortlist struct {
+	ID          string `json:"id"`
+	WorkspaceID string `json:"workspace_id"`
+	// UserID is the member who made the list.
+	UserID string `json:"user_id,omitempty"`
+	Name   string `json:"name"`
+	// FlatIDs are the flats on the list, in order.
+	FlatIDs []string `json:"flat_ids"`
+	// Flats are the flats on the list, only set when a single list is read.
+	Flats     []Flat    `json:"flats,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ShortlistFilter selects the shortlists of a workspace.
+type ShortlistFilter struct {
+	WorkspaceID string
+	IDs         []string
+}
+
+// ShortlistFlat is a request to put a flat on a shortlist.
+type ShortlistFlat struct {
+	FlatID string `json:"flat_id"`
+}
+
+// ShortlistOrder is a request to reorder a shortlist. FlatIDs must list the
+// flats on the list, each once.
+type ShortlistOrder struct {
+	FlatIDs []string `json:"flat_ids"`
+}
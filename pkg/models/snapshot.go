@@ -0,0 +1,67 @@
+package models
+
+import (
+	"time"
+)
+
+// Snapshot is a listing page as it was fetched, kept in the page archive.
+type Snapshot struct {
+	ID string `json:"id"`
+	// URL is the URL of the listing, FinalURL the one the page was served
+	// from after redirects.
+	URL         string `json:"url"`
+	FinalURL    string `json:"final_url"`
+	StatusCode  int    `json:"status_code"`
+	ContentType string `json:"content_type,omitempty"`
+	// Size is the size of the page before compression, in bytes.
+	Size int64 `json:"size"`
+	// BlobKey locates the compressed page in the blob store.
+	BlobKey string `json:"-"`
+	// FlatID is the flat scraped from the page, empty if there is none.
+	FlatID string `json:"flat_id,omitempty"`
+	// ParseError is why the page could not be turned into a flat, empty if it was.
+	ParseError string `json:"parse_error,omitempty"`
+	// RequestedBy is the user whose scrape or import fetched the page, empty
+	// for pages fetched on the app's own account, such as refreshes.
+	RequestedBy string    `json:"requested_by,omitempty"`
+	FetchedAt   time.Time `json:"fetched_at"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// SnapshotFilter selects snapshots. Only the latest snapshot of each URL
+// is returned if LatestOnly is set.
+type SnapshotFilter struct {
+	URLs       []string
+	FlatIDs    []string
+	LatestOnly bool
+	Limit      int
+}
+
+// ReparseRequest selects the archived pages to run the current parsers
+// over. The latest page of every listing is used if no URLs or flats are given.
+type ReparseRequest struct {
+	URLs    []string `json:"urls"`
+	FlatIDs []string `json:"flat_ids"`
+	// Limit bounds the number of pages, 0 means the configured default.
+	Limit int `json:"limit"`
+	// DryRun reports the changes without applying them.
+	DryRun bool `json:"dry_run"`
+}
+
+// ReparseReport is the outcome of a re-parse.
+type ReparseReport struct {
+	Pages   int            `json:"pages"`
+	Changed int            `json:"changed"`
+	Failed  int            `json:"failed"`
+	DryRun  bool           `json:"dry_run"`
+	Results []ReparsedPage `json:"results"`
+}
+
+// ReparsedPage is the outcome of re-parsing a single archived page.
+type ReparsedPage struct {
+	SnapshotID string       `json:"snapshot_id"`
+	URL        string       `json:"url"`
+	FlatID     string       `json:"flat_id,omitempty"`
+	Changes    []FlatChange `json:"changes"`
+	Error      string       `json:"error,omitempty"`
+}
@@ -2,6 +2,7 @@ package models
 
 import (
 	"context"
+	"time"
 )
 
 // Store provides access to the user store.
@@ -9,9 +10,51 @@ type Store interface {
 	BeginTx(ctx context.Context) (Tx, error)
 
 	FindUsers(ctx context.Context, filter UserFilter) ([]User, error)
+	PageUsers(ctx context.Context, filter UserFilter, page PageRequest) (Page[User], error)
 
 	FindFlats(ctx context.Context, filter FlatFilter) ([]Flat, error)
+	PageFlats(ctx context.Context, filter FlatFilter, page PageRequest) (Page[Flat], error)
 	GetFlatByID(ctx context.Context, id string) (Flat, error)
+	GetFlatHistory(ctx context.Context, flatID string) (FlatHistory, error)
+	FindFlatsToRefresh(ctx context.Context, checkedBefore time.Time, limit int) ([]Flat, error)
+
+	FindPhotos(ctx context.Context, flatID string) ([]Photo, error)
+	GetPhoto(ctx context.Context, flatID, id string) (Photo, error)
+	ClaimPhoto(ctx context.Context, now time.Time) (Photo, error)
+	RequeuePhotos(ctx context.Context, now time.Time) (int64, error)
+
+	FindSnapshots(ctx context.Context, filter SnapshotFilter) ([]Snapshot, error)
+	GetSnapshot(ctx context.Context, id string) (Snapshot, error)
+	PruneSnapshots(ctx context.Context, before time.Time) ([]string, error)
+
+	GetDistrictStats(ctx context.Context, city, currency string) ([]DistrictStats, error)
+	FindUnrecognizedAddresses(ctx context.Context, limit int) ([]UnrecognizedAddress, error)
+
+	FindParserRules(ctx context.Context, filter ParserRuleFilter) ([]ParserRuleSet, error)
+	GetParserRules(ctx context.Context, id string) (ParserRuleSet, error)
+
+	FindShortlists(ctx context.Context, filter ShortlistFilter) ([]Shortlist, error)
+	GetShortlist(ctx context.Context, workspaceID, id string) (Shortlist, error)
+	FindFlatShortlists(ctx context.Context, workspaceID string, flatIDs []string) (map[string][]string, error)
+
+	FindSavedSearches(ctx context.Context, filter SavedSearchFilter) ([]SavedSearch, error)
+	GetSavedSearch(ctx context.Context, userID, id string) (SavedSearch, error)
+
+	FindFlatStatuses(ctx context.Context, filter FlatStatusFilter) ([]FlatStatus, error)
+	GetFlatStatus(ctx context.Context, workspaceID, flatID string) (FlatStatus, error)
+
+	GetFlatNote(ctx context.Context, userID, flatID string) (FlatNote, error)
+	FindComments(ctx context.Context, workspaceID, flatID string) ([]Comment, error)
+	GetComment(ctx context.Context, workspaceID, flatID, id string) (Comment, error)
+
+	GetWorkspace(ctx context.Context, id string) (Workspace, error)
+	GetMemberWorkspaceID(ctx context.Context, userID string) (string, error)
+	FindFlatWorkspaces(ctx context.Context, flatID string) ([]string, error)
+
+	GetScrapeJobByID(ctx context.Context, id string) (ScrapeJob, error)
+	FindScrapeJobs(ctx context.Context, filter ScrapeJobFilter) ([]ScrapeJob, error)
+	ClaimScrapeJob(ctx context.Context, now time.Time) (ScrapeJob, error)
+	RequeueScrapeJobs(ctx context.Context, now time.Time) (int64, error)
 }
 
 // Tx is a transaction.
@@ -26,8 +69,72 @@ type Tx interface {
 
 	CreateEmailToken(t EmailToken) error
 	UpdateEmailToken(t EmailToken) error
+	GetEmailToken(id string) (EmailToken, error)
 
-	CreateFlat(u Flat) error
+	CreateFlat(u Flat) (string, error)
+	FindFlats(filter FlatFilter) ([]Flat, error)
+	GetFlatByID(id string) (Flat, error)
 	DeleteFlat(id string) error
 	UpdateFlat(u Flat) error
+	UpdateFlatLocation(id string, l Location) error
+	CreateFlatChanges(changes []FlatChange) error
+	CreatePricePoints(points []PricePoint) error
+
+	CreatePhotos(photos []Photo) error
+	FindPhotos(flatID string) ([]Photo, error)
+	UpdatePhoto(p Photo) error
+
+	CreateSnapshot(s Snapshot) (string, error)
+	UpdateSnapshot(s Snapshot) error
+
+	CreateParserRules(rs ParserRuleSet) (string, error)
+	GetParserRules(id string) (ParserRuleSet, error)
+	PublishParserRules(id string, at time.Time) error
+	DeleteParserRules(id string) error
+
+	CreateShortlist(sl Shortlist) (string, error)
+	GetShortlist(workspaceID, id string) (Shortlist, error)
+	DeleteShortlist(workspaceID, id string) error
+	TouchShortlist(id string, at time.Time) error
+	AddShortlistFlat(shortlistID, flatID string, at time.Time) error
+	RemoveShortlistFlat(shortlistID, flatID string) error
+	ReorderShortlist(shortlistID string, flatIDs []string) error
+
+	CreateSavedSearch(ss SavedSearch) (string, error)
+	GetSavedSearch(userID, id string) (SavedSearch, error)
+	UpdateSavedSearch(ss SavedSearch) error
+	DeleteSavedSearch(userID, id string) error
+	UpdateSavedSearchChecked(id string, at time.Time) error
+	CreateSearchAlerts(searchID string, flatIDs []string, at time.Time) error
+	FindPendingAlerts(searchID string) ([]string, error)
+	MarkAlertsSent(searchID string, flatIDs []string, at time.Time) error
+
+	GetFlatStatus(workspaceID, flatID string) (FlatStatus, error)
+	SaveFlatStatus(st FlatStatus) error
+	DeleteFlatStatus(workspaceID, flatID string) error
+	CreateStageTransition(workspaceID, flatID string, t StageTransition) error
+
+	SaveFlatNote(n FlatNote) error
+	DeleteFlatNote(userID, flatID string) error
+	CreateComment(c Comment) (string, error)
+	GetComment(workspaceID, flatID, id string) (Comment, error)
+	UpdateComment(c Comment) error
+	DeleteComment(id string, at time.Time) error
+	CreateCommentRevision(commentID string, rev CommentRevision) error
+
+	CreateWorkspace(ws Workspace) (string, error)
+	GetWorkspace(id string) (Workspace, error)
+	UpdateWorkspace(ws Workspace) error
+	DeleteWorkspace(id string) error
+	GetMemberWorkspaceID(userID string) (string, error)
+	AddWorkspaceMember(workspaceID string, m WorkspaceMember) error
+	UpdateWorkspaceMember(workspaceID string, m WorkspaceMember) error
+	RemoveWorkspaceMember(workspaceID, userID string) error
+	AddWorkspaceFlat(workspaceID, flatID, addedBy string, at time.Time) error
+	CopyWorkspaceFlats(fromID, toID string) error
+	RemoveWorkspaceFlat(workspaceID, flatID string) error
+	FindFlatWorkspaces(flatID string) ([]string, error)
+
+	CreateScrapeJob(j ScrapeJob) (string, error)
+	UpdateScrapeJob(j ScrapeJob) error
 }
@@ -30,6 +30,7 @@ type Credentials struct {
 type UserFilter struct {
 	IDs      []string
 	Emails   []string
+	Roles    []string
 	Password Password
 	IsActive bool
 }
@@ -0,0 +1,80 @@
+package models
+
+import (
+	"time"
+)
+
+// WorkspaceRole is the part a member plays in a workspace.
+type WorkspaceRole string
+
+const (
+	// WorkspaceRoleOwner may rename the workspace, invite and remove members.
+	WorkspaceRoleOwner WorkspaceRole = "owner"
+	// WorkspaceRoleMember shares the flats of the workspace.
+	WorkspaceRoleMember WorkspaceRole = "member"
+)
+
+// Workspace is a household hunting for a flat together. Its members share
+// its flats, shortlists, pipeline and comments. A user is a member of a
+// single workspace.
+type Workspace struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Members are set when a single workspace is read, the owner first.
+	Members   []WorkspaceMember `json:"members,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+// Owner returns the owner of the workspace among its members.
+func (w Workspace) Owner() (WorkspaceMember, bool) {
+	for _, m := range w.Members {
+		if m.Role == WorkspaceRoleOwner {
+			return m, true
+		}
+	}
+
+	return WorkspaceMember{}, false
+}
+
+// Member returns the member of the workspace with userID.
+func (w Workspace) Member(userID string) (WorkspaceMember, bool) {
+	for _, m := range w.Members {
+		if m.UserID == userID {
+			return m, true
+		}
+	}
+
+	return WorkspaceMember{}, false
+}
+
+// WorkspaceMember is a user in a workspace.
+type WorkspaceMember struct {
+	UserID   string        `json:"user_id"`
+	Email    string        `json:"email"`
+	Role     WorkspaceRole `json:"role"`
+	JoinedAt time.Time     `json:"joined_at"`
+}
+
+// WorkspaceInvite is a request to invite the user with Email to a workspace.
+type WorkspaceInvite struct {
+	Email string `json:"email"`
+}
+
+// WorkspaceJoin is a request to accept the invitation sent as the email
+// token with ID.
+type WorkspaceJoin struct {
+	ID    string `json:"id"`
+	Token string `json:"token"`
+}
+
+// WorkspaceInvitation is the data of the email inviting a user to a workspace.
+type WorkspaceInvitation struct {
+	Workspace Workspace
+	// InvitedBy is the email of the member sending the invitation.
+	InvitedBy string
+	Token     EmailTokenRaw
+	ExpiresAt time.Time
+	// URL is where the invitation is accepted.
+	URL string
+}
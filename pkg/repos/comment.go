@@ -0,0 +1,338 @@
+package repos
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+
+	"hestia/pkg/custerrors"
+	"hestia/pkg/db"
+	"hestia/pkg/models"
+)
+
+// commentColumns are the columns read by scanComment, in the order it expects them.
+const commentColumns = `c.id, c.workspace_id, c.flat_id, c.user_id, u.email, c.body, c.mentions, c.created_at, c.updated_at, c.deleted_at`
+
+// upsertFlatNote stores the note of the user on the flat.
+func upsertFlatNote(ef execFunc, n models.FlatNote) error {
+	q := db.Query{}
+	count := 0
+
+	q.Unsafe(`INSERT INTO flat_notes (user_id, flat_id, body, rating, created_at, updated_at) VALUES (`)
+	q.Params(&count, n.UserID, n.FlatID, n.Body, n.Rating, n.CreatedAt, n.UpdatedAt)
+	q.Unsafe(`) ON CONFLICT (user_id, flat_id) DO UPDATE
+		SET (body, rating, updated_at) = (excluded.body, excluded.rating, excluded.updated_at)`)
+
+	s, params, err := q.Get()
+	if err != nil {
+		return err
+	}
+
+	_, err = ef(s, params...)
+	if err != nil {
+		return custerrors.MapDBErr(err)
+	}
+
+	return nil
+}
+
+// deleteFlatNote deletes the note of the user on the flat.
+func deleteFlatNote(ef execFunc, userID, flatID string) error {
+	q := db.Query{}
+	count := 0
+
+	q.Unsafe(`DELETE FROM flat_notes WHERE user_id = `)
+	q.Param(&count, userID)
+	q.Unsafe(` AND flat_id = `)
+	q.Param(&count, flatID)
+
+	s, params, err := q.Get()
+	if err != nil {
+		return err
+	}
+
+	result, err := ef(s, params...)
+	if err != nil {
+		return custerrors.MapDBErr(err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return custerrors.MapDBErr(err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("flat note not found: %w", custerrors.ErrNotFound)
+	}
+
+	return nil
+}
+
+func selectFlatNote(qf queryFunc, userID, flatID string) (models.FlatNote, error) {
+	q := db.Query{}
+	count := 0
+
+	q.Unsafe(`SELECT user_id, flat_id, body, rating, created_at, updated_at FROM flat_notes WHERE user_id = `)
+	q.Param(&count, userID)
+	q.Unsafe(` AND flat_id = `)
+	q.Param(&count, flatID)
+
+	s, params, err := q.Get()
+	if err != nil {
+		return models.FlatNote{}, err
+	}
+
+	rows, err := qf(s, params...)
+	if err != nil {
+		return models.FlatNote{}, custerrors.MapDBErr(err)
+	}
+
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return models.FlatNote{}, custerrors.MapDBErr(err)
+		}
+		return models.FlatNote{}, fmt.Errorf("flat note not found: %w", custerrors.ErrNotFound)
+	}
+
+	var (
+		n      models.FlatNote
+		rating sql.NullInt64
+	)
+	err = rows.Scan(&n.UserID, &n.FlatID, &n.Body, &rating, &n.CreatedAt, &n.UpdatedAt)
+	if err != nil {
+		return models.FlatNote{}, custerrors.MapDBErr(err)
+	}
+
+	n.Rating = nullPtr(int(rating.Int64), rating.Valid)
+
+	return n, nil
+}
+
+// insertComment inserts c and returns the id of the new row.
+func insertComment(qf queryFunc, c models.Comment) (string, error) {
+	q := db.Query{}
+	count := 0
+
+	q.Unsafe(`INSERT INTO flat_comments (workspace_id, flat_id, user_id, body, mentions, created_at, updated_at) VALUES (`)
+	q.Params(&count, c.WorkspaceID, c.FlatID, c.UserID, c.Body, pq.Array(c.Mentions), c.CreatedAt, c.UpdatedAt)
+	q.Unsafe(`) RETURNING id`)
+
+	return insertReturningID(qf, &q)
+}
+
+// updateComment updates the body and mentions of a comment that is not deleted.
+func updateComment(ef execFunc, c models.Comment) error {
+	q := db.Query{}
+	count := 0
+
+	q.Unsafe(`UPDATE flat_comments SET (body, mentions, updated_at) = (`)
+	q.Params(&count, c.Body, pq.Array(c.Mentions), c.UpdatedAt)
+	q.Unsafe(`) WHERE id = `)
+	q.Param(&count, c.ID)
+	q.Unsafe(` AND deleted_at IS NULL`)
+
+	s, params, err := q.Get()
+	if err != nil {
+		return err
+	}
+
+	result, err := ef(s, params...)
+	if err != nil {
+		return custerrors.MapDBErr(err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return custerrors.MapDBErr(err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("comment not found: %w", custerrors.ErrNotFound)
+	}
+
+	return nil
+}
+
+// softDeleteComment marks a comment deleted at, keeping its row and revisions.
+func softDeleteComment(ef execFunc, id string, at time.Time) error {
+	q := db.Query{}
+	count := 0
+
+	q.Unsafe(`UPDATE flat_comments SET deleted_at = `)
+	q.Param(&count, at)
+	q.Unsafe(` WHERE id = `)
+	q.Param(&count, id)
+	q.Unsafe(` AND deleted_at IS NULL`)
+
+	s, params, err := q.Get()
+	if err != nil {
+		return err
+	}
+
+	result, err := ef(s, params...)
+	if err != nil {
+		return custerrors.MapDBErr(err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return custerrors.MapDBErr(err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("comment not found: %w", custerrors.ErrNotFound)
+	}
+
+	return nil
+}
+
+func selectComment(qf queryFunc, workspaceID, flatID, id string) (models.Comment, error) {
+	comments, err := selectComments(qf, workspaceID, flatID, id)
+	if err != nil {
+		return models.Comment{}, err
+	}
+
+	if len(comments) == 0 {
+		return models.Comment{}, fmt.Errorf("comment not found: %w", custerrors.ErrNotFound)
+	}
+
+	return comments[0], nil
+}
+
+// selectComments returns the comments on the flat in the workspace, oldest
+// first, limited to ids if any are given.
+func selectComments(qf queryFunc, workspaceID, flatID string, ids ...string) ([]models.Comment, error) {
+	q := db.Query{}
+	count := 0
+
+	q.Unsafe(`SELECT ` + commentColumns + ` FROM flat_comments c JOIN users u ON u.id = c.user_id WHERE c.workspace_id = `)
+	q.Param(&count, workspaceID)
+	q.Unsafe(` AND c.flat_id = `)
+	q.Param(&count, flatID)
+	q.Unsafe(` `)
+
+	if len(ids) > 0 {
+		q.Unsafe(`AND c.id IN (`)
+		q.Params(&count, anySlice(ids)...)
+		q.Unsafe(`) `)
+	}
+
+	q.Unsafe(`ORDER BY c.created_at ASC, c.id ASC`)
+
+	s, params, err := q.Get()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := qf(s, params...)
+	if err != nil {
+		return nil, custerrors.MapDBErr(err)
+	}
+
+	defer rows.Close()
+
+	out := make([]models.Comment, 0)
+	for rows.Next() {
+		c, err := scanComment(rows)
+		if err != nil {
+			return nil, custerrors.MapDBErr(err)
+		}
+
+		out = append(out, c)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, custerrors.MapDBErr(err)
+	}
+
+	return out, nil
+}
+
+// scanComment scans a comment, dropping the body and mentions of a deleted one.
+func scanComment(rows *sql.Rows) (models.Comment, error) {
+	var (
+		c         models.Comment
+		deletedAt sql.NullTime
+	)
+
+	err := rows.Scan(&c.ID, &c.WorkspaceID, &c.FlatID, &c.UserID, &c.AuthorEmail, &c.Body, pq.Array(&c.Mentions), &c.CreatedAt, &c.UpdatedAt, &deletedAt)
+	if err != nil {
+		return models.Comment{}, err
+	}
+
+	c.DeletedAt = nullPtr(deletedAt.Time, deletedAt.Valid)
+	if c.DeletedAt != nil {
+		c.Body = ""
+		c.Mentions = nil
+	}
+	if c.Mentions == nil {
+		c.Mentions = make([]string, 0)
+	}
+
+	return c, nil
+}
+
+// insertCommentRevision keeps a body the comment had before an edit.
+func insertCommentRevision(ef execFunc, commentID string, rev models.CommentRevision) error {
+	q := db.Query{}
+	count := 0
+
+	q.Unsafe(`INSERT INTO flat_comment_revisions (comment_id, body, created_at) VALUES (`)
+	q.Params(&count, commentID, rev.Body, rev.CreatedAt)
+	q.Unsafe(`)`)
+
+	s, params, err := q.Get()
+	if err != nil {
+		return err
+	}
+
+	_, err = ef(s, params...)
+	if err != nil {
+		return custerrors.MapDBErr(err)
+	}
+
+	return nil
+}
+
+// selectCommentRevisions returns the earlier bodies of the comment, oldest first.
+func selectCommentRevisions(qf queryFunc, commentID string) ([]models.CommentRevision, error) {
+	q := db.Query{}
+	count := 0
+
+	q.Unsafe(`SELECT body, created_at FROM flat_comment_revisions WHERE comment_id = `)
+	q.Param(&count, commentID)
+	q.Unsafe(` ORDER BY created_at ASC, id ASC`)
+
+	s, params, err := q.Get()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := qf(s, params...)
+	if err != nil {
+		return nil, custerrors.MapDBErr(err)
+	}
+
+	defer rows.Close()
+
+	out := make([]models.CommentRevision, 0)
+	for rows.Next() {
+		var rev models.CommentRevision
+		err := rows.Scan(&rev.Body, &rev.CreatedAt)
+		if err != nil {
+			return nil, custerrors.MapDBErr(err)
+		}
+
+		out = append(out, rev)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, custerrors.MapDBErr(err)
+	}
+
+	return out, nil
+}
@@ -1,15 +1,107 @@
 package repos
 
 import (
+	"database/sql"
+	"fmt"
+
+	"hestia/pkg/custerrors"
+	"hestia/pkg/db"
 	"hestia/pkg/models"
 )
 
 func insertEmailToken(ef execFunc, tok models.EmailToken) error {
+	q := db.Query{}
+	count := 0
+
+	q.Unsafe(`INSERT INTO email_tokens (id, token_hash, user_id, email, purpose, workspace_id, created_at, consumed_at) VALUES (`)
+	q.Params(&count, tok.ID, tok.TokenHash, tok.UserID, tok.Email, string(tok.Purpose), nullString(tok.WorkspaceID), tok.CreatedAt, tok.ConsumedAt)
+	q.Unsafe(`)`)
+
+	s, params, err := q.Get()
+	if err != nil {
+		return err
+	}
+
+	_, err = ef(s, params...)
+	if err != nil {
+		return custerrors.MapDBErr(err)
+	}
 
 	return nil
 }
 
+// updateEmailToken sets when the token was consumed.
 func updateEmailToken(ef execFunc, tok models.EmailToken) error {
+	q := db.Query{}
+	count := 0
+
+	q.Unsafe(`UPDATE email_tokens SET consumed_at = `)
+	q.Param(&count, tok.ConsumedAt)
+	q.Unsafe(` WHERE id = `)
+	q.Param(&count, tok.ID)
+
+	s, params, err := q.Get()
+	if err != nil {
+		return err
+	}
+
+	result, err := ef(s, params...)
+	if err != nil {
+		return custerrors.MapDBErr(err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return custerrors.MapDBErr(err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("email token not found: %w", custerrors.ErrNotFound)
+	}
 
 	return nil
 }
+
+func selectEmailToken(qf queryFunc, id string) (models.EmailToken, error) {
+	q := db.Query{}
+	count := 0
+
+	q.Unsafe(`SELECT id, token_hash, user_id, email, purpose, workspace_id, created_at, consumed_at FROM email_tokens WHERE id = `)
+	q.Param(&count, id)
+
+	s, params, err := q.Get()
+	if err != nil {
+		return models.EmailToken{}, err
+	}
+
+	rows, err := qf(s, params...)
+	if err != nil {
+		return models.EmailToken{}, custerrors.MapDBErr(err)
+	}
+
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return models.EmailToken{}, custerrors.MapDBErr(err)
+		}
+		return models.EmailToken{}, fmt.Errorf("email token not found: %w", custerrors.ErrNotFound)
+	}
+
+	var (
+		tok         models.EmailToken
+		purpose     string
+		workspaceID sql.NullString
+		consumedAt  sql.NullTime
+	)
+	err = rows.Scan(&tok.ID, &tok.TokenHash, &tok.UserID, &tok.Email, &purpose, &workspaceID, &tok.CreatedAt, &consumedAt)
+	if err != nil {
+		return models.EmailToken{}, custerrors.MapDBErr(err)
+	}
+
+	tok.Purpose = models.TokenPurpose(purpose)
+	tok.WorkspaceID = workspaceID.String
+	tok.ConsumedAt = nullPtr(consumedAt.Time, consumedAt.Valid)
+
+	return tok, nil
+}
@@ -1,19 +1,48 @@
 package repos
 
 import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+
 	"hestia/pkg/custerrors"
 	"hestia/pkg/db"
 	"hestia/pkg/models"
 )
 
-func insertFlat(ef execFunc, f models.Flat) error {
+// flatColumns are the columns read by selectFlat and selectFlats, in the order scanFlat expects them.
+// They end with the source URLs of the flat and its duplicates, the first recorded
+// price of the flat and when it was recorded.
+const flatColumns = `id, title, price, address, surface, rooms, floor, available_from, rent, deposit, description,
+	created_at, updated_at, price_amount, price_currency, rent_amount, rent_currency, deposit_amount, deposit_currency,
+	surface_m2, room_count, floor_number, total_floors, available_from_date, parse_warnings,
+	city, district, sub_district, street, region, address_unmatched, source_url, last_checked_at, withdrawn_at, canonical_id, fingerprint,
+	ARRAY(SELECT d.source_url FROM flats d WHERE (d.id = flats.id OR d.canonical_id = flats.id)
+		AND d.source_url IS NOT NULL ORDER BY d.id),
+	(SELECT h.amount FROM flat_price_history h WHERE h.flat_id = flats.id AND h.kind = 'price'
+		ORDER BY h.recorded_at, h.id LIMIT 1),
+	(SELECT h.currency FROM flat_price_history h WHERE h.flat_id = flats.id AND h.kind = 'price'
+		ORDER BY h.recorded_at, h.id LIMIT 1),
+	(SELECT h.recorded_at FROM flat_price_history h WHERE h.flat_id = flats.id AND h.kind = 'price'
+		ORDER BY h.recorded_at, h.id LIMIT 1)`
+
+// insertFlat inserts f and returns the id of the new row.
+func insertFlat(qf queryFunc, f models.Flat) (string, error) {
 	q := db.Query{}
 	count := 0
 
-	q.Unsafe(`INSERT INTO "flats" (title, price, 
-                   					address, surface, rooms, 
-                   					floor, available_from, rent, 
-                   					deposit, description, created_at, updated_at) VALUES (`)
+	q.Unsafe(`INSERT INTO "flats" (title, price,
+                   					address, surface, rooms,
+                   					floor, available_from, rent,
+                   					deposit, description, created_at, updated_at,
+                   					price_amount, price_currency, rent_amount, rent_currency,
+                   					deposit_amount, deposit_currency, surface_m2, room_count,
+                   					floor_number, total_floors, available_from_date, parse_warnings,
+                   					city, district, sub_district, street, region, address_unmatched,
+                   					source_url, last_checked_at, withdrawn_at, canonical_id, fingerprint) VALUES (`)
 	q.Params(&count,
 		f.Title,
 		f.Price,
@@ -27,34 +56,585 @@ func insertFlat(ef execFunc, f models.Flat) error {
 		f.Description,
 		f.CreatedAt,
 		f.UpdatedAt)
+	q.Unsafe(`, `)
+	q.Params(&count, typedFlatValues(f)...)
+	q.Unsafe(`, `)
+	q.Params(&count, nullString(f.SourceURL), f.LastCheckedAt, f.WithdrawnAt, nullString(f.CanonicalID), nullString(f.Fingerprint))
+	q.Unsafe(`) RETURNING id`)
+
+	return insertReturningID(qf, &q)
+}
+
+func updateFlat(ef execFunc, f models.Flat) error {
+	q := db.Query{}
+	count := 0
+
+	q.Unsafe(`UPDATE flats SET title = `)
+	q.Param(&count, f.Title)
+	q.Unsafe(`, price = `)
+	q.Param(&count, f.Price)
+	q.Unsafe(`, address = `)
+	q.Param(&count, f.Address)
+	q.Unsafe(`, surface = `)
+	q.Param(&count, f.Surface)
+	q.Unsafe(`, rooms = `)
+	q.Param(&count, f.Rooms)
+	q.Unsafe(`, floor = `)
+	q.Param(&count, f.Floor)
+	q.Unsafe(`, available_from = `)
+	q.Param(&count, f.AvailableFrom)
+	q.Unsafe(`, rent = `)
+	q.Param(&count, f.Rent)
+	q.Unsafe(`, deposit = `)
+	q.Param(&count, f.Deposit)
+	q.Unsafe(`, description = `)
+	q.Param(&count, f.Description)
+	q.Unsafe(`, updated_at = `)
+	q.Param(&count, f.UpdatedAt)
+	q.Unsafe(`, source_url = `)
+	q.Param(&count, nullString(f.SourceURL))
+	q.Unsafe(`, last_checked_at = `)
+	q.Param(&count, f.LastCheckedAt)
+	q.Unsafe(`, withdrawn_at = `)
+	q.Param(&count, f.WithdrawnAt)
+	q.Unsafe(`, canonical_id = `)
+	q.Param(&count, nullString(f.CanonicalID))
+	q.Unsafe(`, fingerprint = `)
+	q.Param(&count, nullString(f.Fingerprint))
+
+	q.Unsafe(`, (price_amount, price_currency, rent_amount, rent_currency,
+		deposit_amount, deposit_currency, surface_m2, room_count,
+		floor_number, total_floors, available_from_date, parse_warnings,
+		city, district, sub_district, street, region, address_unmatched) = (`)
+	q.Params(&count, typedFlatValues(f)...)
 	q.Unsafe(`)`)
 
+	q.Unsafe(` WHERE id = `)
+	q.Param(&count, f.ID)
+
 	s, params, err := q.Get()
 	if err != nil {
 		return err
 	}
 
-	_, err = ef(s, params...)
+	result, err := ef(s, params...)
 	if err != nil {
 		return custerrors.MapDBErr(err)
 	}
 
-	return nil
-}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return custerrors.MapDBErr(err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("flat not found: %w", custerrors.ErrNotFound)
+	}
 
-func updateFlat(ef execFunc, u models.Flat) error {
 	return nil
 }
 
 func selectFlat(qf queryFunc, id string) (models.Flat, error) {
-	return models.Flat{}, nil
+	q := db.Query{}
+	count := 0
+
+	q.Unsafe(`SELECT ` + flatColumns + ` FROM flats WHERE id = `)
+	q.Param(&count, id)
+
+	s, params, err := q.Get()
+	if err != nil {
+		return models.Flat{}, err
+	}
+
+	rows, err := qf(s, params...)
+	if err != nil {
+		return models.Flat{}, custerrors.MapDBErr(err)
+	}
+
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return models.Flat{}, custerrors.MapDBErr(err)
+		}
+		return models.Flat{}, fmt.Errorf("flat not found: %w", custerrors.ErrNotFound)
+	}
+
+	f, err := scanFlat(rows)
+	if err != nil {
+		return models.Flat{}, custerrors.MapDBErr(err)
+	}
+
+	return f, nil
 }
 
+// flatSortKeys are the keys flat lists can be sorted by.
+var flatSortKeys = map[string]sortKey{
+	"price":          {column: "price_amount", kind: sortInt, low: "-9223372036854775808", high: "9223372036854775807"},
+	"rent":           {column: "rent_amount", kind: sortInt, low: "-9223372036854775808", high: "9223372036854775807"},
+	"surface":        {column: "surface_m2", kind: sortNumber, low: "-1", high: "1000000"},
+	"rooms":          {column: "room_count", kind: sortInt, low: "-2147483648", high: "2147483647"},
+	"floor":          {column: "floor_number", kind: sortInt, low: "-2147483648", high: "2147483647"},
+	"available_from": {column: "available_from_date", kind: sortDate, low: "'-infinity'::date", high: "'infinity'::date"},
+	"created_at":     {column: "created_at", kind: sortTimestamp},
+	"id":             {column: "id", kind: sortInt},
+}
+
+// defaultFlatSort lists the newest flats first.
+const defaultFlatSort = "-created_at"
+
 func selectFlats(qf queryFunc, f models.FlatFilter) ([]models.Flat, error) {
+	q := db.Query{}
+	count := 0
+	q.Unsafe(`SELECT ` + flatColumns + ` `)
+
+	flatSource(&q, &count, f)
+
+	q.Unsafe(`ORDER BY id ASC`)
+
+	s, params, err := q.Get()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := qf(s, params...)
+	if err != nil {
+		return nil, custerrors.MapDBErr(err)
+	}
+
+	defer rows.Close()
+
+	out := make([]models.Flat, 0)
+	for rows.Next() {
+		flat, err := scanFlat(rows)
+		if err != nil {
+			return nil, custerrors.MapDBErr(err)
+		}
+
+		out = append(out, flat)
+	}
 
-	return nil, nil
+	if err := rows.Err(); err != nil {
+		return nil, custerrors.MapDBErr(err)
+	}
+
+	return out, nil
 }
 
-func deleteFlat(ef execFunc, uuid string) error {
+func pageFlats(qf queryFunc, f models.FlatFilter, p models.PageRequest) (models.Page[models.Flat], error) {
+	search := searchQuery(f.Query) != ""
+
+	keys, def := flatSortKeys, defaultFlatSort
+	if search {
+		keys = make(map[string]sortKey, len(flatSortKeys)+1)
+		for k, v := range flatSortKeys {
+			keys[k] = v
+		}
+		keys["relevance"] = sortKey{column: flatRank, kind: sortNumber}
+		def = "-relevance"
+	}
+
+	spec, err := resolveSort(keys, p.Sort, def)
+	if err != nil {
+		return models.Page[models.Flat]{}, err
+	}
+
+	var after *cursor
+	if p.Cursor != "" {
+		c, err := decodeCursor(p.Cursor, spec)
+		if err != nil {
+			return models.Page[models.Flat]{}, err
+		}
+		after = &c
+	}
+
+	cq := db.Query{}
+	count := 0
+	cq.Unsafe(`SELECT COUNT(*) `)
+	flatSource(&cq, &count, f)
+
+	total, err := countRows(qf, &cq)
+	if err != nil {
+		return models.Page[models.Flat]{}, err
+	}
+
+	limit := pageLimit(p.Limit)
+
+	q := db.Query{}
+	count = 0
+	q.Unsafe(`SELECT ` + flatColumns + `, (` + spec.expr + `)::text`)
+	if search {
+		q.Unsafe(`, ` + flatRank + `, ` + flatHeadlines)
+	}
+	q.Unsafe(` `)
+	flatSource(&q, &count, f)
+	if after != nil {
+		spec.after(&q, &count, *after)
+	}
+	spec.orderBy(&q)
+	writeLimit(&q, limit)
+
+	s, params, err := q.Get()
+	if err != nil {
+		return models.Page[models.Flat]{}, err
+	}
+
+	rows, err := qf(s, params...)
+	if err != nil {
+		return models.Page[models.Flat]{}, custerrors.MapDBErr(err)
+	}
+
+	defer rows.Close()
+
+	page := models.Page[models.Flat]{
+		Items: make([]models.Flat, 0, limit),
+		Total: total,
+	}
+
+	var last cursor
+	for rows.Next() {
+		if len(page.Items) == limit {
+			page.NextCursor = encodeCursor(last)
+			break
+		}
+
+		var (
+			sortValue string
+			flat      models.Flat
+			err       error
+		)
+		if search {
+			m := &models.FlatMatch{}
+			flat, err = scanFlat(rows, &sortValue, &m.Rank, &m.Title, &m.Address, &m.Description)
+			m.Title, m.Address, m.Description = highlight(m.Title), highlight(m.Address), highlight(m.Description)
+			flat.Match = m
+		} else {
+			flat, err = scanFlat(rows, &sortValue)
+		}
+		if err != nil {
+			return models.Page[models.Flat]{}, custerrors.MapDBErr(err)
+		}
+
+		page.Items = append(page.Items, flat)
+		last = cursor{Sort: spec.name, Value: sortValue, ID: flat.ID}
+	}
+
+	if err := rows.Err(); err != nil {
+		return models.Page[models.Flat]{}, custerrors.MapDBErr(err)
+	}
+
+	return page, nil
+}
+
+// flatSource writes the FROM and WHERE clauses selecting the flats matching f.
+// When f has a search query, the parsed query is available to the select list as tsq.
+func flatSource(q *db.Query, count *int, f models.FlatFilter) {
+	terms := searchQuery(f.Query)
+	if terms == "" {
+		q.Unsafe(`FROM flats WHERE 1=1 `)
+		flatConditions(q, count, f)
+		return
+	}
+
+	q.Unsafe(`FROM flats, (SELECT to_tsquery('hestia_pl', `)
+	q.Param(count, terms)
+	q.Unsafe(`) || to_tsquery('hestia_pl_stem', `)
+	q.Param(count, terms)
+	q.Unsafe(`) AS tsq) AS search WHERE search_vector @@ tsq `)
+	flatConditions(q, count, f)
+}
+
+// flatConditions writes the conditions of f, each starting with AND.
+func flatConditions(q *db.Query, count *int, f models.FlatFilter) {
+	if len(f.IDs) > 0 {
+		q.Unsafe(`AND id IN (`)
+		q.Params(count, anySlice(f.IDs)...)
+		q.Unsafe(`) `)
+	}
+
+	if len(f.SourceURLs) > 0 {
+		q.Unsafe(`AND source_url IN (`)
+		q.Params(count, anySlice(f.SourceURLs)...)
+		q.Unsafe(`) `)
+	}
+
+	if len(f.Groups) > 0 {
+		q.Unsafe(`AND (id IN (`)
+		q.Params(count, anySlice(f.Groups)...)
+		q.Unsafe(`) OR canonical_id IN (`)
+		q.Params(count, anySlice(f.Groups)...)
+		q.Unsafe(`)) `)
+	}
+
+	if len(f.Fingerprints) > 0 {
+		q.Unsafe(`AND fingerprint IN (`)
+		q.Params(count, anySlice(f.Fingerprints)...)
+		q.Unsafe(`) `)
+	}
+
+	if f.WorkspaceID != "" {
+		q.Unsafe(`AND id IN (SELECT flat_id FROM workspace_flats WHERE workspace_id = `)
+		q.Param(count, f.WorkspaceID)
+		q.Unsafe(`) `)
+	}
+
+	switch {
+	case f.CanonicalOnly && f.WorkspaceID != "":
+		q.Unsafe(`AND (canonical_id IS NULL OR canonical_id NOT IN (SELECT flat_id FROM workspace_flats WHERE workspace_id = `)
+		q.Param(count, f.WorkspaceID)
+		q.Unsafe(`)) `)
+	case f.CanonicalOnly:
+		q.Unsafe(`AND canonical_id IS NULL `)
+	}
+
+	rangeFilter(q, count, "price_amount", f.MinPrice, f.MaxPrice)
+	rangeFilter(q, count, "rent_amount", f.MinRent, f.MaxRent)
+	rangeFilter(q, count, "surface_m2", f.MinSurface, f.MaxSurface)
+	rangeFilter(q, count, "floor_number", f.MinFloor, f.MaxFloor)
+	rangeFilter(q, count, "created_at", f.CreatedAfter, f.CreatedBefore)
+	switch {
+	case f.UpdatedAfter != nil && f.WorkspaceID != "":
+		q.Unsafe(`AND (updated_at > `)
+		q.Param(count, *f.UpdatedAfter)
+		q.Unsafe(` OR id IN (SELECT flat_id FROM workspace_flats WHERE workspace_id = `)
+		q.Param(count, f.WorkspaceID)
+		q.Unsafe(` AND added_at > `)
+		q.Param(count, *f.UpdatedAfter)
+		q.Unsafe(`)) `)
+	case f.UpdatedAfter != nil:
+		q.Unsafe(`AND updated_at > `)
+		q.Param(count, *f.UpdatedAfter)
+		q.Unsafe(` `)
+	}
+	rangeFilter(q, count, "available_from_date", nil, f.AvailableFrom)
+
+	if len(f.Rooms) > 0 {
+		q.Unsafe(`AND room_count IN (`)
+		q.Params(count, anySlice(f.Rooms)...)
+		q.Unsafe(`) `)
+	}
+
+	// Flats stored before addresses were parsed fall back to the raw address.
+	if f.City != "" {
+		q.Unsafe(`AND (city = `)
+		q.Param(count, f.City)
+		q.Unsafe(` OR (city IS NULL AND address ILIKE '%' || `)
+		q.Param(count, escapeLike(f.City))
+		q.Unsafe(` || '%')) `)
+	}
+
+	if f.District != "" {
+		q.Unsafe(`AND (district = `)
+		q.Param(count, f.District)
+		q.Unsafe(` OR sub_district = `)
+		q.Param(count, f.District)
+		q.Unsafe(` OR (district IS NULL AND address ILIKE '%' || `)
+		q.Param(count, escapeLike(f.District))
+		q.Unsafe(` || '%')) `)
+	}
+}
+
+// rangeFilter adds an inclusive range condition on column. Nil bounds are ignored.
+func rangeFilter[T any](q *db.Query, count *int, column string, min, max *T) {
+	if min != nil {
+		q.Unsafe(`AND ` + column + ` >= `)
+		q.Param(count, *min)
+		q.Unsafe(` `)
+	}
+
+	if max != nil {
+		q.Unsafe(`AND ` + column + ` <= `)
+		q.Param(count, *max)
+		q.Unsafe(` `)
+	}
+}
+
+// escapeLike escapes the wildcard characters of a LIKE pattern.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// selectFlatsToRefresh returns up to limit flats with a source URL that were
+// not checked since checkedBefore, least recently checked first. Withdrawn
+// flats are not refreshed.
+func selectFlatsToRefresh(qf queryFunc, checkedBefore time.Time, limit int) ([]models.Flat, error) {
+	q := db.Query{}
+	count := 0
+
+	q.Unsafe(`SELECT ` + flatColumns + ` FROM flats WHERE source_url IS NOT NULL AND withdrawn_at IS NULL
+		AND (last_checked_at IS NULL OR last_checked_at < `)
+	q.Param(&count, checkedBefore)
+	q.Unsafe(`) ORDER BY last_checked_at ASC NULLS FIRST, id ASC LIMIT `)
+	q.Param(&count, limit)
+
+	s, params, err := q.Get()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := qf(s, params...)
+	if err != nil {
+		return nil, custerrors.MapDBErr(err)
+	}
+
+	defer rows.Close()
+
+	out := make([]models.Flat, 0)
+	for rows.Next() {
+		flat, err := scanFlat(rows)
+		if err != nil {
+			return nil, custerrors.MapDBErr(err)
+		}
+
+		out = append(out, flat)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, custerrors.MapDBErr(err)
+	}
+
+	return out, nil
+}
+
+func deleteFlat(ef execFunc, id string) error {
+	q := db.Query{}
+	count := 0
+
+	q.Unsafe(`DELETE FROM flats WHERE id = `)
+	q.Param(&count, id)
+
+	s, params, err := q.Get()
+	if err != nil {
+		return err
+	}
+
+	result, err := ef(s, params...)
+	if err != nil {
+		return custerrors.MapDBErr(err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return custerrors.MapDBErr(err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("flat not found: %w", custerrors.ErrNotFound)
+	}
+
 	return nil
 }
+
+// typedFlatValues returns the values of the typed flat columns, in the order used by insertFlat and updateFlat.
+func typedFlatValues(f models.Flat) []any {
+	priceAmount, priceCurrency := moneyValues(f.PriceValue)
+	rentAmount, rentCurrency := moneyValues(f.RentValue)
+	depositAmount, depositCurrency := moneyValues(f.DepositValue)
+
+	return []any{
+		priceAmount, priceCurrency,
+		rentAmount, rentCurrency,
+		depositAmount, depositCurrency,
+		f.SurfaceM2,
+		f.RoomCount,
+		f.FloorNumber,
+		f.TotalFloors,
+		f.AvailableFromDate,
+		pq.Array(f.ParseWarnings),
+		nullString(f.Location.City),
+		nullString(f.Location.District),
+		nullString(f.Location.SubDistrict),
+		nullString(f.Location.Street),
+		nullString(f.Location.Region),
+		pq.Array(f.Location.Unmatched),
+	}
+}
+
+func moneyValues(m *models.Money) (any, any) {
+	if m == nil {
+		return nil, nil
+	}
+
+	return m.Amount, m.Currency
+}
+
+// scanFlat scans a row selected with flatColumns. Columns selected after
+// flatColumns are scanned into extra.
+func scanFlat(rows *sql.Rows, extra ...any) (models.Flat, error) {
+	var (
+		f                 models.Flat
+		price, rent, dep  sql.NullInt64
+		priceCur, rentCur sql.NullString
+		depCur            sql.NullString
+		surface           sql.NullFloat64
+		rooms, floor      sql.NullInt64
+		totalFloors       sql.NullInt64
+		availableFrom     sql.NullTime
+		city, district    sql.NullString
+		subDistrict       sql.NullString
+		street, region    sql.NullString
+		sourceURL         sql.NullString
+		lastChecked       sql.NullTime
+		withdrawn         sql.NullTime
+		canonicalID       sql.NullString
+		fingerprint       sql.NullString
+		firstPrice        sql.NullInt64
+		firstCur          sql.NullString
+		firstSeen         sql.NullTime
+	)
+
+	dest := []any{&f.ID, &f.Title, &f.Price, &f.Address, &f.Surface, &f.Rooms, &f.Floor, &f.AvailableFrom,
+		&f.Rent, &f.Deposit, &f.Description, &f.CreatedAt, &f.UpdatedAt,
+		&price, &priceCur, &rent, &rentCur, &dep, &depCur,
+		&surface, &rooms, &floor, &totalFloors, &availableFrom, pq.Array(&f.ParseWarnings),
+		&city, &district, &subDistrict, &street, &region, pq.Array(&f.Location.Unmatched), &sourceURL, &lastChecked, &withdrawn, &canonicalID, &fingerprint, pq.Array(&f.Sources),
+		&firstPrice, &firstCur, &firstSeen}
+
+	err := rows.Scan(append(dest, extra...)...)
+	if err != nil {
+		return models.Flat{}, err
+	}
+
+	f.PriceValue = nullMoney(price, priceCur)
+	f.RentValue = nullMoney(rent, rentCur)
+	f.DepositValue = nullMoney(dep, depCur)
+	f.SurfaceM2 = nullPtr(surface.Float64, surface.Valid)
+	f.RoomCount = nullPtr(int(rooms.Int64), rooms.Valid)
+	f.FloorNumber = nullPtr(int(floor.Int64), floor.Valid)
+	f.TotalFloors = nullPtr(int(totalFloors.Int64), totalFloors.Valid)
+	f.AvailableFromDate = nullPtr(availableFrom.Time, availableFrom.Valid)
+	f.Location.City = city.String
+	f.Location.District = district.String
+	f.Location.SubDistrict = subDistrict.String
+	f.Location.Street = street.String
+	f.Location.Region = region.String
+	f.SourceURL = sourceURL.String
+	f.LastCheckedAt = nullPtr(lastChecked.Time, lastChecked.Valid)
+	f.WithdrawnAt = nullPtr(withdrawn.Time, withdrawn.Valid)
+	f.CanonicalID = canonicalID.String
+	f.Fingerprint = fingerprint.String
+	if first := nullMoney(firstPrice, firstCur); first != nil {
+		f.PriceChange = models.ComparePrice(*first, firstSeen.Time, f.PriceValue)
+	}
+
+	return f, nil
+}
+
+func nullMoney(amount sql.NullInt64, currency sql.NullString) *models.Money {
+	if !amount.Valid {
+		return nil
+	}
+
+	return &models.Money{
+		Amount:   amount.Int64,
+		Currency: currency.String,
+	}
+}
+
+func nullPtr[T any](v T, valid bool) *T {
+	if !valid {
+		return nil
+	}
+
+	return &v
+}
@@ -0,0 +1,38 @@
+package repos
+
+import (
+	"hestia/pkg/custerrors"
+	"hestia/pkg/db"
+	"hestia/pkg/models"
+)
+
+func insertFlatChanges(ef execFunc, changes []models.FlatChange) error {
+	if len(changes) == 0 {
+		return nil
+	}
+
+	q := db.Query{}
+	count := 0
+
+	q.Unsafe(`INSERT INTO flat_changes (flat_id, field, old_value, new_value, detected_at) VALUES `)
+	for i, c := range changes {
+		if i > 0 {
+			q.Unsafe(`, `)
+		}
+		q.Unsafe(`(`)
+		q.Params(&count, c.FlatID, c.Field, c.OldValue, c.NewValue, c.DetectedAt)
+		q.Unsafe(`)`)
+	}
+
+	s, params, err := q.Get()
+	if err != nil {
+		return err
+	}
+
+	_, err = ef(s, params...)
+	if err != nil {
+		return custerrors.MapDBErr(err)
+	}
+
+	return nil
+}
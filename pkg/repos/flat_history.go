@@ -0,0 +1,142 @@
+package repos
+
+import (
+	"database/sql"
+
+	"hestia/pkg/custerrors"
+	"hestia/pkg/db"
+	"hestia/pkg/models"
+)
+
+func insertPricePoints(ef execFunc, points []models.PricePoint) error {
+	if len(points) == 0 {
+		return nil
+	}
+
+	q := db.Query{}
+	count := 0
+
+	q.Unsafe(`INSERT INTO flat_price_history (flat_id, kind, amount, currency, raw, recorded_at) VALUES `)
+	for i, p := range points {
+		if i > 0 {
+			q.Unsafe(`, `)
+		}
+		amount, currency := moneyValues(p.Value)
+		q.Unsafe(`(`)
+		q.Params(&count, p.FlatID, p.Kind, amount, currency, p.Raw, p.RecordedAt)
+		q.Unsafe(`)`)
+	}
+
+	s, params, err := q.Get()
+	if err != nil {
+		return err
+	}
+
+	_, err = ef(s, params...)
+	if err != nil {
+		return custerrors.MapDBErr(err)
+	}
+
+	return nil
+}
+
+func selectPricePoints(qf queryFunc, flatID string) ([]models.PricePoint, error) {
+	q := db.Query{}
+	count := 0
+
+	q.Unsafe(`SELECT id, flat_id, kind, amount, currency, raw, recorded_at FROM flat_price_history WHERE flat_id = `)
+	q.Param(&count, flatID)
+	q.Unsafe(` ORDER BY recorded_at ASC, id ASC`)
+
+	s, params, err := q.Get()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := qf(s, params...)
+	if err != nil {
+		return nil, custerrors.MapDBErr(err)
+	}
+
+	defer rows.Close()
+
+	out := make([]models.PricePoint, 0)
+	for rows.Next() {
+		var (
+			p        models.PricePoint
+			amount   sql.NullInt64
+			currency sql.NullString
+		)
+
+		err := rows.Scan(&p.ID, &p.FlatID, &p.Kind, &amount, &currency, &p.Raw, &p.RecordedAt)
+		if err != nil {
+			return nil, custerrors.MapDBErr(err)
+		}
+
+		p.Value = nullMoney(amount, currency)
+		out = append(out, p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, custerrors.MapDBErr(err)
+	}
+
+	return out, nil
+}
+
+// selectFlatHistory returns the price history and field changes of the flat with the given id.
+func selectFlatHistory(qf queryFunc, flatID string) (models.FlatHistory, error) {
+	prices, err := selectPricePoints(qf, flatID)
+	if err != nil {
+		return models.FlatHistory{}, err
+	}
+
+	changes, err := selectFlatChanges(qf, flatID)
+	if err != nil {
+		return models.FlatHistory{}, err
+	}
+
+	return models.FlatHistory{
+		FlatID:  flatID,
+		Prices:  prices,
+		Changes: changes,
+	}, nil
+}
+
+func selectFlatChanges(qf queryFunc, flatID string) ([]models.FlatChange, error) {
+	q := db.Query{}
+	count := 0
+
+	q.Unsafe(`SELECT id, flat_id, field, old_value, new_value, detected_at FROM flat_changes WHERE flat_id = `)
+	q.Param(&count, flatID)
+	q.Unsafe(` ORDER BY detected_at ASC, id ASC`)
+
+	s, params, err := q.Get()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := qf(s, params...)
+	if err != nil {
+		return nil, custerrors.MapDBErr(err)
+	}
+
+	defer rows.Close()
+
+	out := make([]models.FlatChange, 0)
+	for rows.Next() {
+		var c models.FlatChange
+		err := rows.Scan(&c.ID, &c.FlatID, &c.Field, &c.OldValue, &c.NewValue, &c.DetectedAt)
+		if err != nil {
+			return nil, custerrors.MapDBErr(err)
+		}
+
+		out = append(out, c)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, custerrors.MapDBErr(err)
+	}
+
+	return out, nil
+}
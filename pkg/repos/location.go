@@ -0,0 +1,147 @@
+package repos
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+
+	"github.com/lib/pq"
+
+	"hestia/pkg/custerrors"
+	"hestia/pkg/db"
+	"hestia/pkg/models"
+)
+
+// selectDistrictStats returns the statistics of the districts of city, or
+// of all cities if it is empty, ordered by city and district.
+func selectDistrictStats(qf queryFunc, city, currency string) ([]models.DistrictStats, error) {
+	q := db.Query{}
+	count := 0
+
+	q.Unsafe(`SELECT city, COALESCE(district, ''), COUNT(*),
+		percentile_cont(0.5) WITHIN GROUP (ORDER BY price_amount) FILTER (WHERE price_currency = `)
+	q.Param(&count, currency)
+	q.Unsafe(`),
+		percentile_cont(0.5) WITHIN GROUP (ORDER BY price_amount / surface_m2) FILTER (WHERE price_currency = `)
+	q.Param(&count, currency)
+	q.Unsafe(` AND surface_m2 > 0)
+		FROM flats WHERE city IS NOT NULL AND canonical_id IS NULL AND withdrawn_at IS NULL `)
+
+	if city != "" {
+		q.Unsafe(`AND city = `)
+		q.Param(&count, city)
+		q.Unsafe(` `)
+	}
+
+	q.Unsafe(`GROUP BY city, district ORDER BY city, district NULLS LAST`)
+
+	s, params, err := q.Get()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := qf(s, params...)
+	if err != nil {
+		return nil, custerrors.MapDBErr(err)
+	}
+
+	defer rows.Close()
+
+	out := make([]models.DistrictStats, 0)
+	for rows.Next() {
+		var (
+			st                    models.DistrictStats
+			medianPrice, medianM2 sql.NullFloat64
+		)
+
+		err := rows.Scan(&st.City, &st.District, &st.Flats, &medianPrice, &medianM2)
+		if err != nil {
+			return nil, custerrors.MapDBErr(err)
+		}
+
+		st.MedianPrice = nullPtr(int64(math.Round(medianPrice.Float64)), medianPrice.Valid)
+		st.MedianPricePerM2 = nullPtr(int64(math.Round(medianM2.Float64)), medianM2.Valid)
+		st.Currency = currency
+		out = append(out, st)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, custerrors.MapDBErr(err)
+	}
+
+	return out, nil
+}
+
+// selectUnrecognizedAddresses returns up to limit addresses without a known
+// city or with parts that were not recognized, the most common first.
+func selectUnrecognizedAddresses(qf queryFunc, limit int) ([]models.UnrecognizedAddress, error) {
+	q := db.Query{}
+	count := 0
+
+	q.Unsafe(`SELECT address, COALESCE(address_unmatched, '{}'), COUNT(*), MAX(updated_at) FROM flats
+		WHERE address <> '' AND (city IS NULL OR cardinality(address_unmatched) > 0)
+		GROUP BY address, address_unmatched ORDER BY COUNT(*) DESC, address LIMIT `)
+	q.Param(&count, limit)
+
+	s, params, err := q.Get()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := qf(s, params...)
+	if err != nil {
+		return nil, custerrors.MapDBErr(err)
+	}
+
+	defer rows.Close()
+
+	out := make([]models.UnrecognizedAddress, 0)
+	for rows.Next() {
+		var a models.UnrecognizedAddress
+		err := rows.Scan(&a.Address, pq.Array(&a.Unmatched), &a.Flats, &a.LastSeen)
+		if err != nil {
+			return nil, custerrors.MapDBErr(err)
+		}
+
+		out = append(out, a)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, custerrors.MapDBErr(err)
+	}
+
+	return out, nil
+}
+
+// updateFlatLocation sets the address parts of a flat without touching the rest of it.
+func updateFlatLocation(ef execFunc, id string, l models.Location) error {
+	q := db.Query{}
+	count := 0
+
+	q.Unsafe(`UPDATE flats SET (city, district, sub_district, street, region, address_unmatched) = (`)
+	q.Params(&count, nullString(l.City), nullString(l.District), nullString(l.SubDistrict), nullString(l.Street),
+		nullString(l.Region), pq.Array(l.Unmatched))
+	q.Unsafe(`) WHERE id = `)
+	q.Param(&count, id)
+
+	s, params, err := q.Get()
+	if err != nil {
+		return err
+	}
+
+	result, err := ef(s, params...)
+	if err != nil {
+		return custerrors.MapDBErr(err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return custerrors.MapDBErr(err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("flat not found: %w", custerrors.ErrNotFound)
+	}
+
+	return nil
+}
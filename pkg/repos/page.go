@@ -0,0 +1,208 @@
+package repos
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"hestia/pkg/custerrors"
+	"hestia/pkg/db"
+	"hestia/pkg/models"
+)
+
+// sortKind is the type of the values of a sort key, which the values in
+// cursors must have.
+type sortKind int
+
+const (
+	sortText sortKind = iota
+	sortInt
+	sortNumber
+	sortDate
+	sortTimestamp
+)
+
+// sortKey is a column a list can be sorted by.
+type sortKey struct {
+	column string
+	kind   sortKind
+	// low and high replace NULL when sorting in descending and ascending
+	// order, so that rows without a value always come last. They are empty
+	// for columns that cannot be NULL.
+	low, high string
+}
+
+// sortSpec is a resolved sort order. Rows are always ordered by id as well,
+// which makes the order total and the cursor unambiguous.
+type sortSpec struct {
+	name string
+	expr string
+	kind sortKind
+	desc bool
+}
+
+// cursor is the decoded form of a page cursor.
+type cursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	ID    string `json:"id"`
+}
+
+// resolveSort resolves sort, e.g. "-price", against keys. An empty sort resolves to def.
+func resolveSort(keys map[string]sortKey, sort, def string) (sortSpec, error) {
+	if sort == "" {
+		sort = def
+	}
+
+	name, desc := strings.CutPrefix(sort, "-")
+	key, ok := keys[name]
+	if !ok {
+		return sortSpec{}, fmt.Errorf("unknown sort key %q: %w", name, custerrors.ErrInvalidInput)
+	}
+
+	expr := key.column
+	if desc && key.low != "" {
+		expr = "COALESCE(" + key.column + ", " + key.low + ")"
+	}
+	if !desc && key.high != "" {
+		expr = "COALESCE(" + key.column + ", " + key.high + ")"
+	}
+
+	return sortSpec{
+		name: sort,
+		expr: expr,
+		kind: key.kind,
+		desc: desc,
+	}, nil
+}
+
+// after writes the condition selecting the rows that follow c.
+func (s sortSpec) after(q *db.Query, count *int, c cursor) {
+	op := ">"
+	if s.desc {
+		op = "<"
+	}
+
+	q.Unsafe(`AND (` + s.expr + `, id) ` + op + ` (`)
+	q.Params(count, c.Value, c.ID)
+	q.Unsafe(`) `)
+}
+
+// orderBy writes the ORDER BY clause.
+func (s sortSpec) orderBy(q *db.Query) {
+	dir := " ASC"
+	if s.desc {
+		dir = " DESC"
+	}
+
+	q.Unsafe(`ORDER BY ` + s.expr + dir + `, id` + dir + ` `)
+}
+
+func encodeCursor(c cursor) string {
+	b, err := json.Marshal(c)
+	if err != nil {
+		// cursor only holds strings, so this cannot happen.
+		panic(err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decodeCursor decodes s and checks it was issued for the sort order spec.
+func decodeCursor(s string, spec sortSpec) (cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return cursor{}, fmt.Errorf("invalid cursor: %w", custerrors.ErrInvalidInput)
+	}
+
+	var c cursor
+	err = json.Unmarshal(b, &c)
+	if err != nil || c.ID == "" {
+		return cursor{}, fmt.Errorf("invalid cursor: %w", custerrors.ErrInvalidInput)
+	}
+
+	if c.Sort != spec.name {
+		return cursor{}, fmt.Errorf("cursor does not match sort %q: %w", spec.name, custerrors.ErrInvalidInput)
+	}
+
+	// Both values end up in the query, malformed ones would fail there.
+	_, err = strconv.ParseInt(c.ID, 10, 64)
+	if err != nil || !spec.validValue(c.Value) {
+		return cursor{}, fmt.Errorf("invalid cursor: %w", custerrors.ErrInvalidInput)
+	}
+
+	return c, nil
+}
+
+// validValue reports whether v, as Postgres writes values of the sort
+// expression as text, is a value of the kind of s.
+func (s sortSpec) validValue(v string) bool {
+	switch s.kind {
+	case sortInt:
+		_, err := strconv.ParseInt(v, 10, 64)
+		return err == nil
+	case sortNumber:
+		f, err := strconv.ParseFloat(v, 64)
+		return err == nil && !math.IsInf(f, 0) && !math.IsNaN(f)
+	case sortDate:
+		if v == "infinity" || v == "-infinity" {
+			return true
+		}
+
+		_, err := time.Parse(time.DateOnly, v)
+		return err == nil
+	case sortTimestamp:
+		_, err := time.Parse("2006-01-02 15:04:05.999999999", v)
+		return err == nil
+	}
+
+	return true
+}
+
+// pageLimit clamps the requested limit to the allowed range.
+func pageLimit(limit int) int {
+	if limit <= 0 {
+		return models.DefaultPageLimit
+	}
+
+	return min(limit, models.MaxPageLimit)
+}
+
+// writeLimit writes a LIMIT clause fetching one row more than the page holds,
+// to find out if there is a next page.
+func writeLimit(q *db.Query, limit int) {
+	q.Unsafe(`LIMIT ` + strconv.Itoa(limit+1))
+}
+
+// countRows runs a COUNT query and returns its result.
+func countRows(qf queryFunc, q *db.Query) (int, error) {
+	s, params, err := q.Get()
+	if err != nil {
+		return 0, err
+	}
+
+	rows, err := qf(s, params...)
+	if err != nil {
+		return 0, custerrors.MapDBErr(err)
+	}
+
+	defer rows.Close()
+
+	var n int
+	if rows.Next() {
+		err := rows.Scan(&n)
+		if err != nil {
+			return 0, custerrors.MapDBErr(err)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return 0, custerrors.MapDBErr(err)
+	}
+
+	return n, nil
+}
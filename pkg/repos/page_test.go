@@ -0,0 +1,47 @@
+package repos
+
+import (
+	"errors"
+	"testing"
+
+	"hestia/pkg/custerrors"
+)
+
+func Test_decodeCursor(t *testing.T) {
+	spec := func(sort string) sortSpec {
+		s, err := resolveSort(flatSortKeys, sort, defaultFlatSort)
+		if err != nil {
+			t.Fatalf("failed to resolve sort: %v", err)
+		}
+
+		return s
+	}
+
+	tests := map[string]struct {
+		cursor  string
+		sort    string
+		wantErr error
+	}{
+		"ok, int":               {cursor: encodeCursor(cursor{Sort: "-price", Value: "450000", ID: "12"}), sort: "-price"},
+		"ok, number":            {cursor: encodeCursor(cursor{Sort: "surface", Value: "48.50", ID: "12"}), sort: "surface"},
+		"ok, date":              {cursor: encodeCursor(cursor{Sort: "available_from", Value: "2024-05-01", ID: "12"}), sort: "available_from"},
+		"ok, date without one":  {cursor: encodeCursor(cursor{Sort: "available_from", Value: "infinity", ID: "12"}), sort: "available_from"},
+		"ok, timestamp":         {cursor: encodeCursor(cursor{Sort: "-created_at", Value: "2024-05-01 10:20:30.123456", ID: "12"}), sort: "-created_at"},
+		"fail, not base64":      {cursor: "!!!", sort: "-price", wantErr: custerrors.ErrInvalidInput},
+		"fail, other sort":      {cursor: encodeCursor(cursor{Sort: "price", Value: "450000", ID: "12"}), sort: "-price", wantErr: custerrors.ErrInvalidInput},
+		"fail, id not a number": {cursor: encodeCursor(cursor{Sort: "-price", Value: "450000", ID: "abc"}), sort: "-price", wantErr: custerrors.ErrInvalidInput},
+		"fail, int value":       {cursor: encodeCursor(cursor{Sort: "-price", Value: "cheap", ID: "12"}), sort: "-price", wantErr: custerrors.ErrInvalidInput},
+		"fail, number value":    {cursor: encodeCursor(cursor{Sort: "surface", Value: "NaN", ID: "12"}), sort: "surface", wantErr: custerrors.ErrInvalidInput},
+		"fail, date value":      {cursor: encodeCursor(cursor{Sort: "available_from", Value: "2024-13-01", ID: "12"}), sort: "available_from", wantErr: custerrors.ErrInvalidInput},
+		"fail, timestamp value": {cursor: encodeCursor(cursor{Sort: "-created_at", Value: "yesterday", ID: "12"}), sort: "-created_at", wantErr: custerrors.ErrInvalidInput},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := decodeCursor(tc.cursor, spec(tc.sort))
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("expected errors to be %v got %v (via errors.Is)", tc.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package repos
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"hestia/pkg/custerrors"
+	"hestia/pkg/db"
+	"hestia/pkg/models"
+)
+
+// parserRuleColumns are the columns read by scanParserRules, in the order it expects them.
+// active is computed by the queries.
+const parserRuleColumns = `id, portal, version, rules, note, created_by, created_at, published_at`
+
+// activeParserRules is true for the latest published version of the portal of r.
+const activeParserRules = `r.published_at IS NOT NULL AND r.published_at = (SELECT MAX(p.published_at)
+	FROM parser_rules p WHERE p.portal = r.portal)`
+
+// ruleSetDoc is how the rules of a set are stored.
+type ruleSetDoc struct {
+	Fields map[string][]models.ExtractionRule `json:"fields"`
+	Photos []models.ExtractionRule            `json:"photos"`
+}
+
+// insertParserRules stores rs as the next version of its portal and returns its id.
+func insertParserRules(qf queryFunc, rs models.ParserRuleSet) (string, error) {
+	doc, err := json.Marshal(ruleSetDoc{Fields: rs.Fields, Photos: rs.Photos})
+	if err != nil {
+		return "", err
+	}
+
+	q := db.Query{}
+	count := 0
+
+	q.Unsafe(`INSERT INTO parser_rules (portal, version, rules, note, created_by, created_at)
+		SELECT `)
+	q.Param(&count, rs.Portal)
+	q.Unsafe(`, COALESCE(MAX(version), 0) + 1, `)
+	q.Params(&count, string(doc), nullString(rs.Note), nullString(rs.CreatedBy), rs.CreatedAt)
+	q.Unsafe(` FROM parser_rules WHERE portal = `)
+	q.Param(&count, rs.Portal)
+	q.Unsafe(` RETURNING id`)
+
+	return insertReturningID(qf, &q)
+}
+
+// publishParserRules makes the rule set with the given id the active version of its portal.
+func publishParserRules(ef execFunc, id string, at time.Time) error {
+	q := db.Query{}
+	count := 0
+
+	q.Unsafe(`UPDATE parser_rules SET published_at = `)
+	q.Param(&count, at)
+	q.Unsafe(` WHERE id = `)
+	q.Param(&count, id)
+
+	s, params, err := q.Get()
+	if err != nil {
+		return err
+	}
+
+	result, err := ef(s, params...)
+	if err != nil {
+		return custerrors.MapDBErr(err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return custerrors.MapDBErr(err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("parser rules not found: %w", custerrors.ErrNotFound)
+	}
+
+	return nil
+}
+
+func deleteParserRules(ef execFunc, id string) error {
+	q := db.Query{}
+	count := 0
+
+	q.Unsafe(`DELETE FROM parser_rules WHERE id = `)
+	q.Param(&count, id)
+
+	s, params, err := q.Get()
+	if err != nil {
+		return err
+	}
+
+	result, err := ef(s, params...)
+	if err != nil {
+		return custerrors.MapDBErr(err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return custerrors.MapDBErr(err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("parser rules not found: %w", custerrors.ErrNotFound)
+	}
+
+	return nil
+}
+
+func selectParserRuleSet(qf queryFunc, id string) (models.ParserRuleSet, error) {
+	sets, err := selectParserRules(qf, models.ParserRuleFilter{IDs: []string{id}})
+	if err != nil {
+		return models.ParserRuleSet{}, err
+	}
+
+	if len(sets) == 0 {
+		return models.ParserRuleSet{}, fmt.Errorf("parser rules not found: %w", custerrors.ErrNotFound)
+	}
+
+	return sets[0], nil
+}
+
+// selectParserRules returns the rule sets matching f by portal, the latest version first.
+func selectParserRules(qf queryFunc, f models.ParserRuleFilter) ([]models.ParserRuleSet, error) {
+	q := db.Query{}
+	count := 0
+
+	q.Unsafe(`SELECT r.` + parserRuleColumns + `, (` + activeParserRules + `) FROM parser_rules r WHERE 1=1 `)
+
+	if len(f.IDs) > 0 {
+		q.Unsafe(`AND r.id IN (`)
+		q.Params(&count, anySlice(f.IDs)...)
+		q.Unsafe(`) `)
+	}
+
+	if len(f.Portals) > 0 {
+		q.Unsafe(`AND r.portal IN (`)
+		q.Params(&count, anySlice(f.Portals)...)
+		q.Unsafe(`) `)
+	}
+
+	if f.ActiveOnly {
+		q.Unsafe(`AND ` + activeParserRules + ` `)
+	}
+
+	q.Unsafe(`ORDER BY r.portal ASC, r.version DESC`)
+
+	s, params, err := q.Get()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := qf(s, params...)
+	if err != nil {
+		return nil, custerrors.MapDBErr(err)
+	}
+
+	defer rows.Close()
+
+	out := make([]models.ParserRuleSet, 0)
+	for rows.Next() {
+		rs, err := scanParserRules(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		out = append(out, rs)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, custerrors.MapDBErr(err)
+	}
+
+	return out, nil
+}
+
+func scanParserRules(rows *sql.Rows) (models.ParserRuleSet, error) {
+	var (
+		rs              models.ParserRuleSet
+		doc             []byte
+		note, createdBy sql.NullString
+		publishedAt     sql.NullTime
+	)
+
+	err := rows.Scan(&rs.ID, &rs.Portal, &rs.Version, &doc, &note, &createdBy, &rs.CreatedAt, &publishedAt,
+		&rs.Active)
+	if err != nil {
+		return models.ParserRuleSet{}, custerrors.MapDBErr(err)
+	}
+
+	var d ruleSetDoc
+	err = json.Unmarshal(doc, &d)
+	if err != nil {
+		return models.ParserRuleSet{}, fmt.Errorf("invalid rules of parser rules %s: %w", rs.ID, err)
+	}
+
+	rs.Fields = d.Fields
+	rs.Photos = d.Photos
+	rs.Note = note.String
+	rs.CreatedBy = createdBy.String
+	rs.PublishedAt = nullPtr(publishedAt.Time, publishedAt.Valid)
+
+	return rs, nil
+}
@@ -0,0 +1,238 @@
+package repos
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"hestia/pkg/custerrors"
+	"hestia/pkg/db"
+	"hestia/pkg/models"
+)
+
+// photoColumns are the columns read by scanPhoto, in the order it expects them.
+const photoColumns = `id, flat_id, source_url, position, status, attempts, last_error, content_type,
+	width, height, size, blob_key, thumbnail_key, run_after, created_at, updated_at`
+
+// insertPhotos adds the photos that the flats do not have yet.
+func insertPhotos(ef execFunc, photos []models.Photo) error {
+	if len(photos) == 0 {
+		return nil
+	}
+
+	q := db.Query{}
+	count := 0
+
+	q.Unsafe(`INSERT INTO flat_photos (flat_id, source_url, position, status, run_after, created_at, updated_at) VALUES `)
+	for i, p := range photos {
+		if i > 0 {
+			q.Unsafe(`, `)
+		}
+		q.Unsafe(`(`)
+		q.Params(&count, p.FlatID, p.SourceURL, p.Position, p.Status, p.RunAfter, p.CreatedAt, p.UpdatedAt)
+		q.Unsafe(`)`)
+	}
+	q.Unsafe(` ON CONFLICT (flat_id, source_url) DO NOTHING`)
+
+	s, params, err := q.Get()
+	if err != nil {
+		return err
+	}
+
+	_, err = ef(s, params...)
+	if err != nil {
+		return custerrors.MapDBErr(err)
+	}
+
+	return nil
+}
+
+func updatePhoto(ef execFunc, p models.Photo) error {
+	q := db.Query{}
+	count := 0
+
+	q.Unsafe(`UPDATE flat_photos SET (status, attempts, last_error, content_type, width, height, size,
+		blob_key, thumbnail_key, run_after, updated_at) = (`)
+	q.Params(&count, p.Status, p.Attempts, nullString(p.LastError), nullString(p.ContentType),
+		nullPtr(p.Width, p.Width != 0), nullPtr(p.Height, p.Height != 0), nullPtr(p.Size, p.Size != 0),
+		nullString(p.BlobKey), nullString(p.ThumbnailKey), p.RunAfter, p.UpdatedAt)
+	q.Unsafe(`) WHERE id = `)
+	q.Param(&count, p.ID)
+
+	s, params, err := q.Get()
+	if err != nil {
+		return err
+	}
+
+	result, err := ef(s, params...)
+	if err != nil {
+		return custerrors.MapDBErr(err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return custerrors.MapDBErr(err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("photo not found: %w", custerrors.ErrNotFound)
+	}
+
+	return nil
+}
+
+func selectPhoto(qf queryFunc, flatID, id string) (models.Photo, error) {
+	q := db.Query{}
+	count := 0
+
+	q.Unsafe(`SELECT ` + photoColumns + ` FROM flat_photos WHERE flat_id = `)
+	q.Param(&count, flatID)
+	q.Unsafe(` AND id = `)
+	q.Param(&count, id)
+
+	return queryPhoto(qf, &q)
+}
+
+// selectPhotos returns the photos of a flat in gallery order.
+func selectPhotos(qf queryFunc, flatID string) ([]models.Photo, error) {
+	q := db.Query{}
+	count := 0
+
+	q.Unsafe(`SELECT ` + photoColumns + ` FROM flat_photos WHERE flat_id = `)
+	q.Param(&count, flatID)
+	q.Unsafe(` ORDER BY position ASC, id ASC`)
+
+	s, params, err := q.Get()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := qf(s, params...)
+	if err != nil {
+		return nil, custerrors.MapDBErr(err)
+	}
+
+	defer rows.Close()
+
+	out := make([]models.Photo, 0)
+	for rows.Next() {
+		p, err := scanPhoto(rows)
+		if err != nil {
+			return nil, custerrors.MapDBErr(err)
+		}
+
+		out = append(out, p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, custerrors.MapDBErr(err)
+	}
+
+	return out, nil
+}
+
+// claimPhoto marks the oldest pending photo that is due at now as
+// downloading and returns it. Photos locked by other workers are skipped.
+// It returns custerrors.ErrNotFound if no photo is due.
+func claimPhoto(qf queryFunc, now time.Time) (models.Photo, error) {
+	q := db.Query{}
+	count := 0
+
+	q.Unsafe(`UPDATE flat_photos SET status = `)
+	q.Param(&count, models.PhotoStatusDownloading)
+	q.Unsafe(`, attempts = attempts + 1, updated_at = `)
+	q.Param(&count, now)
+	q.Unsafe(` WHERE id = (SELECT id FROM flat_photos WHERE status = `)
+	q.Param(&count, models.PhotoStatusPending)
+	q.Unsafe(` AND run_after <= `)
+	q.Param(&count, now)
+	q.Unsafe(` ORDER BY run_after, id LIMIT 1 FOR UPDATE SKIP LOCKED) RETURNING ` + photoColumns)
+
+	return queryPhoto(qf, &q)
+}
+
+// requeuePhotos puts the photos left downloading by a stopped process back
+// in the queue and returns how many there were.
+func requeuePhotos(ef execFunc, now time.Time) (int64, error) {
+	q := db.Query{}
+	count := 0
+
+	q.Unsafe(`UPDATE flat_photos SET status = `)
+	q.Param(&count, models.PhotoStatusPending)
+	q.Unsafe(`, run_after = `)
+	q.Param(&count, now)
+	q.Unsafe(`, updated_at = `)
+	q.Param(&count, now)
+	q.Unsafe(` WHERE status = `)
+	q.Param(&count, models.PhotoStatusDownloading)
+
+	s, params, err := q.Get()
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := ef(s, params...)
+	if err != nil {
+		return 0, custerrors.MapDBErr(err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, custerrors.MapDBErr(err)
+	}
+
+	return rows, nil
+}
+
+func queryPhoto(qf queryFunc, q *db.Query) (models.Photo, error) {
+	s, params, err := q.Get()
+	if err != nil {
+		return models.Photo{}, err
+	}
+
+	rows, err := qf(s, params...)
+	if err != nil {
+		return models.Photo{}, custerrors.MapDBErr(err)
+	}
+
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return models.Photo{}, custerrors.MapDBErr(err)
+		}
+		return models.Photo{}, fmt.Errorf("photo not found: %w", custerrors.ErrNotFound)
+	}
+
+	p, err := scanPhoto(rows)
+	if err != nil {
+		return models.Photo{}, custerrors.MapDBErr(err)
+	}
+
+	return p, nil
+}
+
+func scanPhoto(rows *sql.Rows) (models.Photo, error) {
+	var (
+		p                     models.Photo
+		lastErr, contentType  sql.NullString
+		blobKey, thumbnailKey sql.NullString
+		width, height, size   sql.NullInt64
+	)
+
+	err := rows.Scan(&p.ID, &p.FlatID, &p.SourceURL, &p.Position, &p.Status, &p.Attempts, &lastErr, &contentType,
+		&width, &height, &size, &blobKey, &thumbnailKey, &p.RunAfter, &p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		return models.Photo{}, err
+	}
+
+	p.LastError = lastErr.String
+	p.ContentType = contentType.String
+	p.Width = int(width.Int64)
+	p.Height = int(height.Int64)
+	p.Size = size.Int64
+	p.BlobKey = blobKey.String
+	p.ThumbnailKey = thumbnailKey.String
+
+	return p, nil
+}
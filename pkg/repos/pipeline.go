@@ -0,0 +1,222 @@
+package repos
+
+import (
+	"database/sql"
+	"fmt"
+
+	"hestia/pkg/custerrors"
+	"hestia/pkg/db"
+	"hestia/pkg/models"
+)
+
+// flatStatusColumns are the columns read by scanFlatStatus, in the order it expects them.
+const flatStatusColumns = `workspace_id, flat_id, stage, user_id, reason, updated_at`
+
+// upsertFlatStatus stores the stage of the flat for the workspace.
+func upsertFlatStatus(ef execFunc, st models.FlatStatus) error {
+	q := db.Query{}
+	count := 0
+
+	q.Unsafe(`INSERT INTO flat_statuses (workspace_id, flat_id, stage, user_id, reason, updated_at) VALUES (`)
+	q.Params(&count, st.WorkspaceID, st.FlatID, string(st.Stage), nullString(st.UserID), nullString(st.Reason), st.UpdatedAt)
+	q.Unsafe(`) ON CONFLICT (workspace_id, flat_id) DO UPDATE
+		SET (stage, user_id, reason, updated_at) = (excluded.stage, excluded.user_id, excluded.reason, excluded.updated_at)`)
+
+	s, params, err := q.Get()
+	if err != nil {
+		return err
+	}
+
+	_, err = ef(s, params...)
+	if err != nil {
+		return custerrors.MapDBErr(err)
+	}
+
+	return nil
+}
+
+// deleteFlatStatus takes the flat out of the pipeline of the workspace, with its transitions.
+func deleteFlatStatus(ef execFunc, workspaceID, flatID string) error {
+	q := db.Query{}
+	count := 0
+
+	q.Unsafe(`DELETE FROM flat_statuses WHERE workspace_id = `)
+	q.Param(&count, workspaceID)
+	q.Unsafe(` AND flat_id = `)
+	q.Param(&count, flatID)
+
+	s, params, err := q.Get()
+	if err != nil {
+		return err
+	}
+
+	result, err := ef(s, params...)
+	if err != nil {
+		return custerrors.MapDBErr(err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return custerrors.MapDBErr(err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("flat status not found: %w", custerrors.ErrNotFound)
+	}
+
+	return nil
+}
+
+func selectFlatStatus(qf queryFunc, workspaceID, flatID string) (models.FlatStatus, error) {
+	statuses, err := selectFlatStatuses(qf, models.FlatStatusFilter{WorkspaceID: workspaceID, FlatIDs: []string{flatID}})
+	if err != nil {
+		return models.FlatStatus{}, err
+	}
+
+	if len(statuses) == 0 {
+		return models.FlatStatus{}, fmt.Errorf("flat status not found: %w", custerrors.ErrNotFound)
+	}
+
+	return statuses[0], nil
+}
+
+// selectFlatStatuses returns the statuses matching f, the latest moved first.
+func selectFlatStatuses(qf queryFunc, f models.FlatStatusFilter) ([]models.FlatStatus, error) {
+	q := db.Query{}
+	count := 0
+
+	q.Unsafe(`SELECT ` + flatStatusColumns + ` FROM flat_statuses WHERE workspace_id = `)
+	q.Param(&count, f.WorkspaceID)
+	q.Unsafe(` `)
+
+	if len(f.FlatIDs) > 0 {
+		q.Unsafe(`AND flat_id IN (`)
+		q.Params(&count, anySlice(f.FlatIDs)...)
+		q.Unsafe(`) `)
+	}
+
+	if len(f.Stages) > 0 {
+		q.Unsafe(`AND stage IN (`)
+		q.Params(&count, anySlice(f.Stages)...)
+		q.Unsafe(`) `)
+	}
+
+	q.Unsafe(`ORDER BY updated_at DESC, flat_id DESC`)
+
+	s, params, err := q.Get()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := qf(s, params...)
+	if err != nil {
+		return nil, custerrors.MapDBErr(err)
+	}
+
+	defer rows.Close()
+
+	out := make([]models.FlatStatus, 0)
+	for rows.Next() {
+		st, err := scanFlatStatus(rows)
+		if err != nil {
+			return nil, custerrors.MapDBErr(err)
+		}
+
+		out = append(out, st)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, custerrors.MapDBErr(err)
+	}
+
+	return out, nil
+}
+
+func scanFlatStatus(rows *sql.Rows) (models.FlatStatus, error) {
+	var (
+		st             models.FlatStatus
+		stage          string
+		userID, reason sql.NullString
+	)
+
+	err := rows.Scan(&st.WorkspaceID, &st.FlatID, &stage, &userID, &reason, &st.UpdatedAt)
+	if err != nil {
+		return models.FlatStatus{}, err
+	}
+
+	st.Stage = models.Stage(stage)
+	st.UserID = userID.String
+	st.Reason = reason.String
+
+	return st, nil
+}
+
+// insertStageTransition records a move of the flat of the workspace between stages.
+func insertStageTransition(ef execFunc, workspaceID, flatID string, t models.StageTransition) error {
+	q := db.Query{}
+	count := 0
+
+	q.Unsafe(`INSERT INTO flat_status_transitions (workspace_id, flat_id, user_id, from_stage, to_stage, reason, created_at) VALUES (`)
+	q.Params(&count, workspaceID, flatID, t.UserID, nullString(string(t.From)), string(t.To), nullString(t.Reason), t.At)
+	q.Unsafe(`)`)
+
+	s, params, err := q.Get()
+	if err != nil {
+		return err
+	}
+
+	_, err = ef(s, params...)
+	if err != nil {
+		return custerrors.MapDBErr(err)
+	}
+
+	return nil
+}
+
+// selectStageTransitions returns the moves of the flat of the workspace between stages, oldest first.
+func selectStageTransitions(qf queryFunc, workspaceID, flatID string) ([]models.StageTransition, error) {
+	q := db.Query{}
+	count := 0
+
+	q.Unsafe(`SELECT from_stage, to_stage, reason, user_id, created_at FROM flat_status_transitions WHERE workspace_id = `)
+	q.Param(&count, workspaceID)
+	q.Unsafe(` AND flat_id = `)
+	q.Param(&count, flatID)
+	q.Unsafe(` ORDER BY created_at ASC, id ASC`)
+
+	s, params, err := q.Get()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := qf(s, params...)
+	if err != nil {
+		return nil, custerrors.MapDBErr(err)
+	}
+
+	defer rows.Close()
+
+	out := make([]models.StageTransition, 0)
+	for rows.Next() {
+		var (
+			t            models.StageTransition
+			from, reason sql.NullString
+			to           string
+		)
+		err := rows.Scan(&from, &to, &reason, &t.UserID, &t.At)
+		if err != nil {
+			return nil, custerrors.MapDBErr(err)
+		}
+
+		t.From = models.Stage(from.String)
+		t.To = models.Stage(to)
+		t.Reason = reason.String
+		out = append(out, t)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, custerrors.MapDBErr(err)
+	}
+
+	return out, nil
+}
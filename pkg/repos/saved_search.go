@@ -0,0 +1,323 @@
+package repos
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+
+	"hestia/pkg/custerrors"
+	"hestia/pkg/db"
+	"hestia/pkg/models"
+)
+
+// savedSearchColumns are the columns read by scanSavedSearch, in the order it expects them.
+const savedSearchColumns = `id, user_id, name, query, delivery, paused, checked_until, last_sent_at, created_at, updated_at`
+
+// insertSavedSearch inserts ss and returns the id of the new row.
+func insertSavedSearch(qf queryFunc, ss models.SavedSearch) (string, error) {
+	q := db.Query{}
+	count := 0
+
+	q.Unsafe(`INSERT INTO saved_searches (user_id, name, query, delivery, paused, checked_until, created_at, updated_at) VALUES (`)
+	q.Params(&count, ss.UserID, ss.Name, ss.Query, string(ss.Delivery), ss.Paused, ss.CheckedUntil, ss.CreatedAt, ss.UpdatedAt)
+	q.Unsafe(`) RETURNING id`)
+
+	return insertReturningID(qf, &q)
+}
+
+// updateSavedSearch updates the saved search of its user.
+func updateSavedSearch(ef execFunc, ss models.SavedSearch) error {
+	q := db.Query{}
+	count := 0
+
+	q.Unsafe(`UPDATE saved_searches SET (name, query, delivery, paused, checked_until, updated_at) = (`)
+	q.Params(&count, ss.Name, ss.Query, string(ss.Delivery), ss.Paused, ss.CheckedUntil, ss.UpdatedAt)
+	q.Unsafe(`) WHERE user_id = `)
+	q.Param(&count, ss.UserID)
+	q.Unsafe(` AND id = `)
+	q.Param(&count, ss.ID)
+
+	s, params, err := q.Get()
+	if err != nil {
+		return err
+	}
+
+	result, err := ef(s, params...)
+	if err != nil {
+		return custerrors.MapDBErr(err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return custerrors.MapDBErr(err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("saved search not found: %w", custerrors.ErrNotFound)
+	}
+
+	return nil
+}
+
+// deleteSavedSearch deletes the saved search of the user with the given id.
+func deleteSavedSearch(ef execFunc, userID, id string) error {
+	q := db.Query{}
+	count := 0
+
+	q.Unsafe(`DELETE FROM saved_searches WHERE user_id = `)
+	q.Param(&count, userID)
+	q.Unsafe(` AND id = `)
+	q.Param(&count, id)
+
+	s, params, err := q.Get()
+	if err != nil {
+		return err
+	}
+
+	result, err := ef(s, params...)
+	if err != nil {
+		return custerrors.MapDBErr(err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return custerrors.MapDBErr(err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("saved search not found: %w", custerrors.ErrNotFound)
+	}
+
+	return nil
+}
+
+func selectSavedSearch(qf queryFunc, userID, id string) (models.SavedSearch, error) {
+	searches, err := selectSavedSearches(qf, models.SavedSearchFilter{UserID: userID, IDs: []string{id}})
+	if err != nil {
+		return models.SavedSearch{}, err
+	}
+
+	if len(searches) == 0 {
+		return models.SavedSearch{}, fmt.Errorf("saved search not found: %w", custerrors.ErrNotFound)
+	}
+
+	return searches[0], nil
+}
+
+// selectSavedSearches returns the saved searches matching f by name.
+func selectSavedSearches(qf queryFunc, f models.SavedSearchFilter) ([]models.SavedSearch, error) {
+	q := db.Query{}
+	count := 0
+
+	q.Unsafe(`SELECT ` + savedSearchColumns + ` FROM saved_searches WHERE 1=1 `)
+
+	if f.UserID != "" {
+		q.Unsafe(`AND user_id = `)
+		q.Param(&count, f.UserID)
+		q.Unsafe(` `)
+	}
+
+	if len(f.IDs) > 0 {
+		q.Unsafe(`AND id IN (`)
+		q.Params(&count, anySlice(f.IDs)...)
+		q.Unsafe(`) `)
+	}
+
+	if f.ActiveOnly {
+		q.Unsafe(`AND NOT paused `)
+	}
+
+	q.Unsafe(`ORDER BY name ASC, id ASC`)
+
+	s, params, err := q.Get()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := qf(s, params...)
+	if err != nil {
+		return nil, custerrors.MapDBErr(err)
+	}
+
+	defer rows.Close()
+
+	out := make([]models.SavedSearch, 0)
+	for rows.Next() {
+		ss, err := scanSavedSearch(rows)
+		if err != nil {
+			return nil, custerrors.MapDBErr(err)
+		}
+
+		out = append(out, ss)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, custerrors.MapDBErr(err)
+	}
+
+	return out, nil
+}
+
+func scanSavedSearch(rows *sql.Rows) (models.SavedSearch, error) {
+	var (
+		ss       models.SavedSearch
+		delivery string
+		lastSent sql.NullTime
+	)
+
+	err := rows.Scan(&ss.ID, &ss.UserID, &ss.Name, &ss.Query, &delivery, &ss.Paused, &ss.CheckedUntil, &lastSent,
+		&ss.CreatedAt, &ss.UpdatedAt)
+	if err != nil {
+		return models.SavedSearch{}, err
+	}
+
+	ss.Delivery = models.SearchDelivery(delivery)
+	ss.LastSentAt = nullPtr(lastSent.Time, lastSent.Valid)
+
+	return ss, nil
+}
+
+// updateSavedSearchChecked records that the flats were matched against the
+// saved search with the given id until at.
+func updateSavedSearchChecked(ef execFunc, id string, at time.Time) error {
+	q := db.Query{}
+	count := 0
+
+	q.Unsafe(`UPDATE saved_searches SET checked_until = `)
+	q.Param(&count, at)
+	q.Unsafe(` WHERE id = `)
+	q.Param(&count, id)
+
+	s, params, err := q.Get()
+	if err != nil {
+		return err
+	}
+
+	result, err := ef(s, params...)
+	if err != nil {
+		return custerrors.MapDBErr(err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return custerrors.MapDBErr(err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("saved search not found: %w", custerrors.ErrNotFound)
+	}
+
+	return nil
+}
+
+// insertSearchAlerts records the flats as matches of the saved search. Flats
+// matched before are left alone, so that they are never sent twice.
+func insertSearchAlerts(ef execFunc, searchID string, flatIDs []string, at time.Time) error {
+	if len(flatIDs) == 0 {
+		return nil
+	}
+
+	q := db.Query{}
+	count := 0
+
+	q.Unsafe(`INSERT INTO saved_search_alerts (search_id, flat_id, matched_at) VALUES `)
+	for i, id := range flatIDs {
+		if i > 0 {
+			q.Unsafe(`, `)
+		}
+		q.Unsafe(`(`)
+		q.Params(&count, searchID, id, at)
+		q.Unsafe(`)`)
+	}
+	q.Unsafe(` ON CONFLICT (search_id, flat_id) DO NOTHING`)
+
+	s, params, err := q.Get()
+	if err != nil {
+		return err
+	}
+
+	_, err = ef(s, params...)
+	if err != nil {
+		return custerrors.MapDBErr(err)
+	}
+
+	return nil
+}
+
+// selectPendingAlerts returns the ids of the flats matched by the saved
+// search that were not sent yet, in the order they were matched.
+func selectPendingAlerts(qf queryFunc, searchID string) ([]string, error) {
+	q := db.Query{}
+	count := 0
+
+	q.Unsafe(`SELECT ARRAY(SELECT flat_id FROM saved_search_alerts WHERE sent_at IS NULL AND search_id = `)
+	q.Param(&count, searchID)
+	q.Unsafe(` ORDER BY matched_at, flat_id)`)
+
+	s, params, err := q.Get()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := qf(s, params...)
+	if err != nil {
+		return nil, custerrors.MapDBErr(err)
+	}
+
+	defer rows.Close()
+
+	out := make([]string, 0)
+	if rows.Next() {
+		err := rows.Scan(pq.Array(&out))
+		if err != nil {
+			return nil, custerrors.MapDBErr(err)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, custerrors.MapDBErr(err)
+	}
+
+	return out, nil
+}
+
+// updateAlertsSent marks the matches of the saved search as sent at, and
+// the search as last sent at.
+func updateAlertsSent(ef execFunc, searchID string, flatIDs []string, at time.Time) error {
+	q := db.Query{}
+	count := 0
+
+	q.Unsafe(`WITH sent AS (UPDATE saved_search_alerts SET sent_at = `)
+	q.Param(&count, at)
+	q.Unsafe(` WHERE sent_at IS NULL AND search_id = `)
+	q.Param(&count, searchID)
+	q.Unsafe(` AND flat_id = ANY(`)
+	q.Param(&count, pq.Array(flatIDs))
+	q.Unsafe(`::bigint[])) UPDATE saved_searches SET last_sent_at = `)
+	q.Param(&count, at)
+	q.Unsafe(` WHERE id = `)
+	q.Param(&count, searchID)
+
+	s, params, err := q.Get()
+	if err != nil {
+		return err
+	}
+
+	result, err := ef(s, params...)
+	if err != nil {
+		return custerrors.MapDBErr(err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return custerrors.MapDBErr(err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("saved search not found: %w", custerrors.ErrNotFound)
+	}
+
+	return nil
+}
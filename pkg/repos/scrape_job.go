@@ -0,0 +1,231 @@
+package repos
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"hestia/pkg/custerrors"
+	"hestia/pkg/db"
+	"hestia/pkg/models"
+)
+
+// scrapeJobColumns are the columns read by scanScrapeJob, in the order it expects them.
+const scrapeJobColumns = `id, url, status, attempts, max_attempts, last_error, flat_id, requested_by,
+	run_after, created_at, updated_at, started_at, finished_at`
+
+func insertScrapeJob(qf queryFunc, j models.ScrapeJob) (string, error) {
+	q := db.Query{}
+	count := 0
+
+	q.Unsafe(`INSERT INTO scrape_jobs (url, status, attempts, max_attempts, requested_by,
+		run_after, created_at, updated_at) VALUES (`)
+	q.Params(&count, j.URL, j.Status, j.Attempts, j.MaxAttempts, nullString(j.RequestedBy),
+		j.RunAfter, j.CreatedAt, j.UpdatedAt)
+	q.Unsafe(`) RETURNING id`)
+
+	return insertReturningID(qf, &q)
+}
+
+func updateScrapeJob(ef execFunc, j models.ScrapeJob) error {
+	q := db.Query{}
+	count := 0
+
+	q.Unsafe(`UPDATE scrape_jobs SET (status, attempts, last_error, flat_id,
+		run_after, updated_at, started_at, finished_at) = (`)
+	q.Params(&count, j.Status, j.Attempts, nullString(j.LastError), nullString(j.FlatID),
+		j.RunAfter, j.UpdatedAt, j.StartedAt, j.FinishedAt)
+	q.Unsafe(`) WHERE id = `)
+	q.Param(&count, j.ID)
+
+	s, params, err := q.Get()
+	if err != nil {
+		return err
+	}
+
+	result, err := ef(s, params...)
+	if err != nil {
+		return custerrors.MapDBErr(err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return custerrors.MapDBErr(err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("scrape job not found: %w", custerrors.ErrNotFound)
+	}
+
+	return nil
+}
+
+func selectScrapeJob(qf queryFunc, id string) (models.ScrapeJob, error) {
+	q := db.Query{}
+	count := 0
+
+	q.Unsafe(`SELECT ` + scrapeJobColumns + ` FROM scrape_jobs WHERE id = `)
+	q.Param(&count, id)
+
+	return queryScrapeJob(qf, &q)
+}
+
+func selectScrapeJobs(qf queryFunc, f models.ScrapeJobFilter) ([]models.ScrapeJob, error) {
+	q := db.Query{}
+	count := 0
+
+	q.Unsafe(`SELECT ` + scrapeJobColumns + ` FROM scrape_jobs WHERE 1=1 `)
+
+	if len(f.URLs) > 0 {
+		q.Unsafe(`AND url IN (`)
+		q.Params(&count, anySlice(f.URLs)...)
+		q.Unsafe(`) `)
+	}
+
+	if len(f.Statuses) > 0 {
+		q.Unsafe(`AND status IN (`)
+		q.Params(&count, anySlice(f.Statuses)...)
+		q.Unsafe(`) `)
+	}
+
+	if f.RequestedBy != "" {
+		q.Unsafe(`AND requested_by = `)
+		q.Param(&count, f.RequestedBy)
+		q.Unsafe(` `)
+	}
+
+	q.Unsafe(`ORDER BY id ASC`)
+
+	s, params, err := q.Get()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := qf(s, params...)
+	if err != nil {
+		return nil, custerrors.MapDBErr(err)
+	}
+
+	defer rows.Close()
+
+	out := make([]models.ScrapeJob, 0)
+	for rows.Next() {
+		j, err := scanScrapeJob(rows)
+		if err != nil {
+			return nil, custerrors.MapDBErr(err)
+		}
+
+		out = append(out, j)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, custerrors.MapDBErr(err)
+	}
+
+	return out, nil
+}
+
+// claimScrapeJob marks the oldest queued job that is due at now as running
+// and returns it. Jobs locked by other workers are skipped. It returns
+// custerrors.ErrNotFound if no job is due.
+func claimScrapeJob(qf queryFunc, now time.Time) (models.ScrapeJob, error) {
+	q := db.Query{}
+	count := 0
+
+	q.Unsafe(`UPDATE scrape_jobs SET status = `)
+	q.Param(&count, models.ScrapeStatusRunning)
+	q.Unsafe(`, attempts = attempts + 1, started_at = `)
+	q.Param(&count, now)
+	q.Unsafe(`, updated_at = `)
+	q.Param(&count, now)
+	q.Unsafe(` WHERE id = (SELECT id FROM scrape_jobs WHERE status = `)
+	q.Param(&count, models.ScrapeStatusQueued)
+	q.Unsafe(` AND run_after <= `)
+	q.Param(&count, now)
+	q.Unsafe(` ORDER BY run_after, id LIMIT 1 FOR UPDATE SKIP LOCKED) RETURNING ` + scrapeJobColumns)
+
+	return queryScrapeJob(qf, &q)
+}
+
+// requeueScrapeJobs puts the jobs left running by a stopped process back in
+// the queue and returns how many there were.
+func requeueScrapeJobs(ef execFunc, now time.Time) (int64, error) {
+	q := db.Query{}
+	count := 0
+
+	q.Unsafe(`UPDATE scrape_jobs SET status = `)
+	q.Param(&count, models.ScrapeStatusQueued)
+	q.Unsafe(`, run_after = `)
+	q.Param(&count, now)
+	q.Unsafe(`, updated_at = `)
+	q.Param(&count, now)
+	q.Unsafe(` WHERE status = `)
+	q.Param(&count, models.ScrapeStatusRunning)
+
+	s, params, err := q.Get()
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := ef(s, params...)
+	if err != nil {
+		return 0, custerrors.MapDBErr(err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, custerrors.MapDBErr(err)
+	}
+
+	return rows, nil
+}
+
+func queryScrapeJob(qf queryFunc, q *db.Query) (models.ScrapeJob, error) {
+	s, params, err := q.Get()
+	if err != nil {
+		return models.ScrapeJob{}, err
+	}
+
+	rows, err := qf(s, params...)
+	if err != nil {
+		return models.ScrapeJob{}, custerrors.MapDBErr(err)
+	}
+
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return models.ScrapeJob{}, custerrors.MapDBErr(err)
+		}
+		return models.ScrapeJob{}, fmt.Errorf("scrape job not found: %w", custerrors.ErrNotFound)
+	}
+
+	j, err := scanScrapeJob(rows)
+	if err != nil {
+		return models.ScrapeJob{}, custerrors.MapDBErr(err)
+	}
+
+	return j, nil
+}
+
+func scanScrapeJob(rows *sql.Rows) (models.ScrapeJob, error) {
+	var (
+		j                            models.ScrapeJob
+		lastErr, flatID, requestedBy sql.NullString
+		startedAt, finishedAt        sql.NullTime
+	)
+
+	err := rows.Scan(&j.ID, &j.URL, &j.Status, &j.Attempts, &j.MaxAttempts, &lastErr, &flatID, &requestedBy,
+		&j.RunAfter, &j.CreatedAt, &j.UpdatedAt, &startedAt, &finishedAt)
+	if err != nil {
+		return models.ScrapeJob{}, err
+	}
+
+	j.LastError = lastErr.String
+	j.FlatID = flatID.String
+	j.RequestedBy = requestedBy.String
+	j.StartedAt = nullPtr(startedAt.Time, startedAt.Valid)
+	j.FinishedAt = nullPtr(finishedAt.Time, finishedAt.Valid)
+
+	return j, nil
+}
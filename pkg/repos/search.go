@@ -0,0 +1,52 @@
+package repos
+
+import (
+	"html"
+	"strings"
+	"unicode"
+)
+
+// flatRank ranks the flats matched by a full-text search. Matches in the
+// title weigh more than matches in the address, which weigh more than
+// matches in the description (see migration 000003).
+const flatRank = `ts_rank_cd(search_vector, tsq)`
+
+// markStart and markStop delimit the matching words in headlines until
+// highlight swaps them for tags. Private use characters cannot be confused
+// with markup in the scraped text.
+const (
+	markStart = "\uE000"
+	markStop  = "\uE001"
+)
+
+// flatHeadlines selects the highlighted title, address and description of a
+// matched flat. They must be passed through highlight.
+const flatHeadlines = `ts_headline('hestia_pl', title, tsq, 'StartSel=` + markStart + `, StopSel=` + markStop + `, HighlightAll=true'),
+	ts_headline('hestia_pl', address, tsq, 'StartSel=` + markStart + `, StopSel=` + markStop + `, HighlightAll=true'),
+	ts_headline('hestia_pl', description, tsq,
+		'StartSel=` + markStart + `, StopSel=` + markStop + `, MaxFragments=2, MaxWords=30, MinWords=10, FragmentDelimiter=" … "')`
+
+// highlighter turns the delimiters of a headline into <mark> tags.
+var highlighter = strings.NewReplacer(markStart, "<mark>", markStop, "</mark>")
+
+// highlight escapes the scraped text of a headline for HTML and only then
+// wraps the matching words in <mark> tags, so the result is safe to render.
+func highlight(headline string) string {
+	return highlighter.Replace(html.EscapeString(headline))
+}
+
+// searchQuery turns free text into a to_tsquery expression matching flats
+// that contain all of its words. Words match as prefixes, which catches most
+// Polish inflections ("balkon" matches "balkonem") when the server has no
+// Polish dictionary. It returns an empty string if s contains no words.
+func searchQuery(s string) string {
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	for i, w := range words {
+		words[i] = w + ":*"
+	}
+
+	return strings.Join(words, " & ")
+}
@@ -0,0 +1,50 @@
+package repos
+
+import (
+	"testing"
+)
+
+func Test_searchQuery(t *testing.T) {
+	tests := map[string]struct {
+		in   string
+		want string
+	}{
+		"ok, single word":       {in: "balkon", want: "balkon:*"},
+		"ok, several words":     {in: "Balkon  i garaż", want: "balkon:* & i:* & garaż:*"},
+		"ok, street and number": {in: "ul. Marszałkowska 10/2", want: "ul:* & marszałkowska:* & 10:* & 2:*"},
+		"ok, operators dropped": {in: "balkon & !(garaż | 'x'):*", want: "balkon:* & garaż:* & x:*"},
+		"ok, no words":          {in: " - & ", want: ""},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := searchQuery(tc.in)
+			if got != tc.want {
+				t.Errorf("got %q want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func Test_highlight(t *testing.T) {
+	tests := map[string]struct {
+		in   string
+		want string
+	}{
+		"ok, plain text":     {in: "Mieszkanie z balkonem", want: "Mieszkanie z balkonem"},
+		"ok, matching words": {in: "Mieszkanie z " + markStart + "balkonem" + markStop, want: "Mieszkanie z <mark>balkonem</mark>"},
+		"ok, markup escaped": {
+			in:   `<img src=x onerror="alert(1)"> ` + markStart + "balkon" + markStop + " & <mark>",
+			want: "&lt;img src=x onerror=&#34;alert(1)&#34;&gt; <mark>balkon</mark> &amp; &lt;mark&gt;",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := highlight(tc.in)
+			if got != tc.want {
+				t.Errorf("got %q want %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,315 @@
+package repos
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+
+	"hestia/pkg/custerrors"
+	"hestia/pkg/db"
+	"hestia/pkg/models"
+)
+
+// shortlistColumns are the columns read by scanShortlist, in the order it expects them.
+// They end with the flats on the list in order.
+const shortlistColumns = `id, workspace_id, user_id, name, created_at, updated_at,
+	ARRAY(SELECT sf.flat_id FROM shortlist_flats sf WHERE sf.shortlist_id = shortlists.id
+		ORDER BY sf.position, sf.added_at, sf.flat_id)`
+
+// insertShortlist inserts sl and returns the id of the new row.
+func insertShortlist(qf queryFunc, sl models.Shortlist) (string, error) {
+	q := db.Query{}
+	count := 0
+
+	q.Unsafe(`INSERT INTO shortlists (workspace_id, user_id, name, created_at, updated_at) VALUES (`)
+	q.Params(&count, sl.WorkspaceID, nullString(sl.UserID), sl.Name, sl.CreatedAt, sl.UpdatedAt)
+	q.Unsafe(`) RETURNING id`)
+
+	return insertReturningID(qf, &q)
+}
+
+// deleteShortlist deletes the shortlist of the workspace with the given id.
+func deleteShortlist(ef execFunc, workspaceID, id string) error {
+	q := db.Query{}
+	count := 0
+
+	q.Unsafe(`DELETE FROM shortlists WHERE workspace_id = `)
+	q.Param(&count, workspaceID)
+	q.Unsafe(` AND id = `)
+	q.Param(&count, id)
+
+	s, params, err := q.Get()
+	if err != nil {
+		return err
+	}
+
+	result, err := ef(s, params...)
+	if err != nil {
+		return custerrors.MapDBErr(err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return custerrors.MapDBErr(err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("shortlist not found: %w", custerrors.ErrNotFound)
+	}
+
+	return nil
+}
+
+// touchShortlist sets when the shortlist with the given id was last changed.
+func touchShortlist(ef execFunc, id string, at time.Time) error {
+	q := db.Query{}
+	count := 0
+
+	q.Unsafe(`UPDATE shortlists SET updated_at = `)
+	q.Param(&count, at)
+	q.Unsafe(` WHERE id = `)
+	q.Param(&count, id)
+
+	s, params, err := q.Get()
+	if err != nil {
+		return err
+	}
+
+	result, err := ef(s, params...)
+	if err != nil {
+		return custerrors.MapDBErr(err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return custerrors.MapDBErr(err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("shortlist not found: %w", custerrors.ErrNotFound)
+	}
+
+	return nil
+}
+
+func selectShortlist(qf queryFunc, workspaceID, id string) (models.Shortlist, error) {
+	lists, err := selectShortlists(qf, models.ShortlistFilter{WorkspaceID: workspaceID, IDs: []string{id}})
+	if err != nil {
+		return models.Shortlist{}, err
+	}
+
+	if len(lists) == 0 {
+		return models.Shortlist{}, fmt.Errorf("shortlist not found: %w", custerrors.ErrNotFound)
+	}
+
+	return lists[0], nil
+}
+
+// selectShortlists returns the shortlists matching f by name.
+func selectShortlists(qf queryFunc, f models.ShortlistFilter) ([]models.Shortlist, error) {
+	q := db.Query{}
+	count := 0
+
+	q.Unsafe(`SELECT ` + shortlistColumns + ` FROM shortlists WHERE workspace_id = `)
+	q.Param(&count, f.WorkspaceID)
+	q.Unsafe(` `)
+
+	if len(f.IDs) > 0 {
+		q.Unsafe(`AND id IN (`)
+		q.Params(&count, anySlice(f.IDs)...)
+		q.Unsafe(`) `)
+	}
+
+	q.Unsafe(`ORDER BY name ASC, id ASC`)
+
+	s, params, err := q.Get()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := qf(s, params...)
+	if err != nil {
+		return nil, custerrors.MapDBErr(err)
+	}
+
+	defer rows.Close()
+
+	out := make([]models.Shortlist, 0)
+	for rows.Next() {
+		sl, err := scanShortlist(rows)
+		if err != nil {
+			return nil, custerrors.MapDBErr(err)
+		}
+
+		out = append(out, sl)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, custerrors.MapDBErr(err)
+	}
+
+	return out, nil
+}
+
+func scanShortlist(rows *sql.Rows) (models.Shortlist, error) {
+	var (
+		sl     models.Shortlist
+		userID sql.NullString
+	)
+
+	err := rows.Scan(&sl.ID, &sl.WorkspaceID, &userID, &sl.Name, &sl.CreatedAt, &sl.UpdatedAt, pq.Array(&sl.FlatIDs))
+	if err != nil {
+		return models.Shortlist{}, err
+	}
+
+	sl.UserID = userID.String
+
+	if sl.FlatIDs == nil {
+		sl.FlatIDs = []string{}
+	}
+
+	return sl, nil
+}
+
+// insertShortlistFlat puts the flat at the end of the shortlist, unless it
+// is already on it.
+func insertShortlistFlat(ef execFunc, shortlistID, flatID string, at time.Time) error {
+	q := db.Query{}
+	count := 0
+
+	q.Unsafe(`INSERT INTO shortlist_flats (shortlist_id, flat_id, position, added_at) SELECT `)
+	q.Params(&count, shortlistID, flatID)
+	q.Unsafe(`, COALESCE(MAX(position) + 1, 0), `)
+	q.Param(&count, at)
+	q.Unsafe(` FROM shortlist_flats WHERE shortlist_id = `)
+	q.Param(&count, shortlistID)
+	q.Unsafe(` ON CONFLICT (shortlist_id, flat_id) DO NOTHING`)
+
+	s, params, err := q.Get()
+	if err != nil {
+		return err
+	}
+
+	_, err = ef(s, params...)
+	if err != nil {
+		return custerrors.MapDBErr(err)
+	}
+
+	return nil
+}
+
+func deleteShortlistFlat(ef execFunc, shortlistID, flatID string) error {
+	q := db.Query{}
+	count := 0
+
+	q.Unsafe(`DELETE FROM shortlist_flats WHERE shortlist_id = `)
+	q.Param(&count, shortlistID)
+	q.Unsafe(` AND flat_id = `)
+	q.Param(&count, flatID)
+
+	s, params, err := q.Get()
+	if err != nil {
+		return err
+	}
+
+	result, err := ef(s, params...)
+	if err != nil {
+		return custerrors.MapDBErr(err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return custerrors.MapDBErr(err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("flat not on shortlist: %w", custerrors.ErrNotFound)
+	}
+
+	return nil
+}
+
+// updateShortlistOrder moves the flats of the shortlist to their place in flatIDs.
+func updateShortlistOrder(ef execFunc, shortlistID string, flatIDs []string) error {
+	if len(flatIDs) == 0 {
+		return nil
+	}
+
+	q := db.Query{}
+	count := 0
+
+	q.Unsafe(`UPDATE shortlist_flats SET position = o.position FROM (VALUES `)
+	for i, id := range flatIDs {
+		if i > 0 {
+			q.Unsafe(`, `)
+		}
+		q.Unsafe(`(`)
+		q.Param(&count, id)
+		q.Unsafe(`::bigint, `)
+		q.Param(&count, i)
+		q.Unsafe(`::integer)`)
+	}
+	q.Unsafe(`) AS o (flat_id, position) WHERE shortlist_flats.flat_id = o.flat_id AND shortlist_flats.shortlist_id = `)
+	q.Param(&count, shortlistID)
+
+	s, params, err := q.Get()
+	if err != nil {
+		return err
+	}
+
+	_, err = ef(s, params...)
+	if err != nil {
+		return custerrors.MapDBErr(err)
+	}
+
+	return nil
+}
+
+// selectFlatShortlists returns the ids of the shortlists of the workspace
+// each of the flats is on, by flat id. Flats on no shortlist are left out.
+func selectFlatShortlists(qf queryFunc, workspaceID string, flatIDs []string) (map[string][]string, error) {
+	out := make(map[string][]string)
+	if len(flatIDs) == 0 {
+		return out, nil
+	}
+
+	q := db.Query{}
+	count := 0
+
+	q.Unsafe(`SELECT sf.flat_id, sf.shortlist_id FROM shortlist_flats sf
+		JOIN shortlists s ON s.id = sf.shortlist_id WHERE s.workspace_id = `)
+	q.Param(&count, workspaceID)
+	q.Unsafe(` AND sf.flat_id IN (`)
+	q.Params(&count, anySlice(flatIDs)...)
+	q.Unsafe(`) ORDER BY sf.flat_id, s.name, s.id`)
+
+	s, params, err := q.Get()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := qf(s, params...)
+	if err != nil {
+		return nil, custerrors.MapDBErr(err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var flatID, shortlistID string
+		err := rows.Scan(&flatID, &shortlistID)
+		if err != nil {
+			return nil, custerrors.MapDBErr(err)
+		}
+
+		out[flatID] = append(out[flatID], shortlistID)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, custerrors.MapDBErr(err)
+	}
+
+	return out, nil
+}
@@ -0,0 +1,223 @@
+package repos
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"hestia/pkg/custerrors"
+	"hestia/pkg/db"
+	"hestia/pkg/models"
+)
+
+// snapshotColumns are the columns read by scanSnapshot, in the order it expects them.
+const snapshotColumns = `id, url, final_url, status_code, content_type, size, blob_key, flat_id, parse_error,
+	requested_by, fetched_at, created_at`
+
+func insertSnapshot(qf queryFunc, s models.Snapshot) (string, error) {
+	q := db.Query{}
+	count := 0
+
+	q.Unsafe(`INSERT INTO page_snapshots (url, final_url, status_code, content_type, size, blob_key, flat_id,
+		parse_error, requested_by, fetched_at, created_at) VALUES (`)
+	q.Params(&count, s.URL, s.FinalURL, s.StatusCode, nullString(s.ContentType), s.Size, s.BlobKey,
+		nullString(s.FlatID), nullString(s.ParseError), nullString(s.RequestedBy), s.FetchedAt, s.CreatedAt)
+	q.Unsafe(`) RETURNING id`)
+
+	return insertReturningID(qf, &q)
+}
+
+// updateSnapshot updates the flat and parse error of a snapshot, the page itself never changes.
+func updateSnapshot(ef execFunc, s models.Snapshot) error {
+	q := db.Query{}
+	count := 0
+
+	q.Unsafe(`UPDATE page_snapshots SET (flat_id, parse_error) = (`)
+	q.Params(&count, nullString(s.FlatID), nullString(s.ParseError))
+	q.Unsafe(`) WHERE id = `)
+	q.Param(&count, s.ID)
+
+	query, params, err := q.Get()
+	if err != nil {
+		return err
+	}
+
+	result, err := ef(query, params...)
+	if err != nil {
+		return custerrors.MapDBErr(err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return custerrors.MapDBErr(err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("snapshot not found: %w", custerrors.ErrNotFound)
+	}
+
+	return nil
+}
+
+func selectSnapshot(qf queryFunc, id string) (models.Snapshot, error) {
+	q := db.Query{}
+	count := 0
+
+	q.Unsafe(`SELECT ` + snapshotColumns + ` FROM page_snapshots WHERE id = `)
+	q.Param(&count, id)
+
+	s, params, err := q.Get()
+	if err != nil {
+		return models.Snapshot{}, err
+	}
+
+	rows, err := qf(s, params...)
+	if err != nil {
+		return models.Snapshot{}, custerrors.MapDBErr(err)
+	}
+
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return models.Snapshot{}, custerrors.MapDBErr(err)
+		}
+		return models.Snapshot{}, fmt.Errorf("snapshot not found: %w", custerrors.ErrNotFound)
+	}
+
+	snap, err := scanSnapshot(rows)
+	if err != nil {
+		return models.Snapshot{}, custerrors.MapDBErr(err)
+	}
+
+	return snap, nil
+}
+
+// selectSnapshots returns the snapshots matching f, the latest first. With
+// f.LatestOnly the latest snapshot of each URL is returned, oldest URL first.
+func selectSnapshots(qf queryFunc, f models.SnapshotFilter) ([]models.Snapshot, error) {
+	q := db.Query{}
+	count := 0
+
+	if f.LatestOnly {
+		q.Unsafe(`SELECT ` + snapshotColumns + ` FROM (SELECT DISTINCT ON (url) ` + snapshotColumns)
+	} else {
+		q.Unsafe(`SELECT ` + snapshotColumns)
+	}
+	q.Unsafe(` FROM page_snapshots WHERE 1=1 `)
+
+	if len(f.URLs) > 0 {
+		q.Unsafe(`AND url IN (`)
+		q.Params(&count, anySlice(f.URLs)...)
+		q.Unsafe(`) `)
+	}
+
+	// Pages that failed to parse have no flat yet, they are found by the URL of the flat.
+	if len(f.FlatIDs) > 0 {
+		q.Unsafe(`AND (flat_id IN (`)
+		q.Params(&count, anySlice(f.FlatIDs)...)
+		q.Unsafe(`) OR url IN (SELECT source_url FROM flats WHERE id IN (`)
+		q.Params(&count, anySlice(f.FlatIDs)...)
+		q.Unsafe(`))) `)
+	}
+
+	if f.LatestOnly {
+		q.Unsafe(`ORDER BY url, fetched_at DESC, id DESC) latest ORDER BY id ASC`)
+	} else {
+		q.Unsafe(`ORDER BY fetched_at DESC, id DESC`)
+	}
+
+	if f.Limit > 0 {
+		q.Unsafe(` LIMIT `)
+		q.Param(&count, f.Limit)
+	}
+
+	s, params, err := q.Get()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := qf(s, params...)
+	if err != nil {
+		return nil, custerrors.MapDBErr(err)
+	}
+
+	defer rows.Close()
+
+	out := make([]models.Snapshot, 0)
+	for rows.Next() {
+		snap, err := scanSnapshot(rows)
+		if err != nil {
+			return nil, custerrors.MapDBErr(err)
+		}
+
+		out = append(out, snap)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, custerrors.MapDBErr(err)
+	}
+
+	return out, nil
+}
+
+// deleteOldSnapshots removes the snapshots fetched before that parsed fine
+// and have a newer snapshot of the same URL, and returns their blob keys.
+func deleteOldSnapshots(qf queryFunc, before time.Time) ([]string, error) {
+	q := db.Query{}
+	count := 0
+
+	q.Unsafe(`DELETE FROM page_snapshots old WHERE fetched_at < `)
+	q.Param(&count, before)
+	q.Unsafe(` AND parse_error IS NULL AND EXISTS (SELECT 1 FROM page_snapshots n WHERE n.url = old.url
+		AND n.fetched_at > old.fetched_at) RETURNING blob_key`)
+
+	s, params, err := q.Get()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := qf(s, params...)
+	if err != nil {
+		return nil, custerrors.MapDBErr(err)
+	}
+
+	defer rows.Close()
+
+	keys := make([]string, 0)
+	for rows.Next() {
+		var key string
+		err := rows.Scan(&key)
+		if err != nil {
+			return nil, custerrors.MapDBErr(err)
+		}
+
+		keys = append(keys, key)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, custerrors.MapDBErr(err)
+	}
+
+	return keys, nil
+}
+
+func scanSnapshot(rows *sql.Rows) (models.Snapshot, error) {
+	var (
+		s                                          models.Snapshot
+		contentType, flatID, parseErr, requestedBy sql.NullString
+	)
+
+	err := rows.Scan(&s.ID, &s.URL, &s.FinalURL, &s.StatusCode, &contentType, &s.Size, &s.BlobKey, &flatID,
+		&parseErr, &requestedBy, &s.FetchedAt, &s.CreatedAt)
+	if err != nil {
+		return models.Snapshot{}, err
+	}
+
+	s.ContentType = contentType.String
+	s.FlatID = flatID.String
+	s.ParseError = parseErr.String
+	s.RequestedBy = requestedBy.String
+
+	return s, nil
+}
@@ -3,7 +3,10 @@ package repos
 import (
 	"context"
 	"database/sql"
+	"time"
 
+	"hestia/pkg/custerrors"
+	"hestia/pkg/db"
 	"hestia/pkg/models"
 )
 
@@ -36,6 +39,20 @@ func (s *Store) FindUsers(ctx context.Context, filter models.UserFilter) ([]mode
 	}, filter)
 }
 
+// PageUsers returns a page of the users matching filter.
+func (s *Store) PageUsers(ctx context.Context, filter models.UserFilter, page models.PageRequest) (models.Page[models.User], error) {
+	return pageUsers(func(query string, params ...any) (*sql.Rows, error) {
+		return s.db.QueryContext(ctx, query, params...)
+	}, filter, page)
+}
+
+// PageFlats returns a page of the flats matching filter.
+func (s *Store) PageFlats(ctx context.Context, filter models.FlatFilter, page models.PageRequest) (models.Page[models.Flat], error) {
+	return pageFlats(func(query string, params ...any) (*sql.Rows, error) {
+		return s.db.QueryContext(ctx, query, params...)
+	}, filter, page)
+}
+
 func (s *Store) FindFlats(ctx context.Context, filter models.FlatFilter) ([]models.Flat, error) {
 	return selectFlats(func(query string, params ...any) (*sql.Rows, error) {
 		return s.db.QueryContext(ctx, query, params...)
@@ -48,6 +65,249 @@ func (s *Store) GetFlatByID(ctx context.Context, id string) (models.Flat, error)
 	}, id)
 }
 
+// GetFlatHistory returns the price history and field changes of a flat.
+func (s *Store) GetFlatHistory(ctx context.Context, flatID string) (models.FlatHistory, error) {
+	return selectFlatHistory(func(query string, params ...any) (*sql.Rows, error) {
+		return s.db.QueryContext(ctx, query, params...)
+	}, flatID)
+}
+
+// FindFlatsToRefresh returns up to limit flats whose listing was not checked since checkedBefore.
+func (s *Store) FindFlatsToRefresh(ctx context.Context, checkedBefore time.Time, limit int) ([]models.Flat, error) {
+	return selectFlatsToRefresh(func(query string, params ...any) (*sql.Rows, error) {
+		return s.db.QueryContext(ctx, query, params...)
+	}, checkedBefore, limit)
+}
+
+// FindPhotos returns the photos of a flat in gallery order.
+func (s *Store) FindPhotos(ctx context.Context, flatID string) ([]models.Photo, error) {
+	return selectPhotos(func(query string, params ...any) (*sql.Rows, error) {
+		return s.db.QueryContext(ctx, query, params...)
+	}, flatID)
+}
+
+// GetPhoto returns the photo with the given id of a flat.
+func (s *Store) GetPhoto(ctx context.Context, flatID, id string) (models.Photo, error) {
+	return selectPhoto(func(query string, params ...any) (*sql.Rows, error) {
+		return s.db.QueryContext(ctx, query, params...)
+	}, flatID, id)
+}
+
+// ClaimPhoto marks the next due photo as downloading and returns it.
+// It returns custerrors.ErrNotFound if no photo is due at now.
+func (s *Store) ClaimPhoto(ctx context.Context, now time.Time) (models.Photo, error) {
+	return claimPhoto(func(query string, params ...any) (*sql.Rows, error) {
+		return s.db.QueryContext(ctx, query, params...)
+	}, now)
+}
+
+// RequeuePhotos queues the photos that were downloading when the process stopped again.
+func (s *Store) RequeuePhotos(ctx context.Context, now time.Time) (int64, error) {
+	return requeuePhotos(func(query string, params ...any) (sql.Result, error) {
+		return s.db.ExecContext(ctx, query, params...)
+	}, now)
+}
+
+// FindSnapshots returns the archived pages matching filter.
+func (s *Store) FindSnapshots(ctx context.Context, filter models.SnapshotFilter) ([]models.Snapshot, error) {
+	return selectSnapshots(func(query string, params ...any) (*sql.Rows, error) {
+		return s.db.QueryContext(ctx, query, params...)
+	}, filter)
+}
+
+// GetSnapshot returns the archived page with the given id.
+func (s *Store) GetSnapshot(ctx context.Context, id string) (models.Snapshot, error) {
+	return selectSnapshot(func(query string, params ...any) (*sql.Rows, error) {
+		return s.db.QueryContext(ctx, query, params...)
+	}, id)
+}
+
+// PruneSnapshots removes the archived pages fetched before that were
+// replaced by a newer page and parsed fine, and returns their blob keys.
+func (s *Store) PruneSnapshots(ctx context.Context, before time.Time) ([]string, error) {
+	return deleteOldSnapshots(func(query string, params ...any) (*sql.Rows, error) {
+		return s.db.QueryContext(ctx, query, params...)
+	}, before)
+}
+
+// GetDistrictStats returns the statistics of the districts of city, or of
+// all cities if it is empty. Prices are taken in currency.
+func (s *Store) GetDistrictStats(ctx context.Context, city, currency string) ([]models.DistrictStats, error) {
+	return selectDistrictStats(func(query string, params ...any) (*sql.Rows, error) {
+		return s.db.QueryContext(ctx, query, params...)
+	}, city, currency)
+}
+
+// FindUnrecognizedAddresses returns the most common addresses the district dictionary does not cover.
+func (s *Store) FindUnrecognizedAddresses(ctx context.Context, limit int) ([]models.UnrecognizedAddress, error) {
+	return selectUnrecognizedAddresses(func(query string, params ...any) (*sql.Rows, error) {
+		return s.db.QueryContext(ctx, query, params...)
+	}, limit)
+}
+
+// FindParserRules returns the extraction rule sets matching filter.
+func (s *Store) FindParserRules(ctx context.Context, filter models.ParserRuleFilter) ([]models.ParserRuleSet, error) {
+	return selectParserRules(func(query string, params ...any) (*sql.Rows, error) {
+		return s.db.QueryContext(ctx, query, params...)
+	}, filter)
+}
+
+// GetParserRules returns the extraction rule set with the given id.
+func (s *Store) GetParserRules(ctx context.Context, id string) (models.ParserRuleSet, error) {
+	return selectParserRuleSet(func(query string, params ...any) (*sql.Rows, error) {
+		return s.db.QueryContext(ctx, query, params...)
+	}, id)
+}
+
+// FindShortlists returns the shortlists matching filter.
+func (s *Store) FindShortlists(ctx context.Context, filter models.ShortlistFilter) ([]models.Shortlist, error) {
+	return selectShortlists(func(query string, params ...any) (*sql.Rows, error) {
+		return s.db.QueryContext(ctx, query, params...)
+	}, filter)
+}
+
+// GetShortlist returns the shortlist of the workspace with the given id.
+func (s *Store) GetShortlist(ctx context.Context, workspaceID, id string) (models.Shortlist, error) {
+	return selectShortlist(func(query string, params ...any) (*sql.Rows, error) {
+		return s.db.QueryContext(ctx, query, params...)
+	}, workspaceID, id)
+}
+
+// FindFlatShortlists returns the ids of the shortlists of the workspace each of the flats is on.
+func (s *Store) FindFlatShortlists(ctx context.Context, workspaceID string, flatIDs []string) (map[string][]string, error) {
+	return selectFlatShortlists(func(query string, params ...any) (*sql.Rows, error) {
+		return s.db.QueryContext(ctx, query, params...)
+	}, workspaceID, flatIDs)
+}
+
+// FindSavedSearches returns the saved searches matching filter.
+func (s *Store) FindSavedSearches(ctx context.Context, filter models.SavedSearchFilter) ([]models.SavedSearch, error) {
+	return selectSavedSearches(func(query string, params ...any) (*sql.Rows, error) {
+		return s.db.QueryContext(ctx, query, params...)
+	}, filter)
+}
+
+// GetSavedSearch returns the saved search of the user with the given id.
+func (s *Store) GetSavedSearch(ctx context.Context, userID, id string) (models.SavedSearch, error) {
+	return selectSavedSearch(func(query string, params ...any) (*sql.Rows, error) {
+		return s.db.QueryContext(ctx, query, params...)
+	}, userID, id)
+}
+
+// FindFlatStatuses returns the statuses of flats matching filter.
+func (s *Store) FindFlatStatuses(ctx context.Context, filter models.FlatStatusFilter) ([]models.FlatStatus, error) {
+	return selectFlatStatuses(func(query string, params ...any) (*sql.Rows, error) {
+		return s.db.QueryContext(ctx, query, params...)
+	}, filter)
+}
+
+// GetFlatStatus returns the status of the flat for the workspace with its transitions.
+func (s *Store) GetFlatStatus(ctx context.Context, workspaceID, flatID string) (models.FlatStatus, error) {
+	qf := func(query string, params ...any) (*sql.Rows, error) {
+		return s.db.QueryContext(ctx, query, params...)
+	}
+
+	st, err := selectFlatStatus(qf, workspaceID, flatID)
+	if err != nil {
+		return models.FlatStatus{}, err
+	}
+
+	st.Transitions, err = selectStageTransitions(qf, workspaceID, flatID)
+	if err != nil {
+		return models.FlatStatus{}, err
+	}
+
+	return st, nil
+}
+
+// GetFlatNote returns the note of the user on the flat.
+func (s *Store) GetFlatNote(ctx context.Context, userID, flatID string) (models.FlatNote, error) {
+	return selectFlatNote(func(query string, params ...any) (*sql.Rows, error) {
+		return s.db.QueryContext(ctx, query, params...)
+	}, userID, flatID)
+}
+
+// FindComments returns the comments on the flat in the workspace, oldest first.
+func (s *Store) FindComments(ctx context.Context, workspaceID, flatID string) ([]models.Comment, error) {
+	return selectComments(func(query string, params ...any) (*sql.Rows, error) {
+		return s.db.QueryContext(ctx, query, params...)
+	}, workspaceID, flatID)
+}
+
+// GetComment returns the comment on the flat in the workspace with the given
+// id and, unless it is deleted, its revisions.
+func (s *Store) GetComment(ctx context.Context, workspaceID, flatID, id string) (models.Comment, error) {
+	qf := func(query string, params ...any) (*sql.Rows, error) {
+		return s.db.QueryContext(ctx, query, params...)
+	}
+
+	c, err := selectComment(qf, workspaceID, flatID, id)
+	if err != nil {
+		return models.Comment{}, err
+	}
+
+	if c.DeletedAt != nil {
+		return c, nil
+	}
+
+	c.Revisions, err = selectCommentRevisions(qf, id)
+	if err != nil {
+		return models.Comment{}, err
+	}
+
+	return c, nil
+}
+
+// GetWorkspace returns the workspace with the given id and its members.
+func (s *Store) GetWorkspace(ctx context.Context, id string) (models.Workspace, error) {
+	return selectWorkspace(func(query string, params ...any) (*sql.Rows, error) {
+		return s.db.QueryContext(ctx, query, params...)
+	}, id)
+}
+
+// GetMemberWorkspaceID returns the id of the workspace of the user.
+func (s *Store) GetMemberWorkspaceID(ctx context.Context, userID string) (string, error) {
+	return selectMemberWorkspaceID(func(query string, params ...any) (*sql.Rows, error) {
+		return s.db.QueryContext(ctx, query, params...)
+	}, userID)
+}
+
+// FindFlatWorkspaces returns the ids of the workspaces holding the flat.
+func (s *Store) FindFlatWorkspaces(ctx context.Context, flatID string) ([]string, error) {
+	return selectFlatWorkspaces(func(query string, params ...any) (*sql.Rows, error) {
+		return s.db.QueryContext(ctx, query, params...)
+	}, flatID)
+}
+
+// GetScrapeJobByID returns the scrape job with the given id.
+func (s *Store) GetScrapeJobByID(ctx context.Context, id string) (models.ScrapeJob, error) {
+	return selectScrapeJob(func(query string, params ...any) (*sql.Rows, error) {
+		return s.db.QueryContext(ctx, query, params...)
+	}, id)
+}
+
+// FindScrapeJobs returns the scrape jobs matching filter.
+func (s *Store) FindScrapeJobs(ctx context.Context, filter models.ScrapeJobFilter) ([]models.ScrapeJob, error) {
+	return selectScrapeJobs(func(query string, params ...any) (*sql.Rows, error) {
+		return s.db.QueryContext(ctx, query, params...)
+	}, filter)
+}
+
+// ClaimScrapeJob marks the next due scrape job as running and returns it.
+// It returns custerrors.ErrNotFound if no job is due at now.
+func (s *Store) ClaimScrapeJob(ctx context.Context, now time.Time) (models.ScrapeJob, error) {
+	return claimScrapeJob(func(query string, params ...any) (*sql.Rows, error) {
+		return s.db.QueryContext(ctx, query, params...)
+	}, now)
+}
+
+// RequeueScrapeJobs queues the jobs that were running when the process stopped again.
+func (s *Store) RequeueScrapeJobs(ctx context.Context, now time.Time) (int64, error) {
+	return requeueScrapeJobs(func(query string, params ...any) (sql.Result, error) {
+		return s.db.ExecContext(ctx, query, params...)
+	}, now)
+}
+
 type execFunc func(query string, params ...any) (sql.Result, error)
 type queryFunc func(query string, params ...any) (*sql.Rows, error)
 
@@ -58,3 +318,41 @@ func anySlice[T any](s []T) []any {
 	}
 	return out
 }
+
+// insertReturningID runs an INSERT ... RETURNING id query and returns the id.
+func insertReturningID(qf queryFunc, q *db.Query) (string, error) {
+	s, params, err := q.Get()
+	if err != nil {
+		return "", err
+	}
+
+	rows, err := qf(s, params...)
+	if err != nil {
+		return "", custerrors.MapDBErr(err)
+	}
+
+	defer rows.Close()
+
+	var id string
+	if rows.Next() {
+		err := rows.Scan(&id)
+		if err != nil {
+			return "", custerrors.MapDBErr(err)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return "", custerrors.MapDBErr(err)
+	}
+
+	return id, nil
+}
+
+// nullString maps an empty string to NULL.
+func nullString(s string) any {
+	if s == "" {
+		return nil
+	}
+
+	return s
+}
@@ -191,6 +191,16 @@ func Test_Tx_FindUser(t *testing.T) {
 				return []models.User{users[1]}
 			},
 		},
+		"ok, one by email in another case": {
+			filter: models.UserFilter{
+				Emails: []string{
+					"Test@TEST.com",
+				},
+			},
+			wantFunc: func(users []models.User) []models.User {
+				return []models.User{users[1]}
+			},
+		},
 		"ok, several by email": {
 			filter: models.UserFilter{
 				Emails: []string{
@@ -2,6 +2,7 @@ package repos
 
 import (
 	"database/sql"
+	"time"
 
 	"hestia/pkg/models"
 )
@@ -51,9 +52,28 @@ func (t *Tx) UpdateEmailToken(tok models.EmailToken) error {
 	return updateEmailToken(t.tx.Exec, tok)
 }
 
-// CreateFlat creates a flat in the database.
-func (t *Tx) CreateFlat(f models.Flat) error {
-	return insertFlat(t.tx.Exec, f)
+// GetEmailToken returns the email token with the given id.
+func (t *Tx) GetEmailToken(id string) (models.EmailToken, error) {
+	return selectEmailToken(t.tx.Query, id)
+}
+
+// CreateFlat creates a flat in the database and returns its id.
+func (t *Tx) CreateFlat(f models.Flat) (string, error) {
+	return insertFlat(t.tx.Query, f)
+}
+
+// FindFlats returns the flats matching filter.
+func (t *Tx) FindFlats(filter models.FlatFilter) ([]models.Flat, error) {
+	return selectFlats(func(query string, params ...any) (*sql.Rows, error) {
+		return t.tx.Query(query, params...)
+	}, filter)
+}
+
+// GetFlatByID returns the flat with the given id.
+func (t *Tx) GetFlatByID(id string) (models.Flat, error) {
+	return selectFlat(func(query string, params ...any) (*sql.Rows, error) {
+		return t.tx.Query(query, params...)
+	}, id)
 }
 
 // UpdateFlat updates a flat in the database.
@@ -61,7 +81,269 @@ func (t *Tx) UpdateFlat(u models.Flat) error {
 	return updateFlat(t.tx.Exec, u)
 }
 
+// UpdateFlatLocation sets the address parts of a flat.
+func (t *Tx) UpdateFlatLocation(id string, l models.Location) error {
+	return updateFlatLocation(t.tx.Exec, id, l)
+}
+
 // DeleteFlat delete users based on the id.
 func (t *Tx) DeleteFlat(id string) error {
 	return deleteFlat(t.tx.Exec, id)
 }
+
+// CreateFlatChanges records changes of flats in the database.
+func (t *Tx) CreateFlatChanges(changes []models.FlatChange) error {
+	return insertFlatChanges(t.tx.Exec, changes)
+}
+
+// CreatePricePoints records prices of flats in the database.
+func (t *Tx) CreatePricePoints(points []models.PricePoint) error {
+	return insertPricePoints(t.tx.Exec, points)
+}
+
+// CreatePhotos adds photos to flats, skipping the ones a flat already has.
+func (t *Tx) CreatePhotos(photos []models.Photo) error {
+	return insertPhotos(t.tx.Exec, photos)
+}
+
+// FindPhotos returns the photos of a flat.
+func (t *Tx) FindPhotos(flatID string) ([]models.Photo, error) {
+	return selectPhotos(func(query string, params ...any) (*sql.Rows, error) {
+		return t.tx.Query(query, params...)
+	}, flatID)
+}
+
+// UpdatePhoto updates a photo in the database.
+func (t *Tx) UpdatePhoto(p models.Photo) error {
+	return updatePhoto(t.tx.Exec, p)
+}
+
+// CreateSnapshot adds a page to the archive and returns its id.
+func (t *Tx) CreateSnapshot(s models.Snapshot) (string, error) {
+	return insertSnapshot(t.tx.Query, s)
+}
+
+// UpdateSnapshot updates the flat and parse error of an archived page.
+func (t *Tx) UpdateSnapshot(s models.Snapshot) error {
+	return updateSnapshot(t.tx.Exec, s)
+}
+
+// CreateParserRules stores a rule set as the next version of its portal and returns its id.
+func (t *Tx) CreateParserRules(rs models.ParserRuleSet) (string, error) {
+	return insertParserRules(t.tx.Query, rs)
+}
+
+// GetParserRules returns the extraction rule set with the given id.
+func (t *Tx) GetParserRules(id string) (models.ParserRuleSet, error) {
+	return selectParserRuleSet(t.tx.Query, id)
+}
+
+// PublishParserRules makes a rule set the active version of its portal.
+func (t *Tx) PublishParserRules(id string, at time.Time) error {
+	return publishParserRules(t.tx.Exec, id, at)
+}
+
+// DeleteParserRules deletes a rule set from the database.
+func (t *Tx) DeleteParserRules(id string) error {
+	return deleteParserRules(t.tx.Exec, id)
+}
+
+// CreateShortlist creates a shortlist in the database and returns its id.
+func (t *Tx) CreateShortlist(sl models.Shortlist) (string, error) {
+	return insertShortlist(t.tx.Query, sl)
+}
+
+// GetShortlist returns the shortlist of the workspace with the given id.
+func (t *Tx) GetShortlist(workspaceID, id string) (models.Shortlist, error) {
+	return selectShortlist(t.tx.Query, workspaceID, id)
+}
+
+// DeleteShortlist deletes a shortlist of the workspace from the database.
+func (t *Tx) DeleteShortlist(workspaceID, id string) error {
+	return deleteShortlist(t.tx.Exec, workspaceID, id)
+}
+
+// TouchShortlist sets when a shortlist was last changed.
+func (t *Tx) TouchShortlist(id string, at time.Time) error {
+	return touchShortlist(t.tx.Exec, id, at)
+}
+
+// AddShortlistFlat puts a flat at the end of a shortlist.
+func (t *Tx) AddShortlistFlat(shortlistID, flatID string, at time.Time) error {
+	return insertShortlistFlat(t.tx.Exec, shortlistID, flatID, at)
+}
+
+// RemoveShortlistFlat takes a flat off a shortlist.
+func (t *Tx) RemoveShortlistFlat(shortlistID, flatID string) error {
+	return deleteShortlistFlat(t.tx.Exec, shortlistID, flatID)
+}
+
+// ReorderShortlist puts the flats of a shortlist in the order of flatIDs.
+func (t *Tx) ReorderShortlist(shortlistID string, flatIDs []string) error {
+	return updateShortlistOrder(t.tx.Exec, shortlistID, flatIDs)
+}
+
+// CreateSavedSearch creates a saved search in the database and returns its id.
+func (t *Tx) CreateSavedSearch(ss models.SavedSearch) (string, error) {
+	return insertSavedSearch(t.tx.Query, ss)
+}
+
+// GetSavedSearch returns the saved search of the user with the given id.
+func (t *Tx) GetSavedSearch(userID, id string) (models.SavedSearch, error) {
+	return selectSavedSearch(t.tx.Query, userID, id)
+}
+
+// UpdateSavedSearch updates a saved search in the database.
+func (t *Tx) UpdateSavedSearch(ss models.SavedSearch) error {
+	return updateSavedSearch(t.tx.Exec, ss)
+}
+
+// DeleteSavedSearch deletes a saved search of the user from the database.
+func (t *Tx) DeleteSavedSearch(userID, id string) error {
+	return deleteSavedSearch(t.tx.Exec, userID, id)
+}
+
+// UpdateSavedSearchChecked records until when the flats were matched against a saved search.
+func (t *Tx) UpdateSavedSearchChecked(id string, at time.Time) error {
+	return updateSavedSearchChecked(t.tx.Exec, id, at)
+}
+
+// CreateSearchAlerts records flats as matches of a saved search, unless they were matched before.
+func (t *Tx) CreateSearchAlerts(searchID string, flatIDs []string, at time.Time) error {
+	return insertSearchAlerts(t.tx.Exec, searchID, flatIDs, at)
+}
+
+// FindPendingAlerts returns the ids of the flats matched by a saved search that were not sent yet.
+func (t *Tx) FindPendingAlerts(searchID string) ([]string, error) {
+	return selectPendingAlerts(t.tx.Query, searchID)
+}
+
+// MarkAlertsSent marks matches of a saved search as sent.
+func (t *Tx) MarkAlertsSent(searchID string, flatIDs []string, at time.Time) error {
+	return updateAlertsSent(t.tx.Exec, searchID, flatIDs, at)
+}
+
+// GetFlatStatus returns the status of the flat for the workspace.
+func (t *Tx) GetFlatStatus(workspaceID, flatID string) (models.FlatStatus, error) {
+	return selectFlatStatus(t.tx.Query, workspaceID, flatID)
+}
+
+// SaveFlatStatus stores the stage of a flat for its workspace.
+func (t *Tx) SaveFlatStatus(st models.FlatStatus) error {
+	return upsertFlatStatus(t.tx.Exec, st)
+}
+
+// DeleteFlatStatus takes a flat out of the pipeline of the workspace.
+func (t *Tx) DeleteFlatStatus(workspaceID, flatID string) error {
+	return deleteFlatStatus(t.tx.Exec, workspaceID, flatID)
+}
+
+// CreateStageTransition records a move of a flat of the workspace between stages.
+func (t *Tx) CreateStageTransition(workspaceID, flatID string, st models.StageTransition) error {
+	return insertStageTransition(t.tx.Exec, workspaceID, flatID, st)
+}
+
+// SaveFlatNote stores the note of a user on a flat.
+func (t *Tx) SaveFlatNote(n models.FlatNote) error {
+	return upsertFlatNote(t.tx.Exec, n)
+}
+
+// DeleteFlatNote deletes the note of the user on the flat.
+func (t *Tx) DeleteFlatNote(userID, flatID string) error {
+	return deleteFlatNote(t.tx.Exec, userID, flatID)
+}
+
+// CreateComment creates a comment in the database and returns its id.
+func (t *Tx) CreateComment(c models.Comment) (string, error) {
+	return insertComment(t.tx.Query, c)
+}
+
+// GetComment returns the comment on the flat in the workspace with the given id.
+func (t *Tx) GetComment(workspaceID, flatID, id string) (models.Comment, error) {
+	return selectComment(t.tx.Query, workspaceID, flatID, id)
+}
+
+// UpdateComment updates the body and mentions of a comment.
+func (t *Tx) UpdateComment(c models.Comment) error {
+	return updateComment(t.tx.Exec, c)
+}
+
+// DeleteComment marks the comment with the given id deleted.
+func (t *Tx) DeleteComment(id string, at time.Time) error {
+	return softDeleteComment(t.tx.Exec, id, at)
+}
+
+// CreateCommentRevision keeps a body a comment had before an edit.
+func (t *Tx) CreateCommentRevision(commentID string, rev models.CommentRevision) error {
+	return insertCommentRevision(t.tx.Exec, commentID, rev)
+}
+
+// CreateWorkspace creates a workspace in the database and returns its id.
+func (t *Tx) CreateWorkspace(ws models.Workspace) (string, error) {
+	return insertWorkspace(t.tx.Query, ws)
+}
+
+// GetWorkspace returns the workspace with the given id and its members.
+func (t *Tx) GetWorkspace(id string) (models.Workspace, error) {
+	return selectWorkspace(t.tx.Query, id)
+}
+
+// UpdateWorkspace updates the name of a workspace.
+func (t *Tx) UpdateWorkspace(ws models.Workspace) error {
+	return updateWorkspace(t.tx.Exec, ws)
+}
+
+// DeleteWorkspace deletes the workspace with the given id.
+func (t *Tx) DeleteWorkspace(id string) error {
+	return deleteWorkspace(t.tx.Exec, id)
+}
+
+// GetMemberWorkspaceID returns the id of the workspace of the user.
+func (t *Tx) GetMemberWorkspaceID(userID string) (string, error) {
+	return selectMemberWorkspaceID(t.tx.Query, userID)
+}
+
+// AddWorkspaceMember adds a user to the workspace.
+func (t *Tx) AddWorkspaceMember(workspaceID string, m models.WorkspaceMember) error {
+	return insertWorkspaceMember(t.tx.Exec, workspaceID, m)
+}
+
+// UpdateWorkspaceMember changes the role of a member of the workspace.
+func (t *Tx) UpdateWorkspaceMember(workspaceID string, m models.WorkspaceMember) error {
+	return updateWorkspaceMember(t.tx.Exec, workspaceID, m)
+}
+
+// RemoveWorkspaceMember takes the user out of the workspace.
+func (t *Tx) RemoveWorkspaceMember(workspaceID, userID string) error {
+	return deleteWorkspaceMember(t.tx.Exec, workspaceID, userID)
+}
+
+// AddWorkspaceFlat adds a flat to the workspace.
+func (t *Tx) AddWorkspaceFlat(workspaceID, flatID, addedBy string, at time.Time) error {
+	return insertWorkspaceFlat(t.tx.Exec, workspaceID, flatID, addedBy, at)
+}
+
+// CopyWorkspaceFlats adds the flats of one workspace to another.
+func (t *Tx) CopyWorkspaceFlats(fromID, toID string) error {
+	return copyWorkspaceFlats(t.tx.Exec, fromID, toID)
+}
+
+// RemoveWorkspaceFlat takes a flat out of the workspace.
+func (t *Tx) RemoveWorkspaceFlat(workspaceID, flatID string) error {
+	return deleteWorkspaceFlat(t.tx.Exec, workspaceID, flatID)
+}
+
+// FindFlatWorkspaces returns the ids of the workspaces holding the flat.
+func (t *Tx) FindFlatWorkspaces(flatID string) ([]string, error) {
+	return selectFlatWorkspaces(t.tx.Query, flatID)
+}
+
+// CreateScrapeJob creates a scrape job in the database and returns its id.
+func (t *Tx) CreateScrapeJob(j models.ScrapeJob) (string, error) {
+	return insertScrapeJob(t.tx.Query, j)
+}
+
+// UpdateScrapeJob updates a scrape job in the database.
+func (t *Tx) UpdateScrapeJob(j models.ScrapeJob) error {
+	return updateScrapeJob(t.tx.Exec, j)
+}
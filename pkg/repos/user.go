@@ -2,6 +2,7 @@ package repos
 
 import (
 	"fmt"
+	"strings"
 
 	"hestia/pkg/custerrors"
 	"hestia/pkg/db"
@@ -88,22 +89,7 @@ func selectUsers(qf queryFunc, f models.UserFilter) ([]models.User, error) {
 	count := 0
 	q.Unsafe(`SELECT id, email, password_hash, role, is_active, created_at, updated_at FROM users WHERE 1=1 `)
 
-	if len(f.IDs) > 0 {
-		q.Unsafe(`AND id IN (`)
-		q.Params(&count, anySlice(f.IDs)...)
-		q.Unsafe(`)`)
-	}
-
-	if len(f.Emails) > 0 {
-		q.Unsafe(`AND email IN (`)
-		q.Params(&count, anySlice(f.Emails)...)
-		q.Unsafe(`)`)
-	}
-
-	if f.IsActive != false {
-		q.Unsafe("AND is_active = ")
-		q.Param(&count, f.IsActive)
-	}
+	userConditions(&q, &count, f)
 
 	q.Unsafe(` ORDER BY id ASC`)
 
@@ -137,6 +123,130 @@ func selectUsers(qf queryFunc, f models.UserFilter) ([]models.User, error) {
 	return out, nil
 }
 
+// userSortKeys are the keys user lists can be sorted by.
+var userSortKeys = map[string]sortKey{
+	"id":         {column: "id", kind: sortInt},
+	"email":      {column: "email"},
+	"created_at": {column: "created_at", kind: sortTimestamp},
+}
+
+const defaultUserSort = "id"
+
+func pageUsers(qf queryFunc, f models.UserFilter, p models.PageRequest) (models.Page[models.User], error) {
+	spec, err := resolveSort(userSortKeys, p.Sort, defaultUserSort)
+	if err != nil {
+		return models.Page[models.User]{}, err
+	}
+
+	var after *cursor
+	if p.Cursor != "" {
+		c, err := decodeCursor(p.Cursor, spec)
+		if err != nil {
+			return models.Page[models.User]{}, err
+		}
+		after = &c
+	}
+
+	cq := db.Query{}
+	count := 0
+	cq.Unsafe(`SELECT COUNT(*) FROM users WHERE 1=1 `)
+	userConditions(&cq, &count, f)
+
+	total, err := countRows(qf, &cq)
+	if err != nil {
+		return models.Page[models.User]{}, err
+	}
+
+	limit := pageLimit(p.Limit)
+
+	q := db.Query{}
+	count = 0
+	q.Unsafe(`SELECT id, email, password_hash, role, is_active, created_at, updated_at, (` + spec.expr + `)::text FROM users WHERE 1=1 `)
+	userConditions(&q, &count, f)
+	if after != nil {
+		q.Unsafe(` `)
+		spec.after(&q, &count, *after)
+	}
+	q.Unsafe(` `)
+	spec.orderBy(&q)
+	writeLimit(&q, limit)
+
+	s, params, err := q.Get()
+	if err != nil {
+		return models.Page[models.User]{}, err
+	}
+
+	rows, err := qf(s, params...)
+	if err != nil {
+		return models.Page[models.User]{}, custerrors.MapDBErr(err)
+	}
+
+	defer rows.Close()
+
+	page := models.Page[models.User]{
+		Items: make([]models.User, 0, limit),
+		Total: total,
+	}
+
+	var last cursor
+	for rows.Next() {
+		if len(page.Items) == limit {
+			page.NextCursor = encodeCursor(last)
+			break
+		}
+
+		var (
+			u         models.User
+			sortValue string
+		)
+		err := rows.Scan(&u.ID, &u.Email, &u.PasswordHash, &u.Role, &u.IsActive, &u.CreatedAt, &u.UpdatedAt, &sortValue)
+		if err != nil {
+			return models.Page[models.User]{}, custerrors.MapDBErr(err)
+		}
+
+		page.Items = append(page.Items, u)
+		last = cursor{Sort: spec.name, Value: sortValue, ID: u.ID}
+	}
+
+	if err := rows.Err(); err != nil {
+		return models.Page[models.User]{}, custerrors.MapDBErr(err)
+	}
+
+	return page, nil
+}
+
+// userConditions writes the conditions of f, each starting with AND.
+func userConditions(q *db.Query, count *int, f models.UserFilter) {
+	if len(f.IDs) > 0 {
+		q.Unsafe(`AND id IN (`)
+		q.Params(count, anySlice(f.IDs)...)
+		q.Unsafe(`)`)
+	}
+
+	if len(f.Emails) > 0 {
+		// Emails are stored as they were typed, compare them ignoring case.
+		emails := make([]string, 0, len(f.Emails))
+		for _, e := range f.Emails {
+			emails = append(emails, strings.ToLower(e))
+		}
+
+		q.Unsafe(`AND lower(email) IN (`)
+		q.Params(count, anySlice(emails)...)
+		q.Unsafe(`)`)
+	}
+
+	if len(f.Roles) > 0 {
+		q.Unsafe(`AND role IN (`)
+		q.Params(count, anySlice(f.Roles)...)
+		q.Unsafe(`)`)
+	}
+
+	if f.IsActive != false {
+		q.Unsafe("AND is_active = ")
+		q.Param(count, f.IsActive)
+	}
+}
+
 func deleteUser(ef execFunc, id string) error {
 	q := db.Query{}
 	count := 0
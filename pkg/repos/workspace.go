@@ -0,0 +1,406 @@
+package repos
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"hestia/pkg/custerrors"
+	"hestia/pkg/db"
+	"hestia/pkg/models"
+)
+
+// insertWorkspace inserts ws and returns the id of the new row.
+func insertWorkspace(qf queryFunc, ws models.Workspace) (string, error) {
+	q := db.Query{}
+	count := 0
+
+	q.Unsafe(`INSERT INTO workspaces (name, created_at, updated_at) VALUES (`)
+	q.Params(&count, ws.Name, ws.CreatedAt, ws.UpdatedAt)
+	q.Unsafe(`) RETURNING id`)
+
+	return insertReturningID(qf, &q)
+}
+
+// updateWorkspace updates the name of the workspace.
+func updateWorkspace(ef execFunc, ws models.Workspace) error {
+	q := db.Query{}
+	count := 0
+
+	q.Unsafe(`UPDATE workspaces SET (name, updated_at) = (`)
+	q.Params(&count, ws.Name, ws.UpdatedAt)
+	q.Unsafe(`) WHERE id = `)
+	q.Param(&count, ws.ID)
+
+	s, params, err := q.Get()
+	if err != nil {
+		return err
+	}
+
+	result, err := ef(s, params...)
+	if err != nil {
+		return custerrors.MapDBErr(err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return custerrors.MapDBErr(err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("workspace not found: %w", custerrors.ErrNotFound)
+	}
+
+	return nil
+}
+
+// deleteWorkspace deletes the workspace with everything its members shared in it.
+func deleteWorkspace(ef execFunc, id string) error {
+	q := db.Query{}
+	count := 0
+
+	q.Unsafe(`DELETE FROM workspaces WHERE id = `)
+	q.Param(&count, id)
+
+	s, params, err := q.Get()
+	if err != nil {
+		return err
+	}
+
+	result, err := ef(s, params...)
+	if err != nil {
+		return custerrors.MapDBErr(err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return custerrors.MapDBErr(err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("workspace not found: %w", custerrors.ErrNotFound)
+	}
+
+	return nil
+}
+
+// selectWorkspace returns the workspace with its members, the owner first.
+func selectWorkspace(qf queryFunc, id string) (models.Workspace, error) {
+	q := db.Query{}
+	count := 0
+
+	q.Unsafe(`SELECT id, name, created_at, updated_at FROM workspaces WHERE id = `)
+	q.Param(&count, id)
+
+	s, params, err := q.Get()
+	if err != nil {
+		return models.Workspace{}, err
+	}
+
+	rows, err := qf(s, params...)
+	if err != nil {
+		return models.Workspace{}, custerrors.MapDBErr(err)
+	}
+
+	// The rows are closed before the members are read, as a transaction
+	// runs a single query at a time.
+	var ws models.Workspace
+	found := rows.Next()
+	if found {
+		err = rows.Scan(&ws.ID, &ws.Name, &ws.CreatedAt, &ws.UpdatedAt)
+	}
+	if err == nil {
+		err = rows.Err()
+	}
+	rows.Close()
+	if err != nil {
+		return models.Workspace{}, custerrors.MapDBErr(err)
+	}
+
+	if !found {
+		return models.Workspace{}, fmt.Errorf("workspace not found: %w", custerrors.ErrNotFound)
+	}
+
+	ws.Members, err = selectWorkspaceMembers(qf, id)
+	if err != nil {
+		return models.Workspace{}, err
+	}
+
+	return ws, nil
+}
+
+// selectWorkspaceMembers returns the members of the workspace, the owner
+// first and the others in the order they joined.
+func selectWorkspaceMembers(qf queryFunc, workspaceID string) ([]models.WorkspaceMember, error) {
+	q := db.Query{}
+	count := 0
+
+	q.Unsafe(`SELECT m.user_id, u.email, m.role, m.joined_at FROM workspace_members m
+		JOIN users u ON u.id = m.user_id WHERE m.workspace_id = `)
+	q.Param(&count, workspaceID)
+	q.Unsafe(` ORDER BY m.role = `)
+	q.Param(&count, string(models.WorkspaceRoleOwner))
+	q.Unsafe(` DESC, m.joined_at ASC, m.user_id ASC`)
+
+	s, params, err := q.Get()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := qf(s, params...)
+	if err != nil {
+		return nil, custerrors.MapDBErr(err)
+	}
+
+	defer rows.Close()
+
+	out := make([]models.WorkspaceMember, 0)
+	for rows.Next() {
+		var (
+			m    models.WorkspaceMember
+			role string
+		)
+		err := rows.Scan(&m.UserID, &m.Email, &role, &m.JoinedAt)
+		if err != nil {
+			return nil, custerrors.MapDBErr(err)
+		}
+
+		m.Role = models.WorkspaceRole(role)
+		out = append(out, m)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, custerrors.MapDBErr(err)
+	}
+
+	return out, nil
+}
+
+// selectMemberWorkspaceID returns the id of the workspace of the user.
+func selectMemberWorkspaceID(qf queryFunc, userID string) (string, error) {
+	q := db.Query{}
+	count := 0
+
+	q.Unsafe(`SELECT workspace_id FROM workspace_members WHERE user_id = `)
+	q.Param(&count, userID)
+
+	ids, err := selectIDs(qf, &q)
+	if err != nil {
+		return "", err
+	}
+
+	if len(ids) == 0 {
+		return "", fmt.Errorf("workspace of user not found: %w", custerrors.ErrNotFound)
+	}
+
+	return ids[0], nil
+}
+
+// insertWorkspaceMember adds the user to the workspace. It fails with
+// custerrors.ErrConstraintViolated if the user is in a workspace already.
+func insertWorkspaceMember(ef execFunc, workspaceID string, m models.WorkspaceMember) error {
+	q := db.Query{}
+	count := 0
+
+	q.Unsafe(`INSERT INTO workspace_members (workspace_id, user_id, role, joined_at) VALUES (`)
+	q.Params(&count, workspaceID, m.UserID, string(m.Role), m.JoinedAt)
+	q.Unsafe(`)`)
+
+	s, params, err := q.Get()
+	if err != nil {
+		return err
+	}
+
+	_, err = ef(s, params...)
+	if err != nil {
+		return custerrors.MapDBErr(err)
+	}
+
+	return nil
+}
+
+// updateWorkspaceMember changes the role of the member of the workspace.
+func updateWorkspaceMember(ef execFunc, workspaceID string, m models.WorkspaceMember) error {
+	q := db.Query{}
+	count := 0
+
+	q.Unsafe(`UPDATE workspace_members SET role = `)
+	q.Param(&count, string(m.Role))
+	q.Unsafe(` WHERE workspace_id = `)
+	q.Param(&count, workspaceID)
+	q.Unsafe(` AND user_id = `)
+	q.Param(&count, m.UserID)
+
+	s, params, err := q.Get()
+	if err != nil {
+		return err
+	}
+
+	result, err := ef(s, params...)
+	if err != nil {
+		return custerrors.MapDBErr(err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return custerrors.MapDBErr(err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("workspace member not found: %w", custerrors.ErrNotFound)
+	}
+
+	return nil
+}
+
+func deleteWorkspaceMember(ef execFunc, workspaceID, userID string) error {
+	q := db.Query{}
+	count := 0
+
+	q.Unsafe(`DELETE FROM workspace_members WHERE workspace_id = `)
+	q.Param(&count, workspaceID)
+	q.Unsafe(` AND user_id = `)
+	q.Param(&count, userID)
+
+	s, params, err := q.Get()
+	if err != nil {
+		return err
+	}
+
+	result, err := ef(s, params...)
+	if err != nil {
+		return custerrors.MapDBErr(err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return custerrors.MapDBErr(err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("workspace member not found: %w", custerrors.ErrNotFound)
+	}
+
+	return nil
+}
+
+// insertWorkspaceFlat adds the flat to the workspace, unless it holds it already.
+func insertWorkspaceFlat(ef execFunc, workspaceID, flatID, addedBy string, at time.Time) error {
+	q := db.Query{}
+	count := 0
+
+	q.Unsafe(`INSERT INTO workspace_flats (workspace_id, flat_id, added_by, added_at) VALUES (`)
+	q.Params(&count, workspaceID, flatID, nullString(addedBy), at)
+	q.Unsafe(`) ON CONFLICT (workspace_id, flat_id) DO NOTHING`)
+
+	s, params, err := q.Get()
+	if err != nil {
+		return err
+	}
+
+	_, err = ef(s, params...)
+	if err != nil {
+		return custerrors.MapDBErr(err)
+	}
+
+	return nil
+}
+
+// copyWorkspaceFlats adds the flats of one workspace to another.
+func copyWorkspaceFlats(ef execFunc, fromID, toID string) error {
+	q := db.Query{}
+	count := 0
+
+	q.Unsafe(`INSERT INTO workspace_flats (workspace_id, flat_id, added_by, added_at) SELECT `)
+	q.Param(&count, toID)
+	q.Unsafe(`, flat_id, added_by, added_at FROM workspace_flats WHERE workspace_id = `)
+	q.Param(&count, fromID)
+	q.Unsafe(` ON CONFLICT (workspace_id, flat_id) DO NOTHING`)
+
+	s, params, err := q.Get()
+	if err != nil {
+		return err
+	}
+
+	_, err = ef(s, params...)
+	if err != nil {
+		return custerrors.MapDBErr(err)
+	}
+
+	return nil
+}
+
+func deleteWorkspaceFlat(ef execFunc, workspaceID, flatID string) error {
+	q := db.Query{}
+	count := 0
+
+	q.Unsafe(`DELETE FROM workspace_flats WHERE workspace_id = `)
+	q.Param(&count, workspaceID)
+	q.Unsafe(` AND flat_id = `)
+	q.Param(&count, flatID)
+
+	s, params, err := q.Get()
+	if err != nil {
+		return err
+	}
+
+	result, err := ef(s, params...)
+	if err != nil {
+		return custerrors.MapDBErr(err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return custerrors.MapDBErr(err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("flat not found: %w", custerrors.ErrNotFound)
+	}
+
+	return nil
+}
+
+// selectFlatWorkspaces returns the ids of the workspaces holding the flat.
+func selectFlatWorkspaces(qf queryFunc, flatID string) ([]string, error) {
+	q := db.Query{}
+	count := 0
+
+	q.Unsafe(`SELECT workspace_id FROM workspace_flats WHERE flat_id = `)
+	q.Param(&count, flatID)
+	q.Unsafe(` ORDER BY workspace_id`)
+
+	return selectIDs(qf, &q)
+}
+
+// selectIDs runs a query reading a single id column.
+func selectIDs(qf queryFunc, q *db.Query) ([]string, error) {
+	s, params, err := q.Get()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := qf(s, params...)
+	if err != nil {
+		return nil, custerrors.MapDBErr(err)
+	}
+
+	defer rows.Close()
+
+	out := make([]string, 0)
+	for rows.Next() {
+		var id sql.NullString
+		err := rows.Scan(&id)
+		if err != nil {
+			return nil, custerrors.MapDBErr(err)
+		}
+
+		out = append(out, id.String)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, custerrors.MapDBErr(err)
+	}
+
+	return out, nil
+}
@@ -0,0 +1,168 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"sync"
+
+	"hestia/pkg/models"
+	"hestia/pkg/repos"
+	"hestia/pkg/utils/normalize"
+)
+
+// AddressService reports on the addresses of the flats split with the
+// district dictionary: statistics by district and the addresses the
+// dictionary does not cover yet.
+type AddressService struct {
+	rep        *repos.Store
+	wg         *sync.WaitGroup
+	errHandler ErrFunc
+}
+
+// NewAddressService creates a new Service.
+func NewAddressService(db *sql.DB, errHandler ErrFunc) *AddressService {
+	svc := &AddressService{
+		rep:        repos.New(db),
+		wg:         &sync.WaitGroup{},
+		errHandler: errHandler,
+	}
+
+	return svc
+}
+
+// Districts writes the statistics of each district, of a single city if the
+// city query parameter is set. Prices are in normalize.DefaultCurrency.
+func (s *AddressService) Districts(w http.ResponseWriter, r *http.Request) error {
+	city := r.URL.Query().Get("city")
+	if name, ok := normalize.Districts().City(city); ok {
+		city = name
+	}
+
+	stats, err := s.rep.GetDistrictStats(r.Context(), city, normalize.DefaultCurrency)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	return s.write(w, stats)
+}
+
+// Unrecognized writes the addresses the district dictionary does not cover,
+// the most common first, up to the limit query parameter.
+func (s *AddressService) Unrecognized(w http.ResponseWriter, r *http.Request) error {
+	page, err := parsePageRequest(r.URL.Query())
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	addrs, err := s.rep.FindUnrecognizedAddresses(r.Context(), page.Limit)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	return s.write(w, addrs)
+}
+
+// Reparse splits the addresses of all flats again, after the district
+// dictionary was curated, and responds with the report.
+func (s *AddressService) Reparse(w http.ResponseWriter, r *http.Request) error {
+	report, err := s.ReparseAll(r.Context())
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	return s.write(w, report)
+}
+
+// ReparseAll splits the addresses of all flats with the current district
+// dictionary and stores the parts that changed.
+func (s *AddressService) ReparseAll(ctx context.Context) (models.AddressReparseReport, error) {
+	var report models.AddressReparseReport
+
+	flats, err := s.rep.FindFlats(ctx, models.FlatFilter{})
+	if err != nil {
+		return report, err
+	}
+
+	err = s.inTx(ctx, func(tx models.Tx) error {
+		for _, f := range flats {
+			report.Flats++
+
+			loc := normalize.Districts().ParseAddress(f.Address)
+			if f.Address != "" && !loc.Recognized() {
+				report.Unrecognized++
+			}
+			if sameLocation(loc, f.Location) {
+				continue
+			}
+
+			err := tx.UpdateFlatLocation(f.ID, loc)
+			if err != nil {
+				return err
+			}
+			report.Updated++
+		}
+
+		return nil
+	})
+	if err != nil {
+		return models.AddressReparseReport{}, err
+	}
+
+	return report, nil
+}
+
+// write writes v as the JSON response.
+func (s *AddressService) write(w http.ResponseWriter, v any) error {
+	j, err := json.Marshal(v)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(j)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	return nil
+}
+
+func (s *AddressService) inTx(ctx context.Context, f func(tx models.Tx) error) error {
+	tx, err := s.rep.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = f(tx)
+	if err != nil {
+		rBackErr := tx.Rollback()
+		if rBackErr != nil {
+			err = errors.Join(err, rBackErr)
+		}
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// sameLocation reports whether a and b are equal, an empty list of
+// unmatched parts being equal to none.
+func sameLocation(a, b models.Location) bool {
+	return a.City == b.City && a.District == b.District && a.SubDistrict == b.SubDistrict &&
+		a.Street == b.Street && a.Region == b.Region && slices.Equal(a.Unmatched, b.Unmatched)
+}
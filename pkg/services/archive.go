@@ -0,0 +1,512 @@
+package services
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"hestia/pkg/blobs"
+	"hestia/pkg/models"
+	"hestia/pkg/repos"
+	"hestia/pkg/utils/normalize"
+	"hestia/pkg/utils/parsers"
+)
+
+// ArchiveConfig is the configuration for the page archive.
+type ArchiveConfig struct {
+	// Retention is how long a page is kept once a newer page of the same
+	// listing was archived. Pages that failed to parse are always kept.
+	// Zero keeps all pages.
+	Retention time.Duration
+	// PruneInterval is how often pages past their retention are removed.
+	PruneInterval time.Duration
+	// ReparseLimit is the default and upper limit of pages re-parsed at once.
+	ReparseLimit int
+	// Timeout bounds a re-parse started over HTTP.
+	Timeout time.Duration
+}
+
+// ArchiveService keeps every fetched listing page, compressed in the blob
+// store, and runs the current parsers over archived pages again.
+type ArchiveService struct {
+	rep        *repos.Store
+	wg         *sync.WaitGroup
+	blobs      blobs.Store
+	collector  *parsers.Collector
+	cfg        ArchiveConfig
+	errHandler ErrFunc
+
+	// NowFunc is used to get the current time.
+	// Exposed for testing purposes.
+	NowFunc func() time.Time
+}
+
+// NewArchiveService creates a new Service.
+func NewArchiveService(db *sql.DB, store blobs.Store, collector *parsers.Collector, cfg ArchiveConfig, errHandler ErrFunc) *ArchiveService {
+	svc := &ArchiveService{
+		rep:        repos.New(db),
+		wg:         &sync.WaitGroup{},
+		blobs:      store,
+		collector:  collector,
+		cfg:        cfg,
+		errHandler: errHandler,
+
+		NowFunc: time.Now,
+	}
+
+	return svc
+}
+
+// Save archives page together with the flat scraped from it or the error
+// that kept it from being scraped. requestedBy is the user the page was
+// fetched for, if any, whose workspace gets the flat if the page parses
+// later. A nil page, for listings that could not be fetched, is not archived.
+func (s *ArchiveService) Save(ctx context.Context, page *parsers.Page, flatID, requestedBy string, parseErr error) error {
+	if page == nil {
+		return nil
+	}
+
+	snap := models.Snapshot{
+		URL:         page.URL,
+		FinalURL:    page.FinalURL,
+		StatusCode:  page.StatusCode,
+		ContentType: page.ContentType,
+		Size:        int64(len(page.Body)),
+		BlobKey:     snapshotKey(page.URL, page.FetchedAt),
+		FlatID:      flatID,
+		RequestedBy: requestedBy,
+		FetchedAt:   page.FetchedAt,
+		CreatedAt:   s.NowFunc(),
+	}
+	if parseErr != nil {
+		snap.ParseError = parseErr.Error()
+	}
+
+	data, err := compress(page.Body)
+	if err != nil {
+		return err
+	}
+
+	err = s.blobs.Put(ctx, snap.BlobKey, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to store page %s: %w", page.URL, err)
+	}
+
+	err = s.inTx(ctx, func(tx models.Tx) error {
+		_, err := tx.CreateSnapshot(snap)
+		return err
+	})
+	if err != nil {
+		// Do not leave the page behind without a record of it.
+		return errors.Join(err, s.blobs.Delete(ctx, snap.BlobKey))
+	}
+
+	return nil
+}
+
+// record archives the page of a scrape and reports failures to the error
+// handler. Listings that are gone were not parsed, so their error is not kept.
+func (s *ArchiveService) record(ctx context.Context, page *parsers.Page, flatID, requestedBy string, scrapeErr error) {
+	if errors.Is(scrapeErr, parsers.ErrListingGone) {
+		scrapeErr = nil
+	}
+
+	err := s.Save(ctx, page, flatID, requestedBy, scrapeErr)
+	if err != nil {
+		s.errHandler(fmt.Errorf("failed to archive page: %w", err))
+	}
+}
+
+// Load returns the archived page of snap.
+func (s *ArchiveService) Load(ctx context.Context, snap models.Snapshot) (parsers.Page, error) {
+	blob, err := s.blobs.Get(ctx, snap.BlobKey)
+	if err != nil {
+		return parsers.Page{}, err
+	}
+
+	defer blob.Close()
+
+	body, err := decompress(blob)
+	if err != nil {
+		return parsers.Page{}, fmt.Errorf("failed to read snapshot %s: %w", snap.ID, err)
+	}
+
+	return parsers.Page{
+		URL:         snap.URL,
+		FinalURL:    snap.FinalURL,
+		StatusCode:  snap.StatusCode,
+		ContentType: snap.ContentType,
+		Body:        body,
+		FetchedAt:   snap.FetchedAt,
+	}, nil
+}
+
+// List writes the archived pages of the flat, the latest first.
+func (s *ArchiveService) List(w http.ResponseWriter, r *http.Request) error {
+	flatID := r.PathValue("id")
+	_, err := s.rep.GetFlatByID(r.Context(), flatID)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	snaps, err := s.rep.FindSnapshots(r.Context(), models.SnapshotFilter{FlatIDs: []string{flatID}})
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	j, err := json.Marshal(snaps)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(j)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	return nil
+}
+
+// Get writes the archived page as a download, to reproduce parses offline.
+// It is not served as HTML so that scripts of the portal do not run on our origin.
+func (s *ArchiveService) Get(w http.ResponseWriter, r *http.Request) error {
+	snap, err := s.rep.GetSnapshot(r.Context(), r.PathValue("id"))
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	page, err := s.Load(r.Context(), snap)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="snapshot-%s.html"`, snap.ID))
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(page.Body)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	return nil
+}
+
+// Reparse re-parses the archived pages selected by the request body and
+// responds with the report. Like a crawl it may outlast the server's write
+// timeout, so the deadline is extended to the re-parse timeout.
+func (s *ArchiveService) Reparse(w http.ResponseWriter, r *http.Request) error {
+	var req models.ReparseRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	err = http.NewResponseController(w).SetWriteDeadline(time.Now().Add(s.cfg.Timeout + time.Second*5))
+	if err != nil && !errors.Is(err, http.ErrNotSupported) {
+		s.errHandler(err)
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), s.cfg.Timeout)
+	defer cancel()
+
+	report, err := s.ReparseAll(ctx, req)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	j, err := json.Marshal(report)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(j)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	return nil
+}
+
+// ReparseAll runs the current parsers over the latest archived page of each
+// selected listing and, unless req.DryRun is set, applies the changed fields
+// to the flats. Listings that failed to parse before get their flat now if
+// the page parses. The report is filled in as far as the re-parse got, also
+// when it returns an error.
+func (s *ArchiveService) ReparseAll(ctx context.Context, req models.ReparseRequest) (models.ReparseReport, error) {
+	report := models.ReparseReport{
+		DryRun:  req.DryRun,
+		Results: make([]models.ReparsedPage, 0),
+	}
+
+	snaps, err := s.rep.FindSnapshots(ctx, models.SnapshotFilter{
+		URLs:       req.URLs,
+		FlatIDs:    req.FlatIDs,
+		LatestOnly: true,
+		Limit:      limit(req.Limit, s.cfg.ReparseLimit),
+	})
+	if err != nil {
+		return report, err
+	}
+
+	for _, snap := range snaps {
+		err := ctx.Err()
+		if err != nil {
+			return report, err
+		}
+
+		res := s.reparse(ctx, snap, req.DryRun)
+		report.Pages++
+		switch {
+		case res.Error != "":
+			report.Failed++
+		case len(res.Changes) > 0:
+			report.Changed++
+		}
+		report.Results = append(report.Results, res)
+	}
+
+	return report, nil
+}
+
+// reparse runs the current parser over the archived page of snap and
+// stores the outcome unless dryRun is set.
+func (s *ArchiveService) reparse(ctx context.Context, snap models.Snapshot, dryRun bool) models.ReparsedPage {
+	out := models.ReparsedPage{
+		SnapshotID: snap.ID,
+		URL:        snap.URL,
+		FlatID:     snap.FlatID,
+		Changes:    make([]models.FlatChange, 0),
+	}
+
+	page, err := s.Load(ctx, snap)
+	if err != nil {
+		out.Error = err.Error()
+		return out
+	}
+
+	res, parseErr := s.collector.ParsePage(page)
+	if parseErr != nil {
+		out.Error = parseErr.Error()
+	}
+
+	now := s.NowFunc()
+	err = s.inTx(ctx, func(tx models.Tx) error {
+		return s.storeReparse(tx, &snap, res, parseErr, dryRun, now, &out)
+	})
+	if err != nil {
+		out.Error = err.Error()
+		return out
+	}
+
+	out.FlatID = snap.FlatID
+	for i := range out.Changes {
+		out.Changes[i].FlatID = snap.FlatID
+	}
+
+	return out
+}
+
+// storeReparse stores the flat in res, or parseErr, as the outcome of parsing
+// the page of snap again and records the changes to the flat in out. A flat
+// that is new is added to the workspace of the user the page was fetched
+// for. Nothing is stored if dryRun is set.
+func (s *ArchiveService) storeReparse(tx models.Tx, snap *models.Snapshot, res parsers.Result, parseErr error, dryRun bool, now time.Time, out *models.ReparsedPage) error {
+	switch {
+	case errors.Is(parseErr, parsers.ErrListingGone):
+		// Not a parser failure, the page is kept like any other.
+		return nil
+	case parseErr != nil:
+		snap.ParseError = parseErr.Error()
+		return s.finishReparse(tx, *snap, dryRun)
+	}
+
+	flat := res.Flat
+	normalize.Flat(&flat, now)
+	flat.SourceURL = snap.URL
+
+	existing, err := tx.FindFlats(models.FlatFilter{SourceURLs: []string{snap.URL}})
+	if err != nil {
+		return err
+	}
+
+	if len(existing) == 0 {
+		out.Changes = diffFlat(models.Flat{}, flat, now)
+		if dryRun {
+			return nil
+		}
+
+		flat.CreatedAt = now
+		flat.UpdatedAt = now
+		snap.FlatID, err = saveScrapedFlat(tx, flat, now)
+		if err != nil {
+			return err
+		}
+
+		// Flats outside of every workspace cannot be reached by anyone.
+		err = addRequestedFlat(tx, snap.RequestedBy, snap.FlatID, now)
+		if err != nil {
+			return err
+		}
+	} else {
+		stored := existing[0]
+		snap.FlatID = stored.ID
+		out.Changes = diffFlat(stored, flat, now)
+		if dryRun {
+			return nil
+		}
+
+		if len(out.Changes) > 0 {
+			applyChanges(&stored, out.Changes)
+			normalize.Flat(&stored, now)
+			stored.UpdatedAt = now
+
+			err = tx.UpdateFlat(stored)
+			if err != nil {
+				return err
+			}
+
+			err = saveFlatChanges(tx, stored, out.Changes, now)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	err = tx.CreatePhotos(newPhotos(snap.FlatID, res.Photos, now))
+	if err != nil {
+		return err
+	}
+
+	snap.ParseError = ""
+	return s.finishReparse(tx, *snap, dryRun)
+}
+
+// finishReparse records the flat and parse error of snap, unless dryRun is set.
+func (s *ArchiveService) finishReparse(tx models.Tx, snap models.Snapshot, dryRun bool) error {
+	if dryRun {
+		return nil
+	}
+
+	return tx.UpdateSnapshot(snap)
+}
+
+// Run removes pages past their retention every prune interval until ctx is
+// done. It returns right away if pages are kept forever.
+func (s *ArchiveService) Run(ctx context.Context) error {
+	if s.cfg.Retention <= 0 {
+		return nil
+	}
+
+	for {
+		err := s.prune(ctx)
+		if err != nil && ctx.Err() == nil {
+			s.errHandler(fmt.Errorf("failed to prune page archive: %w", err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(s.cfg.PruneInterval):
+		}
+	}
+}
+
+// prune removes the pages past their retention and their blobs.
+func (s *ArchiveService) prune(ctx context.Context) error {
+	keys, err := s.rep.PruneSnapshots(ctx, s.NowFunc().Add(-s.cfg.Retention))
+	if err != nil {
+		return err
+	}
+
+	var errs error
+	for _, key := range keys {
+		err := s.blobs.Delete(ctx, key)
+		if err != nil {
+			errs = errors.Join(errs, fmt.Errorf("failed to delete blob %s: %w", key, err))
+		}
+	}
+
+	return errs
+}
+
+// snapshotKey returns the blob key of the page of the listing at url fetched at fetchedAt.
+func snapshotKey(url string, fetchedAt time.Time) string {
+	sum := sha256.Sum256([]byte(url))
+
+	return fmt.Sprintf("pages/%s/%s.html.gz", hex.EncodeToString(sum[:8]), fetchedAt.UTC().Format("20060102T150405.000000000Z"))
+}
+
+func compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+
+	_, err := zw.Write(data)
+	if err != nil {
+		return nil, err
+	}
+
+	err = zw.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func decompress(r io.Reader) ([]byte, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	defer zr.Close()
+
+	return io.ReadAll(zr)
+}
+
+func (s *ArchiveService) inTx(ctx context.Context, f func(tx models.Tx) error) error {
+	tx, err := s.rep.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = f(tx)
+	if err != nil {
+		rBackErr := tx.Rollback()
+		if rBackErr != nil {
+			err = errors.Join(err, rBackErr)
+		}
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
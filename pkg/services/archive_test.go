@@ -0,0 +1,180 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"hestia/pkg/blobs"
+	"hestia/pkg/custerrors"
+	"hestia/pkg/models"
+	"hestia/pkg/utils/parsers"
+)
+
+func Test_snapshotKey(t *testing.T) {
+	fetched := time.Date(2026, 10, 17, 12, 30, 5, 123, time.FixedZone("CEST", 2*60*60))
+
+	got := snapshotKey("https://www.olx.pl/d/oferta/mieszkanie-ID1.html", fetched)
+	again := snapshotKey("https://www.olx.pl/d/oferta/mieszkanie-ID1.html", fetched.Add(time.Nanosecond))
+	other := snapshotKey("https://www.olx.pl/d/oferta/mieszkanie-ID2.html", fetched)
+
+	if want := "pages/"; got[:len(want)] != want {
+		t.Errorf("got %s want prefix %s", got, want)
+	}
+	if want := "/20261017T103005.000000123Z.html.gz"; got[len(got)-len(want):] != want {
+		t.Errorf("got %s want suffix %s", got, want)
+	}
+	if got == again || got[:23] != again[:23] {
+		t.Errorf("expected pages of one listing to share the directory and differ by time, got %s and %s", got, again)
+	}
+	if got[:23] == other[:23] {
+		t.Errorf("expected pages of different listings in different directories, got %s and %s", got, other)
+	}
+}
+
+func Test_ArchiveService_Load(t *testing.T) {
+	store, err := blobs.NewFS(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create blob store: %v", err)
+	}
+
+	body := []byte("<html><body><h1>Mieszkanie</h1></body></html>")
+	data, err := compress(body)
+	if err != nil {
+		t.Fatalf("failed to compress: %v", err)
+	}
+	err = store.Put(context.Background(), "pages/ok.html.gz", bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("failed to store page: %v", err)
+	}
+	err = store.Put(context.Background(), "pages/corrupt.html.gz", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("failed to store page: %v", err)
+	}
+
+	s := &ArchiveService{blobs: store}
+
+	tests := map[string]struct {
+		key     string
+		want    []byte
+		wantErr error
+		anyErr  bool
+	}{
+		"ok, decompressed":   {key: "pages/ok.html.gz", want: body},
+		"fail, missing blob": {key: "pages/missing.html.gz", wantErr: custerrors.ErrNotFound},
+		"fail, not gzip":     {key: "pages/corrupt.html.gz", anyErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			page, err := s.Load(context.Background(), models.Snapshot{ID: "1", URL: "https://olx.pl/1", BlobKey: tc.key})
+			if tc.anyErr {
+				if err == nil {
+					t.Fatalf("expected an error")
+				}
+				return
+			}
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("expected errors to be %v got %v (via errors.Is)", tc.wantErr, err)
+			}
+
+			if !reflect.DeepEqual(page.Body, tc.want) {
+				t.Errorf("got %q want %q", page.Body, tc.want)
+			}
+		})
+	}
+}
+
+// reparseTx stores nothing and records the flats added to workspaces.
+type reparseTx struct {
+	models.Tx
+	existing   []models.Flat
+	workspaces map[string]string
+	added      []string
+}
+
+func (tx *reparseTx) FindFlats(f models.FlatFilter) ([]models.Flat, error) {
+	if len(f.SourceURLs) > 0 {
+		return tx.existing, nil
+	}
+
+	return nil, nil
+}
+
+func (tx *reparseTx) CreateFlat(models.Flat) (string, error)      { return "42", nil }
+func (tx *reparseTx) UpdateFlat(models.Flat) error                { return nil }
+func (tx *reparseTx) CreateFlatChanges([]models.FlatChange) error { return nil }
+func (tx *reparseTx) CreatePricePoints([]models.PricePoint) error { return nil }
+func (tx *reparseTx) CreatePhotos([]models.Photo) error           { return nil }
+func (tx *reparseTx) UpdateSnapshot(models.Snapshot) error        { return nil }
+func (tx *reparseTx) AddWorkspaceFlat(workspaceID, flatID, addedBy string, _ time.Time) error {
+	tx.added = append(tx.added, workspaceID+"/"+flatID+"/"+addedBy)
+	return nil
+}
+
+func (tx *reparseTx) GetMemberWorkspaceID(userID string) (string, error) {
+	id, ok := tx.workspaces[userID]
+	if !ok {
+		return "", custerrors.ErrNotFound
+	}
+
+	return id, nil
+}
+
+func Test_ArchiveService_storeReparse(t *testing.T) {
+	s := &ArchiveService{}
+	res := parsers.Result{Flat: models.Flat{Title: "Mieszkanie 2 pokoje"}}
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+	tests := map[string]struct {
+		requestedBy string
+		existing    []models.Flat
+		dryRun      bool
+		wantFlatID  string
+		wantAdded   []string
+	}{
+		"ok, new flat added to the workspace of the requester": {
+			requestedBy: "7",
+			wantFlatID:  "42",
+			wantAdded:   []string{"w1/42/7"},
+		},
+		"ok, new flat without requester": {
+			wantFlatID: "42",
+		},
+		"ok, requester in no workspace": {
+			requestedBy: "8",
+			wantFlatID:  "42",
+		},
+		"ok, existing flat": {
+			requestedBy: "7",
+			existing:    []models.Flat{{ID: "5", Title: "Mieszkanie 2 pokoje"}},
+			wantFlatID:  "5",
+		},
+		"ok, dry run": {
+			requestedBy: "7",
+			dryRun:      true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			tx := &reparseTx{existing: tc.existing, workspaces: map[string]string{"7": "w1"}}
+			snap := models.Snapshot{ID: "1", URL: "https://olx.pl/1", RequestedBy: tc.requestedBy}
+
+			err := s.storeReparse(tx, &snap, res, nil, tc.dryRun, now, &models.ReparsedPage{})
+			if err != nil {
+				t.Fatalf("failed to store reparse: %v", err)
+			}
+
+			if snap.FlatID != tc.wantFlatID {
+				t.Errorf("got flat %q want %q", snap.FlatID, tc.wantFlatID)
+			}
+			if !reflect.DeepEqual(tx.added, tc.wantAdded) {
+				t.Errorf("got added %v want %v", tx.added, tc.wantAdded)
+			}
+		})
+	}
+}
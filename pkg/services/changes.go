@@ -0,0 +1,90 @@
+package services
+
+import (
+	"time"
+
+	"hestia/pkg/models"
+	"hestia/pkg/utils/parsers"
+)
+
+// diffFlat returns the changes of the raw text fields from stored to scraped.
+// Empty fields of scraped are ignored, as a field missing from a page is more
+// often a parsing problem than a change made by the advertiser.
+func diffFlat(stored, scraped models.Flat, now time.Time) []models.FlatChange {
+	var changes []models.FlatChange
+	for _, f := range parsers.Fields {
+		o, n := *f.Ptr(&stored), *f.Ptr(&scraped)
+		if n == "" || n == o {
+			continue
+		}
+
+		changes = append(changes, models.FlatChange{
+			FlatID:     stored.ID,
+			Field:      string(f),
+			OldValue:   o,
+			NewValue:   n,
+			DetectedAt: now,
+		})
+	}
+
+	return changes
+}
+
+// applyChanges sets the changed fields on flat.
+func applyChanges(flat *models.Flat, changes []models.FlatChange) {
+	for _, c := range changes {
+		if p := parsers.Field(c.Field).Ptr(flat); p != nil {
+			*p = c.NewValue
+		}
+	}
+}
+
+// saveFlatChanges stores the changes of flat and records the new values of
+// changed prices. flat must already hold the changes and be normalized.
+func saveFlatChanges(tx models.Tx, flat models.Flat, changes []models.FlatChange, now time.Time) error {
+	err := tx.CreateFlatChanges(changes)
+	if err != nil {
+		return err
+	}
+
+	fields := make([]parsers.Field, 0, len(changes))
+	for _, c := range changes {
+		fields = append(fields, parsers.Field(c.Field))
+	}
+
+	return tx.CreatePricePoints(pricePoints(flat, fields, now))
+}
+
+// pricePoints returns the price history entries for the price, rent and
+// deposit of flat, if they are among fields and not empty.
+func pricePoints(flat models.Flat, fields []parsers.Field, now time.Time) []models.PricePoint {
+	var points []models.PricePoint
+	for _, f := range fields {
+		var v *models.Money
+		switch f {
+		case parsers.FieldPrice:
+			v = flat.PriceValue
+		case parsers.FieldRent:
+			v = flat.RentValue
+		case parsers.FieldDeposit:
+			v = flat.DepositValue
+		default:
+			continue
+		}
+
+		raw := *f.Ptr(&flat)
+		if raw == "" {
+			continue
+		}
+
+		points = append(points, models.PricePoint{
+			FlatID:     flat.ID,
+			Kind:       string(f),
+			Value:      v,
+			Raw:        raw,
+			RecordedAt: now,
+		})
+	}
+
+	return points
+}
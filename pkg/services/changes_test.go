@@ -0,0 +1,102 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"hestia/pkg/models"
+	"hestia/pkg/utils/parsers"
+)
+
+func Test_diffFlat(t *testing.T) {
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	stored := models.Flat{
+		ID:      "7",
+		Title:   "Mieszkanie 2-pokojowe",
+		Price:   "3 200 zł",
+		Rent:    "650 zł",
+		Deposit: "3 200 zł",
+	}
+
+	tests := map[string]struct {
+		scraped models.Flat
+		want    []models.FlatChange
+	}{
+		"ok, unchanged": {
+			scraped: models.Flat{Title: "Mieszkanie 2-pokojowe", Price: "3 200 zł", Rent: "650 zł", Deposit: "3 200 zł"},
+		},
+		"ok, price and rent changed": {
+			scraped: models.Flat{Title: "Mieszkanie 2-pokojowe", Price: "2 900 zł", Rent: "700 zł", Deposit: "3 200 zł"},
+			want: []models.FlatChange{
+				{FlatID: "7", Field: "price", OldValue: "3 200 zł", NewValue: "2 900 zł", DetectedAt: now},
+				{FlatID: "7", Field: "rent", OldValue: "650 zł", NewValue: "700 zł", DetectedAt: now},
+			},
+		},
+		"ok, missing fields ignored": {
+			scraped: models.Flat{Title: "Mieszkanie 2-pokojowe", Surface: "45 m²"},
+			want: []models.FlatChange{
+				{FlatID: "7", Field: "surface", OldValue: "", NewValue: "45 m²", DetectedAt: now},
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := diffFlat(stored, tc.scraped, now)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got\n%#v\nwant\n%#v\n", got, tc.want)
+			}
+
+			flat := stored
+			applyChanges(&flat, got)
+			if len(diffFlat(flat, tc.scraped, now)) != 0 {
+				t.Errorf("expected no changes after applying them")
+			}
+		})
+	}
+}
+
+func Test_pricePoints(t *testing.T) {
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	flat := models.Flat{
+		ID:         "7",
+		Title:      "Mieszkanie 2-pokojowe",
+		Price:      "2 900 zł",
+		PriceValue: &models.Money{Amount: 290000, Currency: "PLN"},
+		Rent:       "700 zł",
+		RentValue:  &models.Money{Amount: 70000, Currency: "PLN"},
+		Deposit:    "do uzgodnienia",
+	}
+
+	tests := map[string]struct {
+		fields []parsers.Field
+		want   []models.PricePoint
+	}{
+		"ok, no money fields": {
+			fields: []parsers.Field{parsers.FieldTitle},
+		},
+		"ok, price changed": {
+			fields: []parsers.Field{parsers.FieldTitle, parsers.FieldPrice},
+			want: []models.PricePoint{
+				{FlatID: "7", Kind: "price", Value: &models.Money{Amount: 290000, Currency: "PLN"}, Raw: "2 900 zł", RecordedAt: now},
+			},
+		},
+		"ok, unparsed deposit": {
+			fields: []parsers.Field{parsers.FieldRent, parsers.FieldDeposit},
+			want: []models.PricePoint{
+				{FlatID: "7", Kind: "rent", Value: &models.Money{Amount: 70000, Currency: "PLN"}, Raw: "700 zł", RecordedAt: now},
+				{FlatID: "7", Kind: "deposit", Raw: "do uzgodnienia", RecordedAt: now},
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := pricePoints(flat, tc.fields, now)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got\n%#v\nwant\n%#v\n", got, tc.want)
+			}
+		})
+	}
+}
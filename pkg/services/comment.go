@@ -0,0 +1,516 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"hestia/pkg/custerrors"
+	"hestia/pkg/models"
+	"hestia/pkg/repos"
+)
+
+// mentionRe matches a mention of a user by email, like @ola@example.com,
+// at the start of the body or after a space or an opening bracket.
+var mentionRe = regexp.MustCompile(`(?:^|[\s(])@([\w.+-]+@[\w-]+(?:\.[\w-]+)*\.[A-Za-z]{2,})`)
+
+// CommentService manages the private notes users keep on flats and the
+// comments on flats visible to the members of a workspace.
+type CommentService struct {
+	rep        *repos.Store
+	wg         *sync.WaitGroup
+	errHandler ErrFunc
+	workspaces *WorkspaceService
+	mailer     Mailer
+	baseURL    string
+
+	// NowFunc is used to get the current time.
+	// Exposed for testing purposes.
+	NowFunc func() time.Time
+}
+
+// NewCommentService creates a new Service. Links in the emails sent to
+// mentioned users point to hestia at baseURL.
+func NewCommentService(db *sql.DB, workspaces *WorkspaceService, mailer Mailer, baseURL string, errHandler ErrFunc) *CommentService {
+	svc := &CommentService{
+		rep:        repos.New(db),
+		wg:         &sync.WaitGroup{},
+		errHandler: errHandler,
+		workspaces: workspaces,
+		mailer:     mailer,
+		baseURL:    baseURL,
+
+		NowFunc: time.Now,
+	}
+
+	return svc
+}
+
+// GetNote writes the note of the user on the flat with the id in the path.
+func (s *CommentService) GetNote(w http.ResponseWriter, r *http.Request) error {
+	userID, err := requestUserID(r)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	n, err := s.rep.GetFlatNote(r.Context(), userID, r.PathValue("id"))
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	return s.write(w, http.StatusOK, n)
+}
+
+// PutNote stores the note and rating in the request body as the note of the
+// user on the flat with the id in the path and writes it.
+func (s *CommentService) PutNote(w http.ResponseWriter, r *http.Request) error {
+	userID, err := requestUserID(r)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	var n models.FlatNote
+	err = json.NewDecoder(r.Body).Decode(&n)
+	if err != nil {
+		err = fmt.Errorf("invalid note: %v: %w", err, custerrors.ErrInvalidInput)
+		s.errHandler(err)
+		return err
+	}
+
+	n.Body = strings.TrimSpace(n.Body)
+	err = checkFlatNote(n)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	now := s.NowFunc()
+	n.UserID = userID
+	n.FlatID = r.PathValue("id")
+	n.CreatedAt = now
+	n.UpdatedAt = now
+
+	err = s.inTx(r.Context(), func(tx models.Tx) error {
+		_, err := tx.GetFlatByID(n.FlatID)
+		if err != nil {
+			return err
+		}
+
+		return tx.SaveFlatNote(n)
+	})
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	n, err = s.rep.GetFlatNote(r.Context(), userID, n.FlatID)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	return s.write(w, http.StatusOK, n)
+}
+
+// DeleteNote deletes the note of the user on the flat with the id in the path.
+func (s *CommentService) DeleteNote(w http.ResponseWriter, r *http.Request) error {
+	userID, err := requestUserID(r)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	err = s.inTx(r.Context(), func(tx models.Tx) error {
+		return tx.DeleteFlatNote(userID, r.PathValue("id"))
+	})
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// List writes the comments of the workspace on the flat with the id in the
+// path, oldest first.
+func (s *CommentService) List(w http.ResponseWriter, r *http.Request) error {
+	_, workspaceID, err := s.workspaces.RequestWorkspaceID(r)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	flatID := r.PathValue("id")
+
+	_, err = s.rep.GetFlatByID(r.Context(), flatID)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	comments, err := s.rep.FindComments(r.Context(), workspaceID, flatID)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	return s.write(w, http.StatusOK, comments)
+}
+
+// Get writes the comment with the commentID in the path with its edit history.
+func (s *CommentService) Get(w http.ResponseWriter, r *http.Request) error {
+	_, workspaceID, err := s.workspaces.RequestWorkspaceID(r)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	c, err := s.rep.GetComment(r.Context(), workspaceID, r.PathValue("id"), r.PathValue("commentID"))
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	return s.write(w, http.StatusOK, c)
+}
+
+// Post posts the comment in the request body on the flat with the id in the
+// path and writes it. Members of the workspace mentioned in the body are
+// notified by email.
+func (s *CommentService) Post(w http.ResponseWriter, r *http.Request) error {
+	userID, workspaceID, err := s.workspaces.RequestWorkspaceID(r)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	body, err := decodeCommentBody(r)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	mentioned, err := s.mentionedUsers(r.Context(), workspaceID, body)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	now := s.NowFunc()
+	c := models.Comment{
+		WorkspaceID: workspaceID,
+		FlatID:      r.PathValue("id"),
+		UserID:      userID,
+		Body:        body,
+		Mentions:    mentionEmails(mentioned),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	var flat models.Flat
+	err = s.inTx(r.Context(), func(tx models.Tx) error {
+		var err error
+		flat, err = tx.GetFlatByID(c.FlatID)
+		if err != nil {
+			return err
+		}
+
+		c.ID, err = tx.CreateComment(c)
+		return err
+	})
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	c, err = s.rep.GetComment(r.Context(), workspaceID, c.FlatID, c.ID)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	s.notifyMentioned(c, flat, mentioned, nil)
+
+	return s.write(w, http.StatusCreated, c)
+}
+
+// Put edits the comment with the commentID in the path, keeping its earlier
+// body, and writes it. Only its author may edit a comment. Members of the
+// workspace newly mentioned in the body are notified by email.
+func (s *CommentService) Put(w http.ResponseWriter, r *http.Request) error {
+	userID, workspaceID, err := s.workspaces.RequestWorkspaceID(r)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	body, err := decodeCommentBody(r)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	mentioned, err := s.mentionedUsers(r.Context(), workspaceID, body)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	var (
+		flatID = r.PathValue("id")
+		id     = r.PathValue("commentID")
+		now    = s.NowFunc()
+		flat   models.Flat
+		before []string
+	)
+	err = s.inTx(r.Context(), func(tx models.Tx) error {
+		c, err := s.authorComment(tx, userID, workspaceID, flatID, id)
+		if err != nil {
+			return err
+		}
+		before = c.Mentions
+
+		flat, err = tx.GetFlatByID(flatID)
+		if err != nil {
+			return err
+		}
+
+		err = tx.CreateCommentRevision(c.ID, models.CommentRevision{Body: c.Body, CreatedAt: c.UpdatedAt})
+		if err != nil {
+			return err
+		}
+
+		c.Body = body
+		c.Mentions = mentionEmails(mentioned)
+		c.UpdatedAt = now
+
+		return tx.UpdateComment(c)
+	})
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	c, err := s.rep.GetComment(r.Context(), workspaceID, flatID, id)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	s.notifyMentioned(c, flat, mentioned, before)
+
+	return s.write(w, http.StatusOK, c)
+}
+
+// Delete deletes the comment with the commentID in the path. The comment
+// stays in the thread without its body. Only its author may delete a comment.
+func (s *CommentService) Delete(w http.ResponseWriter, r *http.Request) error {
+	userID, workspaceID, err := s.workspaces.RequestWorkspaceID(r)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	err = s.inTx(r.Context(), func(tx models.Tx) error {
+		c, err := s.authorComment(tx, userID, workspaceID, r.PathValue("id"), r.PathValue("commentID"))
+		if err != nil {
+			return err
+		}
+
+		return tx.DeleteComment(c.ID, s.NowFunc())
+	})
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// authorComment returns the comment of the workspace on the flat if userID
+// wrote it and it is not deleted.
+func (s *CommentService) authorComment(tx models.Tx, userID, workspaceID, flatID, id string) (models.Comment, error) {
+	c, err := tx.GetComment(workspaceID, flatID, id)
+	if err != nil {
+		return models.Comment{}, err
+	}
+
+	if c.DeletedAt != nil {
+		return models.Comment{}, fmt.Errorf("comment not found: %w", custerrors.ErrNotFound)
+	}
+
+	if c.UserID != userID {
+		return models.Comment{}, fmt.Errorf("comment of another user: %w", custerrors.ErrForbidden)
+	}
+
+	return c, nil
+}
+
+// mentionedUsers returns the active members of the workspace mentioned in
+// body. Mentions of other emails are ignored.
+func (s *CommentService) mentionedUsers(ctx context.Context, workspaceID, body string) ([]models.User, error) {
+	emails := parseMentions(body)
+	if len(emails) == 0 {
+		return nil, nil
+	}
+
+	users, err := s.rep.FindUsers(ctx, models.UserFilter{Emails: emails, IsActive: true})
+	if err != nil {
+		return nil, err
+	}
+
+	ws, err := s.rep.GetWorkspace(ctx, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+
+	return slices.DeleteFunc(users, func(u models.User) bool {
+		_, ok := ws.Member(u.ID)
+		return !ok
+	}), nil
+}
+
+// notifyMentioned emails the users mentioned in the comment about it, except
+// its author and the users in before, who were notified already.
+func (s *CommentService) notifyMentioned(c models.Comment, flat models.Flat, mentioned []models.User, before []string) {
+	to := make([]string, 0, len(mentioned))
+	for _, u := range mentioned {
+		if u.ID == c.UserID || slices.Contains(before, u.Email) {
+			continue
+		}
+		to = append(to, u.Email)
+	}
+
+	if len(to) == 0 {
+		return
+	}
+
+	data := models.CommentMention{Comment: c, Flat: flat, URL: flatURL(s.baseURL, flat.ID)}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+		defer cancel()
+
+		var errSum error
+		for _, addr := range to {
+			err := s.mailer.Send(ctx, "comment-mention", addr, data)
+			if err != nil {
+				errSum = errors.Join(errSum, fmt.Errorf("failed to send comment-mention to %s: %w", addr, err))
+			}
+		}
+
+		if errSum != nil {
+			s.errHandler(errSum)
+		}
+	}()
+}
+
+// decodeCommentBody reads the body of the comment in the request.
+func decodeCommentBody(r *http.Request) (string, error) {
+	var cb models.CommentBody
+	err := json.NewDecoder(r.Body).Decode(&cb)
+	if err != nil {
+		return "", fmt.Errorf("invalid comment: %v: %w", err, custerrors.ErrInvalidInput)
+	}
+
+	body := strings.TrimSpace(cb.Body)
+	if body == "" {
+		return "", fmt.Errorf("comment body is required: %w", custerrors.ErrInvalidInput)
+	}
+
+	return body, nil
+}
+
+// checkFlatNote checks that the note has a body or a rating and that the
+// rating is from 1 to 5 stars.
+func checkFlatNote(n models.FlatNote) error {
+	if n.Body == "" && n.Rating == nil {
+		return fmt.Errorf("note body or rating is required: %w", custerrors.ErrInvalidInput)
+	}
+
+	if n.Rating != nil && (*n.Rating < 1 || *n.Rating > 5) {
+		return fmt.Errorf("rating must be from 1 to 5, got %d: %w", *n.Rating, custerrors.ErrInvalidInput)
+	}
+
+	return nil
+}
+
+// parseMentions returns the emails mentioned in body in lower case, in the
+// order they are first mentioned.
+func parseMentions(body string) []string {
+	var out []string
+	for _, m := range mentionRe.FindAllStringSubmatch(body, -1) {
+		if e := strings.ToLower(m[1]); !slices.Contains(out, e) {
+			out = append(out, e)
+		}
+	}
+
+	return out
+}
+
+// mentionEmails returns the emails of the mentioned users.
+func mentionEmails(users []models.User) []string {
+	out := make([]string, 0, len(users))
+	for _, u := range users {
+		out = append(out, u.Email)
+	}
+
+	return out
+}
+
+// write writes v as the JSON response with status.
+func (s *CommentService) write(w http.ResponseWriter, status int, v any) error {
+	j, err := json.Marshal(v)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, err = w.Write(j)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	return nil
+}
+
+func (s *CommentService) inTx(ctx context.Context, f func(tx models.Tx) error) error {
+	tx, err := s.rep.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = f(tx)
+	if err != nil {
+		rBackErr := tx.Rollback()
+		if rBackErr != nil {
+			err = errors.Join(err, rBackErr)
+		}
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
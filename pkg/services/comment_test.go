@@ -0,0 +1,62 @@
+package services
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"hestia/pkg/custerrors"
+	"hestia/pkg/models"
+)
+
+func Test_parseMentions(t *testing.T) {
+	tests := map[string]struct {
+		body string
+		want []string
+	}{
+		"ok, no mentions":        {body: "Ładny balkon, ale drogo."},
+		"ok, at start":           {body: "@ola@example.com zobacz", want: []string{"ola@example.com"}},
+		"ok, trailing dot":       {body: "Pytanie do @jan.kowalski@mail.example.pl.", want: []string{"jan.kowalski@mail.example.pl"}},
+		"ok, in brackets":        {body: "Dzwoniłem (@ola@example.com wie więcej)", want: []string{"ola@example.com"}},
+		"ok, several in order":   {body: "@jan@example.com i @ola@example.com", want: []string{"jan@example.com", "ola@example.com"}},
+		"ok, repeated any case":  {body: "@Ola@Example.com oraz @ola@example.com", want: []string{"ola@example.com"}},
+		"ok, upper case":         {body: "@JAN.Kowalski@Example.COM zobacz", want: []string{"jan.kowalski@example.com"}},
+		"ok, plain email":        {body: "pisz na ola@example.com"},
+		"ok, inside a word":      {body: "a@ola@example.com"},
+		"ok, handle without dot": {body: "@ola jutro"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := parseMentions(tc.body)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %v want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func Test_checkFlatNote(t *testing.T) {
+	rating := func(v int) *int { return &v }
+
+	tests := map[string]struct {
+		note    models.FlatNote
+		wantErr error
+	}{
+		"ok, body":             {note: models.FlatNote{Body: "Cicha okolica"}},
+		"ok, rating":           {note: models.FlatNote{Rating: rating(5)}},
+		"ok, body and rating":  {note: models.FlatNote{Body: "Za mała kuchnia", Rating: rating(1)}},
+		"fail, empty":          {note: models.FlatNote{}, wantErr: custerrors.ErrInvalidInput},
+		"fail, rating zero":    {note: models.FlatNote{Rating: rating(0)}, wantErr: custerrors.ErrInvalidInput},
+		"fail, rating too big": {note: models.FlatNote{Body: "Super", Rating: rating(6)}, wantErr: custerrors.ErrInvalidInput},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := checkFlatNote(tc.note)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("expected errors to be %v got %v (via errors.Is)", tc.wantErr, err)
+			}
+		})
+	}
+}
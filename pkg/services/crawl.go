@@ -0,0 +1,159 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"hestia/pkg/middlewares"
+	"hestia/pkg/models"
+	"hestia/pkg/repos"
+	"hestia/pkg/utils/parsers"
+)
+
+// CrawlConfig is the configuration for crawling search results.
+type CrawlConfig struct {
+	// MaxPages and MaxListings are the upper limits of a single crawl.
+	MaxPages    int
+	MaxListings int
+	// Timeout bounds a crawl started over HTTP.
+	Timeout time.Duration
+}
+
+// CrawlService imports all listings of a portal search by queueing a scrape job for each of them.
+type CrawlService struct {
+	rep        *repos.Store
+	wg         *sync.WaitGroup
+	collector  *parsers.Collector
+	jobs       *ScrapeService
+	cfg        CrawlConfig
+	errHandler ErrFunc
+}
+
+// NewCrawlService creates a new Service.
+func NewCrawlService(db *sql.DB, collector *parsers.Collector, jobs *ScrapeService, cfg CrawlConfig, errHandler ErrFunc) *CrawlService {
+	svc := &CrawlService{
+		rep:        repos.New(db),
+		wg:         &sync.WaitGroup{},
+		collector:  collector,
+		jobs:       jobs,
+		cfg:        cfg,
+		errHandler: errHandler,
+	}
+
+	return svc
+}
+
+// Post crawls the search in the request body and responds with the report.
+// The crawl may outlast the server's write timeout, so the deadline is
+// extended to the crawl timeout.
+func (s *CrawlService) Post(w http.ResponseWriter, r *http.Request) error {
+	var req models.CrawlRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	err = http.NewResponseController(w).SetWriteDeadline(time.Now().Add(s.cfg.Timeout + time.Second*5))
+	if err != nil && !errors.Is(err, http.ErrNotSupported) {
+		s.errHandler(err)
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), s.cfg.Timeout)
+	defer cancel()
+
+	userID, _ := middlewares.UserIDFromContext(ctx)
+	report, err := s.Crawl(ctx, req, userID)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	j, err := json.Marshal(report)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(j)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	return nil
+}
+
+// Crawl follows the search results starting at req.URL and queues a scrape
+// job for every listing that is not queued for requestedBy yet, so the flats
+// end up in their workspace. The report is filled in as far as the crawl
+// got, also when it returns an error.
+func (s *CrawlService) Crawl(ctx context.Context, req models.CrawlRequest, requestedBy string) (models.CrawlReport, error) {
+	report := models.CrawlReport{
+		URL:    req.URL,
+		JobIDs: make([]string, 0),
+	}
+
+	limits := parsers.CrawlLimits{
+		MaxPages:    limit(req.MaxPages, s.cfg.MaxPages),
+		MaxListings: limit(req.MaxListings, s.cfg.MaxListings),
+	}
+
+	pages, err := s.collector.Crawl(ctx, req.URL, limits, func(listing string) error {
+		if s.collector.Supports(listing) != nil {
+			report.Skipped++
+			return nil
+		}
+
+		pending, err := s.rep.FindScrapeJobs(ctx, models.ScrapeJobFilter{
+			URLs:        []string{listing},
+			Statuses:    []models.ScrapeStatus{models.ScrapeStatusQueued, models.ScrapeStatusRunning},
+			RequestedBy: requestedBy,
+		})
+		if err != nil {
+			return err
+		}
+		if len(pending) > 0 {
+			report.Skipped++
+			return nil
+		}
+
+		flats, err := s.rep.FindFlats(ctx, models.FlatFilter{SourceURLs: []string{listing}})
+		if err != nil {
+			return err
+		}
+
+		job, err := s.jobs.Enqueue(ctx, listing, requestedBy)
+		if err != nil {
+			return err
+		}
+
+		if len(flats) > 0 {
+			report.Updated++
+		} else {
+			report.New++
+		}
+		report.JobIDs = append(report.JobIDs, job.ID)
+
+		return nil
+	})
+	report.Pages = pages
+
+	return report, err
+}
+
+// limit returns requested if it is within (0, upper], upper otherwise.
+func limit(requested, upper int) int {
+	if requested <= 0 || requested > upper {
+		return upper
+	}
+
+	return requested
+}
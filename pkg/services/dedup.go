@@ -0,0 +1,338 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strings"
+	"unicode"
+
+	"hestia/pkg/custerrors"
+	"hestia/pkg/models"
+)
+
+const (
+	// surfaceTolerance is how much the surfaces of duplicates may differ,
+	// relative to the larger one. Portals round differently.
+	surfaceTolerance = 0.03
+	// priceTolerance is how much the prices of duplicates may differ,
+	// relative to the higher one, e.g. with and without the agency fee.
+	priceTolerance = 0.1
+	// shingleSize is the number of words in a description shingle.
+	shingleSize = 3
+	// minShingles is the number of shingles both descriptions need for
+	// their similarity to be taken into account.
+	minShingles = 5
+	// minSimilarity is the lowest Jaccard similarity of the description
+	// shingles of duplicates.
+	minSimilarity = 0.2
+)
+
+// isDuplicate reports whether a and b are the same apartment. They must share
+// a fingerprint and have close surfaces. Prices and descriptions are compared
+// when both flats have them.
+func isDuplicate(a, b models.Flat) bool {
+	if a.Fingerprint == "" || a.Fingerprint != b.Fingerprint {
+		return false
+	}
+
+	if a.SurfaceM2 == nil || b.SurfaceM2 == nil {
+		return false
+	}
+	if !within(*a.SurfaceM2, *b.SurfaceM2, surfaceTolerance) {
+		return false
+	}
+
+	if a.PriceValue != nil && b.PriceValue != nil {
+		if a.PriceValue.Currency != b.PriceValue.Currency {
+			return false
+		}
+		if !within(float64(a.PriceValue.Amount), float64(b.PriceValue.Amount), priceTolerance) {
+			return false
+		}
+	}
+
+	as, bs := shingles(a.Description), shingles(b.Description)
+	if len(as) >= minShingles && len(bs) >= minShingles && jaccard(as, bs) < minSimilarity {
+		return false
+	}
+
+	return true
+}
+
+// within reports whether a and b differ by at most tolerance of the larger one.
+func within(a, b, tolerance float64) bool {
+	return math.Abs(a-b) <= tolerance*math.Max(math.Abs(a), math.Abs(b))
+}
+
+// shingles returns the set of runs of shingleSize consecutive words of s.
+func shingles(s string) map[string]bool {
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	out := make(map[string]bool)
+	for i := 0; i+shingleSize <= len(words); i++ {
+		out[strings.Join(words[i:i+shingleSize], " ")] = true
+	}
+
+	return out
+}
+
+// jaccard returns the size of the intersection of a and b over the size of their union.
+func jaccard(a, b map[string]bool) float64 {
+	common := 0
+	for s := range a {
+		if b[s] {
+			common++
+		}
+	}
+
+	union := len(a) + len(b) - common
+	if union == 0 {
+		return 0
+	}
+
+	return float64(common) / float64(union)
+}
+
+// canonicalOf returns the id of the canonical flat of the group f belongs to.
+func canonicalOf(f models.Flat) string {
+	if f.CanonicalID != "" {
+		return f.CanonicalID
+	}
+
+	return f.ID
+}
+
+// findCanonical returns the id of the canonical flat of the first stored flat
+// that flat is a duplicate of, or an empty string if there is none.
+func findCanonical(tx models.Tx, flat models.Flat) (string, error) {
+	if flat.Fingerprint == "" {
+		return "", nil
+	}
+
+	candidates, err := tx.FindFlats(models.FlatFilter{Fingerprints: []string{flat.Fingerprint}})
+	if err != nil {
+		return "", err
+	}
+
+	for _, c := range candidates {
+		if c.ID != flat.ID && isDuplicate(flat, c) {
+			return canonicalOf(c), nil
+		}
+	}
+
+	return "", nil
+}
+
+// mergeGroups links the flats of groups to the canonical flat with the id
+// canonicalID and returns the flats that changed.
+func mergeGroups(canonicalID string, groups []models.Flat) []models.Flat {
+	var changed []models.Flat
+	for _, f := range groups {
+		want := canonicalID
+		if f.ID == canonicalID {
+			want = ""
+		}
+
+		if f.CanonicalID != want {
+			f.CanonicalID = want
+			changed = append(changed, f)
+		}
+	}
+
+	return changed
+}
+
+// splitGroup detaches the flat with the id from its group and returns the
+// flats that changed. If the flat is canonical, the oldest of its duplicates
+// becomes the canonical flat of the rest.
+func splitGroup(id string, group []models.Flat) []models.Flat {
+	i := slices.IndexFunc(group, func(f models.Flat) bool { return f.ID == id })
+	if i < 0 {
+		return nil
+	}
+
+	// A duplicate leaves alone, the canonical flat can be newer than the rest.
+	if f := group[i]; f.CanonicalID != "" {
+		f.CanonicalID = ""
+		return []models.Flat{f}
+	}
+
+	group = slices.Clone(group)
+	slices.SortFunc(group, func(a, b models.Flat) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+
+	var (
+		changed []models.Flat
+		next    string
+	)
+	for _, f := range group {
+		want := next
+		switch {
+		case f.ID == id:
+			want = ""
+		case next == "":
+			// The first flat left over heads the rest of the group.
+			next = f.ID
+			want = ""
+		}
+
+		if f.CanonicalID != want {
+			f.CanonicalID = want
+			changed = append(changed, f)
+		}
+	}
+
+	return changed
+}
+
+// Duplicates writes the flat and the flats in its duplicate group that
+// belong to the workspace of the user.
+func (s *FlatService) Duplicates(w http.ResponseWriter, r *http.Request) error {
+	_, workspaceID, err := s.workspaces.RequestWorkspaceID(r)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	flat, err := s.rep.GetFlatByID(r.Context(), r.PathValue("id"))
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	group, err := s.rep.FindFlats(r.Context(), models.FlatFilter{Groups: []string{canonicalOf(flat)}, WorkspaceID: workspaceID})
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	return s.writeFlats(w, group)
+}
+
+// Merge links the flats in the body, and their duplicates, into the group of
+// the flat in the path. The flats in the body must belong to the workspace
+// of the user.
+func (s *FlatService) Merge(w http.ResponseWriter, r *http.Request) error {
+	_, workspaceID, err := s.workspaces.RequestWorkspaceID(r)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	var req models.FlatMerge
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		err = fmt.Errorf("%w: %v", custerrors.ErrInvalidInput, err)
+		s.errHandler(err)
+		return err
+	}
+	if len(req.IDs) == 0 {
+		err = fmt.Errorf("%w: no flats to merge", custerrors.ErrInvalidInput)
+		s.errHandler(err)
+		return err
+	}
+
+	now := s.NowFunc()
+	var group []models.Flat
+	err = s.inTx(r.Context(), func(tx models.Tx) error {
+		flat, err := tx.GetFlatByID(r.PathValue("id"))
+		if err != nil {
+			return err
+		}
+
+		canonicalID := canonicalOf(flat)
+		ids := []string{canonicalID}
+		for _, id := range req.IDs {
+			held, err := tx.FindFlatWorkspaces(id)
+			if err != nil {
+				return err
+			}
+			if !slices.Contains(held, workspaceID) {
+				return fmt.Errorf("flat %s not found: %w", id, custerrors.ErrNotFound)
+			}
+
+			f, err := tx.GetFlatByID(id)
+			if err != nil {
+				return err
+			}
+			ids = append(ids, canonicalOf(f))
+		}
+
+		groups, err := tx.FindFlats(models.FlatFilter{Groups: ids})
+		if err != nil {
+			return err
+		}
+
+		for _, f := range mergeGroups(canonicalID, groups) {
+			f.UpdatedAt = now
+			err := tx.UpdateFlat(f)
+			if err != nil {
+				return err
+			}
+		}
+
+		group, err = tx.FindFlats(models.FlatFilter{Groups: []string{canonicalID}})
+		return err
+	})
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	return s.writeFlats(w, group)
+}
+
+// Split takes the flat in the path out of its duplicate group.
+func (s *FlatService) Split(w http.ResponseWriter, r *http.Request) error {
+	now := s.NowFunc()
+	err := s.inTx(r.Context(), func(tx models.Tx) error {
+		flat, err := tx.GetFlatByID(r.PathValue("id"))
+		if err != nil {
+			return err
+		}
+
+		group, err := tx.FindFlats(models.FlatFilter{Groups: []string{canonicalOf(flat)}})
+		if err != nil {
+			return err
+		}
+
+		for _, f := range splitGroup(flat.ID, group) {
+			f.UpdatedAt = now
+			err := tx.UpdateFlat(f)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	return nil
+}
+
+func (s *FlatService) writeFlats(w http.ResponseWriter, flats []models.Flat) error {
+	j, err := json.Marshal(flats)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(j)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	return nil
+}
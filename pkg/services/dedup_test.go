@@ -0,0 +1,168 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"hestia/pkg/models"
+)
+
+func Test_isDuplicate(t *testing.T) {
+	const description = "Do wynajęcia przestronne mieszkanie dwupokojowe w nowym bloku przy parku, " +
+		"w pełni umeblowane i wyposażone, blisko tramwaju i sklepów."
+
+	base := models.Flat{
+		ID:          "1",
+		Fingerprint: "5 krakow wroclawska|2",
+		SurfaceM2:   ptr(45.0),
+		PriceValue:  &models.Money{Amount: 320000, Currency: "PLN"},
+		Description: description,
+	}
+
+	tests := map[string]struct {
+		change func(f *models.Flat)
+		want   bool
+	}{
+		"ok, same listing elsewhere": {
+			change: func(f *models.Flat) { f.ID = "2" },
+			want:   true,
+		},
+		"ok, rounded surface and agency price": {
+			change: func(f *models.Flat) {
+				f.SurfaceM2 = ptr(45.8)
+				f.PriceValue = &models.Money{Amount: 340000, Currency: "PLN"}
+			},
+			want: true,
+		},
+		"ok, unknown price and short description": {
+			change: func(f *models.Flat) {
+				f.PriceValue = nil
+				f.Description = "Polecam!"
+			},
+			want: true,
+		},
+		"no, other fingerprint": {
+			change: func(f *models.Flat) { f.Fingerprint = "5 krakow wroclawska|3" },
+		},
+		"no, no fingerprint": {
+			change: func(f *models.Flat) { f.Fingerprint = "" },
+		},
+		"no, surface differs": {
+			change: func(f *models.Flat) { f.SurfaceM2 = ptr(52.0) },
+		},
+		"no, unknown surface": {
+			change: func(f *models.Flat) { f.SurfaceM2 = nil },
+		},
+		"no, price out of band": {
+			change: func(f *models.Flat) { f.PriceValue = &models.Money{Amount: 390000, Currency: "PLN"} },
+		},
+		"no, other currency": {
+			change: func(f *models.Flat) { f.PriceValue = &models.Money{Amount: 320000, Currency: "EUR"} },
+		},
+		"no, different description": {
+			change: func(f *models.Flat) {
+				f.Description = "Kawalerka na poddaszu kamienicy, okna na podwórze, ogrzewanie gazowe, bez mebli, od zaraz."
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			other := base
+			tc.change(&other)
+
+			got := isDuplicate(base, other)
+			if got != tc.want {
+				t.Errorf("got %t want %t", got, tc.want)
+			}
+		})
+	}
+}
+
+func Test_mergeGroups(t *testing.T) {
+	flats := []models.Flat{
+		{ID: "1"},
+		{ID: "2", CanonicalID: "1"},
+		{ID: "3"},
+		{ID: "4", CanonicalID: "3"},
+	}
+
+	got := mergeGroups("3", flats)
+	want := []models.Flat{
+		{ID: "1", CanonicalID: "3"},
+		{ID: "2", CanonicalID: "3"},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got\n%#v\nwant\n%#v\n", got, want)
+	}
+}
+
+func Test_splitGroup(t *testing.T) {
+	day := func(d int) time.Time {
+		return time.Date(2026, 10, d, 0, 0, 0, 0, time.UTC)
+	}
+
+	group := []models.Flat{
+		{ID: "1", CreatedAt: day(1)},
+		{ID: "5", CanonicalID: "1", CreatedAt: day(5)},
+		{ID: "3", CanonicalID: "1", CreatedAt: day(3)},
+	}
+
+	// Merge can make the newest flat canonical.
+	newest := []models.Flat{
+		{ID: "5", CreatedAt: day(5)},
+		{ID: "1", CanonicalID: "5", CreatedAt: day(1)},
+		{ID: "3", CanonicalID: "5", CreatedAt: day(3)},
+	}
+
+	tests := map[string]struct {
+		id    string
+		group []models.Flat
+		want  []models.Flat
+	}{
+		"ok, duplicate": {
+			id:   "5",
+			want: []models.Flat{{ID: "5", CreatedAt: day(5)}},
+		},
+		"ok, duplicate older than the canonical": {
+			id:    "1",
+			group: newest,
+			want:  []models.Flat{{ID: "1", CreatedAt: day(1)}},
+		},
+		"ok, newest canonical hands over to the oldest duplicate": {
+			id:    "5",
+			group: newest,
+			want: []models.Flat{
+				{ID: "1", CreatedAt: day(1)},
+				{ID: "3", CanonicalID: "1", CreatedAt: day(3)},
+			},
+		},
+		"ok, not in the group": {
+			id:    "7",
+			group: newest,
+		},
+		"ok, canonical hands over to the oldest duplicate": {
+			id: "1",
+			want: []models.Flat{
+				{ID: "3", CreatedAt: day(3)},
+				{ID: "5", CanonicalID: "3", CreatedAt: day(5)},
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			g := group
+			if tc.group != nil {
+				g = tc.group
+			}
+
+			got := splitGroup(tc.id, g)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got\n%#v\nwant\n%#v\n", got, tc.want)
+			}
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"sync"
 	"time"
 
@@ -31,11 +32,12 @@ type EmailService struct {
 }
 
 // NewEmailService creates a new Service.
-func NewEmailService(db *sql.DB, errHandler ErrFunc) *EmailService {
+func NewEmailService(db *sql.DB, mailer Mailer, errHandler ErrFunc) *EmailService {
 	svc := &EmailService{
 		repo:       repos.New(db),
 		wg:         &sync.WaitGroup{},
 		errHandler: errHandler,
+		mailer:     mailer,
 
 		NowFunc: time.Now,
 	}
@@ -118,6 +120,43 @@ func (s *EmailService) passwordReset(ctx context.Context, addr string) error {
 
 }
 
+// NotifyAdmins sends the email template filled with data to all active admins.
+func (s *EmailService) NotifyAdmins(template string, data interface{}) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+		defer cancel()
+
+		err := s.notifyAdmins(ctx, template, data)
+		if err != nil {
+			s.errHandler(err)
+			return
+		}
+	}()
+}
+
+func (s *EmailService) notifyAdmins(ctx context.Context, template string, data interface{}) error {
+	admins, err := s.repo.FindUsers(ctx, models.UserFilter{
+		Roles:    []string{"admin"},
+		IsActive: true,
+	})
+	if err != nil {
+		return err
+	}
+
+	var errSum error
+	for _, admin := range admins {
+		err := s.mailer.Send(ctx, template, admin.Email, data)
+		if err != nil {
+			errSum = errors.Join(errSum, fmt.Errorf("failed to send %s to %s: %w", template, admin.Email, err))
+		}
+	}
+
+	return errSum
+}
+
 func (s *EmailService) findUserByEmail(ctx context.Context, filter models.UserFilter) (models.User, error) {
 	users, err := s.repo.FindUsers(ctx, filter)
 	if err != nil {
@@ -5,14 +5,22 @@ import (
 	"database/sql"
 	"encoding/json"
 	"errors"
-	"hestia/pkg/utils/parsers"
+	"fmt"
+	"io"
+	"math"
 	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"hestia/pkg/custerrors"
 	"hestia/pkg/models"
 	"hestia/pkg/repos"
+	"hestia/pkg/utils/normalize"
+	"hestia/pkg/utils/parsers"
 )
 
 var (
@@ -20,6 +28,20 @@ var (
 	FlatNotFound     = errors.New("flat not found")
 )
 
+// DuplicateFlatError is returned when a listing is already stored as the flat with ID.
+// It wraps ErrDuplicateFlat.
+type DuplicateFlatError struct {
+	ID string
+}
+
+func (e *DuplicateFlatError) Error() string {
+	return fmt.Sprintf("%v: stored as flat %s", ErrDuplicateFlat, e.ID)
+}
+
+func (e *DuplicateFlatError) Unwrap() error {
+	return ErrDuplicateFlat
+}
+
 type FlatInterface interface {
 	Get(w http.ResponseWriter, r *http.Request) error
 	GetAll(w http.ResponseWriter, r *http.Request) error
@@ -32,7 +54,9 @@ type FlatInterface interface {
 type FlatService struct {
 	rep        *repos.Store
 	wg         *sync.WaitGroup
-	collector  *parsers.Collector
+	jobs       *ScrapeService
+	photos     *PhotoService
+	workspaces *WorkspaceService
 	errHandler ErrFunc
 
 	// NowFunc is used to get the current time.
@@ -41,12 +65,14 @@ type FlatService struct {
 }
 
 // NewFlatService creates a new Service.
-func NewFlatService(db *sql.DB, collector *parsers.Collector, errHandler ErrFunc) *FlatService {
+func NewFlatService(db *sql.DB, jobs *ScrapeService, photos *PhotoService, workspaces *WorkspaceService, errHandler ErrFunc) *FlatService {
 	svc := &FlatService{
 		rep:        repos.New(db),
 		wg:         &sync.WaitGroup{},
 		errHandler: errHandler,
-		collector:  collector,
+		jobs:       jobs,
+		photos:     photos,
+		workspaces: workspaces,
 
 		NowFunc: time.Now,
 	}
@@ -55,6 +81,12 @@ func NewFlatService(db *sql.DB, collector *parsers.Collector, errHandler ErrFunc
 }
 
 func (s *FlatService) Get(w http.ResponseWriter, r *http.Request) error {
+	_, workspaceID, err := s.workspaces.RequestWorkspaceID(r)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
 	id := r.PathValue("id")
 	flat, err := s.rep.GetFlatByID(r.Context(), id)
 	if err != nil {
@@ -62,7 +94,14 @@ func (s *FlatService) Get(w http.ResponseWriter, r *http.Request) error {
 		return err
 	}
 
-	j, err := json.Marshal(flat)
+	flats := []models.Flat{flat}
+	err = markShortlisted(r.Context(), s.rep, workspaceID, flats)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	j, err := json.Marshal(flats[0])
 	if err != nil {
 		s.errHandler(err)
 		return err
@@ -78,16 +117,70 @@ func (s *FlatService) Get(w http.ResponseWriter, r *http.Request) error {
 	return nil
 }
 
+// History writes the price history and the field changes of the flat.
+func (s *FlatService) History(w http.ResponseWriter, r *http.Request) error {
+	id := r.PathValue("id")
+	_, err := s.rep.GetFlatByID(r.Context(), id)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	history, err := s.rep.GetFlatHistory(r.Context(), id)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	j, err := json.Marshal(history)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(j)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	return nil
+}
+
+// GetAll writes a page of the flats of the workspace of the user.
 func (s *FlatService) GetAll(w http.ResponseWriter, r *http.Request) error {
-	flats, err := s.rep.FindFlats(r.Context(), models.FlatFilter{})
+	_, workspaceID, err := s.workspaces.RequestWorkspaceID(r)
 	if err != nil {
 		s.errHandler(err)
 		return err
 	}
 
-	if len(flats) == 0 {
-		s.errHandler(FlatNotFound)
-		return custerrors.ErrNotFound
+	filter, err := parseFlatFilter(r.URL.Query())
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+	filter.WorkspaceID = workspaceID
+	// Duplicates are only listed as sources of their canonical flat, unless asked for.
+	filter.CanonicalOnly = r.URL.Query().Get("duplicates") != "true"
+
+	page, err := parsePageRequest(r.URL.Query())
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	flats, err := s.rep.PageFlats(r.Context(), filter, page)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	err = markShortlisted(r.Context(), s.rep, workspaceID, flats.Items)
+	if err != nil {
+		s.errHandler(err)
+		return err
 	}
 
 	j, err := json.Marshal(flats)
@@ -104,22 +197,46 @@ func (s *FlatService) GetAll(w http.ResponseWriter, r *http.Request) error {
 	return nil
 }
 
+// Put applies the changes in the request body to the flat. Flats are shared
+// by the workspaces holding the same listing, so only admins may edit a flat
+// that another workspace holds as well.
 func (s *FlatService) Put(w http.ResponseWriter, r *http.Request) error {
+	userID, workspaceID, err := s.workspaces.RequestWorkspaceID(r)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	now := s.NowFunc()
 	id := r.PathValue("id")
-	var flat models.Flat
-	err := json.NewDecoder(r.Body).Decode(&flat)
+	var update models.Flat
+	err = json.NewDecoder(r.Body).Decode(&update)
 	if err != nil {
 		s.errHandler(err)
 		return err
 	}
 	err = s.inTx(r.Context(), func(tx models.Tx) error {
-		flat.ID = id
-		err := tx.UpdateFlat(flat)
+		err := checkFlatEditor(tx, userID, workspaceID, id)
 		if err != nil {
 			return err
 		}
 
-		return nil
+		flat, err := tx.GetFlatByID(id)
+		if err != nil {
+			return err
+		}
+
+		changes := diffFlat(flat, update, now)
+		applyChanges(&flat, changes)
+		normalize.Flat(&flat, now)
+		flat.UpdatedAt = now
+
+		err = tx.UpdateFlat(flat)
+		if err != nil {
+			return err
+		}
+
+		return saveFlatChanges(tx, flat, changes, now)
 	})
 	if err != nil {
 		s.errHandler(err)
@@ -129,27 +246,114 @@ func (s *FlatService) Put(w http.ResponseWriter, r *http.Request) error {
 	return nil
 }
 
+// checkFlatEditor checks that the user may edit the flat: it belongs to their
+// workspace alone, or they are an admin.
+func checkFlatEditor(tx models.Tx, userID, workspaceID, flatID string) error {
+	held, err := tx.FindFlatWorkspaces(flatID)
+	if err != nil {
+		return err
+	}
+
+	if !slices.Contains(held, workspaceID) {
+		return fmt.Errorf("flat not found: %w", custerrors.ErrNotFound)
+	}
+	if len(held) == 1 {
+		return nil
+	}
+
+	users, err := tx.FindUsers(models.UserFilter{IDs: []string{userID}})
+	if err != nil {
+		return err
+	}
+	if len(users) == 1 && users[0].Role == "admin" {
+		return nil
+	}
+
+	return fmt.Errorf("flat is shared with other workspaces: %w", custerrors.ErrForbidden)
+}
+
+// Post queues a scrape job for the listing URL in the request body and
+// responds with the job. The flat is created once the job succeeded and added
+// to the workspace of the user. A listing stored already is added to the
+// workspace right away.
 func (s *FlatService) Post(w http.ResponseWriter, r *http.Request) error {
-	var url models.Url
-	err := json.NewDecoder(r.Body).Decode(&url)
+	userID, workspaceID, err := s.workspaces.RequestWorkspaceID(r)
 	if err != nil {
 		s.errHandler(err)
 		return err
 	}
-	flat, err := s.collector.Parse(url.Url)
+
+	var url models.Url
+	err = json.NewDecoder(r.Body).Decode(&url)
 	if err != nil {
 		s.errHandler(err)
 		return err
 	}
 
-	err = s.inTx(r.Context(), func(tx models.Tx) error {
-		err := tx.CreateFlat(flat)
+	if c := parsers.CanonicalURL(url.Url); c != "" {
+		existing, err := s.rep.FindFlats(r.Context(), models.FlatFilter{SourceURLs: []string{c}})
 		if err != nil {
+			s.errHandler(err)
+			return err
+		}
+		if len(existing) > 0 {
+			err = s.inTx(r.Context(), func(tx models.Tx) error {
+				return tx.AddWorkspaceFlat(workspaceID, existing[0].ID, userID, s.NowFunc())
+			})
+			if err == nil {
+				err = &DuplicateFlatError{ID: existing[0].ID}
+			}
+			s.errHandler(err)
 			return err
 		}
+	}
 
-		return nil
-	})
+	job, err := s.jobs.Enqueue(r.Context(), url.Url, userID)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	j, err := json.Marshal(job)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/api/v1/scrape-jobs/"+job.ID)
+	w.WriteHeader(http.StatusAccepted)
+	_, err = w.Write(j)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	return nil
+}
+
+// Preview parses the listing in the request without storing anything and
+// responds with the result. The request is read by readPageUpload.
+func (s *FlatService) Preview(w http.ResponseWriter, r *http.Request) error {
+	req, err := s.readPageUpload(w, r)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	preview, err := s.jobs.Preview(r.Context(), req)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	j, err := json.Marshal(preview)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(j)
 	if err != nil {
 		s.errHandler(err)
 		return err
@@ -158,24 +362,279 @@ func (s *FlatService) Post(w http.ResponseWriter, r *http.Request) error {
 	return nil
 }
 
+// Import creates the flat from the listing page in the request, read by
+// readPageUpload, and responds with the flat. A flat already stored for the
+// listing is updated. The flat is added to the workspace of the user.
+func (s *FlatService) Import(w http.ResponseWriter, r *http.Request) error {
+	userID, _, err := s.workspaces.RequestWorkspaceID(r)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	req, err := s.readPageUpload(w, r)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	flat, created, err := s.jobs.Import(r.Context(), req, userID)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	j, err := json.Marshal(flat)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/api/v1/flats/"+flat.ID)
+	if created {
+		w.WriteHeader(http.StatusCreated)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+	_, err = w.Write(j)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	return nil
+}
+
+// readPageUpload reads the listing page of a preview or import request. The
+// request is either JSON or a multipart form with the listing URL in the
+// field "url" and the page saved as HTML or MHTML in the file "html". The
+// URL recorded in an MHTML file is used if the field is empty.
+func (s *FlatService) readPageUpload(w http.ResponseWriter, r *http.Request) (models.PageUpload, error) {
+	limit := int64(s.jobs.collector.MaxBodySize)
+	if limit <= 0 {
+		limit = 10 << 20
+	}
+	// Leave room for the rest of the request around the page.
+	r.Body = http.MaxBytesReader(w, r.Body, limit+1<<20)
+
+	var req models.PageUpload
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			return models.PageUpload{}, fmt.Errorf("invalid page upload: %v: %w", err, custerrors.ErrInvalidInput)
+		}
+
+		return req, nil
+	}
+
+	err := r.ParseMultipartForm(1 << 20)
+	if err != nil {
+		return models.PageUpload{}, fmt.Errorf("invalid form: %v: %w", err, custerrors.ErrInvalidInput)
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	req.URL = r.FormValue("url")
+	file, header, err := r.FormFile("html")
+	if errors.Is(err, http.ErrMissingFile) {
+		return req, nil
+	}
+	if err != nil {
+		return models.PageUpload{}, err
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return models.PageUpload{}, err
+	}
+
+	page, err := parsers.ReadSavedPage(header.Filename, data)
+	if err != nil {
+		return models.PageUpload{}, err
+	}
+
+	req.HTML = string(page.Body)
+	if req.URL == "" {
+		req.URL = page.URL
+	}
+
+	return req, nil
+}
+
+// Delete takes the flat out of the workspace of the user. A flat no
+// workspace holds anymore is deleted with the stored images of its photos.
 func (s *FlatService) Delete(w http.ResponseWriter, r *http.Request) error {
+	_, workspaceID, err := s.workspaces.RequestWorkspaceID(r)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
 	id := r.PathValue("id")
-	err := s.inTx(r.Context(), func(tx models.Tx) error {
-		err := tx.DeleteFlat(id)
+	var photos []models.Photo
+	err = s.inTx(r.Context(), func(tx models.Tx) error {
+		err := tx.RemoveWorkspaceFlat(workspaceID, id)
 		if err != nil {
 			return err
 		}
 
-		return nil
+		held, err := tx.FindFlatWorkspaces(id)
+		if err != nil || len(held) > 0 {
+			return err
+		}
+
+		photos, err = tx.FindPhotos(id)
+		if err != nil {
+			return err
+		}
+
+		return tx.DeleteFlat(id)
 	})
 	if err != nil {
 		s.errHandler(err)
 		return err
 	}
 
+	// The flat is gone, leftover blobs are only logged.
+	err = s.photos.DeleteBlobs(r.Context(), photos)
+	if err != nil {
+		s.errHandler(err)
+	}
+
 	return nil
 }
 
+// parseFlatFilter builds a filter from the query parameters of a request.
+// Amounts of money are given in whole currency units, cities and districts
+// are spelled any way the district dictionary knows.
+func parseFlatFilter(v url.Values) (models.FlatFilter, error) {
+	var (
+		f    models.FlatFilter
+		errs error
+	)
+
+	parse := func(name string, fn func(string) error) {
+		raw := strings.TrimSpace(v.Get(name))
+		if raw == "" {
+			return
+		}
+
+		if err := fn(raw); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("invalid query parameter %q: %w", name, custerrors.ErrInvalidInput))
+		}
+	}
+
+	parse("price_min", moneyParam(&f.MinPrice))
+	parse("price_max", moneyParam(&f.MaxPrice))
+	parse("rent_min", moneyParam(&f.MinRent))
+	parse("rent_max", moneyParam(&f.MaxRent))
+	parse("surface_min", floatParam(&f.MinSurface))
+	parse("surface_max", floatParam(&f.MaxSurface))
+	parse("floor", func(raw string) error {
+		err := intParam(&f.MinFloor)(raw)
+		f.MaxFloor = f.MinFloor
+		return err
+	})
+	parse("floor_min", intParam(&f.MinFloor))
+	parse("floor_max", intParam(&f.MaxFloor))
+	parse("rooms", func(raw string) error {
+		for _, p := range strings.Split(raw, ",") {
+			n, err := strconv.Atoi(strings.TrimSpace(p))
+			if err != nil || n < 0 {
+				return custerrors.ErrInvalidInput
+			}
+			f.Rooms = append(f.Rooms, n)
+		}
+		return nil
+	})
+	// Names are matched with the district dictionary, whatever the spelling.
+	parse("city", func(raw string) error {
+		f.City = raw
+		if name, ok := normalize.Districts().City(raw); ok {
+			f.City = name
+		}
+		return nil
+	})
+	parse("district", func(raw string) error {
+		f.District = raw
+		if name, ok := normalize.Districts().District(f.City, raw); ok {
+			f.District = name
+		}
+		return nil
+	})
+	parse("q", func(raw string) error {
+		f.Query = raw
+		return nil
+	})
+	parse("available_from", timeParam(&f.AvailableFrom))
+	parse("created_after", timeParam(&f.CreatedAfter))
+	parse("created_before", timeParam(&f.CreatedBefore))
+
+	if errs != nil {
+		return models.FlatFilter{}, errs
+	}
+
+	return f, nil
+}
+
+func moneyParam(tgt **int64) func(string) error {
+	return func(raw string) error {
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil || v < 0 || math.IsInf(v, 0) || math.IsNaN(v) {
+			return custerrors.ErrInvalidInput
+		}
+
+		amount := int64(math.Round(v * 100))
+		*tgt = &amount
+		return nil
+	}
+}
+
+func floatParam(tgt **float64) func(string) error {
+	return func(raw string) error {
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil || v < 0 || math.IsInf(v, 0) || math.IsNaN(v) {
+			return custerrors.ErrInvalidInput
+		}
+
+		*tgt = &v
+		return nil
+	}
+}
+
+func intParam(tgt **int) func(string) error {
+	return func(raw string) error {
+		v, err := strconv.Atoi(raw)
+		if err != nil {
+			return custerrors.ErrInvalidInput
+		}
+
+		*tgt = &v
+		return nil
+	}
+}
+
+// timeParam accepts RFC 3339 timestamps and plain dates (YYYY-MM-DD).
+func timeParam(tgt **time.Time) func(string) error {
+	return func(raw string) error {
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			t, err = time.Parse(time.DateOnly, raw)
+		}
+		if err != nil {
+			return custerrors.ErrInvalidInput
+		}
+
+		*tgt = &t
+		return nil
+	}
+}
+
+// flatURL returns the link to the flat with id in hestia reached at baseURL.
+func flatURL(baseURL, id string) string {
+	return strings.TrimSuffix(baseURL, "/") + "/api/v1/flats/" + id
+}
+
 func (s *FlatService) inTx(ctx context.Context, f func(tx models.Tx) error) error {
 	tx, err := s.rep.BeginTx(ctx)
 	if err != nil {
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"errors"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"hestia/pkg/custerrors"
+	"hestia/pkg/models"
+)
+
+func Test_parseFlatFilter(t *testing.T) {
+	tests := map[string]struct {
+		query     string
+		want      models.FlatFilter
+		wantParam string
+	}{
+		"ok, empty": {
+			query: "",
+			want:  models.FlatFilter{},
+		},
+		"ok, all filters": {
+			query: "price_min=2000&price_max=3500.50&rent_min=0&rent_max=800&surface_min=30&surface_max=60.5" +
+				"&rooms=2,3&floor_min=1&floor_max=4&city=Warszawa&district=Mokot%C3%B3w" +
+				"&available_from=2026-12-01&created_after=2026-10-01T00:00:00Z&created_before=2026-10-31",
+			want: models.FlatFilter{
+				MinPrice:      ptr(int64(200000)),
+				MaxPrice:      ptr(int64(350050)),
+				MinRent:       ptr(int64(0)),
+				MaxRent:       ptr(int64(80000)),
+				MinSurface:    ptr(30.0),
+				MaxSurface:    ptr(60.5),
+				Rooms:         []int{2, 3},
+				MinFloor:      ptr(1),
+				MaxFloor:      ptr(4),
+				City:          "Warszawa",
+				District:      "Mokotów",
+				AvailableFrom: ptr(time.Date(2026, 12, 1, 0, 0, 0, 0, time.UTC)),
+				CreatedAfter:  ptr(time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)),
+				CreatedBefore: ptr(time.Date(2026, 10, 31, 0, 0, 0, 0, time.UTC)),
+			},
+		},
+		"ok, search": {
+			query: "q=balkon+gara%C5%BC&rooms=2",
+			want: models.FlatFilter{
+				Rooms: []int{2},
+				Query: "balkon garaż",
+			},
+		},
+		"ok, names from the dictionary": {
+			query: "city=warszawa&district=praga+poludnie",
+			want: models.FlatFilter{
+				City:     "Warszawa",
+				District: "Praga-Południe",
+			},
+		},
+		"ok, district without city": {
+			query: "district=KABATY",
+			want: models.FlatFilter{
+				District: "Kabaty",
+			},
+		},
+		"ok, unknown names": {
+			query: "city=Pcim&district=Centrum",
+			want: models.FlatFilter{
+				City:     "Pcim",
+				District: "Centrum",
+			},
+		},
+		"ok, exact floor": {
+			query: "floor=0",
+			want: models.FlatFilter{
+				MinFloor: ptr(0),
+				MaxFloor: ptr(0),
+			},
+		},
+		"fail, price not a number": {
+			query:     "price_min=cheap",
+			wantParam: "price_min",
+		},
+		"fail, negative surface": {
+			query:     "surface_max=-1",
+			wantParam: "surface_max",
+		},
+		"fail, rooms list": {
+			query:     "rooms=2,x",
+			wantParam: "rooms",
+		},
+		"fail, date": {
+			query:     "available_from=01.12.2026",
+			wantParam: "available_from",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			v, err := url.ParseQuery(tc.query)
+			if err != nil {
+				t.Fatalf("failed to parse query: %v", err)
+			}
+
+			got, err := parseFlatFilter(v)
+			if tc.wantParam != "" {
+				if !errors.Is(err, custerrors.ErrInvalidInput) {
+					t.Fatalf("expected errors to be %v got %v (via errors.Is)", custerrors.ErrInvalidInput, err)
+				}
+				if !strings.Contains(err.Error(), tc.wantParam) {
+					t.Errorf("expected error %q to name parameter %q", err, tc.wantParam)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("failed to parse filter: %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got\n%#v\nwant\n%#v\n", got, tc.want)
+			}
+		})
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
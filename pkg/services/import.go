@@ -0,0 +1,89 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"hestia/pkg/custerrors"
+	"hestia/pkg/models"
+	"hestia/pkg/utils/normalize"
+	"hestia/pkg/utils/parsers"
+)
+
+// Import parses the page of up with the parser for its URL and stores the
+// flat right away, for listings the server cannot fetch itself. A flat
+// already scraped from the same listing is updated instead. It reports
+// whether the flat was created. The flat is added to the workspace of
+// requestedBy. The page is kept in the archive like a scraped one.
+func (s *ScrapeService) Import(ctx context.Context, up models.PageUpload, requestedBy string) (models.Flat, bool, error) {
+	if strings.TrimSpace(up.URL) == "" {
+		return models.Flat{}, false, fmt.Errorf("missing listing url: %w", custerrors.ErrInvalidInput)
+	}
+	if up.HTML == "" {
+		return models.Flat{}, false, fmt.Errorf("missing listing page: %w", custerrors.ErrInvalidInput)
+	}
+
+	url := up.URL
+	if c := parsers.CanonicalURL(url); c != "" {
+		url = c
+	}
+
+	now := s.NowFunc()
+	res, err := s.collector.ParsePage(parsers.Page{
+		URL:         url,
+		FinalURL:    up.URL,
+		StatusCode:  http.StatusOK,
+		ContentType: "text/html; charset=utf-8",
+		Body:        []byte(up.HTML),
+		FetchedAt:   now,
+	})
+	if err != nil {
+		s.archive.record(ctx, res.Page, "", requestedBy, err)
+		if errors.Is(err, parsers.ErrListingGone) || errors.Is(err, parsers.ErrIncompleteListing) {
+			err = fmt.Errorf("%w: %w", custerrors.ErrInvalidInput, err)
+		}
+		return models.Flat{}, false, err
+	}
+
+	flat := res.Flat
+	normalize.Flat(&flat, now)
+	flat.SourceURL = url
+	flat.CreatedAt = now
+	flat.UpdatedAt = now
+
+	created := false
+	err = s.inTx(ctx, func(tx models.Tx) error {
+		existing, err := tx.FindFlats(models.FlatFilter{SourceURLs: []string{url}})
+		if err != nil {
+			return err
+		}
+		created = len(existing) == 0
+
+		flat.ID, err = saveScrapedFlat(tx, flat, now)
+		if err != nil {
+			return err
+		}
+
+		err = tx.CreatePhotos(newPhotos(flat.ID, res.Photos, now))
+		if err != nil {
+			return err
+		}
+
+		return addRequestedFlat(tx, requestedBy, flat.ID, now)
+	})
+	if err != nil {
+		return models.Flat{}, false, err
+	}
+
+	s.archive.record(ctx, res.Page, flat.ID, requestedBy, nil)
+
+	stored, err := s.rep.GetFlatByID(ctx, flat.ID)
+	if err != nil {
+		return models.Flat{}, false, err
+	}
+
+	return stored, created, nil
+}
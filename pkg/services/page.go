@@ -0,0 +1,30 @@
+package services
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"hestia/pkg/custerrors"
+	"hestia/pkg/models"
+)
+
+// parsePageRequest reads the limit, cursor and sort query parameters of a list request.
+func parsePageRequest(v url.Values) (models.PageRequest, error) {
+	p := models.PageRequest{
+		Limit:  models.DefaultPageLimit,
+		Cursor: v.Get("cursor"),
+		Sort:   v.Get("sort"),
+	}
+
+	if raw := v.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > models.MaxPageLimit {
+			return models.PageRequest{}, fmt.Errorf("invalid query parameter %q, must be between 1 and %d: %w",
+				"limit", models.MaxPageLimit, custerrors.ErrInvalidInput)
+		}
+		p.Limit = limit
+	}
+
+	return p, nil
+}
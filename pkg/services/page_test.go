@@ -0,0 +1,65 @@
+package services
+
+import (
+	"errors"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"hestia/pkg/custerrors"
+	"hestia/pkg/models"
+)
+
+func Test_parsePageRequest(t *testing.T) {
+	tests := map[string]struct {
+		query   string
+		want    models.PageRequest
+		wantErr error
+	}{
+		"ok, defaults": {
+			query: "",
+			want:  models.PageRequest{Limit: models.DefaultPageLimit},
+		},
+		"ok, all parameters": {
+			query: "limit=5&cursor=abc&sort=-price",
+			want:  models.PageRequest{Limit: 5, Cursor: "abc", Sort: "-price"},
+		},
+		"fail, limit not a number": {
+			query:   "limit=all",
+			wantErr: custerrors.ErrInvalidInput,
+		},
+		"fail, limit too large": {
+			query:   "limit=1000",
+			wantErr: custerrors.ErrInvalidInput,
+		},
+		"fail, limit zero": {
+			query:   "limit=0",
+			wantErr: custerrors.ErrInvalidInput,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			v, err := url.ParseQuery(tc.query)
+			if err != nil {
+				t.Fatalf("failed to parse query: %v", err)
+			}
+
+			got, err := parsePageRequest(v)
+			if tc.wantErr != nil {
+				if !errors.Is(err, tc.wantErr) {
+					t.Fatalf("expected errors to be %v got %v (via errors.Is)", tc.wantErr, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("failed to parse page request: %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got\n%#v\nwant\n%#v\n", got, tc.want)
+			}
+		})
+	}
+}
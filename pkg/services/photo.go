@@ -0,0 +1,421 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+
+	"hestia/pkg/blobs"
+	"hestia/pkg/custerrors"
+	"hestia/pkg/models"
+	"hestia/pkg/repos"
+	"hestia/pkg/utils/parsers"
+)
+
+// errUnusablePhoto is returned for photos that will not download on another attempt.
+var errUnusablePhoto = errors.New("unusable photo")
+
+// PhotoConfig is the configuration for downloading the photos of flats.
+type PhotoConfig struct {
+	// Workers is the number of photos downloaded at the same time.
+	Workers int
+	// MaxAttempts is the number of times a photo is tried before it fails.
+	MaxAttempts int
+	// PollInterval is how often idle workers look for due photos.
+	PollInterval time.Duration
+	// RetryDelay is the delay before the second attempt of a photo. It
+	// doubles with every further attempt.
+	RetryDelay time.Duration
+	// Timeout limits the download of a single photo.
+	Timeout time.Duration
+	// MaxSize is the largest image accepted, in bytes.
+	MaxSize int
+	// MaxPixels is the largest width times height of an image accepted,
+	// checked before it is decoded. Zero means no limit.
+	MaxPixels int
+	// ThumbnailSize is the length of the longer side of thumbnails, in pixels.
+	ThumbnailSize int
+}
+
+// PhotoService downloads the photos of flats into the blob store in the
+// background and serves them.
+type PhotoService struct {
+	rep        *repos.Store
+	wg         *sync.WaitGroup
+	blobs      blobs.Store
+	collector  *parsers.Collector
+	cfg        PhotoConfig
+	errHandler ErrFunc
+
+	// NowFunc is used to get the current time.
+	// Exposed for testing purposes.
+	NowFunc func() time.Time
+}
+
+// NewPhotoService creates a new Service. Photos are downloaded with
+// collector, like the listings they belong to.
+func NewPhotoService(db *sql.DB, store blobs.Store, collector *parsers.Collector, cfg PhotoConfig, errHandler ErrFunc) *PhotoService {
+	svc := &PhotoService{
+		rep:        repos.New(db),
+		wg:         &sync.WaitGroup{},
+		blobs:      store,
+		collector:  collector,
+		cfg:        cfg,
+		errHandler: errHandler,
+
+		NowFunc: time.Now,
+	}
+
+	return svc
+}
+
+// List writes the photos of the flat in gallery order.
+func (s *PhotoService) List(w http.ResponseWriter, r *http.Request) error {
+	flatID := r.PathValue("id")
+	_, err := s.rep.GetFlatByID(r.Context(), flatID)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	photos, err := s.rep.FindPhotos(r.Context(), flatID)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	for i := range photos {
+		setPhotoURLs(&photos[i])
+	}
+
+	j, err := json.Marshal(photos)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(j)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	return nil
+}
+
+// Get writes the stored image of the photo.
+func (s *PhotoService) Get(w http.ResponseWriter, r *http.Request) error {
+	p, err := s.storedPhoto(r)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	return s.serve(w, r, p.BlobKey, p.ContentType)
+}
+
+// Thumbnail writes the thumbnail of the photo.
+func (s *PhotoService) Thumbnail(w http.ResponseWriter, r *http.Request) error {
+	p, err := s.storedPhoto(r)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	return s.serve(w, r, p.ThumbnailKey, "image/jpeg")
+}
+
+// storedPhoto returns the photo in the path of r, if it was downloaded.
+func (s *PhotoService) storedPhoto(r *http.Request) (models.Photo, error) {
+	p, err := s.rep.GetPhoto(r.Context(), r.PathValue("id"), r.PathValue("photoID"))
+	if err != nil {
+		return models.Photo{}, err
+	}
+
+	if p.Status != models.PhotoStatusStored {
+		return models.Photo{}, fmt.Errorf("photo %s is %s: %w", p.ID, p.Status, custerrors.ErrNotFound)
+	}
+
+	return p, nil
+}
+
+func (s *PhotoService) serve(w http.ResponseWriter, r *http.Request, key, contentType string) error {
+	blob, err := s.blobs.Get(r.Context(), key)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	defer blob.Close()
+
+	// Stored images do not change.
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "private, max-age=86400")
+	w.WriteHeader(http.StatusOK)
+	_, err = io.Copy(w, blob)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	return nil
+}
+
+// DeleteBlobs removes the stored images and thumbnails of photos.
+func (s *PhotoService) DeleteBlobs(ctx context.Context, photos []models.Photo) error {
+	var errs error
+	for _, p := range photos {
+		for _, key := range []string{p.BlobKey, p.ThumbnailKey} {
+			if key == "" {
+				continue
+			}
+
+			err := s.blobs.Delete(ctx, key)
+			if err != nil {
+				errs = errors.Join(errs, fmt.Errorf("failed to delete blob %s: %w", key, err))
+			}
+		}
+	}
+
+	return errs
+}
+
+// Run starts the workers and blocks until ctx is done and all workers
+// stopped. Photos left downloading by a previous process are queued again first.
+func (s *PhotoService) Run(ctx context.Context) error {
+	_, err := s.rep.RequeuePhotos(ctx, s.NowFunc())
+	if err != nil {
+		return fmt.Errorf("failed to requeue photos: %w", err)
+	}
+
+	for range max(s.cfg.Workers, 1) {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.work(ctx)
+		}()
+	}
+
+	s.wg.Wait()
+
+	return nil
+}
+
+// work claims and downloads due photos until ctx is done.
+func (s *PhotoService) work(ctx context.Context) {
+	for {
+		p, err := s.rep.ClaimPhoto(ctx, s.NowFunc())
+		if err == nil {
+			s.process(ctx, p)
+			continue
+		}
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		if !errors.Is(err, custerrors.ErrNotFound) {
+			s.errHandler(fmt.Errorf("failed to claim photo: %w", err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(s.cfg.PollInterval):
+		}
+	}
+}
+
+// process downloads the photo and records the outcome. A photo that cannot
+// be finished because ctx is done stays downloading and is requeued by the next Run.
+func (s *PhotoService) process(ctx context.Context, p models.Photo) {
+	err := s.download(ctx, &p)
+	if ctx.Err() != nil {
+		return
+	}
+
+	now := s.NowFunc()
+	p.UpdatedAt = now
+	if err == nil {
+		p.Status = models.PhotoStatusStored
+		p.LastError = ""
+	} else {
+		s.errHandler(fmt.Errorf("photo %s attempt %d failed: %w", p.ID, p.Attempts, err))
+
+		p.LastError = err.Error()
+		p.Status = models.PhotoStatusPending
+		p.RunAfter = now.Add(s.cfg.RetryDelay << max(p.Attempts-1, 0))
+		if p.Attempts >= s.cfg.MaxAttempts || errors.Is(err, errUnusablePhoto) {
+			p.Status = models.PhotoStatusFailed
+		}
+	}
+
+	err = s.inTx(ctx, func(tx models.Tx) error {
+		return tx.UpdatePhoto(p)
+	})
+	if errors.Is(err, custerrors.ErrNotFound) {
+		// The flat was deleted during the download.
+		err = s.DeleteBlobs(ctx, []models.Photo{p})
+	}
+	if err != nil {
+		s.errHandler(fmt.Errorf("failed to update photo %s: %w", p.ID, err))
+	}
+}
+
+// download fetches the image of p and stores it with its thumbnail.
+func (s *PhotoService) download(ctx context.Context, p *models.Photo) error {
+	if s.cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.cfg.Timeout)
+		defer cancel()
+	}
+
+	status, data, err := s.collector.Fetch(ctx, p.SourceURL, s.cfg.MaxSize)
+	switch {
+	case errors.Is(err, parsers.ErrBodyTooLarge):
+		return fmt.Errorf("%w: larger than %d bytes", errUnusablePhoto, s.cfg.MaxSize)
+	case errors.Is(err, parsers.ErrRobotsDisallowed):
+		return fmt.Errorf("%w: %v", errUnusablePhoto, err)
+	case err != nil:
+		return err
+	case status == http.StatusNotFound || status == http.StatusGone:
+		return fmt.Errorf("%w: status %d", errUnusablePhoto, status)
+	case status != http.StatusOK:
+		return fmt.Errorf("unexpected status %d", status)
+	}
+
+	// Small files can decode to huge images, check the size before decoding.
+	conf, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("%w: %v", errUnusablePhoto, err)
+	}
+	if s.cfg.MaxPixels > 0 && conf.Width*conf.Height > s.cfg.MaxPixels {
+		return fmt.Errorf("%w: %dx%d is more than %d pixels", errUnusablePhoto, conf.Width, conf.Height, s.cfg.MaxPixels)
+	}
+
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("%w: %v", errUnusablePhoto, err)
+	}
+
+	var thumb bytes.Buffer
+	err = jpeg.Encode(&thumb, thumbnail(img, s.cfg.ThumbnailSize), &jpeg.Options{Quality: 80})
+	if err != nil {
+		return err
+	}
+
+	prefix := fmt.Sprintf("flats/%s/photos/%s", p.FlatID, p.ID)
+	blobKey, thumbKey := prefix+"."+format, prefix+"_thumb.jpg"
+
+	err = s.blobs.Put(ctx, blobKey, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	err = s.blobs.Put(ctx, thumbKey, &thumb)
+	if err != nil {
+		return err
+	}
+
+	p.BlobKey = blobKey
+	p.ThumbnailKey = thumbKey
+	p.ContentType = "image/" + format
+	p.Width = img.Bounds().Dx()
+	p.Height = img.Bounds().Dy()
+	p.Size = int64(len(data))
+
+	return nil
+}
+
+// thumbnail scales img down to fit a square with the given side, on a white
+// background for transparent images. Smaller images keep their size.
+func thumbnail(img image.Image, side int) image.Image {
+	b := img.Bounds()
+	w, h := fitSize(b.Dx(), b.Dy(), side)
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Over, nil)
+
+	return dst
+}
+
+// fitSize returns the size of a w x h image scaled down to fit a square with
+// the given side, keeping its aspect ratio.
+func fitSize(w, h, side int) (int, int) {
+	if side <= 0 || (w <= side && h <= side) {
+		return w, h
+	}
+
+	if w >= h {
+		return side, max(h*side/w, 1)
+	}
+
+	return max(w*side/h, 1), side
+}
+
+// newPhotos returns the photos to queue for the gallery of the flat with the given id.
+func newPhotos(flatID string, urls []string, now time.Time) []models.Photo {
+	photos := make([]models.Photo, 0, len(urls))
+	for i, u := range urls {
+		photos = append(photos, models.Photo{
+			FlatID:    flatID,
+			SourceURL: u,
+			Position:  i,
+			Status:    models.PhotoStatusPending,
+			RunAfter:  now,
+			CreatedAt: now,
+			UpdatedAt: now,
+		})
+	}
+
+	return photos
+}
+
+// setPhotoURLs sets where the images of a stored photo are served.
+func setPhotoURLs(p *models.Photo) {
+	if p.Status != models.PhotoStatusStored {
+		return
+	}
+
+	p.URL = fmt.Sprintf("/api/v1/flats/%s/photos/%s", p.FlatID, p.ID)
+	p.ThumbnailURL = p.URL + "/thumbnail"
+}
+
+func (s *PhotoService) inTx(ctx context.Context, f func(tx models.Tx) error) error {
+	tx, err := s.rep.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = f(tx)
+	if err != nil {
+		rBackErr := tx.Rollback()
+		if rBackErr != nil {
+			err = errors.Join(err, rBackErr)
+		}
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
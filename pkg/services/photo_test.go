@@ -0,0 +1,153 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gocolly/colly/v2"
+
+	"hestia/pkg/blobs"
+	"hestia/pkg/models"
+	"hestia/pkg/utils/parsers"
+)
+
+func Test_fitSize(t *testing.T) {
+	tests := map[string]struct {
+		w, h, side   int
+		wantW, wantH int
+	}{
+		"ok, landscape":      {w: 1600, h: 1200, side: 320, wantW: 320, wantH: 240},
+		"ok, portrait":       {w: 900, h: 1600, side: 320, wantW: 180, wantH: 320},
+		"ok, already small":  {w: 200, h: 100, side: 320, wantW: 200, wantH: 100},
+		"ok, very wide":      {w: 5000, h: 2, side: 320, wantW: 320, wantH: 1},
+		"ok, no size limits": {w: 1600, h: 1200, side: 0, wantW: 1600, wantH: 1200},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			w, h := fitSize(tc.w, tc.h, tc.side)
+			if w != tc.wantW || h != tc.wantH {
+				t.Errorf("got %dx%d want %dx%d", w, h, tc.wantW, tc.wantH)
+			}
+		})
+	}
+}
+
+func Test_PhotoService_download(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 64, 32))
+	for x := range 64 {
+		for y := range 32 {
+			img.Set(x, y, color.RGBA{R: uint8(x * 4), G: uint8(y * 8), B: 128, A: 255})
+		}
+	}
+
+	var pngData bytes.Buffer
+	err := png.Encode(&pngData, img)
+	if err != nil {
+		t.Fatalf("failed to encode fixture: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/photo.png", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(pngData.Bytes())
+	})
+	mux.HandleFunc("/text", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("not an image"))
+	})
+	mux.HandleFunc("/flaky", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	store, err := blobs.NewFS(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create blob store: %v", err)
+	}
+
+	s := &PhotoService{
+		blobs:     store,
+		collector: parsers.NewCollector(colly.NewCollector(colly.AllowURLRevisit()), parsers.NewRegistry(), func(err error) {}),
+		cfg:       PhotoConfig{MaxSize: 1 << 20, ThumbnailSize: 16},
+	}
+
+	tests := map[string]struct {
+		path          string
+		maxSize       int
+		maxPixels     int
+		wantUnusable  bool
+		wantRetryable bool
+	}{
+		"ok, png":               {path: "/photo.png"},
+		"ok, png at max size":   {path: "/photo.png", maxSize: pngData.Len()},
+		"ok, png at max pixels": {path: "/photo.png", maxPixels: 64 * 32},
+		"fail, not an image":    {path: "/text", wantUnusable: true},
+		"fail, gone":            {path: "/missing", wantUnusable: true},
+		"fail, too large":       {path: "/photo.png", maxSize: 16, wantUnusable: true},
+		"fail, too many pixels": {path: "/photo.png", maxPixels: 64*32 - 1, wantUnusable: true},
+		"fail, server errors":   {path: "/flaky", wantRetryable: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			s.cfg.MaxSize = 1 << 20
+			if tc.maxSize > 0 {
+				s.cfg.MaxSize = tc.maxSize
+			}
+			s.cfg.MaxPixels = tc.maxPixels
+
+			p := models.Photo{ID: "3", FlatID: "7", SourceURL: srv.URL + tc.path}
+			err := s.download(context.Background(), &p)
+			if tc.wantUnusable || tc.wantRetryable {
+				if err == nil {
+					t.Fatalf("expected an error")
+				}
+				if errors.Is(err, errUnusablePhoto) != tc.wantUnusable {
+					t.Errorf("expected unusable to be %t got %v", tc.wantUnusable, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("failed to download photo: %v", err)
+			}
+
+			want := models.Photo{
+				ID:           "3",
+				FlatID:       "7",
+				SourceURL:    p.SourceURL,
+				ContentType:  "image/png",
+				Width:        64,
+				Height:       32,
+				Size:         int64(pngData.Len()),
+				BlobKey:      "flats/7/photos/3.png",
+				ThumbnailKey: "flats/7/photos/3_thumb.jpg",
+			}
+			if p != want {
+				t.Errorf("got\n%#v\nwant\n%#v\n", p, want)
+			}
+
+			r, err := store.Get(context.Background(), p.ThumbnailKey)
+			if err != nil {
+				t.Fatalf("failed to get thumbnail: %v", err)
+			}
+			defer r.Close()
+
+			thumb, err := jpeg.Decode(r)
+			if err != nil {
+				t.Fatalf("failed to decode thumbnail: %v", err)
+			}
+			if got := thumb.Bounds().Size(); got != image.Pt(16, 8) {
+				t.Errorf("got thumbnail size %v want %v", got, image.Pt(16, 8))
+			}
+		})
+	}
+}
@@ -0,0 +1,305 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"hestia/pkg/custerrors"
+	"hestia/pkg/models"
+	"hestia/pkg/repos"
+)
+
+// stageMoves are the stages a flat may be moved to from each stage: on to
+// the next stage, to rejected from any stage and back to new once rejected.
+var stageMoves = map[models.Stage][]models.Stage{
+	models.StageNew:       {models.StageContacted, models.StageRejected},
+	models.StageContacted: {models.StageViewing, models.StageRejected},
+	models.StageViewing:   {models.StageApplied, models.StageRejected},
+	models.StageApplied:   {models.StageRejected},
+	models.StageRejected:  {models.StageNew},
+}
+
+// PipelineService tracks where the workspace of the user making the request
+// is with each flat in the flat hunt.
+type PipelineService struct {
+	rep        *repos.Store
+	wg         *sync.WaitGroup
+	errHandler ErrFunc
+	workspaces *WorkspaceService
+
+	// NowFunc is used to get the current time.
+	// Exposed for testing purposes.
+	NowFunc func() time.Time
+}
+
+// NewPipelineService creates a new Service.
+func NewPipelineService(db *sql.DB, workspaces *WorkspaceService, errHandler ErrFunc) *PipelineService {
+	svc := &PipelineService{
+		rep:        repos.New(db),
+		wg:         &sync.WaitGroup{},
+		errHandler: errHandler,
+		workspaces: workspaces,
+
+		NowFunc: time.Now,
+	}
+
+	return svc
+}
+
+// Board writes the flats of the workspace grouped by stage, in the order of
+// the flat hunt. The stage query parameter limits the board to some stages.
+func (s *PipelineService) Board(w http.ResponseWriter, r *http.Request) error {
+	_, workspaceID, err := s.workspaces.RequestWorkspaceID(r)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	filter := models.FlatStatusFilter{WorkspaceID: workspaceID}
+	if raw := r.URL.Query().Get("stage"); raw != "" {
+		for _, p := range strings.Split(raw, ",") {
+			stage := models.Stage(strings.TrimSpace(p))
+			if _, ok := stageMoves[stage]; !ok {
+				err = fmt.Errorf("unknown stage %q: %w", stage, custerrors.ErrInvalidInput)
+				s.errHandler(err)
+				return err
+			}
+			filter.Stages = append(filter.Stages, stage)
+		}
+	}
+
+	statuses, err := s.rep.FindFlatStatuses(r.Context(), filter)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	var flats []models.Flat
+	if len(statuses) > 0 {
+		ids := make([]string, 0, len(statuses))
+		for _, st := range statuses {
+			ids = append(ids, st.FlatID)
+		}
+
+		flats, err = s.rep.FindFlats(r.Context(), models.FlatFilter{IDs: ids})
+		if err != nil {
+			s.errHandler(err)
+			return err
+		}
+	}
+
+	return s.write(w, http.StatusOK, pipelineBoard(statuses, flats, filter.Stages))
+}
+
+// Get writes the stage of the flat with the id in the path and how it got there.
+func (s *PipelineService) Get(w http.ResponseWriter, r *http.Request) error {
+	_, workspaceID, err := s.workspaces.RequestWorkspaceID(r)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	st, err := s.rep.GetFlatStatus(r.Context(), workspaceID, r.PathValue("id"))
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	return s.write(w, http.StatusOK, st)
+}
+
+// Move moves the flat with the id in the path to the stage in the request
+// body and writes its status. A flat the workspace did not track yet starts
+// in new. The reason and the member moving the flat are kept with the move
+// and, on rejection, the reason with the status.
+func (s *PipelineService) Move(w http.ResponseWriter, r *http.Request) error {
+	userID, workspaceID, err := s.workspaces.RequestWorkspaceID(r)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	var move models.StageMove
+	err = json.NewDecoder(r.Body).Decode(&move)
+	if err != nil {
+		err = fmt.Errorf("invalid stage move: %v: %w", err, custerrors.ErrInvalidInput)
+		s.errHandler(err)
+		return err
+	}
+	move.Reason = strings.TrimSpace(move.Reason)
+
+	flatID := r.PathValue("id")
+	now := s.NowFunc()
+	err = s.inTx(r.Context(), func(tx models.Tx) error {
+		var from models.Stage
+
+		st, err := tx.GetFlatStatus(workspaceID, flatID)
+		switch {
+		case err == nil:
+			from = st.Stage
+		case errors.Is(err, custerrors.ErrNotFound):
+			_, err = tx.GetFlatByID(flatID)
+			if err != nil {
+				return err
+			}
+		default:
+			return err
+		}
+
+		err = checkStageMove(from, move.Stage)
+		if err != nil {
+			return err
+		}
+
+		st = models.FlatStatus{WorkspaceID: workspaceID, FlatID: flatID, Stage: move.Stage, UserID: userID, UpdatedAt: now}
+		if move.Stage == models.StageRejected {
+			st.Reason = move.Reason
+		}
+
+		err = tx.SaveFlatStatus(st)
+		if err != nil {
+			return err
+		}
+
+		return tx.CreateStageTransition(workspaceID, flatID, models.StageTransition{
+			From:   from,
+			To:     move.Stage,
+			Reason: move.Reason,
+			UserID: userID,
+			At:     now,
+		})
+	})
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	st, err := s.rep.GetFlatStatus(r.Context(), workspaceID, flatID)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	return s.write(w, http.StatusOK, st)
+}
+
+// Delete takes the flat with the id in the path out of the pipeline of the
+// workspace, forgetting its moves.
+func (s *PipelineService) Delete(w http.ResponseWriter, r *http.Request) error {
+	_, workspaceID, err := s.workspaces.RequestWorkspaceID(r)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	err = s.inTx(r.Context(), func(tx models.Tx) error {
+		return tx.DeleteFlatStatus(workspaceID, r.PathValue("id"))
+	})
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// checkStageMove checks that a flat may be moved from one stage to the
+// other. A flat that is not tracked yet, with an empty stage, is in new.
+func checkStageMove(from, to models.Stage) error {
+	if _, ok := stageMoves[to]; !ok {
+		return fmt.Errorf("unknown stage %q: %w", to, custerrors.ErrInvalidInput)
+	}
+
+	if from == "" {
+		if to == models.StageNew {
+			return nil
+		}
+		from = models.StageNew
+	}
+
+	if !slices.Contains(stageMoves[from], to) {
+		return fmt.Errorf("flat cannot be moved from %s to %s: %w", from, to, custerrors.ErrInvalidInput)
+	}
+
+	return nil
+}
+
+// pipelineBoard groups the statuses by stage, keeping their order, and sets
+// their flats. All stages are listed unless stages is set.
+func pipelineBoard(statuses []models.FlatStatus, flats []models.Flat, stages []models.Stage) []models.PipelineColumn {
+	byID := make(map[string]models.Flat, len(flats))
+	for _, f := range flats {
+		byID[f.ID] = f
+	}
+
+	board := make([]models.PipelineColumn, 0, len(models.Stages))
+	for _, stage := range models.Stages {
+		if len(stages) > 0 && !slices.Contains(stages, stage) {
+			continue
+		}
+
+		col := models.PipelineColumn{Stage: stage, Statuses: make([]models.FlatStatus, 0)}
+		for _, st := range statuses {
+			if st.Stage != stage {
+				continue
+			}
+			if f, ok := byID[st.FlatID]; ok {
+				st.Flat = &f
+			}
+			col.Statuses = append(col.Statuses, st)
+		}
+		board = append(board, col)
+	}
+
+	return board
+}
+
+// write writes v as the JSON response with status.
+func (s *PipelineService) write(w http.ResponseWriter, status int, v any) error {
+	j, err := json.Marshal(v)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, err = w.Write(j)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	return nil
+}
+
+func (s *PipelineService) inTx(ctx context.Context, f func(tx models.Tx) error) error {
+	tx, err := s.rep.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = f(tx)
+	if err != nil {
+		rBackErr := tx.Rollback()
+		if rBackErr != nil {
+			err = errors.Join(err, rBackErr)
+		}
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,82 @@
+package services
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"hestia/pkg/custerrors"
+	"hestia/pkg/models"
+)
+
+func Test_checkStageMove(t *testing.T) {
+	tests := map[string]struct {
+		from    models.Stage
+		to      models.Stage
+		wantErr error
+	}{
+		"ok, start in new":            {to: models.StageNew},
+		"ok, start in contacted":      {to: models.StageContacted},
+		"ok, next stage":              {from: models.StageViewing, to: models.StageApplied},
+		"ok, reject":                  {from: models.StageApplied, to: models.StageRejected},
+		"ok, reopen":                  {from: models.StageRejected, to: models.StageNew},
+		"fail, skip stage":            {from: models.StageNew, to: models.StageViewing, wantErr: custerrors.ErrInvalidInput},
+		"fail, back":                  {from: models.StageViewing, to: models.StageContacted, wantErr: custerrors.ErrInvalidInput},
+		"fail, same stage":            {from: models.StageNew, to: models.StageNew, wantErr: custerrors.ErrInvalidInput},
+		"fail, start beyond next":     {to: models.StageApplied, wantErr: custerrors.ErrInvalidInput},
+		"fail, reopen into contacted": {from: models.StageRejected, to: models.StageContacted, wantErr: custerrors.ErrInvalidInput},
+		"fail, unknown stage":         {from: models.StageNew, to: "signed", wantErr: custerrors.ErrInvalidInput},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := checkStageMove(tc.from, tc.to)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("expected errors to be %v got %v (via errors.Is)", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func Test_pipelineBoard(t *testing.T) {
+	statuses := []models.FlatStatus{
+		{FlatID: "3", Stage: models.StageViewing},
+		{FlatID: "5", Stage: models.StageNew},
+		{FlatID: "7", Stage: models.StageViewing},
+	}
+	flats := []models.Flat{{ID: "3", Title: "Balkon"}, {ID: "5", Title: "Metro"}, {ID: "7", Title: "Ogród"}}
+
+	tests := map[string]struct {
+		stages []models.Stage
+		want   []models.PipelineColumn
+	}{
+		"ok, all stages": {
+			want: []models.PipelineColumn{
+				{Stage: models.StageNew, Statuses: []models.FlatStatus{{FlatID: "5", Stage: models.StageNew, Flat: &flats[1]}}},
+				{Stage: models.StageContacted, Statuses: []models.FlatStatus{}},
+				{Stage: models.StageViewing, Statuses: []models.FlatStatus{
+					{FlatID: "3", Stage: models.StageViewing, Flat: &flats[0]},
+					{FlatID: "7", Stage: models.StageViewing, Flat: &flats[2]},
+				}},
+				{Stage: models.StageApplied, Statuses: []models.FlatStatus{}},
+				{Stage: models.StageRejected, Statuses: []models.FlatStatus{}},
+			},
+		},
+		"ok, some stages": {
+			stages: []models.Stage{models.StageApplied, models.StageNew},
+			want: []models.PipelineColumn{
+				{Stage: models.StageNew, Statuses: []models.FlatStatus{{FlatID: "5", Stage: models.StageNew, Flat: &flats[1]}}},
+				{Stage: models.StageApplied, Statuses: []models.FlatStatus{}},
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := pipelineBoard(statuses, flats, tc.stages)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got\n%+v\nwant\n%+v", got, tc.want)
+			}
+		})
+	}
+}
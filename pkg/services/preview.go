@@ -0,0 +1,102 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"hestia/pkg/custerrors"
+	"hestia/pkg/models"
+	"hestia/pkg/utils/normalize"
+	"hestia/pkg/utils/parsers"
+)
+
+// Preview parses the listing of req the way a scrape job does, without
+// storing anything. Problems a scrape job would fail on, such as missing
+// required fields or a withdrawn listing, are reported as warnings.
+func (s *ScrapeService) Preview(ctx context.Context, req models.PageUpload) (models.FlatPreview, error) {
+	if strings.TrimSpace(req.URL) == "" {
+		return models.FlatPreview{}, fmt.Errorf("missing listing url: %w", custerrors.ErrInvalidInput)
+	}
+
+	var body []byte
+	if req.HTML != "" {
+		body = []byte(req.HTML)
+	}
+
+	res, err := s.collector.Preview(ctx, req.URL, body)
+	gone := errors.Is(err, parsers.ErrListingGone)
+	if err != nil && !gone {
+		return models.FlatPreview{}, err
+	}
+
+	preview := newPreview(res, req.URL, s.collector.Missing(res), gone, s.NowFunc())
+
+	existing, err := s.rep.FindFlats(ctx, models.FlatFilter{SourceURLs: []string{preview.Flat.SourceURL}})
+	if err != nil {
+		return models.FlatPreview{}, err
+	}
+	if len(existing) > 0 {
+		preview.ExistingID = existing[0].ID
+	}
+
+	return preview, nil
+}
+
+// newPreview builds the preview of res, parsed from the listing at url.
+// The missing required fields and a gone listing are reported as warnings.
+func newPreview(res parsers.Result, url string, missing []parsers.Field, gone bool, now time.Time) models.FlatPreview {
+	var warnings []string
+	if gone {
+		warnings = append(warnings, "listing is no longer available")
+	}
+
+	flat := res.Flat
+	normalize.Flat(&flat, now)
+	flat.SourceURL = url
+	if c := parsers.CanonicalURL(url); c != "" {
+		flat.SourceURL = c
+	}
+
+	for _, f := range missing {
+		warnings = append(warnings, fmt.Sprintf("%s: required field is empty", f))
+	}
+	warnings = append(warnings, flat.ParseWarnings...)
+
+	preview := models.FlatPreview{
+		Portal:   res.Portal,
+		Flat:     flat,
+		Fields:   previewFields(res),
+		Photos:   res.Photos,
+		Warnings: warnings,
+	}
+	if preview.Photos == nil {
+		preview.Photos = make([]string, 0)
+	}
+	if preview.Warnings == nil {
+		preview.Warnings = make([]string, 0)
+	}
+
+	return preview
+}
+
+// previewFields lists every field of res in extraction order.
+func previewFields(res parsers.Result) []models.FieldSource {
+	out := make([]models.FieldSource, 0, len(parsers.Fields))
+	for _, f := range parsers.Fields {
+		fs := models.FieldSource{
+			Field: string(f),
+			Value: *f.Ptr(&res.Flat),
+		}
+		if src, ok := res.Sources[f]; ok {
+			fs.Strategy = string(src.Strategy)
+			fs.Detail = src.Detail
+		}
+
+		out = append(out, fs)
+	}
+
+	return out
+}
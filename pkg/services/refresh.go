@@ -0,0 +1,174 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"hestia/pkg/models"
+	"hestia/pkg/repos"
+	"hestia/pkg/utils/normalize"
+	"hestia/pkg/utils/parsers"
+)
+
+// RefreshConfig is the configuration for refreshing stored flats.
+type RefreshConfig struct {
+	// Interval is how long a flat is left alone after it was checked.
+	// A zero Interval disables refreshing.
+	Interval time.Duration
+	// PollInterval is how often the refresher looks for flats that are due.
+	PollInterval time.Duration
+	// BatchSize is the number of flats loaded at once.
+	BatchSize int
+}
+
+// RefreshService scrapes the listings of stored flats again to pick up
+// changes and withdrawn listings.
+type RefreshService struct {
+	rep        *repos.Store
+	wg         *sync.WaitGroup
+	collector  *parsers.Collector
+	archive    *ArchiveService
+	cfg        RefreshConfig
+	errHandler ErrFunc
+
+	// NowFunc is used to get the current time.
+	// Exposed for testing purposes.
+	NowFunc func() time.Time
+}
+
+// NewRefreshService creates a new Service.
+func NewRefreshService(db *sql.DB, collector *parsers.Collector, archive *ArchiveService, cfg RefreshConfig, errHandler ErrFunc) *RefreshService {
+	svc := &RefreshService{
+		rep:        repos.New(db),
+		wg:         &sync.WaitGroup{},
+		collector:  collector,
+		archive:    archive,
+		cfg:        cfg,
+		errHandler: errHandler,
+
+		NowFunc: time.Now,
+	}
+
+	return svc
+}
+
+// Run refreshes due flats one at a time until ctx is done.
+func (s *RefreshService) Run(ctx context.Context) error {
+	if s.cfg.Interval <= 0 {
+		return nil
+	}
+
+	for {
+		flats, err := s.rep.FindFlatsToRefresh(ctx, s.NowFunc().Add(-s.cfg.Interval), s.cfg.BatchSize)
+		if err != nil && ctx.Err() == nil {
+			s.errHandler(fmt.Errorf("failed to find flats to refresh: %w", err))
+		}
+
+		for _, flat := range flats {
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			err := s.refresh(ctx, flat)
+			if err != nil && ctx.Err() == nil {
+				s.errHandler(fmt.Errorf("failed to refresh flat %s: %w", flat.ID, err))
+			}
+		}
+
+		// Keep going while there is a backlog.
+		if len(flats) > 0 && len(flats) == s.cfg.BatchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(s.cfg.PollInterval):
+		}
+	}
+}
+
+// refresh scrapes the listing of flat and stores the changed fields. A flat
+// whose listing is gone is marked as withdrawn. Flats that fail to scrape
+// for other reasons are tried again after the next interval.
+func (s *RefreshService) refresh(ctx context.Context, flat models.Flat) error {
+	res, scrapeErr := s.collector.Parse(ctx, flat.SourceURL)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	now := s.NowFunc()
+
+	var changes []models.FlatChange
+	switch {
+	case errors.Is(scrapeErr, parsers.ErrListingGone):
+		flat.WithdrawnAt = &now
+		changes = append(changes, models.FlatChange{
+			FlatID:     flat.ID,
+			Field:      "withdrawn_at",
+			NewValue:   now.UTC().Format(time.RFC3339),
+			DetectedAt: now,
+		})
+	case scrapeErr == nil:
+		changes = diffFlat(flat, res.Flat, now)
+		if len(changes) > 0 {
+			applyChanges(&flat, changes)
+			normalize.Flat(&flat, now)
+			flat.UpdatedAt = now
+		}
+	}
+
+	flat.LastCheckedAt = &now
+
+	err := s.inTx(ctx, func(tx models.Tx) error {
+		err := tx.UpdateFlat(flat)
+		if err != nil {
+			return err
+		}
+
+		err = tx.CreatePhotos(newPhotos(flat.ID, res.Photos, now))
+		if err != nil {
+			return err
+		}
+
+		return saveFlatChanges(tx, flat, changes, now)
+	})
+	if err != nil {
+		return errors.Join(scrapeErr, err)
+	}
+
+	s.archive.record(ctx, res.Page, flat.ID, "", scrapeErr)
+
+	if errors.Is(scrapeErr, parsers.ErrListingGone) {
+		return nil
+	}
+
+	return scrapeErr
+}
+
+func (s *RefreshService) inTx(ctx context.Context, f func(tx models.Tx) error) error {
+	tx, err := s.rep.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = f(tx)
+	if err != nil {
+		rBackErr := tx.Rollback()
+		if rBackErr != nil {
+			err = errors.Join(err, rBackErr)
+		}
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
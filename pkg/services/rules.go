@@ -0,0 +1,446 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"hestia/pkg/custerrors"
+	"hestia/pkg/middlewares"
+	"hestia/pkg/models"
+	"hestia/pkg/repos"
+	"hestia/pkg/utils/parsers"
+)
+
+// RulesConfig is the configuration for the extraction rules edited by admins.
+type RulesConfig struct {
+	// ReloadInterval is how often the published rules are checked for
+	// changes made on other instances. Zero loads them only at startup.
+	ReloadInterval time.Duration
+}
+
+// ParserRuleService keeps versions of the extraction rules of each portal
+// and loads the published ones into the collector, replacing its built-in
+// rules without a restart.
+type ParserRuleService struct {
+	rep        *repos.Store
+	wg         *sync.WaitGroup
+	collector  *parsers.Collector
+	archive    *ArchiveService
+	cfg        RulesConfig
+	errHandler ErrFunc
+
+	// mu guards loaded, the id of the rule set in use for each portal.
+	mu     sync.Mutex
+	loaded map[string]string
+
+	// NowFunc is used to get the current time.
+	// Exposed for testing purposes.
+	NowFunc func() time.Time
+}
+
+// NewParserRuleService creates a new Service.
+func NewParserRuleService(db *sql.DB, collector *parsers.Collector, archive *ArchiveService, cfg RulesConfig, errHandler ErrFunc) *ParserRuleService {
+	svc := &ParserRuleService{
+		rep:        repos.New(db),
+		wg:         &sync.WaitGroup{},
+		collector:  collector,
+		archive:    archive,
+		cfg:        cfg,
+		errHandler: errHandler,
+		loaded:     make(map[string]string),
+
+		NowFunc: time.Now,
+	}
+
+	return svc
+}
+
+// List writes the rule sets, the latest version of each portal first. The
+// portal query parameter limits them to a single portal.
+func (s *ParserRuleService) List(w http.ResponseWriter, r *http.Request) error {
+	var filter models.ParserRuleFilter
+	if p := r.URL.Query().Get("portal"); p != "" {
+		filter.Portals = []string{p}
+	}
+
+	sets, err := s.rep.FindParserRules(r.Context(), filter)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	return s.write(w, http.StatusOK, sets)
+}
+
+// Get writes the rule set with the id in the path.
+func (s *ParserRuleService) Get(w http.ResponseWriter, r *http.Request) error {
+	rs, err := s.rep.GetParserRules(r.Context(), r.PathValue("id"))
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	return s.write(w, http.StatusOK, rs)
+}
+
+// Post stores the rule set in the request body as a new, unpublished
+// version of its portal. The rules are checked before they are stored.
+func (s *ParserRuleService) Post(w http.ResponseWriter, r *http.Request) error {
+	var rs models.ParserRuleSet
+	err := json.NewDecoder(r.Body).Decode(&rs)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	if !slices.Contains(s.collector.Portals(), rs.Portal) {
+		err = fmt.Errorf("unknown portal %q: %w", rs.Portal, custerrors.ErrInvalidInput)
+		s.errHandler(err)
+		return err
+	}
+
+	_, err = compileRuleSet(rs)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	rs.CreatedBy, _ = middlewares.UserIDFromContext(r.Context())
+	rs.CreatedAt = s.NowFunc()
+
+	err = s.inTx(r.Context(), func(tx models.Tx) error {
+		id, err := tx.CreateParserRules(rs)
+		if err != nil {
+			return err
+		}
+
+		rs, err = tx.GetParserRules(id)
+		return err
+	})
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	w.Header().Set("Location", "/api/v1/parser-rules/"+rs.ID)
+	return s.write(w, http.StatusCreated, rs)
+}
+
+// Publish makes the rule set with the id in the path the active version of
+// its portal and loads it. Publishing an older version rolls back to it.
+func (s *ParserRuleService) Publish(w http.ResponseWriter, r *http.Request) error {
+	id := r.PathValue("id")
+	err := s.inTx(r.Context(), func(tx models.Tx) error {
+		rs, err := tx.GetParserRules(id)
+		if err != nil {
+			return err
+		}
+
+		// Checked again in case the parser changed since the rules were stored.
+		_, err = compileRuleSet(rs)
+		if err != nil {
+			return err
+		}
+
+		return tx.PublishParserRules(id, s.NowFunc())
+	})
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	err = s.Reload(r.Context())
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	rs, err := s.rep.GetParserRules(r.Context(), id)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	return s.write(w, http.StatusOK, rs)
+}
+
+// Delete deletes the rule set with the id in the path. If it was active,
+// the version published before it takes over, or the built-in rules if
+// there is none.
+func (s *ParserRuleService) Delete(w http.ResponseWriter, r *http.Request) error {
+	err := s.inTx(r.Context(), func(tx models.Tx) error {
+		return tx.DeleteParserRules(r.PathValue("id"))
+	})
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	err = s.Reload(r.Context())
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// Test applies the rule set with the id in the path to the archived page
+// selected by the request body and responds with the flat it yields next to
+// the one the rules in use yield. Nothing is stored.
+func (s *ParserRuleService) Test(w http.ResponseWriter, r *http.Request) error {
+	var req models.RuleTestRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	rs, err := s.rep.GetParserRules(r.Context(), r.PathValue("id"))
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	res, err := s.TestRules(r.Context(), rs, req)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	return s.write(w, http.StatusOK, res)
+}
+
+// TestRules parses the archived page selected by req with rs and with the
+// rules in use and compares the results.
+func (s *ParserRuleService) TestRules(ctx context.Context, rs models.ParserRuleSet, req models.RuleTestRequest) (models.RuleTestResult, error) {
+	p, err := compileRuleSet(rs)
+	if err != nil {
+		return models.RuleTestResult{}, err
+	}
+
+	snap, err := s.testSnapshot(ctx, req)
+	if err != nil {
+		return models.RuleTestResult{}, err
+	}
+
+	page, err := s.archive.Load(ctx, snap)
+	if err != nil {
+		return models.RuleTestResult{}, err
+	}
+
+	now := s.NowFunc()
+	draft, err := s.testPreview(page, p, now)
+	if err != nil {
+		return models.RuleTestResult{}, err
+	}
+
+	active, err := s.testPreview(page, nil, now)
+	if err != nil {
+		return models.RuleTestResult{}, err
+	}
+
+	return models.RuleTestResult{
+		SnapshotID: snap.ID,
+		URL:        snap.URL,
+		Draft:      draft,
+		Active:     active,
+		Changes:    ruleChanges(active.Flat, draft.Flat, snap.FlatID, now),
+	}, nil
+}
+
+// testSnapshot returns the snapshot selected by req.
+func (s *ParserRuleService) testSnapshot(ctx context.Context, req models.RuleTestRequest) (models.Snapshot, error) {
+	if req.SnapshotID != "" {
+		return s.rep.GetSnapshot(ctx, req.SnapshotID)
+	}
+
+	url := strings.TrimSpace(req.URL)
+	if url == "" {
+		return models.Snapshot{}, fmt.Errorf("missing snapshot id or listing url: %w", custerrors.ErrInvalidInput)
+	}
+	if c := parsers.CanonicalURL(url); c != "" {
+		url = c
+	}
+
+	snaps, err := s.rep.FindSnapshots(ctx, models.SnapshotFilter{URLs: []string{url}, Limit: 1})
+	if err != nil {
+		return models.Snapshot{}, err
+	}
+
+	if len(snaps) == 0 {
+		return models.Snapshot{}, fmt.Errorf("snapshot not found: %w", custerrors.ErrNotFound)
+	}
+
+	return snaps[0], nil
+}
+
+// testPreview parses page with p, or with the parser in use if p is nil.
+func (s *ParserRuleService) testPreview(page parsers.Page, p parsers.Parser, now time.Time) (models.FlatPreview, error) {
+	res, err := s.collector.ParsePageWith(page, p)
+	gone := errors.Is(err, parsers.ErrListingGone)
+	if err != nil && !gone {
+		return models.FlatPreview{}, err
+	}
+
+	return newPreview(res, page.URL, s.collector.Missing(res), gone, now), nil
+}
+
+// Reload loads the published rule sets into the collector. Portals whose
+// rules were all unpublished or deleted get their built-in rules back. A rule
+// set that no longer compiles is reported and the rules in use are kept.
+func (s *ParserRuleService) Reload(ctx context.Context) error {
+	sets, err := s.rep.FindParserRules(ctx, models.ParserRuleFilter{ActiveOnly: true})
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	active := make(map[string]bool, len(sets))
+	for _, rs := range sets {
+		active[rs.Portal] = true
+		if s.loaded[rs.Portal] == rs.ID {
+			continue
+		}
+
+		p, err := compileRuleSet(rs)
+		if err != nil {
+			s.errHandler(fmt.Errorf("failed to load parser rules %s of %s: %w", rs.ID, rs.Portal, err))
+			continue
+		}
+
+		s.collector.Override(rs.Portal, p)
+		s.loaded[rs.Portal] = rs.ID
+	}
+
+	for portal := range s.loaded {
+		if !active[portal] {
+			s.collector.Override(portal, nil)
+			delete(s.loaded, portal)
+		}
+	}
+
+	return nil
+}
+
+// Run reloads the published rules every reload interval until ctx is done.
+func (s *ParserRuleService) Run(ctx context.Context) error {
+	if s.cfg.ReloadInterval <= 0 {
+		return nil
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(s.cfg.ReloadInterval):
+		}
+
+		err := s.Reload(ctx)
+		if err != nil && ctx.Err() == nil {
+			s.errHandler(fmt.Errorf("failed to reload parser rules: %w", err))
+		}
+	}
+}
+
+// write writes v as the JSON response with the given status.
+func (s *ParserRuleService) write(w http.ResponseWriter, status int, v any) error {
+	j, err := json.Marshal(v)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, err = w.Write(j)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	return nil
+}
+
+func (s *ParserRuleService) inTx(ctx context.Context, f func(tx models.Tx) error) error {
+	tx, err := s.rep.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = f(tx)
+	if err != nil {
+		rBackErr := tx.Rollback()
+		if rBackErr != nil {
+			err = errors.Join(err, rBackErr)
+		}
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// compileRuleSet turns rs into a parser for its portal. The error wraps
+// custerrors.ErrInvalidInput if a rule is invalid.
+func compileRuleSet(rs models.ParserRuleSet) (*parsers.SelectorParser, error) {
+	fields := make(map[parsers.Field][]parsers.Rule, len(rs.Fields))
+	for name, rules := range rs.Fields {
+		for _, r := range rules {
+			fields[parsers.Field(name)] = append(fields[parsers.Field(name)], parserRule(r))
+		}
+	}
+
+	photos := make([]parsers.Rule, 0, len(rs.Photos))
+	for _, r := range rs.Photos {
+		photos = append(photos, parserRule(r))
+	}
+
+	return parsers.CompileSelectorParser(rs.Portal, fields, photos)
+}
+
+func parserRule(r models.ExtractionRule) parsers.Rule {
+	return parsers.Rule{
+		Selector: r.Selector,
+		XPath:    r.XPath,
+		Attr:     r.Attr,
+		Label:    r.Label,
+		Pattern:  r.Pattern,
+	}
+}
+
+// ruleChanges returns the fields of the flat parsed with the rules in use
+// that the draft rules change, including the ones they leave empty.
+func ruleChanges(active, draft models.Flat, flatID string, now time.Time) []models.FlatChange {
+	changes := make([]models.FlatChange, 0)
+	for _, f := range parsers.Fields {
+		o, n := *f.Ptr(&active), *f.Ptr(&draft)
+		if n == o {
+			continue
+		}
+
+		changes = append(changes, models.FlatChange{
+			FlatID:     flatID,
+			Field:      string(f),
+			OldValue:   o,
+			NewValue:   n,
+			DetectedAt: now,
+		})
+	}
+
+	return changes
+}
@@ -0,0 +1,94 @@
+package services
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"hestia/pkg/custerrors"
+	"hestia/pkg/models"
+)
+
+func Test_compileRuleSet(t *testing.T) {
+	tests := map[string]struct {
+		rs      models.ParserRuleSet
+		wantErr error
+	}{
+		"ok, fields and photos": {
+			rs: models.ParserRuleSet{
+				Portal: "olx",
+				Fields: map[string][]models.ExtractionRule{
+					"title": {{Selector: "h1"}, {XPath: "//h4"}},
+					"price": {{Selector: "h3", Pattern: `([\d ]+) zł`}},
+				},
+				Photos: []models.ExtractionRule{{XPath: "//img/@src"}},
+			},
+		},
+		"ok, no rules": {
+			rs: models.ParserRuleSet{Portal: "olx"},
+		},
+		"fail, unknown field": {
+			rs: models.ParserRuleSet{
+				Portal: "olx",
+				Fields: map[string][]models.ExtractionRule{"colour": {{Selector: "h1"}}},
+			},
+			wantErr: custerrors.ErrInvalidInput,
+		},
+		"fail, invalid pattern": {
+			rs: models.ParserRuleSet{
+				Portal: "olx",
+				Fields: map[string][]models.ExtractionRule{"price": {{Selector: "h3", Pattern: "(["}}},
+			},
+			wantErr: custerrors.ErrInvalidInput,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			p, err := compileRuleSet(tc.rs)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("expected errors to be %v got %v (via errors.Is)", tc.wantErr, err)
+			}
+			if tc.wantErr != nil {
+				return
+			}
+
+			if p.Portal() != tc.rs.Portal {
+				t.Errorf("got portal %q want %q", p.Portal(), tc.rs.Portal)
+			}
+		})
+	}
+}
+
+func Test_ruleChanges(t *testing.T) {
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	active := models.Flat{Title: "Kawalerka", Price: "2 100 zł", Rent: "450 zł"}
+
+	tests := map[string]struct {
+		draft models.Flat
+		want  []models.FlatChange
+	}{
+		"ok, same": {
+			draft: models.Flat{Title: "Kawalerka", Price: "2 100 zł", Rent: "450 zł"},
+			want:  []models.FlatChange{},
+		},
+		"ok, changed and emptied": {
+			draft: models.Flat{Title: "Kawalerka", Price: "2 100", Floor: "parter"},
+			want: []models.FlatChange{
+				{FlatID: "7", Field: "price", OldValue: "2 100 zł", NewValue: "2 100", DetectedAt: now},
+				{FlatID: "7", Field: "floor", OldValue: "", NewValue: "parter", DetectedAt: now},
+				{FlatID: "7", Field: "rent", OldValue: "450 zł", NewValue: "", DetectedAt: now},
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := ruleChanges(active, tc.draft, "7", now)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got\n%+v\nwant\n%+v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,473 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"hestia/pkg/custerrors"
+	"hestia/pkg/models"
+	"hestia/pkg/repos"
+)
+
+// searchOverlap is how far back flats are matched before the time a saved
+// search was last checked, to catch flats stored by transactions that
+// were still running then. Flats matched twice are only sent once.
+const searchOverlap = time.Minute
+
+// SearchConfig is the configuration for saved search alerts.
+type SearchConfig struct {
+	// PollInterval is how often new flats are matched against the saved
+	// searches. A zero PollInterval disables alerts.
+	PollInterval time.Duration
+	// DigestInterval is the time between two digests of a daily search.
+	DigestInterval time.Duration
+}
+
+// SavedSearchService manages the saved searches of the user making the
+// request and emails the flats that newly match them.
+type SavedSearchService struct {
+	rep        *repos.Store
+	wg         *sync.WaitGroup
+	mailer     Mailer
+	baseURL    string
+	cfg        SearchConfig
+	errHandler ErrFunc
+
+	// NowFunc is used to get the current time.
+	// Exposed for testing purposes.
+	NowFunc func() time.Time
+}
+
+// NewSavedSearchService creates a new Service. Flats are linked from alerts
+// below baseURL.
+func NewSavedSearchService(db *sql.DB, mailer Mailer, baseURL string, cfg SearchConfig, errHandler ErrFunc) *SavedSearchService {
+	svc := &SavedSearchService{
+		rep:        repos.New(db),
+		wg:         &sync.WaitGroup{},
+		mailer:     mailer,
+		baseURL:    baseURL,
+		cfg:        cfg,
+		errHandler: errHandler,
+
+		NowFunc: time.Now,
+	}
+
+	return svc
+}
+
+// List writes the saved searches of the user by name.
+func (s *SavedSearchService) List(w http.ResponseWriter, r *http.Request) error {
+	userID, err := requestUserID(r)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	searches, err := s.rep.FindSavedSearches(r.Context(), models.SavedSearchFilter{UserID: userID})
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	return s.write(w, http.StatusOK, searches)
+}
+
+// Get writes the saved search with the id in the path.
+func (s *SavedSearchService) Get(w http.ResponseWriter, r *http.Request) error {
+	userID, err := requestUserID(r)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	ss, err := s.rep.GetSavedSearch(r.Context(), userID, r.PathValue("id"))
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	return s.write(w, http.StatusOK, ss)
+}
+
+// Post saves the search in the request body for the user. Only flats
+// stored or changed from now on are sent.
+func (s *SavedSearchService) Post(w http.ResponseWriter, r *http.Request) error {
+	userID, err := requestUserID(r)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	ss, err := decodeSavedSearch(r)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	now := s.NowFunc()
+	ss.UserID = userID
+	ss.CheckedUntil = now
+	ss.CreatedAt = now
+	ss.UpdatedAt = now
+
+	err = s.inTx(r.Context(), func(tx models.Tx) error {
+		id, err := tx.CreateSavedSearch(ss)
+		if err != nil {
+			return err
+		}
+
+		ss, err = tx.GetSavedSearch(userID, id)
+		return err
+	})
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	w.Header().Set("Location", "/api/v1/me/searches/"+ss.ID)
+	return s.write(w, http.StatusCreated, ss)
+}
+
+// Put replaces the name, filter, delivery and pause of the saved search
+// with the id in the path. Flats stored while the search was paused or
+// before its filter changed are not sent.
+func (s *SavedSearchService) Put(w http.ResponseWriter, r *http.Request) error {
+	userID, err := requestUserID(r)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	update, err := decodeSavedSearch(r)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	now := s.NowFunc()
+	var ss models.SavedSearch
+	err = s.inTx(r.Context(), func(tx models.Tx) error {
+		var err error
+		ss, err = tx.GetSavedSearch(userID, r.PathValue("id"))
+		if err != nil {
+			return err
+		}
+
+		if (ss.Paused && !update.Paused) || ss.Query != update.Query {
+			ss.CheckedUntil = now
+		}
+		ss.Name = update.Name
+		ss.Query = update.Query
+		ss.Delivery = update.Delivery
+		ss.Paused = update.Paused
+		ss.UpdatedAt = now
+
+		err = tx.UpdateSavedSearch(ss)
+		if err != nil {
+			return err
+		}
+
+		ss, err = tx.GetSavedSearch(userID, ss.ID)
+		return err
+	})
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	return s.write(w, http.StatusOK, ss)
+}
+
+// Delete deletes the saved search with the id in the path.
+func (s *SavedSearchService) Delete(w http.ResponseWriter, r *http.Request) error {
+	userID, err := requestUserID(r)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	err = s.inTx(r.Context(), func(tx models.Tx) error {
+		return tx.DeleteSavedSearch(userID, r.PathValue("id"))
+	})
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// Run matches new flats against the saved searches and sends the alerts
+// that are due every PollInterval until ctx is done.
+func (s *SavedSearchService) Run(ctx context.Context) error {
+	if s.cfg.PollInterval <= 0 {
+		return nil
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(s.cfg.PollInterval):
+		}
+
+		err := s.Alert(ctx)
+		if err != nil && ctx.Err() == nil {
+			s.errHandler(fmt.Errorf("failed to send saved search alerts: %w", err))
+		}
+	}
+}
+
+// Alert matches the flats stored, changed or added to a workspace since the
+// last check against the saved searches that are not paused and sends the
+// matches that are due. A search that fails does not keep the others from
+// being sent.
+func (s *SavedSearchService) Alert(ctx context.Context) error {
+	searches, err := s.rep.FindSavedSearches(ctx, models.SavedSearchFilter{ActiveOnly: true})
+	if err != nil {
+		return err
+	}
+
+	var errs error
+	for _, ss := range searches {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		err := s.alert(ctx, ss, s.NowFunc())
+		if err != nil {
+			errs = errors.Join(errs, fmt.Errorf("saved search %s: %w", ss.ID, err))
+		}
+	}
+
+	return errs
+}
+
+// alert records the new matches of ss among the flats of the workspace of
+// its user and sends them if they are due.
+func (s *SavedSearchService) alert(ctx context.Context, ss models.SavedSearch, now time.Time) error {
+	filter, err := searchFilter(ss.Query)
+	if err != nil {
+		return err
+	}
+
+	after := ss.CheckedUntil.Add(-searchOverlap)
+	filter.UpdatedAfter = &after
+	filter.CanonicalOnly = true
+
+	// Only the flats of the workspace of the user match, a user in no
+	// workspace has no flats.
+	var flats []models.Flat
+	filter.WorkspaceID, err = s.rep.GetMemberWorkspaceID(ctx, ss.UserID)
+	switch {
+	case err == nil:
+		flats, err = s.rep.FindFlats(ctx, filter)
+		if err != nil {
+			return err
+		}
+	case !errors.Is(err, custerrors.ErrNotFound):
+		return err
+	}
+
+	ids := make([]string, 0, len(flats))
+	for _, f := range flats {
+		ids = append(ids, f.ID)
+	}
+
+	err = s.inTx(ctx, func(tx models.Tx) error {
+		err := tx.CreateSearchAlerts(ss.ID, ids, now)
+		if err != nil {
+			return err
+		}
+
+		return tx.UpdateSavedSearchChecked(ss.ID, now)
+	})
+	if err != nil {
+		return err
+	}
+
+	if !deliveryDue(ss, now, s.cfg.DigestInterval) {
+		return nil
+	}
+
+	return s.send(ctx, ss, now)
+}
+
+// send emails the matches of ss that were not sent yet to its user. They
+// are only marked as sent once the email went out. The email is sent outside
+// of a transaction, so a slow mail server does not hold one open.
+func (s *SavedSearchService) send(ctx context.Context, ss models.SavedSearch, now time.Time) error {
+	users, err := s.rep.FindUsers(ctx, models.UserFilter{IDs: []string{ss.UserID}, IsActive: true})
+	if err != nil {
+		return err
+	}
+	if len(users) == 0 {
+		return nil
+	}
+
+	var (
+		ids   []string
+		flats []models.Flat
+	)
+	err = s.inTx(ctx, func(tx models.Tx) error {
+		ids, err = tx.FindPendingAlerts(ss.ID)
+		if err != nil || len(ids) == 0 {
+			return err
+		}
+
+		flats, err = tx.FindFlats(models.FlatFilter{IDs: ids})
+		return err
+	})
+	if err != nil || len(ids) == 0 {
+		return err
+	}
+
+	err = s.mailer.Send(ctx, "saved-search-alert", users[0].Email, newSearchAlert(ss, ids, flats, s.baseURL))
+	if err != nil {
+		return err
+	}
+
+	// Only the matches that were sent, new ones go out with the next email.
+	return s.inTx(ctx, func(tx models.Tx) error {
+		return tx.MarkAlertsSent(ss.ID, ids, now)
+	})
+}
+
+// deliveryDue reports whether the matches of ss are due to be sent at now.
+// Daily searches are sent a digest every interval since they were last
+// sent or created.
+func deliveryDue(ss models.SavedSearch, now time.Time, interval time.Duration) bool {
+	if ss.Delivery != models.SearchDeliveryDaily {
+		return true
+	}
+
+	since := ss.CreatedAt
+	if ss.LastSentAt != nil {
+		since = *ss.LastSentAt
+	}
+
+	return now.Sub(since) >= interval
+}
+
+// newSearchAlert builds the alert for the flats with ids, in that order.
+// Flats deleted in the meantime are left out.
+func newSearchAlert(ss models.SavedSearch, ids []string, flats []models.Flat, baseURL string) models.SearchAlert {
+	byID := make(map[string]models.Flat, len(flats))
+	for _, f := range flats {
+		byID[f.ID] = f
+	}
+
+	alert := models.SearchAlert{Search: ss, Flats: make([]models.SearchAlertFlat, 0, len(ids))}
+	for _, id := range ids {
+		f, ok := byID[id]
+		if !ok {
+			continue
+		}
+
+		alert.Flats = append(alert.Flats, models.SearchAlertFlat{
+			ID:        f.ID,
+			Title:     f.Title,
+			Address:   f.Address,
+			Price:     f.Price,
+			URL:       flatURL(baseURL, f.ID),
+			SourceURL: f.SourceURL,
+		})
+	}
+
+	return alert
+}
+
+// decodeSavedSearch reads the saved search in the request body and checks it.
+func decodeSavedSearch(r *http.Request) (models.SavedSearch, error) {
+	var ss models.SavedSearch
+	err := json.NewDecoder(r.Body).Decode(&ss)
+	if err != nil {
+		return models.SavedSearch{}, fmt.Errorf("invalid saved search: %v: %w", err, custerrors.ErrInvalidInput)
+	}
+
+	return checkSavedSearch(ss)
+}
+
+// checkSavedSearch checks the name, filter and delivery of ss. The delivery
+// defaults to instant.
+func checkSavedSearch(ss models.SavedSearch) (models.SavedSearch, error) {
+	ss.Name = strings.TrimSpace(ss.Name)
+	if ss.Name == "" {
+		return models.SavedSearch{}, fmt.Errorf("saved search without a name: %w", custerrors.ErrInvalidInput)
+	}
+
+	ss.Query = strings.TrimPrefix(strings.TrimSpace(ss.Query), "?")
+	_, err := searchFilter(ss.Query)
+	if err != nil {
+		return models.SavedSearch{}, err
+	}
+
+	if ss.Delivery == "" {
+		ss.Delivery = models.SearchDeliveryInstant
+	}
+	if !ss.Delivery.Valid() {
+		return models.SavedSearch{}, fmt.Errorf("unknown delivery %q: %w", ss.Delivery, custerrors.ErrInvalidInput)
+	}
+
+	return ss, nil
+}
+
+// searchFilter parses the filter of a saved search like the query of the flat list.
+func searchFilter(query string) (models.FlatFilter, error) {
+	v, err := url.ParseQuery(query)
+	if err != nil {
+		return models.FlatFilter{}, fmt.Errorf("invalid saved search query: %v: %w", err, custerrors.ErrInvalidInput)
+	}
+
+	return parseFlatFilter(v)
+}
+
+// write writes v as the JSON response with status.
+func (s *SavedSearchService) write(w http.ResponseWriter, status int, v any) error {
+	j, err := json.Marshal(v)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, err = w.Write(j)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	return nil
+}
+
+func (s *SavedSearchService) inTx(ctx context.Context, f func(tx models.Tx) error) error {
+	tx, err := s.rep.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = f(tx)
+	if err != nil {
+		rBackErr := tx.Rollback()
+		if rBackErr != nil {
+			err = errors.Join(err, rBackErr)
+		}
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,110 @@
+package services
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"hestia/pkg/custerrors"
+	"hestia/pkg/models"
+)
+
+func Test_checkSavedSearch(t *testing.T) {
+	tests := map[string]struct {
+		ss      models.SavedSearch
+		want    models.SavedSearch
+		wantErr error
+	}{
+		"ok, default delivery": {
+			ss:   models.SavedSearch{Name: " Mokotów ", Query: "?price_max=3000&rooms=2,3&district=mokotow&q=balkon"},
+			want: models.SavedSearch{Name: "Mokotów", Query: "price_max=3000&rooms=2,3&district=mokotow&q=balkon", Delivery: models.SearchDeliveryInstant},
+		},
+		"ok, daily and paused": {
+			ss:   models.SavedSearch{Name: "Kraków", Query: "city=Krakow", Delivery: models.SearchDeliveryDaily, Paused: true},
+			want: models.SavedSearch{Name: "Kraków", Query: "city=Krakow", Delivery: models.SearchDeliveryDaily, Paused: true},
+		},
+		"ok, no filter": {
+			ss:   models.SavedSearch{Name: "Wszystko"},
+			want: models.SavedSearch{Name: "Wszystko", Delivery: models.SearchDeliveryInstant},
+		},
+		"fail, no name": {
+			ss:      models.SavedSearch{Name: " ", Query: "rooms=2"},
+			wantErr: custerrors.ErrInvalidInput,
+		},
+		"fail, invalid filter": {
+			ss:      models.SavedSearch{Name: "Tanio", Query: "price_max=tanio"},
+			wantErr: custerrors.ErrInvalidInput,
+		},
+		"fail, unknown delivery": {
+			ss:      models.SavedSearch{Name: "Tanio", Delivery: "weekly"},
+			wantErr: custerrors.ErrInvalidInput,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := checkSavedSearch(tc.ss)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("expected errors to be %v got %v (via errors.Is)", tc.wantErr, err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got\n%+v\nwant\n%+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func Test_deliveryDue(t *testing.T) {
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	sent := now.Add(-time.Hour * 23)
+
+	tests := map[string]struct {
+		ss   models.SavedSearch
+		want bool
+	}{
+		"ok, instant": {
+			ss:   models.SavedSearch{Delivery: models.SearchDeliveryInstant, CreatedAt: now, LastSentAt: &now},
+			want: true,
+		},
+		"ok, daily not sent yet": {
+			ss:   models.SavedSearch{Delivery: models.SearchDeliveryDaily, CreatedAt: now.Add(-time.Hour * 24)},
+			want: true,
+		},
+		"ok, daily created today": {
+			ss: models.SavedSearch{Delivery: models.SearchDeliveryDaily, CreatedAt: now.Add(-time.Hour)},
+		},
+		"ok, daily sent today": {
+			ss: models.SavedSearch{Delivery: models.SearchDeliveryDaily, CreatedAt: now.Add(-time.Hour * 48), LastSentAt: &sent},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := deliveryDue(tc.ss, now, time.Hour*24); got != tc.want {
+				t.Errorf("got %v want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func Test_newSearchAlert(t *testing.T) {
+	ss := models.SavedSearch{ID: "4", Name: "Mokotów"}
+	flats := []models.Flat{
+		{ID: "9", Title: "Metro", SourceURL: "https://www.olx.pl/d/oferta/9.html"},
+		{ID: "7", Title: "Balkon", Price: "3 200 zł", Address: "Mokotów, Warszawa"},
+	}
+
+	got := newSearchAlert(ss, []string{"7", "8", "9"}, flats, "https://hestia.example/")
+
+	want := models.SearchAlert{
+		Search: ss,
+		Flats: []models.SearchAlertFlat{
+			{ID: "7", Title: "Balkon", Price: "3 200 zł", Address: "Mokotów, Warszawa", URL: "https://hestia.example/api/v1/flats/7"},
+			{ID: "9", Title: "Metro", URL: "https://hestia.example/api/v1/flats/9", SourceURL: "https://www.olx.pl/d/oferta/9.html"},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got\n%+v\nwant\n%+v", got, want)
+	}
+}
@@ -0,0 +1,364 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"hestia/pkg/custerrors"
+	"hestia/pkg/models"
+	"hestia/pkg/repos"
+	"hestia/pkg/utils/normalize"
+	"hestia/pkg/utils/parsers"
+)
+
+// ScrapeConfig is the configuration for the scrape job workers.
+type ScrapeConfig struct {
+	// Workers is the number of jobs scraped at the same time.
+	Workers int
+	// MaxAttempts is the number of times a job is tried before it fails.
+	MaxAttempts int
+	// PollInterval is how often idle workers look for due jobs.
+	PollInterval time.Duration
+	// RetryDelay is the delay before the second attempt of a job. It doubles
+	// with every further attempt.
+	RetryDelay time.Duration
+}
+
+// ScrapeService queues listing URLs and scrapes them into flats in the background.
+type ScrapeService struct {
+	rep        *repos.Store
+	wg         *sync.WaitGroup
+	collector  *parsers.Collector
+	archive    *ArchiveService
+	cfg        ScrapeConfig
+	wake       chan struct{}
+	errHandler ErrFunc
+
+	// NowFunc is used to get the current time.
+	// Exposed for testing purposes.
+	NowFunc func() time.Time
+}
+
+// NewScrapeService creates a new Service.
+func NewScrapeService(db *sql.DB, collector *parsers.Collector, archive *ArchiveService, cfg ScrapeConfig, errHandler ErrFunc) *ScrapeService {
+	svc := &ScrapeService{
+		rep:        repos.New(db),
+		wg:         &sync.WaitGroup{},
+		collector:  collector,
+		archive:    archive,
+		cfg:        cfg,
+		wake:       make(chan struct{}, 1),
+		errHandler: errHandler,
+
+		NowFunc: time.Now,
+	}
+
+	return svc
+}
+
+// Enqueue queues a job scraping url. Unsupported URLs are rejected right away.
+func (s *ScrapeService) Enqueue(ctx context.Context, url, requestedBy string) (models.ScrapeJob, error) {
+	if c := parsers.CanonicalURL(url); c != "" {
+		url = c
+	}
+
+	err := s.collector.Supports(url)
+	if err != nil {
+		return models.ScrapeJob{}, err
+	}
+
+	now := s.NowFunc()
+	job := models.ScrapeJob{
+		URL:         url,
+		Status:      models.ScrapeStatusQueued,
+		MaxAttempts: s.cfg.MaxAttempts,
+		RequestedBy: requestedBy,
+		RunAfter:    now,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	err = s.inTx(ctx, func(tx models.Tx) error {
+		id, err := tx.CreateScrapeJob(job)
+		if err != nil {
+			return err
+		}
+
+		job.ID = id
+		return nil
+	})
+	if err != nil {
+		return models.ScrapeJob{}, err
+	}
+
+	// Wake up an idle worker, if there is one.
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+
+	return job, nil
+}
+
+func (s *ScrapeService) Get(w http.ResponseWriter, r *http.Request) error {
+	id := r.PathValue("id")
+	job, err := s.rep.GetScrapeJobByID(r.Context(), id)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	j, err := json.Marshal(job)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(j)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	return nil
+}
+
+// ParserHealth writes the health of the parser of each portal.
+func (s *ScrapeService) ParserHealth(w http.ResponseWriter, r *http.Request) error {
+	j, err := json.Marshal(s.collector.Health())
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(j)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	return nil
+}
+
+// Run starts the workers and blocks until ctx is done and all workers
+// stopped. Jobs left running by a previous process are queued again first.
+func (s *ScrapeService) Run(ctx context.Context) error {
+	_, err := s.rep.RequeueScrapeJobs(ctx, s.NowFunc())
+	if err != nil {
+		return fmt.Errorf("failed to requeue scrape jobs: %w", err)
+	}
+
+	for range max(s.cfg.Workers, 1) {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.work(ctx)
+		}()
+	}
+
+	s.wg.Wait()
+
+	return nil
+}
+
+// work claims and runs due jobs until ctx is done.
+func (s *ScrapeService) work(ctx context.Context) {
+	for {
+		job, err := s.rep.ClaimScrapeJob(ctx, s.NowFunc())
+		if err == nil {
+			s.process(ctx, job)
+			continue
+		}
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		if !errors.Is(err, custerrors.ErrNotFound) {
+			s.errHandler(fmt.Errorf("failed to claim scrape job: %w", err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.wake:
+		case <-time.After(s.cfg.PollInterval):
+		}
+	}
+}
+
+// process scrapes the listing of job and stores the flat. The page is kept in
+// the archive either way, so listings missing required fields are not stored
+// but quarantined in the archive until they are reparsed. A job that cannot
+// be finished because ctx is done stays running and is requeued by the next Run.
+func (s *ScrapeService) process(ctx context.Context, job models.ScrapeJob) {
+	res, err := s.collector.Parse(ctx, job.URL)
+	if ctx.Err() != nil {
+		return
+	}
+
+	now := s.NowFunc()
+	if err == nil {
+		flat := res.Flat
+		normalize.Flat(&flat, now)
+		flat.SourceURL = job.URL
+		flat.CreatedAt = now
+		flat.UpdatedAt = now
+
+		err = s.inTx(ctx, func(tx models.Tx) error {
+			id, err := saveScrapedFlat(tx, flat, now)
+			if err != nil {
+				return err
+			}
+
+			err = tx.CreatePhotos(newPhotos(id, res.Photos, now))
+			if err != nil {
+				return err
+			}
+
+			err = addRequestedFlat(tx, job.RequestedBy, id, now)
+			if err != nil {
+				return err
+			}
+
+			job.Status = models.ScrapeStatusSucceeded
+			job.FlatID = id
+			job.LastError = ""
+			job.UpdatedAt = now
+			job.FinishedAt = &now
+
+			return tx.UpdateScrapeJob(job)
+		})
+		if err == nil {
+			s.archive.record(ctx, res.Page, job.FlatID, job.RequestedBy, nil)
+			return
+		}
+	}
+
+	s.errHandler(fmt.Errorf("scrape job %s attempt %d failed: %w", job.ID, job.Attempts, err))
+	s.archive.record(ctx, res.Page, "", job.RequestedBy, err)
+
+	job = retryOrFail(job, err, now, s.cfg.RetryDelay)
+	err = s.inTx(ctx, func(tx models.Tx) error {
+		return tx.UpdateScrapeJob(job)
+	})
+	if err != nil {
+		s.errHandler(fmt.Errorf("failed to update scrape job %s: %w", job.ID, err))
+	}
+}
+
+// saveScrapedFlat creates flat, linked to the flat it is a duplicate of if
+// there is one, or applies the changes to the flat scraped from the same
+// listing before, and returns its id.
+func saveScrapedFlat(tx models.Tx, flat models.Flat, now time.Time) (string, error) {
+	existing, err := tx.FindFlats(models.FlatFilter{SourceURLs: []string{flat.SourceURL}})
+	if err != nil {
+		return "", err
+	}
+
+	if len(existing) == 0 {
+		flat.CanonicalID, err = findCanonical(tx, flat)
+		if err != nil {
+			return "", err
+		}
+
+		flat.ID, err = tx.CreateFlat(flat)
+		if err != nil {
+			return "", err
+		}
+
+		err = tx.CreatePricePoints(pricePoints(flat, parsers.Fields, now))
+		if err != nil {
+			return "", err
+		}
+
+		return flat.ID, nil
+	}
+
+	stored := existing[0]
+	changes := diffFlat(stored, flat, now)
+	applyChanges(&stored, changes)
+	normalize.Flat(&stored, now)
+	stored.UpdatedAt = now
+	stored.LastCheckedAt = &now
+
+	err = tx.UpdateFlat(stored)
+	if err != nil {
+		return "", err
+	}
+
+	err = saveFlatChanges(tx, stored, changes, now)
+	if err != nil {
+		return "", err
+	}
+
+	return stored.ID, nil
+}
+
+// addRequestedFlat adds the flat to the workspace of the user who asked for
+// it. Flats scraped for no one, or for a user in no workspace, are not added.
+func addRequestedFlat(tx models.Tx, userID, flatID string, now time.Time) error {
+	if userID == "" {
+		return nil
+	}
+
+	workspaceID, err := tx.GetMemberWorkspaceID(userID)
+	if errors.Is(err, custerrors.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return tx.AddWorkspaceFlat(workspaceID, flatID, userID, now)
+}
+
+// retryOrFail records the failed attempt of job. The job is queued again
+// with an exponential delay unless it ran out of attempts or err is not
+// worth retrying.
+func retryOrFail(job models.ScrapeJob, err error, now time.Time, delay time.Duration) models.ScrapeJob {
+	job.LastError = err.Error()
+	job.UpdatedAt = now
+
+	if job.Attempts >= job.MaxAttempts || errors.Is(err, custerrors.ErrInvalidInput) || errors.Is(err, parsers.ErrListingGone) || errors.Is(err, parsers.ErrBodyTooLarge) || errors.Is(err, parsers.ErrRobotsDisallowed) || errors.Is(err, parsers.ErrIncompleteListing) {
+		job.Status = models.ScrapeStatusFailed
+		job.FinishedAt = &now
+		return job
+	}
+
+	job.Status = models.ScrapeStatusQueued
+	job.RunAfter = now.Add(delay << max(job.Attempts-1, 0))
+
+	return job
+}
+
+func (s *ScrapeService) inTx(ctx context.Context, f func(tx models.Tx) error) error {
+	tx, err := s.rep.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = f(tx)
+	if err != nil {
+		rBackErr := tx.Rollback()
+		if rBackErr != nil {
+			err = errors.Join(err, rBackErr)
+		}
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
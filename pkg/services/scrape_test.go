@@ -0,0 +1,118 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"hestia/pkg/custerrors"
+	"hestia/pkg/models"
+	"hestia/pkg/utils/parsers"
+)
+
+func Test_retryOrFail(t *testing.T) {
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	errTimeout := errors.New("timeout")
+
+	tests := map[string]struct {
+		job  models.ScrapeJob
+		err  error
+		want models.ScrapeJob
+	}{
+		"ok, first attempt retried after delay": {
+			job: models.ScrapeJob{Status: models.ScrapeStatusRunning, Attempts: 1, MaxAttempts: 3},
+			err: errTimeout,
+			want: models.ScrapeJob{
+				Status:      models.ScrapeStatusQueued,
+				Attempts:    1,
+				MaxAttempts: 3,
+				LastError:   "timeout",
+				RunAfter:    now.Add(30 * time.Second),
+				UpdatedAt:   now,
+			},
+		},
+		"ok, delay doubles": {
+			job: models.ScrapeJob{Status: models.ScrapeStatusRunning, Attempts: 2, MaxAttempts: 3},
+			err: errTimeout,
+			want: models.ScrapeJob{
+				Status:      models.ScrapeStatusQueued,
+				Attempts:    2,
+				MaxAttempts: 3,
+				LastError:   "timeout",
+				RunAfter:    now.Add(60 * time.Second),
+				UpdatedAt:   now,
+			},
+		},
+		"ok, out of attempts": {
+			job: models.ScrapeJob{Status: models.ScrapeStatusRunning, Attempts: 3, MaxAttempts: 3},
+			err: errTimeout,
+			want: models.ScrapeJob{
+				Status:      models.ScrapeStatusFailed,
+				Attempts:    3,
+				MaxAttempts: 3,
+				LastError:   "timeout",
+				UpdatedAt:   now,
+				FinishedAt:  &now,
+			},
+		},
+		"ok, invalid input not retried": {
+			job: models.ScrapeJob{Status: models.ScrapeStatusRunning, Attempts: 1, MaxAttempts: 3},
+			err: fmt.Errorf("unsupported portal: %w", custerrors.ErrInvalidInput),
+			want: models.ScrapeJob{
+				Status:      models.ScrapeStatusFailed,
+				Attempts:    1,
+				MaxAttempts: 3,
+				LastError:   "unsupported portal: invalid input",
+				UpdatedAt:   now,
+				FinishedAt:  &now,
+			},
+		},
+		"ok, page too large not retried": {
+			job: models.ScrapeJob{Status: models.ScrapeStatusRunning, Attempts: 1, MaxAttempts: 3},
+			err: parsers.ErrBodyTooLarge,
+			want: models.ScrapeJob{
+				Status:      models.ScrapeStatusFailed,
+				Attempts:    1,
+				MaxAttempts: 3,
+				LastError:   "response body too large",
+				UpdatedAt:   now,
+				FinishedAt:  &now,
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := retryOrFail(tc.job, tc.err, now, 30*time.Second)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got\n%#v\nwant\n%#v\n", got, tc.want)
+			}
+		})
+	}
+}
+
+func Test_previewFields(t *testing.T) {
+	res := parsers.Result{
+		Flat: models.Flat{Title: "Kawalerka", Price: "2 100 zł"},
+		Sources: map[parsers.Field]parsers.Source{
+			parsers.FieldTitle: {Strategy: parsers.StrategyJSONLD, Detail: "name"},
+			parsers.FieldPrice: {Strategy: parsers.StrategyCSS, Detail: "[data-testid=ad-price]"},
+		},
+	}
+
+	got := previewFields(res)
+	if len(got) != len(parsers.Fields) {
+		t.Fatalf("got %d fields want %d", len(got), len(parsers.Fields))
+	}
+
+	want := []models.FieldSource{
+		{Field: "title", Value: "Kawalerka", Strategy: "json-ld", Detail: "name"},
+		{Field: "price", Value: "2 100 zł", Strategy: "css", Detail: "[data-testid=ad-price]"},
+		{Field: "address"},
+	}
+	if !reflect.DeepEqual(got[:3], want) {
+		t.Errorf("got %+v want %+v", got[:3], want)
+	}
+}
@@ -0,0 +1,357 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"hestia/pkg/custerrors"
+	"hestia/pkg/middlewares"
+	"hestia/pkg/models"
+	"hestia/pkg/repos"
+)
+
+// ShortlistService manages the shortlists of the workspace of the user
+// making the request. Shortlists of other workspaces are never found.
+type ShortlistService struct {
+	rep        *repos.Store
+	wg         *sync.WaitGroup
+	errHandler ErrFunc
+	workspaces *WorkspaceService
+
+	// NowFunc is used to get the current time.
+	// Exposed for testing purposes.
+	NowFunc func() time.Time
+}
+
+// NewShortlistService creates a new Service.
+func NewShortlistService(db *sql.DB, workspaces *WorkspaceService, errHandler ErrFunc) *ShortlistService {
+	svc := &ShortlistService{
+		rep:        repos.New(db),
+		wg:         &sync.WaitGroup{},
+		errHandler: errHandler,
+		workspaces: workspaces,
+
+		NowFunc: time.Now,
+	}
+
+	return svc
+}
+
+// List writes the shortlists of the workspace by name.
+func (s *ShortlistService) List(w http.ResponseWriter, r *http.Request) error {
+	_, workspaceID, err := s.workspaces.RequestWorkspaceID(r)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	lists, err := s.rep.FindShortlists(r.Context(), models.ShortlistFilter{WorkspaceID: workspaceID})
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	return s.write(w, http.StatusOK, lists)
+}
+
+// Get writes the shortlist with the id in the path with its flats in order.
+func (s *ShortlistService) Get(w http.ResponseWriter, r *http.Request) error {
+	_, workspaceID, err := s.workspaces.RequestWorkspaceID(r)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	sl, err := s.rep.GetShortlist(r.Context(), workspaceID, r.PathValue("id"))
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	sl.Flats = make([]models.Flat, 0, len(sl.FlatIDs))
+	if len(sl.FlatIDs) > 0 {
+		flats, err := s.rep.FindFlats(r.Context(), models.FlatFilter{IDs: sl.FlatIDs})
+		if err != nil {
+			s.errHandler(err)
+			return err
+		}
+
+		byID := make(map[string]models.Flat, len(flats))
+		for _, f := range flats {
+			byID[f.ID] = f
+		}
+		for _, id := range sl.FlatIDs {
+			if f, ok := byID[id]; ok {
+				sl.Flats = append(sl.Flats, f)
+			}
+		}
+
+		err = markShortlisted(r.Context(), s.rep, workspaceID, sl.Flats)
+		if err != nil {
+			s.errHandler(err)
+			return err
+		}
+	}
+
+	return s.write(w, http.StatusOK, sl)
+}
+
+// Post creates the shortlist named in the request body in the workspace.
+// Names are unique per workspace.
+func (s *ShortlistService) Post(w http.ResponseWriter, r *http.Request) error {
+	userID, workspaceID, err := s.workspaces.RequestWorkspaceID(r)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	var req models.Shortlist
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		err = fmt.Errorf("invalid shortlist: %v: %w", err, custerrors.ErrInvalidInput)
+		s.errHandler(err)
+		return err
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		err = fmt.Errorf("shortlist without a name: %w", custerrors.ErrInvalidInput)
+		s.errHandler(err)
+		return err
+	}
+
+	now := s.NowFunc()
+	var sl models.Shortlist
+	err = s.inTx(r.Context(), func(tx models.Tx) error {
+		id, err := tx.CreateShortlist(models.Shortlist{WorkspaceID: workspaceID, UserID: userID, Name: name, CreatedAt: now, UpdatedAt: now})
+		if err != nil {
+			return err
+		}
+
+		sl, err = tx.GetShortlist(workspaceID, id)
+		return err
+	})
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	w.Header().Set("Location", "/api/v1/me/shortlists/"+sl.ID)
+	return s.write(w, http.StatusCreated, sl)
+}
+
+// Delete deletes the shortlist with the id in the path. The flats stay.
+func (s *ShortlistService) Delete(w http.ResponseWriter, r *http.Request) error {
+	_, workspaceID, err := s.workspaces.RequestWorkspaceID(r)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	err = s.inTx(r.Context(), func(tx models.Tx) error {
+		return tx.DeleteShortlist(workspaceID, r.PathValue("id"))
+	})
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// AddFlat puts the flat in the request body at the end of the shortlist
+// with the id in the path and writes the shortlist. A flat already on the
+// list keeps its place. The flat must belong to the workspace.
+func (s *ShortlistService) AddFlat(w http.ResponseWriter, r *http.Request) error {
+	var req models.ShortlistFlat
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil || req.FlatID == "" {
+		err = fmt.Errorf("invalid shortlist flat: %w", custerrors.ErrInvalidInput)
+		s.errHandler(err)
+		return err
+	}
+
+	return s.change(w, r, func(tx models.Tx, sl models.Shortlist, now time.Time) error {
+		ids, err := tx.FindFlatWorkspaces(req.FlatID)
+		if err != nil {
+			return err
+		}
+
+		if !slices.Contains(ids, sl.WorkspaceID) {
+			return fmt.Errorf("flat not found: %w", custerrors.ErrNotFound)
+		}
+
+		return tx.AddShortlistFlat(sl.ID, req.FlatID, now)
+	})
+}
+
+// RemoveFlat takes the flat with the flatID in the path off the shortlist
+// with the id in the path and writes the shortlist.
+func (s *ShortlistService) RemoveFlat(w http.ResponseWriter, r *http.Request) error {
+	return s.change(w, r, func(tx models.Tx, sl models.Shortlist, now time.Time) error {
+		return tx.RemoveShortlistFlat(sl.ID, r.PathValue("flatID"))
+	})
+}
+
+// Reorder puts the flats of the shortlist with the id in the path in the
+// order of the request body and writes the shortlist.
+func (s *ShortlistService) Reorder(w http.ResponseWriter, r *http.Request) error {
+	var req models.ShortlistOrder
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		err = fmt.Errorf("invalid shortlist order: %v: %w", err, custerrors.ErrInvalidInput)
+		s.errHandler(err)
+		return err
+	}
+
+	return s.change(w, r, func(tx models.Tx, sl models.Shortlist, now time.Time) error {
+		err := checkShortlistOrder(sl.FlatIDs, req.FlatIDs)
+		if err != nil {
+			return err
+		}
+
+		return tx.ReorderShortlist(sl.ID, req.FlatIDs)
+	})
+}
+
+// change applies fn to the shortlist of the workspace with the id in the
+// path and writes the shortlist after the change.
+func (s *ShortlistService) change(w http.ResponseWriter, r *http.Request, fn func(tx models.Tx, sl models.Shortlist, now time.Time) error) error {
+	_, workspaceID, err := s.workspaces.RequestWorkspaceID(r)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	now := s.NowFunc()
+	var sl models.Shortlist
+	err = s.inTx(r.Context(), func(tx models.Tx) error {
+		sl, err = tx.GetShortlist(workspaceID, r.PathValue("id"))
+		if err != nil {
+			return err
+		}
+
+		err = fn(tx, sl, now)
+		if err != nil {
+			return err
+		}
+
+		err = tx.TouchShortlist(sl.ID, now)
+		if err != nil {
+			return err
+		}
+
+		sl, err = tx.GetShortlist(workspaceID, sl.ID)
+		return err
+	})
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	return s.write(w, http.StatusOK, sl)
+}
+
+// checkShortlistOrder checks that order lists the flats of current, each once.
+func checkShortlistOrder(current, order []string) error {
+	if len(order) != len(current) {
+		return fmt.Errorf("order lists %d flats, the shortlist has %d: %w", len(order), len(current), custerrors.ErrInvalidInput)
+	}
+
+	seen := make(map[string]bool, len(order))
+	for _, id := range order {
+		if seen[id] {
+			return fmt.Errorf("flat %s listed twice: %w", id, custerrors.ErrInvalidInput)
+		}
+		if !slices.Contains(current, id) {
+			return fmt.Errorf("flat %s is not on the shortlist: %w", id, custerrors.ErrInvalidInput)
+		}
+		seen[id] = true
+	}
+
+	return nil
+}
+
+// markShortlisted sets which shortlists of the workspace each of the flats is on.
+func markShortlisted(ctx context.Context, rep *repos.Store, workspaceID string, flats []models.Flat) error {
+	if workspaceID == "" || len(flats) == 0 {
+		return nil
+	}
+
+	ids := make([]string, 0, len(flats))
+	for _, f := range flats {
+		ids = append(ids, f.ID)
+	}
+
+	byFlat, err := rep.FindFlatShortlists(ctx, workspaceID, ids)
+	if err != nil {
+		return err
+	}
+
+	for i := range flats {
+		flats[i].Shortlists = byFlat[flats[i].ID]
+		flats[i].Saved = len(flats[i].Shortlists) > 0
+	}
+
+	return nil
+}
+
+// requestUserID returns the id of the user making the request.
+func requestUserID(r *http.Request) (string, error) {
+	id, ok := middlewares.UserIDFromContext(r.Context())
+	if !ok || id == "" {
+		return "", fmt.Errorf("%w: %w", UserNotFound, custerrors.ErrNotFound)
+	}
+
+	return id, nil
+}
+
+// write writes v as the JSON response with status.
+func (s *ShortlistService) write(w http.ResponseWriter, status int, v any) error {
+	j, err := json.Marshal(v)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, err = w.Write(j)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	return nil
+}
+
+func (s *ShortlistService) inTx(ctx context.Context, f func(tx models.Tx) error) error {
+	tx, err := s.rep.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = f(tx)
+	if err != nil {
+		rBackErr := tx.Rollback()
+		if rBackErr != nil {
+			err = errors.Join(err, rBackErr)
+		}
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
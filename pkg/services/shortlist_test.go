@@ -0,0 +1,39 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"hestia/pkg/custerrors"
+)
+
+func Test_checkShortlistOrder(t *testing.T) {
+	current := []string{"3", "7", "12"}
+
+	tests := map[string]struct {
+		order   []string
+		wantErr error
+	}{
+		"ok, same order":     {order: []string{"3", "7", "12"}},
+		"ok, reordered":      {order: []string{"12", "3", "7"}},
+		"fail, missing flat": {order: []string{"12", "3"}, wantErr: custerrors.ErrInvalidInput},
+		"fail, listed twice": {order: []string{"12", "3", "3"}, wantErr: custerrors.ErrInvalidInput},
+		"fail, other flat":   {order: []string{"12", "3", "8"}, wantErr: custerrors.ErrInvalidInput},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := checkShortlistOrder(current, tc.order)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("expected errors to be %v got %v (via errors.Is)", tc.wantErr, err)
+			}
+		})
+	}
+
+	t.Run("ok, empty shortlist", func(t *testing.T) {
+		err := checkShortlistOrder(nil, []string{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+}
@@ -92,14 +92,16 @@ func (s *UserService) GetAll(w http.ResponseWriter, r *http.Request) error {
 		return UserNotFound
 	}
 
-	users, err := s.repo.FindUsers(r.Context(), models.UserFilter{})
+	page, err := parsePageRequest(r.URL.Query())
 	if err != nil {
 		s.errHandler(err)
 		return err
 	}
-	if len(users) == 0 {
-		s.errHandler(UserNotFound)
-		return custerrors.ErrNotFound
+
+	users, err := s.repo.PageUsers(r.Context(), models.UserFilter{}, page)
+	if err != nil {
+		s.errHandler(err)
+		return err
 	}
 
 	j, err := json.Marshal(users)
@@ -0,0 +1,594 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"hestia/pkg/custerrors"
+	"hestia/pkg/models"
+	"hestia/pkg/repos"
+	"hestia/pkg/utils"
+)
+
+// workspaceInviteTTL is how long an invitation to a workspace can be accepted.
+const workspaceInviteTTL = 7 * 24 * time.Hour
+
+// WorkspaceService manages the workspace of the user making the request and
+// checks that the flats a request reads or changes belong to it.
+type WorkspaceService struct {
+	rep        *repos.Store
+	wg         *sync.WaitGroup
+	errHandler ErrFunc
+	mailer     Mailer
+	baseURL    string
+
+	// NowFunc is used to get the current time.
+	// Exposed for testing purposes.
+	NowFunc func() time.Time
+}
+
+// NewWorkspaceService creates a new Service. Links in the invitation emails
+// point to hestia at baseURL.
+func NewWorkspaceService(db *sql.DB, mailer Mailer, baseURL string, errHandler ErrFunc) *WorkspaceService {
+	svc := &WorkspaceService{
+		rep:        repos.New(db),
+		wg:         &sync.WaitGroup{},
+		errHandler: errHandler,
+		mailer:     mailer,
+		baseURL:    baseURL,
+
+		NowFunc: time.Now,
+	}
+
+	return svc
+}
+
+// MemberWorkspaceID returns the id of the workspace of the user. A user who
+// is in no workspace yet gets a workspace of their own.
+func (s *WorkspaceService) MemberWorkspaceID(ctx context.Context, userID string) (string, error) {
+	id, err := s.rep.GetMemberWorkspaceID(ctx, userID)
+	if !errors.Is(err, custerrors.ErrNotFound) {
+		return id, err
+	}
+
+	err = s.inTx(ctx, func(tx models.Tx) error {
+		id, err = s.createPersonal(tx, userID)
+		return err
+	})
+	if errors.Is(err, custerrors.ErrConstraintViolated) {
+		// Another request made the workspace first.
+		return s.rep.GetMemberWorkspaceID(ctx, userID)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
+
+// RequestWorkspaceID returns the ids of the user making the request and of
+// their workspace.
+func (s *WorkspaceService) RequestWorkspaceID(r *http.Request) (string, string, error) {
+	userID, err := requestUserID(r)
+	if err != nil {
+		return "", "", err
+	}
+
+	workspaceID, err := s.MemberWorkspaceID(r.Context(), userID)
+	if err != nil {
+		return "", "", err
+	}
+
+	return userID, workspaceID, nil
+}
+
+// CheckFlat checks that the flat with the id in the path belongs to the
+// workspace of the user making the request. Flats of other workspaces are
+// not found.
+func (s *WorkspaceService) CheckFlat(r *http.Request) error {
+	_, workspaceID, err := s.RequestWorkspaceID(r)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	ids, err := s.rep.FindFlatWorkspaces(r.Context(), r.PathValue("id"))
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	if !slices.Contains(ids, workspaceID) {
+		return fmt.Errorf("flat not found: %w", custerrors.ErrNotFound)
+	}
+
+	return nil
+}
+
+// CheckScrapeJob checks that the scrape job with the id in the path was
+// queued by a member of the workspace of the user making the request. Jobs
+// of other workspaces, and jobs queued for no one, are not found.
+func (s *WorkspaceService) CheckScrapeJob(r *http.Request) error {
+	userID, workspaceID, err := s.RequestWorkspaceID(r)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	job, err := s.rep.GetScrapeJobByID(r.Context(), r.PathValue("id"))
+	if err != nil {
+		if !errors.Is(err, custerrors.ErrNotFound) {
+			s.errHandler(err)
+		}
+		return err
+	}
+
+	if job.RequestedBy == userID {
+		return nil
+	}
+
+	notFound := fmt.Errorf("scrape job not found: %w", custerrors.ErrNotFound)
+	if job.RequestedBy == "" {
+		return notFound
+	}
+
+	requesterWorkspaceID, err := s.rep.GetMemberWorkspaceID(r.Context(), job.RequestedBy)
+	if errors.Is(err, custerrors.ErrNotFound) {
+		return notFound
+	}
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	if requesterWorkspaceID != workspaceID {
+		return notFound
+	}
+
+	return nil
+}
+
+// Get writes the workspace of the user with its members.
+func (s *WorkspaceService) Get(w http.ResponseWriter, r *http.Request) error {
+	_, workspaceID, err := s.RequestWorkspaceID(r)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	ws, err := s.rep.GetWorkspace(r.Context(), workspaceID)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	return s.write(w, http.StatusOK, ws)
+}
+
+// Put renames the workspace of the user to the name in the request body and
+// writes it. Only the owner may rename a workspace.
+func (s *WorkspaceService) Put(w http.ResponseWriter, r *http.Request) error {
+	userID, workspaceID, err := s.RequestWorkspaceID(r)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	var req models.Workspace
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		err = fmt.Errorf("invalid workspace: %v: %w", err, custerrors.ErrInvalidInput)
+		s.errHandler(err)
+		return err
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		err = fmt.Errorf("workspace without a name: %w", custerrors.ErrInvalidInput)
+		s.errHandler(err)
+		return err
+	}
+
+	var ws models.Workspace
+	err = s.inTx(r.Context(), func(tx models.Tx) error {
+		ws, err = s.ownedWorkspace(tx, userID, workspaceID)
+		if err != nil {
+			return err
+		}
+
+		ws.Name = name
+		ws.UpdatedAt = s.NowFunc()
+
+		return tx.UpdateWorkspace(ws)
+	})
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	return s.write(w, http.StatusOK, ws)
+}
+
+// Invite emails an invitation to the workspace of the user to the email in
+// the request body. Only the owner may invite users. The email is sent in
+// the background.
+func (s *WorkspaceService) Invite(w http.ResponseWriter, r *http.Request) error {
+	userID, workspaceID, err := s.RequestWorkspaceID(r)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	var req models.WorkspaceInvite
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		err = fmt.Errorf("invalid invitation: %v: %w", err, custerrors.ErrInvalidInput)
+		s.errHandler(err)
+		return err
+	}
+
+	addr := strings.TrimSpace(req.Email)
+	if addr == "" {
+		err = fmt.Errorf("invitation without an email: %w", custerrors.ErrInvalidInput)
+		s.errHandler(err)
+		return err
+	}
+
+	token, err := utils.TokenGenerator()
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	now := s.NowFunc()
+	emailToken := models.EmailToken{
+		ID:          utils.RandStringBytes(),
+		TokenHash:   hashWorkspaceToken(token),
+		UserID:      userID,
+		Email:       addr,
+		Purpose:     models.TokenPurposeWorkspaceInvite,
+		WorkspaceID: workspaceID,
+		CreatedAt:   now,
+	}
+
+	var ws models.Workspace
+	err = s.inTx(r.Context(), func(tx models.Tx) error {
+		ws, err = s.ownedWorkspace(tx, userID, workspaceID)
+		if err != nil {
+			return err
+		}
+
+		if slices.ContainsFunc(ws.Members, func(m models.WorkspaceMember) bool { return strings.EqualFold(m.Email, addr) }) {
+			return fmt.Errorf("%s is a member already: %w", addr, custerrors.ErrConstraintViolated)
+		}
+
+		return tx.CreateEmailToken(emailToken)
+	})
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	owner, _ := ws.Member(userID)
+	data := models.WorkspaceInvitation{
+		Workspace: ws,
+		InvitedBy: owner.Email,
+		Token:     models.EmailTokenRaw{ID: emailToken.ID, Token: token},
+		ExpiresAt: now.Add(workspaceInviteTTL),
+		URL:       strings.TrimSuffix(s.baseURL, "/") + "/api/v1/me/workspace/join",
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+		defer cancel()
+
+		err := s.mailer.Send(ctx, "workspace-invitation", addr, data)
+		if err != nil {
+			s.errHandler(fmt.Errorf("failed to send workspace-invitation to %s: %w", addr, err))
+		}
+	}()
+
+	w.WriteHeader(http.StatusAccepted)
+	return nil
+}
+
+// Join accepts the invitation in the request body and writes the workspace
+// the user joined. The user leaves their current workspace. When they were
+// its last member, the flats of the workspace move along with them and the
+// workspace is deleted.
+func (s *WorkspaceService) Join(w http.ResponseWriter, r *http.Request) error {
+	userID, err := requestUserID(r)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	var req models.WorkspaceJoin
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil || req.ID == "" || req.Token == "" {
+		err = fmt.Errorf("invalid invitation: %w", custerrors.ErrInvalidInput)
+		s.errHandler(err)
+		return err
+	}
+
+	now := s.NowFunc()
+	var ws models.Workspace
+	err = s.inTx(r.Context(), func(tx models.Tx) error {
+		tok, err := tx.GetEmailToken(req.ID)
+		if err != nil {
+			return err
+		}
+
+		err = checkWorkspaceToken(tok, req.Token, now)
+		if err != nil {
+			return err
+		}
+
+		users, err := tx.FindUsers(models.UserFilter{IDs: []string{userID}})
+		if err != nil {
+			return err
+		}
+		if len(users) != 1 {
+			return fmt.Errorf("%w: %w", UserNotFound, custerrors.ErrNotFound)
+		}
+		if !strings.EqualFold(users[0].Email, tok.Email) {
+			return fmt.Errorf("invitation sent to another email: %w", custerrors.ErrForbidden)
+		}
+
+		currentID, err := tx.GetMemberWorkspaceID(userID)
+		if err != nil && !errors.Is(err, custerrors.ErrNotFound) {
+			return err
+		}
+
+		if currentID == tok.WorkspaceID {
+			return fmt.Errorf("already a member of the workspace: %w", custerrors.ErrConstraintViolated)
+		}
+
+		if currentID != "" {
+			err = s.leave(tx, currentID, userID, tok.WorkspaceID)
+			if err != nil {
+				return err
+			}
+		}
+
+		err = tx.AddWorkspaceMember(tok.WorkspaceID, models.WorkspaceMember{UserID: userID, Role: models.WorkspaceRoleMember, JoinedAt: now})
+		if err != nil {
+			return err
+		}
+
+		tok.ConsumedAt = &now
+		err = tx.UpdateEmailToken(tok)
+		if err != nil {
+			return err
+		}
+
+		ws, err = tx.GetWorkspace(tok.WorkspaceID)
+		return err
+	})
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	return s.write(w, http.StatusOK, ws)
+}
+
+// RemoveMember removes the member with the id in the path from the workspace
+// of the user. Members may leave, the owner may remove anyone. The flats
+// stay in the workspace. When the owner leaves, the member who joined first
+// becomes the owner.
+func (s *WorkspaceService) RemoveMember(w http.ResponseWriter, r *http.Request) error {
+	userID, workspaceID, err := s.RequestWorkspaceID(r)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	memberID := r.PathValue("id")
+	err = s.inTx(r.Context(), func(tx models.Tx) error {
+		ws, err := tx.GetWorkspace(workspaceID)
+		if err != nil {
+			return err
+		}
+
+		if _, ok := ws.Member(memberID); !ok {
+			return fmt.Errorf("workspace member not found: %w", custerrors.ErrNotFound)
+		}
+
+		if memberID != userID {
+			if owner, _ := ws.Owner(); owner.UserID != userID {
+				return fmt.Errorf("only the owner may remove members: %w", custerrors.ErrForbidden)
+			}
+		}
+
+		if len(ws.Members) == 1 {
+			return fmt.Errorf("the last member cannot leave the workspace: %w", custerrors.ErrInvalidInput)
+		}
+
+		return s.leave(tx, workspaceID, memberID, "")
+	})
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// leave takes the user out of the workspace. When the user is its last
+// member, its flats are copied to the workspace with moveToID and the
+// workspace is deleted. When the user owns it, the next owner is promoted.
+func (s *WorkspaceService) leave(tx models.Tx, workspaceID, userID, moveToID string) error {
+	ws, err := tx.GetWorkspace(workspaceID)
+	if err != nil {
+		return err
+	}
+
+	next, ok := nextOwner(ws, userID)
+	if !ok {
+		if moveToID != "" {
+			err = tx.CopyWorkspaceFlats(workspaceID, moveToID)
+			if err != nil {
+				return err
+			}
+		}
+
+		return tx.DeleteWorkspace(workspaceID)
+	}
+
+	err = tx.RemoveWorkspaceMember(workspaceID, userID)
+	if err != nil {
+		return err
+	}
+
+	if next.Role == models.WorkspaceRoleOwner {
+		return nil
+	}
+
+	next.Role = models.WorkspaceRoleOwner
+	return tx.UpdateWorkspaceMember(workspaceID, next)
+}
+
+// createPersonal creates a workspace owned by the user, named after their email.
+func (s *WorkspaceService) createPersonal(tx models.Tx, userID string) (string, error) {
+	users, err := tx.FindUsers(models.UserFilter{IDs: []string{userID}})
+	if err != nil {
+		return "", err
+	}
+	if len(users) != 1 {
+		return "", fmt.Errorf("%w: %w", UserNotFound, custerrors.ErrNotFound)
+	}
+
+	now := s.NowFunc()
+	id, err := tx.CreateWorkspace(models.Workspace{Name: users[0].Email, CreatedAt: now, UpdatedAt: now})
+	if err != nil {
+		return "", err
+	}
+
+	err = tx.AddWorkspaceMember(id, models.WorkspaceMember{UserID: userID, Role: models.WorkspaceRoleOwner, JoinedAt: now})
+	if err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
+
+// ownedWorkspace returns the workspace if userID owns it.
+func (s *WorkspaceService) ownedWorkspace(tx models.Tx, userID, workspaceID string) (models.Workspace, error) {
+	ws, err := tx.GetWorkspace(workspaceID)
+	if err != nil {
+		return models.Workspace{}, err
+	}
+
+	if owner, _ := ws.Owner(); owner.UserID != userID {
+		return models.Workspace{}, fmt.Errorf("only the owner may change the workspace: %w", custerrors.ErrForbidden)
+	}
+
+	return ws, nil
+}
+
+// nextOwner returns the member owning the workspace once the user with
+// leavingID leaves it: the owner if it is someone else, the member who
+// joined first otherwise. It returns false when no one is left.
+func nextOwner(ws models.Workspace, leavingID string) (models.WorkspaceMember, bool) {
+	var next models.WorkspaceMember
+	found := false
+	for _, m := range ws.Members {
+		if m.UserID == leavingID {
+			continue
+		}
+		if m.Role == models.WorkspaceRoleOwner {
+			return m, true
+		}
+		if !found || m.JoinedAt.Before(next.JoinedAt) {
+			next = m
+			found = true
+		}
+	}
+
+	return next, found
+}
+
+// checkWorkspaceToken checks that tok is an unused, unexpired invitation to
+// a workspace matching the raw token.
+func checkWorkspaceToken(tok models.EmailToken, raw string, now time.Time) error {
+	if tok.Purpose != models.TokenPurposeWorkspaceInvite || tok.WorkspaceID == "" {
+		return fmt.Errorf("invitation not found: %w", custerrors.ErrNotFound)
+	}
+
+	if subtle.ConstantTimeCompare([]byte(tok.TokenHash), []byte(hashWorkspaceToken(raw))) != 1 {
+		return fmt.Errorf("invitation not found: %w", custerrors.ErrNotFound)
+	}
+
+	if tok.ConsumedAt != nil {
+		return fmt.Errorf("invitation was used already: %w", custerrors.ErrInvalidInput)
+	}
+
+	if !now.Before(tok.CreatedAt.Add(workspaceInviteTTL)) {
+		return fmt.Errorf("invitation expired: %w", custerrors.ErrInvalidInput)
+	}
+
+	return nil
+}
+
+// hashWorkspaceToken returns the hash of the raw token stored with an invitation.
+func hashWorkspaceToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+// write writes v as the JSON response with status.
+func (s *WorkspaceService) write(w http.ResponseWriter, status int, v any) error {
+	j, err := json.Marshal(v)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, err = w.Write(j)
+	if err != nil {
+		s.errHandler(err)
+		return err
+	}
+
+	return nil
+}
+
+func (s *WorkspaceService) inTx(ctx context.Context, f func(tx models.Tx) error) error {
+	tx, err := s.rep.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = f(tx)
+	if err != nil {
+		rBackErr := tx.Rollback()
+		if rBackErr != nil {
+			err = errors.Join(err, rBackErr)
+		}
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
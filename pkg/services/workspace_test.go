@@ -0,0 +1,79 @@
+package services
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"hestia/pkg/custerrors"
+	"hestia/pkg/models"
+)
+
+func Test_nextOwner(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 5, d, 0, 0, 0, 0, time.UTC) }
+	owner := models.WorkspaceMember{UserID: "1", Role: models.WorkspaceRoleOwner, JoinedAt: day(1)}
+	early := models.WorkspaceMember{UserID: "2", Role: models.WorkspaceRoleMember, JoinedAt: day(2)}
+	late := models.WorkspaceMember{UserID: "3", Role: models.WorkspaceRoleMember, JoinedAt: day(3)}
+
+	tests := map[string]struct {
+		members []models.WorkspaceMember
+		leaving string
+		want    models.WorkspaceMember
+		wantOK  bool
+	}{
+		"ok, member leaves":          {members: []models.WorkspaceMember{owner, early, late}, leaving: "3", want: owner, wantOK: true},
+		"ok, owner leaves":           {members: []models.WorkspaceMember{owner, late, early}, leaving: "1", want: early, wantOK: true},
+		"ok, owner leaves one other": {members: []models.WorkspaceMember{owner, late}, leaving: "1", want: late, wantOK: true},
+		"ok, last member leaves":     {members: []models.WorkspaceMember{owner}, leaving: "1"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, ok := nextOwner(models.Workspace{Members: tc.members}, tc.leaving)
+			if ok != tc.wantOK {
+				t.Fatalf("got ok %v want %v", ok, tc.wantOK)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %+v want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func Test_checkWorkspaceToken(t *testing.T) {
+	now := time.Date(2024, 5, 8, 12, 0, 0, 0, time.UTC)
+	invite := models.EmailToken{
+		ID:          "abc",
+		TokenHash:   hashWorkspaceToken("secret"),
+		Purpose:     models.TokenPurposeWorkspaceInvite,
+		WorkspaceID: "3",
+		CreatedAt:   now.Add(-time.Hour),
+	}
+	with := func(f func(tok *models.EmailToken)) models.EmailToken {
+		tok := invite
+		f(&tok)
+		return tok
+	}
+
+	tests := map[string]struct {
+		tok     models.EmailToken
+		raw     string
+		wantErr error
+	}{
+		"ok":                   {tok: invite, raw: "secret"},
+		"fail, wrong token":    {tok: invite, raw: "guess", wantErr: custerrors.ErrNotFound},
+		"fail, password reset": {tok: with(func(tok *models.EmailToken) { tok.Purpose = models.TokenPurposePasswordReset }), raw: "secret", wantErr: custerrors.ErrNotFound},
+		"fail, consumed":       {tok: with(func(tok *models.EmailToken) { tok.ConsumedAt = &now }), raw: "secret", wantErr: custerrors.ErrInvalidInput},
+		"fail, expired":        {tok: with(func(tok *models.EmailToken) { tok.CreatedAt = now.Add(-workspaceInviteTTL) }), raw: "secret", wantErr: custerrors.ErrInvalidInput},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := checkWorkspaceToken(tc.tok, tc.raw, now)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("expected errors to be %v got %v (via errors.Is)", tc.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,339 @@
+package normalize
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"unicode"
+
+	"hestia/pkg/models"
+)
+
+//go:embed data/districts.json
+var districtsJSON []byte
+
+var (
+	postcodeRe = regexp.MustCompile(`\b\d{2}-\d{3}\b`)
+	romanRe    = regexp.MustCompile(`^[ivxl]+$`)
+	streetRe   = regexp.MustCompile(`(?i)^(ul\.?|ulica)\s+`)
+)
+
+// locationNoise are the words left out when address parts are compared
+// with the dictionary, on top of addressNoise.
+var locationNoise = map[string]bool{
+	"dzielnica": true, "wojewodztwo": true, "gmina": true, "powiat": true,
+	"polska": true, "poland": true,
+}
+
+// streetPrefixes are the words a street part of an address starts with.
+var streetPrefixes = map[string]bool{
+	"ul": true, "ulica": true,
+	"al": true, "aleja": true, "aleje": true,
+	"pl": true, "plac": true,
+	"os": true, "osiedle": true,
+	"rondo": true,
+}
+
+// Dictionary is the district taxonomy addresses are parsed with: the
+// regions and, for each city, its districts and their sub-districts.
+type Dictionary struct {
+	// regions and cities are keyed by folded name, cities also by alias.
+	regions map[string]string
+	cities  map[string]*dictCity
+	// districts lists the cities with a district or sub-district of each
+	// folded name, to tell the city of an address without one.
+	districts map[string][]*dictCity
+}
+
+type dictCity struct {
+	name   string
+	region string
+	// districts maps folded district names and aliases to district names.
+	districts map[string]string
+	// subdistricts maps folded sub-district names to the sub-district and its district.
+	subdistricts map[string][2]string
+}
+
+// dictionaryFile is the format of a dictionary file.
+type dictionaryFile struct {
+	Regions []string `json:"regions"`
+	Cities  []struct {
+		Name      string   `json:"name"`
+		Region    string   `json:"region"`
+		Aliases   []string `json:"aliases"`
+		Districts []struct {
+			Name         string   `json:"name"`
+			Aliases      []string `json:"aliases"`
+			Subdistricts []string `json:"subdistricts"`
+		} `json:"districts"`
+	} `json:"cities"`
+}
+
+// LoadDictionary reads a district dictionary in the format of the bundled
+// one. Names that are ambiguous within a city are rejected.
+func LoadDictionary(r io.Reader) (*Dictionary, error) {
+	var file dictionaryFile
+	err := json.NewDecoder(r).Decode(&file)
+	if err != nil {
+		return nil, fmt.Errorf("invalid district dictionary: %w", err)
+	}
+
+	d := &Dictionary{
+		regions:   make(map[string]string, len(file.Regions)),
+		cities:    make(map[string]*dictCity, len(file.Cities)),
+		districts: make(map[string][]*dictCity),
+	}
+
+	for _, name := range file.Regions {
+		d.regions[fold(name)] = name
+	}
+
+	var errs error
+	for _, fc := range file.Cities {
+		if fc.Name == "" {
+			errs = errors.Join(errs, errors.New("city without a name"))
+			continue
+		}
+		if _, ok := d.regions[fold(fc.Region)]; !ok {
+			errs = errors.Join(errs, fmt.Errorf("%s: unknown region %q", fc.Name, fc.Region))
+		}
+
+		c := &dictCity{
+			name:         fc.Name,
+			region:       fc.Region,
+			districts:    make(map[string]string),
+			subdistricts: make(map[string][2]string),
+		}
+		for _, name := range append([]string{fc.Name}, fc.Aliases...) {
+			if other, ok := d.cities[fold(name)]; ok && other != c {
+				errs = errors.Join(errs, fmt.Errorf("%s: ambiguous city name %q", fc.Name, name))
+			}
+			d.cities[fold(name)] = c
+		}
+
+		for _, fd := range fc.Districts {
+			for _, name := range append([]string{fd.Name}, fd.Aliases...) {
+				if other, ok := c.districts[fold(name)]; ok && other != fd.Name {
+					errs = errors.Join(errs, fmt.Errorf("%s: ambiguous district name %q", fc.Name, name))
+				}
+				c.districts[fold(name)] = fd.Name
+			}
+		}
+
+		for _, fd := range fc.Districts {
+			for _, name := range fd.Subdistricts {
+				key := fold(name)
+				if district, ok := c.districts[key]; ok && district != fd.Name {
+					errs = errors.Join(errs, fmt.Errorf("%s: sub-district %q is also a district", fc.Name, name))
+				}
+				if sd, ok := c.subdistricts[key]; ok && sd[1] != fd.Name {
+					errs = errors.Join(errs, fmt.Errorf("%s: ambiguous sub-district name %q", fc.Name, name))
+				}
+				c.subdistricts[key] = [2]string{name, fd.Name}
+			}
+		}
+
+		keys := make(map[string]bool, len(c.districts)+len(c.subdistricts))
+		for k := range c.districts {
+			keys[k] = true
+		}
+		for k := range c.subdistricts {
+			keys[k] = true
+		}
+		for k := range keys {
+			d.districts[k] = append(d.districts[k], c)
+		}
+	}
+
+	if errs != nil {
+		return nil, fmt.Errorf("invalid district dictionary: %w", errs)
+	}
+
+	return d, nil
+}
+
+// bundledDictionary is the dictionary shipped with hestia.
+var bundledDictionary = sync.OnceValue(func() *Dictionary {
+	d, err := LoadDictionary(bytes.NewReader(districtsJSON))
+	if err != nil {
+		// The bundled file is covered by the tests.
+		panic(err)
+	}
+	return d
+})
+
+var dictionary atomic.Pointer[Dictionary]
+
+// Districts returns the dictionary used by Flat, the bundled one unless
+// SetDistricts replaced it.
+func Districts() *Dictionary {
+	if d := dictionary.Load(); d != nil {
+		return d
+	}
+
+	return bundledDictionary()
+}
+
+// SetDistricts replaces the dictionary used by Flat. A nil dictionary
+// restores the bundled one.
+func SetDistricts(d *Dictionary) {
+	dictionary.Store(d)
+}
+
+// ParseAddress splits an address such as "Mokotów, Warszawa, mazowieckie"
+// or "ul. Puławska 12, Stary Mokotów, Warszawa" into its parts. The parts are
+// separated by commas and may come in any order. The city is derived from
+// the district if it is not mentioned and the district is unique, the region
+// from the city. Parts that are neither in the dictionary nor a street are
+// returned as unmatched.
+func (d *Dictionary) ParseAddress(s string) models.Location {
+	var (
+		loc  models.Location
+		city *dictCity
+		rest []string
+	)
+
+	for _, part := range strings.Split(postcodeRe.ReplaceAllString(s, " "), ",") {
+		part = strings.Join(strings.Fields(part), " ")
+		key := fold(part)
+		if key == "" {
+			continue
+		}
+
+		if r, ok := d.regions[key]; ok && loc.Region == "" {
+			loc.Region = r
+			continue
+		}
+		if c, ok := d.cities[key]; ok && city == nil {
+			city = c
+			continue
+		}
+
+		rest = append(rest, part)
+	}
+
+	if city == nil {
+		city = d.cityOf(rest)
+	}
+
+	for _, part := range rest {
+		key := fold(part)
+		if city != nil {
+			if name, ok := city.districts[key]; ok && loc.District == "" {
+				loc.District = name
+				continue
+			}
+			if sd, ok := city.subdistricts[key]; ok && loc.SubDistrict == "" {
+				loc.SubDistrict = sd[0]
+				if loc.District == "" {
+					loc.District = sd[1]
+				}
+				continue
+			}
+		}
+
+		if loc.Street == "" && isStreet(part) {
+			loc.Street = streetRe.ReplaceAllString(part, "")
+			continue
+		}
+
+		loc.Unmatched = append(loc.Unmatched, part)
+	}
+
+	if city != nil {
+		loc.City = city.name
+		if loc.Region == "" {
+			loc.Region = city.region
+		}
+	}
+
+	return loc
+}
+
+// cityOf returns the only city that has a district or sub-district named
+// like one of parts, nil if there is none or several.
+func (d *Dictionary) cityOf(parts []string) *dictCity {
+	for _, part := range parts {
+		if cities := d.districts[fold(part)]; len(cities) == 1 {
+			return cities[0]
+		}
+	}
+
+	return nil
+}
+
+// City returns the dictionary name of the city spelled name.
+func (d *Dictionary) City(name string) (string, bool) {
+	c, ok := d.cities[fold(name)]
+	if !ok {
+		return "", false
+	}
+
+	return c.name, true
+}
+
+// District returns the dictionary name of the district or sub-district
+// spelled name in city. An empty city matches any city that has a district
+// or sub-district of that name, as long as there is only one.
+func (d *Dictionary) District(city, name string) (string, bool) {
+	key := fold(name)
+
+	c, ok := d.cities[fold(city)]
+	if !ok {
+		if city != "" || len(d.districts[key]) != 1 {
+			return "", false
+		}
+		c = d.districts[key][0]
+	}
+
+	if name, ok := c.districts[key]; ok {
+		return name, true
+	}
+	if sd, ok := c.subdistricts[key]; ok {
+		return sd[0], true
+	}
+
+	return "", false
+}
+
+// fold reduces a name to its words, in lowercase and without diacritics or
+// the words portals add inconsistently, so that "Dzielnica II Grzegórzki"
+// and "grzegorzki" are equal.
+func fold(s string) string {
+	s = diacritics.Replace(strings.ToLower(s))
+	fields := strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	words := make([]string, 0, len(fields))
+	for i, w := range fields {
+		if addressNoise[w] || locationNoise[w] {
+			continue
+		}
+		// Districts of Kraków are numbered, "Dzielnica II Grzegórzki".
+		if i > 0 && fields[i-1] == "dzielnica" && romanRe.MatchString(w) {
+			continue
+		}
+		words = append(words, w)
+	}
+
+	return strings.Join(words, " ")
+}
+
+// isStreet reports whether the address part s looks like a street: it
+// starts with a street type or has a house number.
+func isStreet(s string) bool {
+	first, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(s)), " ")
+	if streetPrefixes[strings.TrimSuffix(first, ".")] {
+		return true
+	}
+
+	return strings.IndexFunc(s, unicode.IsDigit) >= 0
+}
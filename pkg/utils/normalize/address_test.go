@@ -0,0 +1,145 @@
+package normalize
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"hestia/pkg/models"
+)
+
+func Test_Dictionary_ParseAddress(t *testing.T) {
+	tests := map[string]struct {
+		address string
+		want    models.Location
+	}{
+		"ok, district city region": {
+			address: "Mokotów, Warszawa, mazowieckie",
+			want:    models.Location{City: "Warszawa", District: "Mokotów", Region: "mazowieckie"},
+		},
+		"ok, sub-district and street": {
+			address: "Warszawa, Mokotów, Stary Mokotów, ul. Puławska 12",
+			want:    models.Location{City: "Warszawa", District: "Mokotów", SubDistrict: "Stary Mokotów", Street: "Puławska 12", Region: "mazowieckie"},
+		},
+		"ok, numbered district": {
+			address: "Dzielnica II Grzegórzki, Kraków, małopolskie",
+			want:    models.Location{City: "Kraków", District: "Grzegórzki", Region: "małopolskie"},
+		},
+		"ok, city from sub-district": {
+			address: "Kabaty",
+			want:    models.Location{City: "Warszawa", District: "Ursynów", SubDistrict: "Kabaty", Region: "mazowieckie"},
+		},
+		"ok, postcode": {
+			address: "ul. Długa 5, 80-001 Gdańsk",
+			want:    models.Location{City: "Gdańsk", Street: "Długa 5", Region: "pomorskie"},
+		},
+		"ok, without diacritics": {
+			address: "krakow, krowodrza",
+			want:    models.Location{City: "Kraków", District: "Krowodrza", Region: "małopolskie"},
+		},
+		"ok, district of several cities": {
+			address: "Stare Miasto",
+			want:    models.Location{Unmatched: []string{"Stare Miasto"}},
+		},
+		"ok, unknown town": {
+			address: "Pcim Dolny, małopolskie",
+			want:    models.Location{Region: "małopolskie", Unmatched: []string{"Pcim Dolny"}},
+		},
+		"ok, empty": {},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := bundledDictionary().ParseAddress(tc.address)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got\n%+v\nwant\n%+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func Test_Dictionary_District(t *testing.T) {
+	tests := map[string]struct {
+		city   string
+		name   string
+		want   string
+		wantOk bool
+	}{
+		"ok, district":            {city: "warszawa", name: "mokotow", want: "Mokotów", wantOk: true},
+		"ok, sub-district":        {city: "Warszawa", name: "kabaty", want: "Kabaty", wantOk: true},
+		"ok, unique without city": {name: "Krowodrza", want: "Krowodrza", wantOk: true},
+		"fail, ambiguous":         {name: "Stare Miasto"},
+		"fail, other city":        {city: "Kraków", name: "Mokotów"},
+		"fail, unknown city":      {city: "Pcim", name: "Mokotów"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, ok := bundledDictionary().District(tc.city, tc.name)
+			if got != tc.want || ok != tc.wantOk {
+				t.Errorf("got %q, %v want %q, %v", got, ok, tc.want, tc.wantOk)
+			}
+		})
+	}
+}
+
+func Test_LoadDictionary(t *testing.T) {
+	tests := map[string]struct {
+		file    string
+		wantErr bool
+	}{
+		"ok, bundled": {
+			file: string(districtsJSON),
+		},
+		"ok, alias without diacritics": {
+			file: `{"regions": ["małopolskie"], "cities": [{"name": "Kraków", "region": "małopolskie", "aliases": ["Krakow"]}]}`,
+		},
+		"fail, unknown region": {
+			file:    `{"regions": ["mazowieckie"], "cities": [{"name": "Kraków", "region": "małopolskie"}]}`,
+			wantErr: true,
+		},
+		"fail, ambiguous district": {
+			file: `{"regions": ["mazowieckie"], "cities": [{"name": "Warszawa", "region": "mazowieckie", "districts": [
+				{"name": "Mokotów", "aliases": ["Wola"]}, {"name": "Wola"}]}]}`,
+			wantErr: true,
+		},
+		"fail, sub-district is a district": {
+			file: `{"regions": ["mazowieckie"], "cities": [{"name": "Warszawa", "region": "mazowieckie", "districts": [
+				{"name": "Ochota", "subdistricts": ["Włochy"]}, {"name": "Włochy"}]}]}`,
+			wantErr: true,
+		},
+		"fail, invalid json": {
+			file:    `{"regions": [`,
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := LoadDictionary(strings.NewReader(tc.file))
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("got error %v want error %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func Test_SetDistricts(t *testing.T) {
+	d, err := LoadDictionary(bytes.NewReader([]byte(`{"regions": ["lubelskie"], "cities": [
+		{"name": "Lublin", "region": "lubelskie", "districts": [{"name": "Czechów"}]}]}`)))
+	if err != nil {
+		t.Fatalf("failed to load dictionary: %v", err)
+	}
+
+	SetDistricts(d)
+	defer SetDistricts(nil)
+
+	f := models.Flat{Address: "Czechów, Lublin"}
+	Flat(&f, day(2026, 10, 17))
+
+	want := models.Location{City: "Lublin", District: "Czechów", Region: "lubelskie"}
+	if !reflect.DeepEqual(f.Location, want) {
+		t.Errorf("got %+v want %+v", f.Location, want)
+	}
+}
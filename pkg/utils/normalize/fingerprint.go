@@ -0,0 +1,54 @@
+package normalize
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+
+	"hestia/pkg/models"
+)
+
+// addressNoise are the words portals put into addresses inconsistently.
+var addressNoise = map[string]bool{
+	"ul": true, "ulica": true,
+	"al": true, "aleja": true, "aleje": true,
+	"os": true, "osiedle": true,
+	"pl": true, "plac": true,
+	"woj": true, "gm": true, "pow": true,
+}
+
+// diacritics folds Polish letters to ASCII, portals are not consistent about them either.
+var diacritics = strings.NewReplacer("ą", "a", "ć", "c", "ę", "e", "ł", "l", "ń", "n", "ó", "o", "ś", "s", "ź", "z", "ż", "z")
+
+// Address reduces an address to the sorted set of its words, in lowercase and
+// without diacritics or street type prefixes, so that "ul. Wrocławska 5, Kraków"
+// and "Kraków, Wrocławska 5" are equal.
+func Address(s string) string {
+	s = diacritics.Replace(strings.ToLower(s))
+	fields := strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	words := make([]string, 0, len(fields))
+	for _, w := range fields {
+		if addressNoise[w] {
+			continue
+		}
+		words = append(words, w)
+	}
+
+	slices.Sort(words)
+	return strings.Join(slices.Compact(words), " ")
+}
+
+// Fingerprint returns the key shared by f and the flats it may be a duplicate
+// of: its normalized address and room count. It is empty if either is unknown.
+func Fingerprint(f models.Flat) string {
+	addr := Address(f.Address)
+	if addr == "" || f.RoomCount == nil {
+		return ""
+	}
+
+	return fmt.Sprintf("%s|%d", addr, *f.RoomCount)
+}
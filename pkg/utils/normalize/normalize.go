@@ -0,0 +1,367 @@
+package normalize
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"hestia/pkg/models"
+)
+
+// DefaultCurrency is assumed when a price does not mention a currency.
+const DefaultCurrency = "PLN"
+
+var ErrUnparseable = errors.New("unparseable value")
+
+var (
+	numberRe = regexp.MustCompile(`\d[\d\s\x{00a0}\x{202f}.,]*`)
+	intRe    = regexp.MustCompile(`-?\d+`)
+	dateRe   = regexp.MustCompile(`(\d{1,2})[./-](\d{1,2})[./-](\d{4})`)
+	isoRe    = regexp.MustCompile(`(\d{4})-(\d{2})-(\d{2})`)
+	wordRe   = regexp.MustCompile(`(\d{1,2})\s+(\p{L}+)\s+(\d{4})`)
+)
+
+// currencies maps the currency markers used on listing pages to ISO 4217 codes.
+var currencies = []struct {
+	marker string
+	code   string
+}{
+	{"pln", "PLN"},
+	{"zł", "PLN"},
+	{"zl", "PLN"},
+	{"eur", "EUR"},
+	{"€", "EUR"},
+	{"usd", "USD"},
+	{"$", "USD"},
+	{"chf", "CHF"},
+	{"gbp", "GBP"},
+	{"£", "GBP"},
+}
+
+// immediately are the phrases meaning a flat is available right away.
+var immediately = []string{"zaraz", "natychmiast", "od ręki", "immediately"}
+
+// namedFloors are the names of floors that are not numbered.
+var namedFloors = map[string]int{
+	"parter":    0,
+	"suterena":  -1,
+	"piwnica":   -1,
+	"ground":    0,
+	"półpiętro": 0,
+}
+
+// months maps Polish month names, in nominative and genitive, to months.
+var months = map[string]time.Month{
+	"styczeń": time.January, "stycznia": time.January,
+	"luty": time.February, "lutego": time.February,
+	"marzec": time.March, "marca": time.March,
+	"kwiecień": time.April, "kwietnia": time.April,
+	"maj": time.May, "maja": time.May,
+	"czerwiec": time.June, "czerwca": time.June,
+	"lipiec": time.July, "lipca": time.July,
+	"sierpień": time.August, "sierpnia": time.August,
+	"wrzesień": time.September, "września": time.September,
+	"październik": time.October, "października": time.October,
+	"listopad": time.November, "listopada": time.November,
+	"grudzień": time.December, "grudnia": time.December,
+}
+
+// Flat fills the typed fields of f from its raw text fields. Values that
+// cannot be parsed are left nil and recorded in f.ParseWarnings instead of
+// failing, so that a listing can still be imported. now is used to resolve
+// relative dates such as "od zaraz". The address is split with the
+// dictionary returned by Districts.
+func Flat(f *models.Flat, now time.Time) {
+	var warnings []string
+
+	warn := func(field, raw string, err error) {
+		warnings = append(warnings, fmt.Sprintf("%s: %v: %q", field, err, raw))
+	}
+
+	f.PriceValue = nil
+	if f.Price != "" {
+		m, err := ParseMoney(f.Price)
+		if err != nil {
+			warn("price", f.Price, err)
+		} else {
+			f.PriceValue = &m
+		}
+	}
+
+	f.RentValue = nil
+	if f.Rent != "" {
+		m, err := ParseMoney(f.Rent)
+		if err != nil {
+			warn("rent", f.Rent, err)
+		} else {
+			f.RentValue = &m
+		}
+	}
+
+	f.DepositValue = nil
+	if f.Deposit != "" {
+		m, err := ParseMoney(f.Deposit)
+		if err != nil {
+			warn("deposit", f.Deposit, err)
+		} else {
+			f.DepositValue = &m
+		}
+	}
+
+	f.SurfaceM2 = nil
+	if f.Surface != "" {
+		a, err := ParseArea(f.Surface)
+		if err != nil {
+			warn("surface", f.Surface, err)
+		} else {
+			f.SurfaceM2 = &a
+		}
+	}
+
+	f.RoomCount = nil
+	if f.Rooms != "" {
+		r, err := ParseRooms(f.Rooms)
+		if err != nil {
+			warn("rooms", f.Rooms, err)
+		} else {
+			f.RoomCount = &r
+		}
+	}
+
+	f.FloorNumber, f.TotalFloors = nil, nil
+	if f.Floor != "" {
+		floor, total, err := ParseFloor(f.Floor)
+		if err != nil {
+			warn("floor", f.Floor, err)
+		} else {
+			f.FloorNumber, f.TotalFloors = floor, total
+		}
+	}
+
+	f.AvailableFromDate = nil
+	if f.AvailableFrom != "" {
+		d, err := ParseDate(f.AvailableFrom, now)
+		if err != nil {
+			warn("available_from", f.AvailableFrom, err)
+		} else {
+			f.AvailableFromDate = &d
+		}
+	}
+
+	f.ParseWarnings = warnings
+	f.Location = Districts().ParseAddress(f.Address)
+	f.Fingerprint = Fingerprint(*f)
+}
+
+// ParseMoney parses amounts such as "2 500 zł", "3 200,50 PLN" or "€800".
+// The currency defaults to DefaultCurrency if none is mentioned.
+func ParseMoney(s string) (models.Money, error) {
+	num := numberRe.FindString(s)
+	if num == "" {
+		return models.Money{}, ErrUnparseable
+	}
+
+	v, err := parseNumber(num, true)
+	if err != nil {
+		return models.Money{}, err
+	}
+
+	return models.Money{
+		Amount:   int64(math.Round(v * 100)),
+		Currency: currency(s),
+	}, nil
+}
+
+// ParseArea parses a surface such as "45,5 m²" or "62 m2" into square metres.
+func ParseArea(s string) (float64, error) {
+	num := numberRe.FindString(s)
+	if num == "" {
+		return 0, ErrUnparseable
+	}
+
+	return parseNumber(num, false)
+}
+
+// ParseRooms parses a room count such as "2", "3 pokoje", "4+" or "kawalerka".
+func ParseRooms(s string) (int, error) {
+	l := strings.ToLower(s)
+	if strings.Contains(l, "kawalerka") || strings.Contains(l, "studio") {
+		return 1, nil
+	}
+
+	n := intRe.FindString(l)
+	if n == "" {
+		return 0, ErrUnparseable
+	}
+
+	return strconv.Atoi(n)
+}
+
+// ParseFloor parses a floor such as "3", "3/5", "parter/4" or "2 z 6".
+// total is nil if the number of floors in the building is not mentioned.
+func ParseFloor(s string) (floor *int, total *int, err error) {
+	l := strings.ToLower(strings.TrimSpace(s))
+	l = strings.TrimPrefix(l, "floor_")
+
+	parts := strings.FieldsFunc(l, func(r rune) bool {
+		return r == '/'
+	})
+	if len(parts) == 1 {
+		if before, after, ok := strings.Cut(l, " z "); ok {
+			parts = []string{before, after}
+		}
+	}
+
+	if len(parts) == 0 || len(parts) > 2 {
+		return nil, nil, ErrUnparseable
+	}
+
+	f, err := parseFloorNumber(parts[0])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(parts) == 2 {
+		t, err := parseFloorNumber(parts[1])
+		if err != nil {
+			return nil, nil, err
+		}
+		total = &t
+	}
+
+	return &f, total, nil
+}
+
+func parseFloorNumber(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	for name, n := range namedFloors {
+		if strings.Contains(s, name) {
+			return n, nil
+		}
+	}
+
+	n := intRe.FindString(s)
+	if n == "" {
+		return 0, ErrUnparseable
+	}
+
+	return strconv.Atoi(n)
+}
+
+// ParseDate parses an availability date such as "od zaraz", "01.11.2026",
+// "2026-12-01" or "1 listopada 2026". "Immediately" resolves to the day of now.
+func ParseDate(s string, now time.Time) (time.Time, error) {
+	l := strings.ToLower(strings.TrimSpace(s))
+
+	for _, p := range immediately {
+		if strings.Contains(l, p) {
+			y, m, d := now.Date()
+			return time.Date(y, m, d, 0, 0, 0, 0, time.UTC), nil
+		}
+	}
+
+	if m := isoRe.FindStringSubmatch(l); m != nil {
+		return date(m[1], m[2], m[3])
+	}
+
+	if m := dateRe.FindStringSubmatch(l); m != nil {
+		return date(m[3], m[2], m[1])
+	}
+
+	if m := wordRe.FindStringSubmatch(l); m != nil {
+		month, ok := months[m[2]]
+		if !ok {
+			return time.Time{}, ErrUnparseable
+		}
+		return date(m[3], strconv.Itoa(int(month)), m[1])
+	}
+
+	return time.Time{}, ErrUnparseable
+}
+
+func date(year, month, day string) (time.Time, error) {
+	y, err := strconv.Atoi(year)
+	if err != nil {
+		return time.Time{}, ErrUnparseable
+	}
+	m, err := strconv.Atoi(month)
+	if err != nil {
+		return time.Time{}, ErrUnparseable
+	}
+	d, err := strconv.Atoi(day)
+	if err != nil {
+		return time.Time{}, ErrUnparseable
+	}
+
+	t := time.Date(y, time.Month(m), d, 0, 0, 0, 0, time.UTC)
+	if t.Year() != y || t.Month() != time.Month(m) || t.Day() != d {
+		return time.Time{}, ErrUnparseable
+	}
+
+	return t, nil
+}
+
+// parseNumber parses a number written with Polish or English separators.
+// Spaces are thousands separators and a comma is a decimal separator.
+// If money is true, a dot followed by exactly three digits is read as a
+// thousands separator, e.g. "1.200 zł", and so is a comma followed by exactly
+// three digits when there is no other separator, e.g. "1,200 zł".
+func parseNumber(s string, money bool) (float64, error) {
+	spaced := false
+	s = strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			spaced = true
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(s))
+	s = strings.TrimRight(s, ".,")
+
+	if strings.Contains(s, ",") && strings.Contains(s, ".") {
+		// The separator that comes last is the decimal one.
+		if strings.LastIndex(s, ",") > strings.LastIndex(s, ".") {
+			s = strings.ReplaceAll(s, ".", "")
+		} else {
+			s = strings.ReplaceAll(s, ",", "")
+		}
+	}
+
+	if money && strings.Count(s, ".") >= 1 && !strings.Contains(s, ",") {
+		i := strings.LastIndex(s, ".")
+		if len(s)-i-1 == 3 {
+			s = strings.ReplaceAll(s, ".", "")
+		}
+	}
+
+	if money && !spaced && strings.Count(s, ",") >= 1 && !strings.Contains(s, ".") {
+		i := strings.LastIndex(s, ",")
+		if len(s)-i-1 == 3 {
+			s = strings.ReplaceAll(s, ",", "")
+		}
+	}
+
+	s = strings.ReplaceAll(s, ",", ".")
+
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, ErrUnparseable
+	}
+
+	return v, nil
+}
+
+func currency(s string) string {
+	l := strings.ToLower(s)
+	for _, c := range currencies {
+		if strings.Contains(l, c.marker) {
+			return c.code
+		}
+	}
+
+	return DefaultCurrency
+}
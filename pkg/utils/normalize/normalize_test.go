@@ -0,0 +1,273 @@
+package normalize
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"hestia/pkg/models"
+)
+
+func Test_ParseMoney(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		want    models.Money
+		wantErr error
+	}{
+		"ok, spaces and zł":       {in: "2 500 zł", want: models.Money{Amount: 250000, Currency: "PLN"}},
+		"ok, non-breaking space":  {in: "3 200 zł", want: models.Money{Amount: 320000, Currency: "PLN"}},
+		"ok, decimal comma":       {in: "3 200,50 PLN", want: models.Money{Amount: 320050, Currency: "PLN"}},
+		"ok, dot thousands":       {in: "1.200 zł", want: models.Money{Amount: 120000, Currency: "PLN"}},
+		"ok, comma thousands":     {in: "1,200", want: models.Money{Amount: 120000, Currency: "PLN"}},
+		"ok, comma millions":      {in: "1,200,000 zł", want: models.Money{Amount: 120000000, Currency: "PLN"}},
+		"ok, space thousands":     {in: "1 200,50", want: models.Money{Amount: 120050, Currency: "PLN"}},
+		"ok, comma two decimals":  {in: "1200,50 zł", want: models.Money{Amount: 120050, Currency: "PLN"}},
+		"ok, english separators":  {in: "1,200.99 EUR", want: models.Money{Amount: 120099, Currency: "EUR"}},
+		"ok, euro sign":           {in: "€800", want: models.Money{Amount: 80000, Currency: "EUR"}},
+		"ok, default currency":    {in: "2600", want: models.Money{Amount: 260000, Currency: "PLN"}},
+		"ok, suffix":              {in: "650 zł/mc", want: models.Money{Amount: 65000, Currency: "PLN"}},
+		"fail, no amount":         {in: "Zapytaj o cenę", wantErr: ErrUnparseable},
+		"fail, only currency tag": {in: "zł", wantErr: ErrUnparseable},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseMoney(tc.in)
+			if tc.wantErr != nil {
+				if !errors.Is(err, tc.wantErr) {
+					t.Fatalf("expected errors to be %v got %v (via errors.Is)", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to parse money: %v", err)
+			}
+
+			if got != tc.want {
+				t.Errorf("got %#v want %#v", got, tc.want)
+			}
+		})
+	}
+}
+
+func Test_ParseArea(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		want    float64
+		wantErr error
+	}{
+		"ok, decimal comma": {in: "45,5 m²", want: 45.5},
+		"ok, m2":            {in: "62 m2", want: 62},
+		"ok, decimal dot":   {in: "47.5 m²", want: 47.5},
+		"ok, no unit":       {in: "48", want: 48},
+		"fail, no number":   {in: "duże", wantErr: ErrUnparseable},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseArea(tc.in)
+			if tc.wantErr != nil {
+				if !errors.Is(err, tc.wantErr) {
+					t.Fatalf("expected errors to be %v got %v (via errors.Is)", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to parse area: %v", err)
+			}
+
+			if got != tc.want {
+				t.Errorf("got %v want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func Test_ParseRooms(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		want    int
+		wantErr error
+	}{
+		"ok, number":      {in: "2", want: 2},
+		"ok, with word":   {in: "3 pokoje", want: 3},
+		"ok, plus":        {in: "4+", want: 4},
+		"ok, kawalerka":   {in: "Kawalerka", want: 1},
+		"fail, no number": {in: "dużo", wantErr: ErrUnparseable},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseRooms(tc.in)
+			if tc.wantErr != nil {
+				if !errors.Is(err, tc.wantErr) {
+					t.Fatalf("expected errors to be %v got %v (via errors.Is)", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to parse rooms: %v", err)
+			}
+
+			if got != tc.want {
+				t.Errorf("got %v want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func Test_ParseFloor(t *testing.T) {
+	tests := map[string]struct {
+		in        string
+		wantFloor int
+		wantTotal *int
+		wantErr   error
+	}{
+		"ok, number":             {in: "3", wantFloor: 3},
+		"ok, with total":         {in: "3/5", wantFloor: 3, wantTotal: ptr(5)},
+		"ok, parter with total":  {in: "parter/4", wantFloor: 0, wantTotal: ptr(4)},
+		"ok, parter":             {in: "Parter", wantFloor: 0},
+		"ok, suterena":           {in: "suterena", wantFloor: -1},
+		"ok, polish separator":   {in: "2 z 6", wantFloor: 2, wantTotal: ptr(6)},
+		"ok, next data raw":      {in: "floor_2", wantFloor: 2},
+		"fail, named floor":      {in: "poddasze", wantErr: ErrUnparseable},
+		"fail, too many parts":   {in: "1/2/3", wantErr: ErrUnparseable},
+		"fail, unparsable total": {in: "3/wysoki", wantErr: ErrUnparseable},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			floor, total, err := ParseFloor(tc.in)
+			if tc.wantErr != nil {
+				if !errors.Is(err, tc.wantErr) {
+					t.Fatalf("expected errors to be %v got %v (via errors.Is)", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to parse floor: %v", err)
+			}
+
+			if *floor != tc.wantFloor {
+				t.Errorf("got floor %v want %v", *floor, tc.wantFloor)
+			}
+
+			if !reflect.DeepEqual(total, tc.wantTotal) {
+				t.Errorf("got total %v want %v", total, tc.wantTotal)
+			}
+		})
+	}
+}
+
+func Test_ParseDate(t *testing.T) {
+	now := time.Date(2026, 10, 17, 15, 4, 5, 0, time.UTC)
+
+	tests := map[string]struct {
+		in      string
+		want    time.Time
+		wantErr error
+	}{
+		"ok, od zaraz":     {in: "od zaraz", want: day(2026, 10, 17)},
+		"ok, dotted":       {in: "01.11.2026", want: day(2026, 11, 1)},
+		"ok, iso":          {in: "2026-12-01", want: day(2026, 12, 1)},
+		"ok, iso datetime": {in: "2026-12-01T00:00:00Z", want: day(2026, 12, 1)},
+		"ok, month name":   {in: "1 listopada 2026", want: day(2026, 11, 1)},
+		"fail, invalid":    {in: "31.02.2026", wantErr: ErrUnparseable},
+		"fail, unknown":    {in: "do uzgodnienia", wantErr: ErrUnparseable},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseDate(tc.in, now)
+			if tc.wantErr != nil {
+				if !errors.Is(err, tc.wantErr) {
+					t.Fatalf("expected errors to be %v got %v (via errors.Is)", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to parse date: %v", err)
+			}
+
+			if !got.Equal(tc.want) {
+				t.Errorf("got %v want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func Test_Flat(t *testing.T) {
+	now := time.Date(2026, 10, 17, 15, 4, 5, 0, time.UTC)
+
+	f := models.Flat{
+		Price:         "2 500 zł",
+		Surface:       "45,5 m²",
+		Rooms:         "2",
+		Floor:         "parter/4",
+		AvailableFrom: "od zaraz",
+		Rent:          "Zapytaj",
+		Deposit:       "",
+	}
+
+	Flat(&f, now)
+
+	want := models.Flat{
+		Price:             f.Price,
+		Surface:           f.Surface,
+		Rooms:             f.Rooms,
+		Floor:             f.Floor,
+		AvailableFrom:     f.AvailableFrom,
+		Rent:              f.Rent,
+		PriceValue:        &models.Money{Amount: 250000, Currency: "PLN"},
+		SurfaceM2:         ptr(45.5),
+		RoomCount:         ptr(2),
+		FloorNumber:       ptr(0),
+		TotalFloors:       ptr(4),
+		AvailableFromDate: ptr(day(2026, 10, 17)),
+		ParseWarnings:     []string{`rent: unparseable value: "Zapytaj"`},
+	}
+
+	if !reflect.DeepEqual(f, want) {
+		t.Errorf("got\n%#v\nwant\n%#v\n", f, want)
+	}
+}
+
+func day(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func Test_Fingerprint(t *testing.T) {
+	tests := map[string]struct {
+		flat models.Flat
+		want string
+	}{
+		"ok, street prefix and order": {
+			flat: models.Flat{Address: "ul. Wrocławska 5, Kraków", RoomCount: ptr(2)},
+			want: "5 krakow wroclawska|2",
+		},
+		"ok, repeated words": {
+			flat: models.Flat{Address: "Kraków, Kraków-Krowodrza, al. Słowackiego", RoomCount: ptr(3)},
+			want: "krakow krowodrza slowackiego|3",
+		},
+		"ok, unknown rooms": {
+			flat: models.Flat{Address: "Kraków, Wrocławska 5"},
+		},
+		"ok, no address": {
+			flat: models.Flat{Address: " , ul. ", RoomCount: ptr(2)},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := Fingerprint(tc.flat)
+			if got != tc.want {
+				t.Errorf("got %q want %q", got, tc.want)
+			}
+		})
+	}
+}
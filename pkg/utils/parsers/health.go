@@ -0,0 +1,225 @@
+package parsers
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"hestia/pkg/models"
+)
+
+// ErrIncompleteListing is returned when a required field could not be
+// extracted from a listing, usually because the portal changed its markup.
+var ErrIncompleteListing = errors.New("listing is missing required fields")
+
+// HealthConfig is the configuration for monitoring the parsers.
+type HealthConfig struct {
+	// Required are the fields a listing must have to be saved.
+	Required []Field
+	// Window is how far back listings count towards the health of a portal.
+	Window time.Duration
+	// MinSamples is the number of listings within the window needed before
+	// an alert is raised.
+	MinSamples int
+	// AlertThreshold is the share of incomplete listings from 0 to 1 at which
+	// an alert is raised. Zero disables alerts.
+	AlertThreshold float64
+}
+
+// AlertFunc is called when a portal starts or stops alerting.
+type AlertFunc func(models.ParserHealth)
+
+// Monitor keeps track of the fields extracted from the listings of each
+// portal over a rolling window and raises an alert when too many listings
+// of a portal miss required fields.
+type Monitor struct {
+	cfg     HealthConfig
+	alert   AlertFunc
+	mu      sync.Mutex
+	portals map[string]*portalStats
+
+	// NowFunc is used to get the current time.
+	// Exposed for testing purposes.
+	NowFunc func() time.Time
+}
+
+// portalStats are the listings of a portal within the window, oldest first.
+type portalStats struct {
+	samples  []sample
+	alerting bool
+}
+
+type sample struct {
+	at time.Time
+	// filled has bit i set if Fields[i] was extracted.
+	filled     uint32
+	incomplete bool
+}
+
+// NewMonitor creates a Monitor that calls alert when a portal starts or stops alerting.
+func NewMonitor(cfg HealthConfig, alert AlertFunc) *Monitor {
+	return &Monitor{
+		cfg:     cfg,
+		alert:   alert,
+		portals: make(map[string]*portalStats),
+
+		NowFunc: time.Now,
+	}
+}
+
+// Missing returns the required fields res has no value for.
+func (m *Monitor) Missing(res Result) []Field {
+	var missing []Field
+	for _, f := range m.cfg.Required {
+		p := f.Ptr(&res.Flat)
+		if p == nil || strings.TrimSpace(*p) == "" {
+			missing = append(missing, f)
+		}
+	}
+
+	return missing
+}
+
+// Record adds the listing parsed into res to the health of portal and
+// returns the required fields it misses.
+func (m *Monitor) Record(portal string, res Result) []Field {
+	missing := m.Missing(res)
+
+	s := sample{at: m.NowFunc(), incomplete: len(missing) > 0}
+	for i, f := range Fields {
+		if p := f.Ptr(&res.Flat); p != nil && strings.TrimSpace(*p) != "" {
+			s.filled |= 1 << i
+		}
+	}
+
+	m.mu.Lock()
+	st, ok := m.portals[portal]
+	if !ok {
+		st = &portalStats{}
+		m.portals[portal] = st
+	}
+	st.samples = append(st.samples, s)
+	m.prune(st, s.at)
+
+	h := m.health(portal, st)
+	changed := false
+	switch {
+	case !st.alerting && m.cfg.AlertThreshold > 0 && h.Samples >= m.cfg.MinSamples && h.IncompleteRate >= m.cfg.AlertThreshold:
+		st.alerting, changed = true, true
+	case st.alerting && h.IncompleteRate < m.cfg.AlertThreshold:
+		st.alerting, changed = false, true
+	}
+	h.Alerting = st.alerting
+	m.mu.Unlock()
+
+	if changed && m.alert != nil {
+		m.alert(h)
+	}
+
+	return missing
+}
+
+// Report returns the health of every portal seen within the window, by portal.
+func (m *Monitor) Report() []models.ParserHealth {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.NowFunc()
+	out := make([]models.ParserHealth, 0, len(m.portals))
+	for portal, st := range m.portals {
+		m.prune(st, now)
+		if len(st.samples) == 0 && !st.alerting {
+			delete(m.portals, portal)
+			continue
+		}
+
+		out = append(out, m.health(portal, st))
+	}
+
+	slices.SortFunc(out, func(a, b models.ParserHealth) int {
+		return strings.Compare(a.Portal, b.Portal)
+	})
+
+	return out
+}
+
+// prune drops the samples of st that fell out of the window. m.mu must be held.
+func (m *Monitor) prune(st *portalStats, now time.Time) {
+	if m.cfg.Window <= 0 {
+		return
+	}
+
+	cutoff := now.Add(-m.cfg.Window)
+	i := 0
+	for i < len(st.samples) && !st.samples[i].at.After(cutoff) {
+		i++
+	}
+	st.samples = st.samples[i:]
+}
+
+// health summarizes the samples of st. m.mu must be held.
+func (m *Monitor) health(portal string, st *portalStats) models.ParserHealth {
+	h := models.ParserHealth{
+		Portal:   portal,
+		Samples:  len(st.samples),
+		Alerting: st.alerting,
+		Fields:   make([]models.FieldHealth, len(Fields)),
+	}
+
+	for i, f := range Fields {
+		h.Fields[i] = models.FieldHealth{Field: string(f), Required: slices.Contains(m.cfg.Required, f)}
+	}
+
+	for _, s := range st.samples {
+		if s.incomplete {
+			h.Incomplete++
+		}
+		for i := range Fields {
+			if s.filled&(1<<i) != 0 {
+				h.Fields[i].Filled++
+			}
+		}
+	}
+
+	if h.Samples > 0 {
+		h.IncompleteRate = float64(h.Incomplete) / float64(h.Samples)
+		h.LastSeen = st.samples[len(st.samples)-1].at
+		for i := range h.Fields {
+			h.Fields[i].FillRate = float64(h.Fields[i].Filled) / float64(h.Samples)
+		}
+	}
+
+	return h
+}
+
+// incompleteError returns the error for the listing at url missing the fields.
+func incompleteError(url string, missing []Field) error {
+	names := make([]string, len(missing))
+	for i, f := range missing {
+		names[i] = string(f)
+	}
+
+	return fmt.Errorf("%w: %s: %s", ErrIncompleteListing, strings.Join(names, ", "), url)
+}
+
+// ParseFields parses the comma separated field names in s.
+func ParseFields(s string) ([]Field, error) {
+	var out []Field
+	for _, name := range strings.Split(s, ",") {
+		f := Field(strings.ToLower(strings.TrimSpace(name)))
+		if f == "" {
+			continue
+		}
+		if !slices.Contains(Fields, f) {
+			return nil, fmt.Errorf("unknown field %q", f)
+		}
+		if !slices.Contains(out, f) {
+			out = append(out, f)
+		}
+	}
+
+	return out, nil
+}
@@ -0,0 +1,158 @@
+package parsers
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gocolly/colly/v2"
+
+	"hestia/pkg/models"
+)
+
+func Test_Monitor_Record(t *testing.T) {
+	complete := Result{Flat: models.Flat{Title: "Flat", Price: "3000", Address: "Warszawa"}}
+	incomplete := Result{Flat: models.Flat{Title: "Flat"}}
+
+	type step struct {
+		res Result
+		// after is the time since the start the listing is recorded at.
+		after time.Duration
+	}
+
+	tests := map[string]struct {
+		steps      []step
+		wantAlerts []bool
+		wantRate   float64
+	}{
+		"ok, alert raised once": {
+			steps: []step{
+				{res: complete}, {res: incomplete}, {res: incomplete}, {res: incomplete}, {res: incomplete},
+			},
+			wantAlerts: []bool{true},
+			wantRate:   0.8,
+		},
+		"ok, too few samples": {
+			steps:    []step{{res: incomplete}, {res: incomplete}},
+			wantRate: 1,
+		},
+		"ok, recovered": {
+			steps: []step{
+				{res: incomplete}, {res: incomplete}, {res: incomplete},
+				{res: complete}, {res: complete}, {res: complete}, {res: complete},
+			},
+			wantAlerts: []bool{true, false},
+			wantRate:   3.0 / 7,
+		},
+		"ok, old samples dropped": {
+			steps: []step{
+				{res: incomplete}, {res: incomplete},
+				{res: incomplete, after: 2 * time.Hour}, {res: complete, after: 2 * time.Hour},
+			},
+			wantRate: 0.5,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var alerts []bool
+			m := NewMonitor(HealthConfig{
+				Required:       []Field{FieldTitle, FieldPrice, FieldAddress},
+				Window:         time.Hour,
+				MinSamples:     3,
+				AlertThreshold: 0.5,
+			}, func(h models.ParserHealth) {
+				alerts = append(alerts, h.Alerting)
+			})
+
+			start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+			for _, s := range tc.steps {
+				m.NowFunc = func() time.Time { return start.Add(s.after) }
+
+				missing := m.Record("otodom", s.res)
+				if wantMissing := s.res.Flat.Price == ""; (len(missing) > 0) != wantMissing {
+					t.Errorf("got missing %v want missing %v", missing, wantMissing)
+				}
+			}
+
+			if !reflect.DeepEqual(alerts, tc.wantAlerts) {
+				t.Errorf("got alerts %v want %v", alerts, tc.wantAlerts)
+			}
+
+			report := m.Report()
+			if len(report) != 1 {
+				t.Fatalf("got %d portals want 1", len(report))
+			}
+			if report[0].IncompleteRate != tc.wantRate {
+				t.Errorf("got incomplete rate %v want %v", report[0].IncompleteRate, tc.wantRate)
+			}
+			if title := report[0].Fields[0]; title.Field != string(FieldTitle) || title.FillRate != 1 || !title.Required {
+				t.Errorf("got title health %+v want required and always filled", title)
+			}
+		})
+	}
+}
+
+func Test_Collector_Parse_Incomplete(t *testing.T) {
+	tests := map[string]struct {
+		parser  Parser
+		wantErr error
+	}{
+		"ok, required fields present": {parser: OLX()},
+		// The selectors of another portal match nothing, like after a markup change.
+		"fail, required fields empty": {parser: Gratka(), wantErr: ErrIncompleteListing},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			srv := fixtureServer(t)
+
+			registry := NewRegistry()
+			registry.Register(tc.parser, hostOf(t, srv.URL))
+
+			c := NewCollector(colly.NewCollector(), registry, func(err error) {})
+			m := NewMonitor(HealthConfig{Required: []Field{FieldTitle, FieldPrice, FieldAddress}, Window: time.Hour}, nil)
+			c.WithMonitor(m)
+
+			res, err := c.Parse(context.Background(), srv.URL+"/olx.html")
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("expected errors to be %v got %v (via errors.Is)", tc.wantErr, err)
+			}
+			if res.Page == nil {
+				t.Errorf("expected the page to be kept")
+			}
+
+			health := c.Health()
+			if len(health) != 1 || health[0].Samples != 1 {
+				t.Errorf("got health %+v want one sample", health)
+			}
+		})
+	}
+}
+
+func Test_ParseFields(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		want    []Field
+		wantErr bool
+	}{
+		"ok, fields":    {in: "title, Price,address,title", want: []Field{FieldTitle, FieldPrice, FieldAddress}},
+		"ok, empty":     {in: ""},
+		"fail, unknown": {in: "title,colour", wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseFields(tc.in)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("got error %v want error %v", err, tc.wantErr)
+			}
+
+			if !tc.wantErr && !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %v want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package parsers
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/gocolly/colly/v2"
+
+	"hestia/pkg/custerrors"
+)
+
+// Page is a listing page as it was fetched.
+type Page struct {
+	// URL is the URL of the listing, FinalURL the one the page was served
+	// from after redirects.
+	URL         string
+	FinalURL    string
+	StatusCode  int
+	ContentType string
+	// Body is the page, converted to UTF-8.
+	Body      []byte
+	FetchedAt time.Time
+}
+
+// ParsePage extracts the flat from a page fetched before, the same way
+// Parse does for a page it fetches. It errors with ErrListingGone if the
+// page shows that the listing was taken down and with ErrIncompleteListing
+// if the monitor requires a field that is empty. The fields are not recorded
+// in the monitor, the page was counted when it was fetched.
+func (c *Collector) ParsePage(page Page) (Result, error) {
+	res, err := c.parsePage(page)
+	if err != nil {
+		return res, err
+	}
+
+	if c.monitor != nil {
+		if missing := c.monitor.Missing(res); len(missing) > 0 {
+			return res, incompleteError(page.URL, missing)
+		}
+	}
+
+	return res, nil
+}
+
+// ParsePageWith extracts the flat from page like ParsePage, but with p
+// instead of the parser registered for the portal, e.g. to try out new
+// rules before they are used. p must be written for the same portal, nil
+// uses the registered parser. Required fields are not enforced.
+func (c *Collector) ParsePageWith(page Page, p Parser) (Result, error) {
+	registered, err := c.registry.Lookup(page.URL)
+	if err != nil {
+		return newResult(), err
+	}
+
+	if p == nil {
+		return parsePage(page, registered)
+	}
+
+	if p.Portal() != registered.Portal() {
+		return newResult(), fmt.Errorf("page %s belongs to %s, not %s: %w", page.URL, registered.Portal(), p.Portal(), custerrors.ErrInvalidInput)
+	}
+
+	if sp, ok := registered.(*searchParser); ok {
+		p = WithSearch(p, sp.rules)
+	}
+
+	return parsePage(page, p)
+}
+
+// parsePage is ParsePage without the monitor.
+func (c *Collector) parsePage(page Page) (Result, error) {
+	p, err := c.registry.Lookup(page.URL)
+	if err != nil {
+		return newResult(), err
+	}
+
+	return parsePage(page, p)
+}
+
+// parsePage extracts the flat from page with p.
+func parsePage(page Page, p Parser) (Result, error) {
+	res := newResult()
+
+	final := page.FinalURL
+	if final == "" {
+		final = page.URL
+	}
+	u, err := url.Parse(final)
+	if err != nil {
+		return res, fmt.Errorf("invalid page url %s: %w", final, custerrors.ErrInvalidInput)
+	}
+
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(page.Body))
+	if err != nil {
+		return res, fmt.Errorf("failed to read page %s: %w", page.URL, err)
+	}
+
+	root := doc.Find("html")
+	if root.Length() == 0 {
+		return res, fmt.Errorf("page %s has no html element: %w", page.URL, custerrors.ErrInvalidInput)
+	}
+
+	headers := http.Header{"Content-Type": {page.ContentType}}
+	resp := &colly.Response{
+		StatusCode: page.StatusCode,
+		Body:       page.Body,
+		Headers:    &headers,
+		Request:    &colly.Request{URL: u},
+	}
+
+	res, gone := parseElement(p, page.URL, colly.NewHTMLElementFromSelectionNode(resp, root.First(), root.Nodes[0], 0))
+	res.Portal = p.Portal()
+	res.Page = &page
+	if gone {
+		return res, fmt.Errorf("%w: %s", ErrListingGone, page.URL)
+	}
+
+	return res, nil
+}
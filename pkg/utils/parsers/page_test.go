@@ -0,0 +1,109 @@
+package parsers
+
+import (
+	"context"
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/gocolly/colly/v2"
+
+	"hestia/pkg/custerrors"
+)
+
+func Test_Collector_ParsePage(t *testing.T) {
+	tests := map[string]struct {
+		parser  Parser
+		fixture string
+		// finalPath is where the page was served from, the fixture itself if empty.
+		finalPath string
+		wantErr   error
+	}{
+		"ok, css":                  {parser: OLX(), fixture: "olx.html"},
+		"ok, next data":            {parser: Otodom(), fixture: "otodom_next.html"},
+		"ok, json-ld":              {parser: Morizon(), fixture: "jsonld.html"},
+		"fail, redirect to search": {parser: WithSearch(OLX(), OLXSearch()), fixture: "olx_search_2.html", finalPath: "/olx_search_2.html", wantErr: ErrListingGone},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			srv := fixtureServer(t)
+
+			registry := NewRegistry()
+			registry.Register(tc.parser, hostOf(t, srv.URL))
+
+			c := NewCollector(colly.NewCollector(), registry, func(err error) {})
+
+			body, err := os.ReadFile("testdata/" + tc.fixture)
+			if err != nil {
+				t.Fatalf("failed to read fixture: %v", err)
+			}
+
+			page := Page{
+				URL:         srv.URL + "/" + tc.fixture,
+				StatusCode:  200,
+				ContentType: "text/html; charset=utf-8",
+				Body:        body,
+			}
+			if tc.finalPath != "" {
+				page.URL = srv.URL + "/listing.html"
+				page.FinalURL = srv.URL + tc.finalPath
+			}
+
+			got, err := c.ParsePage(page)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("expected errors to be %v got %v (via errors.Is)", tc.wantErr, err)
+			}
+			if tc.wantErr != nil {
+				return
+			}
+
+			// The archived page parses exactly like the page fetched live.
+			want, err := c.Parse(context.Background(), page.URL)
+			if err != nil {
+				t.Fatalf("failed to parse: %v", err)
+			}
+
+			if !reflect.DeepEqual(got.Flat, want.Flat) {
+				t.Errorf("got\n%#v\nwant\n%#v\n", got.Flat, want.Flat)
+			}
+			if !reflect.DeepEqual(got.Photos, want.Photos) {
+				t.Errorf("got photos %v want %v", got.Photos, want.Photos)
+			}
+			if want.Page == nil || !reflect.DeepEqual(want.Page.Body, body) {
+				t.Errorf("expected the fetched page to be kept")
+			}
+		})
+	}
+}
+
+func Test_Collector_ParsePage_Invalid(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register(OLX(), "olx.example")
+
+	c := NewCollector(colly.NewCollector(), registry, func(err error) {})
+
+	tests := map[string]struct {
+		page    Page
+		wantErr error
+	}{
+		"fail, unsupported portal": {
+			page:    Page{URL: "https://unknown.example/listing", Body: []byte("<html></html>")},
+			wantErr: custerrors.ErrInvalidInput,
+		},
+		"fail, invalid final url": {
+			page:    Page{URL: "https://olx.example/listing", FinalURL: "://", Body: []byte("<html></html>")},
+			wantErr: custerrors.ErrInvalidInput,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := c.ParsePage(tc.page)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("expected errors to be %v got %v (via errors.Is)", tc.wantErr, err)
+			}
+		})
+	}
+}
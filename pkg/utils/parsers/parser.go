@@ -1,51 +1,226 @@
 package parsers
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
 	"github.com/gocolly/colly/v2"
+
 	"hestia/pkg/models"
 )
 
+// ErrListingGone is returned when a listing was removed from its portal.
+var ErrListingGone = errors.New("listing no longer available")
+
 // ErrFunc is a function that handles errors.
 type ErrFunc func(error)
 
 type Collector struct {
 	*colly.Collector
+	registry   *Registry
 	errHandler ErrFunc
+	transport  *callTransport
+	monitor    *Monitor
+	calls      atomic.Uint64
+
+	// RequestTimeout limits each request, including reading the body.
+	// Zero means no limit besides the context of the call.
+	RequestTimeout time.Duration
 }
 
-func NewCollector(collector *colly.Collector, errHandler ErrFunc) *Collector {
+// NewCollector creates a Collector that is safe for concurrent use. It takes
+// over the HTTP transport and timeout of collector, which must not be changed afterwards.
+func NewCollector(collector *colly.Collector, registry *Registry, errHandler ErrFunc) *Collector {
+	transport := &callTransport{base: http.DefaultTransport}
+	collector.WithTransport(transport)
+	// RequestTimeout replaces the client timeout, which cannot differ per call.
+	collector.SetRequestTimeout(0)
+
 	return &Collector{
 		Collector:  collector,
+		registry:   registry,
 		errHandler: errHandler,
+		transport:  transport,
+
+		RequestTimeout: DefaultRequestTimeout,
 	}
 }
 
-func (c *Collector) Parse(url string) (models.Flat, error) {
-	flat := models.Flat{}
+// WithTransport sets the transport requests are sent with. It must be
+// called before the collector is used.
+func (c *Collector) WithTransport(rt http.RoundTripper) {
+	c.transport.base = rt
+}
+
+// WithMonitor makes Parse record the fields it extracts in m and reject
+// listings that miss required fields. It must be called before the
+// collector is used.
+func (c *Collector) WithMonitor(m *Monitor) {
+	c.monitor = m
+}
 
-	// Set up callbacks to handle scraping events
-	c.OnHTML(".css-y6l269.er0e7w63", func(e *colly.HTMLElement) {
-		// Extract data from HTML elements
-		flat.Title = e.ChildText(".css-1wnihf5.efcnut38")
-		flat.Price = e.ChildText(".css-t3wmkv.e1l1avn10")
-		flat.Address = e.ChildText(".e1w8sadu0.css-1helwne.exgq9l20")
-
-		flat.Surface = e.ChildText(".css-1wi2w6s.enb64yk5")
-		flat.Rooms = e.ChildText(".css-19yhkv9.enb64yk08")
-		flat.Floor = e.ChildText(".css-1wi2w6s.enb64yk5")
-		flat.AvailableFrom = e.ChildText(".css-x0kl3j.e1k3ukdh0")
-		flat.Rent = e.ChildText(".css-1wi2w6s.enb64yk5")
-		flat.Deposit = e.ChildText(".css-1wi2w6s.enb64yk5")
-		flat.Description = e.ChildText(".css-1ugtzj2.e175i4j93")
+// Health returns the health of the parser of each portal, empty if the
+// collector has no monitor.
+func (c *Collector) Health() []models.ParserHealth {
+	if c.monitor == nil {
+		return make([]models.ParserHealth, 0)
+	}
+
+	return c.monitor.Report()
+}
+
+// Parse visits url and extracts the flat from the page. Structured data
+// embedded in the page is preferred, the portal's CSS selectors only fill
+// the fields that are still empty. It errors with ErrListingGone if the
+// portal took the listing down, with ErrBodyTooLarge if the page is larger
+// than MaxBodySize, with ErrRobotsDisallowed if robots.txt of the portal
+// forbids it, with ErrIncompleteListing if the monitor requires a field
+// that is empty and with the error of ctx if it is done first.
+func (c *Collector) Parse(ctx context.Context, url string) (Result, error) {
+	res, p, err := c.parse(ctx, url)
+	if err != nil {
+		return res, err
+	}
+
+	if c.monitor != nil {
+		if missing := c.monitor.Record(p.Portal(), res); len(missing) > 0 {
+			return res, incompleteError(url, missing)
+		}
+	}
+
+	return res, nil
+}
+
+// parse is Parse without the monitor. It also returns the parser used.
+func (c *Collector) parse(ctx context.Context, url string) (Result, Parser, error) {
+	res := newResult()
+
+	// Pick the parser for the portal the URL belongs to.
+	p, err := c.registry.Lookup(url)
+	if err != nil {
+		c.errHandler(err)
+		return res, nil, err
+	}
+
+	cc, done := c.session(ctx)
+	defer done()
+
+	var (
+		gone bool
+		page *Page
+	)
+	cc.OnError(func(r *colly.Response, _ error) {
+		gone = r.StatusCode == http.StatusNotFound || r.StatusCode == http.StatusGone
+	})
 
+	// Keep the page for the archive, also if it turns out not to be a listing.
+	cc.OnResponse(func(r *colly.Response) {
+		page = &Page{
+			URL:         url,
+			FinalURL:    r.Request.URL.String(),
+			StatusCode:  r.StatusCode,
+			ContentType: r.Headers.Get("Content-Type"),
+			Body:        r.Body,
+			FetchedAt:   time.Now(),
+		}
+	})
+
+	// Set up callbacks to handle scraping events
+	cc.OnHTML("html", func(e *colly.HTMLElement) {
+		res, gone = parseElement(p, url, e)
 	})
 
 	// Visit the URL and start scraping
-	err := c.Visit(url)
+	err = cc.visit(url)
+	res.Portal = p.Portal()
+	res.Page = page
+	if gone {
+		return res, p, fmt.Errorf("%w: %s", ErrListingGone, url)
+	}
 	if err != nil {
 		c.errHandler(err)
-		return flat, err
+		return res, p, err
+	}
+
+	return res, p, nil
+}
+
+// parseElement extracts the flat from the page root e of the listing at
+// url. It reports whether the portal showed something else because the
+// listing is gone.
+func parseElement(p Parser, url string, e *colly.HTMLElement) (Result, bool) {
+	if redirectedToSearch(p, url, e) {
+		return newResult(), true
+	}
+
+	return extract(p, e), false
+}
+
+// redirectedToSearch reports whether the request for the listing at url was
+// redirected to a search results page or the home page of the portal, which
+// is what portals do with withdrawn listings.
+func redirectedToSearch(p Parser, url string, e *colly.HTMLElement) bool {
+	if CanonicalURL(e.Request.URL.String()) == CanonicalURL(url) {
+		return false
+	}
+
+	if strings.Trim(e.Request.URL.Path, "/") == "" {
+		return true
+	}
+
+	sp, ok := p.(SearchParser)
+	return ok && len(sp.ParseSearch(e).Listings) > 0
+}
+
+// Override replaces the parser of portal, see Registry.Override. It takes
+// effect for the pages parsed from then on.
+func (c *Collector) Override(portal string, p Parser) {
+	c.registry.Override(portal, p)
+}
+
+// Portals returns the names of the portals the collector has parsers for.
+func (c *Collector) Portals() []string {
+	return c.registry.Portals()
+}
+
+// Supports reports whether Parse can handle url. The error wraps
+// custerrors.ErrInvalidInput if the URL is invalid or the portal is unknown.
+func (c *Collector) Supports(url string) error {
+	_, err := c.registry.Lookup(url)
+	return err
+}
+
+// extract runs all extraction strategies against the page root e.
+func extract(p Parser, e *colly.HTMLElement) Result {
+	res := parseStructured(e.DOM)
+	res.merge(p.Parse(e))
+	res.Photos = photoURLs(e, res.Photos)
+
+	return res
+}
+
+// photoURLs resolves the photo URLs against the page and drops duplicates
+// and URLs that cannot be downloaded, such as inline data URIs.
+func photoURLs(e *colly.HTMLElement, photos []string) []string {
+	var out []string
+	seen := make(map[string]bool, len(photos))
+	for _, p := range photos {
+		u := e.Request.AbsoluteURL(p)
+		if !strings.HasPrefix(u, "http://") && !strings.HasPrefix(u, "https://") {
+			continue
+		}
+		if seen[u] {
+			continue
+		}
+
+		seen[u] = true
+		out = append(out, u)
 	}
 
-	return flat, nil
+	return out
 }
@@ -0,0 +1,426 @@
+package parsers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gocolly/colly/v2"
+
+	"hestia/pkg/custerrors"
+	"hestia/pkg/models"
+)
+
+func Test_Collector_Parse(t *testing.T) {
+	tests := map[string]struct {
+		parser  Parser
+		fixture string
+		want    models.Flat
+	}{
+		"ok, otodom": {
+			parser:  Otodom(),
+			fixture: "otodom.html",
+			want: models.Flat{
+				Title:         "Mieszkanie 2-pokojowe z balkonem, Mokotów",
+				Price:         "3 200 zł",
+				Address:       "Mokotów, Warszawa, mazowieckie",
+				Surface:       "45,5 m²",
+				Rooms:         "2",
+				Floor:         "3/5",
+				AvailableFrom: "od zaraz",
+				Rent:          "650 zł",
+				Deposit:       "3 200 zł",
+				Description:   "Przestronne mieszkanie z balkonem i miejscem w garażu.",
+			},
+		},
+		"ok, olx": {
+			parser:  OLX(),
+			fixture: "olx.html",
+			want: models.Flat{
+				Title:         "Kawalerka Krowodrza, blisko AGH",
+				Price:         "2 100 zł",
+				Address:       "Kraków, Krowodrza",
+				Surface:       "28 m²",
+				Rooms:         "Kawalerka",
+				Floor:         "parter",
+				AvailableFrom: "01.11.2026",
+				Rent:          "450 zł",
+				Deposit:       "2100 zł",
+				Description:   "Kawalerka po remoncie, 10 minut pieszo do AGH.",
+			},
+		},
+		"ok, gratka": {
+			parser:  Gratka(),
+			fixture: "gratka.html",
+			want: models.Flat{
+				Title:         "Mieszkanie 3-pokojowe, Krzyki, ul. Powstańców Śląskich",
+				Price:         "4 500 zł",
+				Address:       "Wrocław, Krzyki, ul. Powstańców Śląskich",
+				Surface:       "62 m2",
+				Rooms:         "3",
+				Floor:         "4",
+				AvailableFrom: "2026-12-01",
+				Rent:          "800 zł",
+				Deposit:       "9 000 zł",
+				Description:   "Mieszkanie z garażem podziemnym i komórką lokatorską.",
+			},
+		},
+		"ok, morizon": {
+			parser:  Morizon(),
+			fixture: "morizon.html",
+			want: models.Flat{
+				Title:         "Mieszkanie na wynajem, 2 pokoje, Wrzeszcz",
+				Price:         "2 800 zł",
+				Address:       "Gdańsk, Wrzeszcz, Grunwaldzka",
+				Surface:       "41 m²",
+				Rooms:         "2",
+				Floor:         "1/4",
+				AvailableFrom: "od zaraz",
+				Rent:          "500 zł",
+				Deposit:       "5 600 zł",
+				Description:   "Jasne mieszkanie blisko SKM, z balkonem.",
+			},
+		},
+		"ok, generic": {
+			parser:  Generic(),
+			fixture: "generic.html",
+			want: models.Flat{
+				Title:       "Mieszkanie 2 pokoje, Poznań Jeżyce",
+				Price:       "2600",
+				Address:     "Poznań, Jeżyce",
+				Surface:     "48 m²",
+				Rooms:       "2",
+				Description: "Mieszkanie w kamienicy, wysoki sufit, balkon.",
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			srv := fixtureServer(t)
+
+			registry := NewRegistry()
+			registry.Register(tc.parser, hostOf(t, srv.URL))
+
+			c := NewCollector(colly.NewCollector(), registry, func(err error) {})
+
+			got, err := c.Parse(context.Background(), srv.URL+"/"+tc.fixture)
+			if err != nil {
+				t.Fatalf("failed to parse: %v", err)
+			}
+
+			if !reflect.DeepEqual(got.Flat, tc.want) {
+				t.Errorf("got\n%#v\nwant\n%#v\n", got.Flat, tc.want)
+			}
+
+			for _, f := range got.FieldsBy(StrategyCSS) {
+				if got.Sources[f].Detail == "" {
+					t.Errorf("expected selector for field %s", f)
+				}
+			}
+		})
+	}
+}
+
+func Test_Collector_Parse_Structured(t *testing.T) {
+	tests := map[string]struct {
+		parser      Parser
+		fixture     string
+		want        models.Flat
+		wantSources map[Field]Strategy
+	}{
+		"ok, next data with css fallback": {
+			parser:  Otodom(),
+			fixture: "otodom_next.html",
+			want: models.Flat{
+				Title:         "Mieszkanie 3-pokojowe, Żoliborz, widok na park",
+				Price:         "5 200 zł",
+				Address:       "ul. Słowackiego 12, Żoliborz, Warszawa, mazowieckie",
+				Surface:       "64,2 m²",
+				Rooms:         "3",
+				Floor:         "2/6",
+				AvailableFrom: "2026-11-15",
+				Rent:          "900 PLN",
+				Deposit:       "10 400 zł",
+				Description:   "Mieszkanie z widokiem na park. Garaż w cenie.",
+			},
+			wantSources: map[Field]Strategy{
+				FieldTitle:         StrategyNextData,
+				FieldPrice:         StrategyNextData,
+				FieldAddress:       StrategyNextData,
+				FieldSurface:       StrategyNextData,
+				FieldRooms:         StrategyNextData,
+				FieldFloor:         StrategyNextData,
+				FieldAvailableFrom: StrategyCSS,
+				FieldRent:          StrategyNextData,
+				FieldDeposit:       StrategyNextData,
+				FieldDescription:   StrategyNextData,
+			},
+		},
+		"ok, json-ld graph with css fallback": {
+			parser:  Morizon(),
+			fixture: "jsonld.html",
+			want: models.Flat{
+				Title:         "Mieszkanie 2 pokoje, Podgórze, Rynek Podgórski",
+				Price:         "3100 PLN",
+				Address:       "Rynek Podgórski, Kraków, małopolskie",
+				Surface:       "47.5 m²",
+				Rooms:         "2",
+				Floor:         "parter",
+				AvailableFrom: "2026-12-01",
+				Rent:          "550 zł",
+				Deposit:       "6 200 zł",
+				Description:   "Mieszkanie z balkonem przy Rynku Podgórskim.",
+			},
+			wantSources: map[Field]Strategy{
+				FieldTitle:         StrategyJSONLD,
+				FieldPrice:         StrategyJSONLD,
+				FieldAddress:       StrategyJSONLD,
+				FieldSurface:       StrategyJSONLD,
+				FieldRooms:         StrategyJSONLD,
+				FieldFloor:         StrategyJSONLD,
+				FieldAvailableFrom: StrategyJSONLD,
+				FieldRent:          StrategyCSS,
+				FieldDeposit:       StrategyCSS,
+				FieldDescription:   StrategyJSONLD,
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			srv := fixtureServer(t)
+
+			registry := NewRegistry()
+			registry.Register(tc.parser, hostOf(t, srv.URL))
+
+			c := NewCollector(colly.NewCollector(), registry, func(err error) {})
+
+			got, err := c.Parse(context.Background(), srv.URL+"/"+tc.fixture)
+			if err != nil {
+				t.Fatalf("failed to parse: %v", err)
+			}
+
+			if !reflect.DeepEqual(got.Flat, tc.want) {
+				t.Errorf("got\n%#v\nwant\n%#v\n", got.Flat, tc.want)
+			}
+
+			gotSources := make(map[Field]Strategy, len(got.Sources))
+			for f, src := range got.Sources {
+				gotSources[f] = src.Strategy
+			}
+
+			if !reflect.DeepEqual(gotSources, tc.wantSources) {
+				t.Errorf("got\n%#v\nwant\n%#v\n", gotSources, tc.wantSources)
+			}
+		})
+	}
+}
+
+func Test_Registry_Lookup(t *testing.T) {
+	tests := map[string]struct {
+		url        string
+		fallback   bool
+		wantPortal string
+		wantErr    error
+	}{
+		"ok, exact host": {
+			url:        "https://otodom.pl/pl/oferta/mieszkanie-ID4abc",
+			wantPortal: "otodom",
+		},
+		"ok, www subdomain": {
+			url:        "https://www.otodom.pl/pl/oferta/mieszkanie-ID4abc",
+			wantPortal: "otodom",
+		},
+		"ok, mobile subdomain": {
+			url:        "https://m.olx.pl/d/oferta/kawalerka-CID3-IDabc.html",
+			wantPortal: "olx",
+		},
+		"ok, mixed case host": {
+			url:        "https://WWW.Gratka.PL/nieruchomosci/mieszkanie/ob/123",
+			wantPortal: "gratka",
+		},
+		"ok, fallback": {
+			url:        "https://www.domiporta.pl/nieruchomosci/123",
+			fallback:   true,
+			wantPortal: "generic",
+		},
+		"fail, unsupported portal": {
+			url:     "https://www.domiporta.pl/nieruchomosci/123",
+			wantErr: ErrUnsupportedPortal,
+		},
+		"fail, lookalike host": {
+			url:     "https://notodom.pl/pl/oferta/mieszkanie-ID4abc",
+			wantErr: ErrUnsupportedPortal,
+		},
+		"fail, unsupported scheme": {
+			url:     "ftp://otodom.pl/pl/oferta/mieszkanie-ID4abc",
+			wantErr: custerrors.ErrInvalidInput,
+		},
+		"fail, no host": {
+			url:     "/pl/oferta/mieszkanie-ID4abc",
+			wantErr: custerrors.ErrInvalidInput,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			registry := DefaultRegistry(tc.fallback)
+
+			p, err := registry.Lookup(tc.url)
+			if tc.wantErr != nil {
+				if !errors.Is(err, tc.wantErr) {
+					t.Fatalf("expected errors to be %v got %v (via errors.Is)", tc.wantErr, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("failed to lookup parser: %v", err)
+			}
+
+			if p.Portal() != tc.wantPortal {
+				t.Errorf("got portal %q want %q", p.Portal(), tc.wantPortal)
+			}
+		})
+	}
+
+	t.Run("fail, unsupported portal is invalid input", func(t *testing.T) {
+		_, err := DefaultRegistry(false).Lookup("https://example.com/flat/1")
+		if !errors.Is(err, custerrors.ErrInvalidInput) {
+			t.Fatalf("expected errors to be %v got %v (via errors.Is)", custerrors.ErrInvalidInput, err)
+		}
+	})
+}
+
+// fixtureServer serves the golden HTML files from testdata.
+func fixtureServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.FileServer(http.Dir("testdata")))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func hostOf(t *testing.T, rawURL string) string {
+	t.Helper()
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatalf("failed to parse url: %v", err)
+	}
+
+	return u.Hostname()
+}
+
+func Test_Collector_Parse_Gone(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle("/", http.FileServer(http.Dir("testdata")))
+	mux.HandleFunc("/gone", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusGone)
+	})
+	mux.HandleFunc("/to-search", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/olx_search_2.html", http.StatusFound)
+	})
+	mux.HandleFunc("/to-home", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/to-listing", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/olx.html", http.StatusMovedPermanently)
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	tests := map[string]struct {
+		path    string
+		wantErr error
+	}{
+		"ok, redirect to another listing": {path: "/to-listing"},
+		"fail, not found":                 {path: "/missing.html", wantErr: ErrListingGone},
+		"fail, gone":                      {path: "/gone", wantErr: ErrListingGone},
+		"fail, redirect to search":        {path: "/to-search", wantErr: ErrListingGone},
+		"fail, redirect to home page":     {path: "/to-home", wantErr: ErrListingGone},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			registry := NewRegistry()
+			registry.Register(WithSearch(OLX(), OLXSearch()), hostOf(t, srv.URL))
+
+			c := NewCollector(colly.NewCollector(), registry, func(err error) {})
+
+			got, err := c.Parse(context.Background(), srv.URL+tc.path)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("expected errors to be %v got %v (via errors.Is)", tc.wantErr, err)
+			}
+
+			if tc.wantErr == nil && got.Flat.Title == "" {
+				t.Errorf("expected the listing to be parsed")
+			}
+		})
+	}
+}
+
+func Test_Collector_Parse_Photos(t *testing.T) {
+	tests := map[string]struct {
+		parser  Parser
+		fixture string
+		want    []string
+	}{
+		"ok, css resolved and deduplicated": {
+			parser:  OLX(),
+			fixture: "olx.html",
+			want:    []string{"{srv}/img/olx/1.jpg", "https://cdn.olx.example/img/2.jpg"},
+		},
+		"ok, next data": {
+			parser:  Otodom(),
+			fixture: "otodom_next.html",
+			want:    []string{"https://cdn.otodom.example/65012345/1-large.webp", "https://cdn.otodom.example/65012345/2-medium.webp"},
+		},
+		"ok, json-ld": {
+			parser:  Morizon(),
+			fixture: "jsonld.html",
+			want:    []string{"https://cdn.morizon.example/podgorze/1.jpg", "{srv}/podgorze/2.jpg"},
+		},
+		"ok, open graph": {
+			parser:  Generic(),
+			fixture: "generic.html",
+			want:    []string{"https://cdn.example.com/poznan/1.jpg"},
+		},
+		"ok, no gallery": {
+			parser:  Gratka(),
+			fixture: "gratka.html",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			srv := fixtureServer(t)
+
+			registry := NewRegistry()
+			registry.Register(tc.parser, hostOf(t, srv.URL))
+
+			c := NewCollector(colly.NewCollector(), registry, func(err error) {})
+
+			got, err := c.Parse(context.Background(), srv.URL+"/"+tc.fixture)
+			if err != nil {
+				t.Fatalf("failed to parse: %v", err)
+			}
+
+			var want []string
+			for _, u := range tc.want {
+				want = append(want, strings.Replace(u, "{srv}", srv.URL, 1))
+			}
+
+			if !reflect.DeepEqual(got.Photos, want) {
+				t.Errorf("got\n%#v\nwant\n%#v\n", got.Photos, want)
+			}
+		})
+	}
+}
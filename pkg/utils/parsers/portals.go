@@ -0,0 +1,119 @@
+package parsers
+
+// Otodom creates the parser for otodom.pl listings.
+func Otodom() *SelectorParser {
+	return NewSelectorParser("otodom", map[Field][]Rule{
+		FieldTitle:         {{Selector: ".css-1wnihf5.efcnut38"}},
+		FieldPrice:         {{Selector: ".css-t3wmkv.e1l1avn10"}},
+		FieldAddress:       {{Selector: ".e1w8sadu0.css-1helwne.exgq9l20"}},
+		FieldSurface:       {{Selector: `[aria-label="Powierzchnia"] .css-1wi2w6s.enb64yk5`}},
+		FieldRooms:         {{Selector: `[aria-label="Liczba pokoi"] .css-1wi2w6s.enb64yk5`}, {Selector: ".css-19yhkv9.enb64yk08"}},
+		FieldFloor:         {{Selector: `[aria-label="Piętro"] .css-1wi2w6s.enb64yk5`}},
+		FieldAvailableFrom: {{Selector: ".css-x0kl3j.e1k3ukdh0"}},
+		FieldRent:          {{Selector: `[aria-label="Czynsz"] .css-1wi2w6s.enb64yk5`}},
+		FieldDeposit:       {{Selector: `[aria-label="Kaucja"] .css-1wi2w6s.enb64yk5`}},
+		FieldDescription:   {{Selector: ".css-1ugtzj2.e175i4j93"}},
+	}).WithPhotos(Rule{Selector: `[data-cy="mosaic-gallery-main-view"] img`, Attr: "src"})
+}
+
+// OLX creates the parser for olx.pl listings.
+func OLX() *SelectorParser {
+	const params = `[data-testid="main"] ul li p`
+
+	return NewSelectorParser("olx", map[Field][]Rule{
+		FieldTitle:         {{Selector: `[data-cy="ad_title"]`}, {Selector: "h1"}},
+		FieldPrice:         {{Selector: `[data-testid="ad-price-container"] h3`}},
+		FieldAddress:       {{Selector: `[data-testid="location-date"]`}, {Selector: `[data-testid="map-aside-section"] p`}},
+		FieldSurface:       {{Selector: params, Label: "Powierzchnia"}},
+		FieldRooms:         {{Selector: params, Label: "Liczba pokoi"}},
+		FieldFloor:         {{Selector: params, Label: "Poziom"}},
+		FieldAvailableFrom: {{Selector: params, Label: "Dostępne od"}},
+		FieldRent:          {{Selector: params, Label: "Czynsz (dodatkowo)"}},
+		FieldDeposit:       {{Selector: params, Label: "Kaucja"}},
+		FieldDescription:   {{Selector: `[data-cy="ad_description"] div`}},
+	}).WithPhotos(
+		Rule{Selector: `[data-testid="swiper-image"]`, Attr: "src"},
+		Rule{Selector: `[data-testid="swiper-image-lazy"]`, Attr: "data-src"},
+	)
+}
+
+// Gratka creates the parser for gratka.pl listings.
+func Gratka() *SelectorParser {
+	const params = "ul.parameters__singleParameters li"
+
+	return NewSelectorParser("gratka", map[Field][]Rule{
+		FieldTitle:         {{Selector: "h1.sticker__title"}},
+		FieldPrice:         {{Selector: ".priceInfo__value"}},
+		FieldAddress:       {{Selector: ".offerLocation__address"}},
+		FieldSurface:       {{Selector: params, Label: "Powierzchnia w m2"}},
+		FieldRooms:         {{Selector: params, Label: "Liczba pokoi"}},
+		FieldFloor:         {{Selector: params, Label: "Piętro"}},
+		FieldAvailableFrom: {{Selector: params, Label: "Dostępne od"}},
+		FieldRent:          {{Selector: params, Label: "Opłaty (czynsz administracyjny, media)"}, {Selector: params, Label: "Czynsz"}},
+		FieldDeposit:       {{Selector: params, Label: "Kaucja"}},
+		FieldDescription:   {{Selector: ".description__rolled"}},
+	}).WithPhotos(Rule{Selector: ".gallery__image img", Attr: "src"})
+}
+
+// Morizon creates the parser for morizon.pl listings.
+func Morizon() *SelectorParser {
+	const params = ".detailed-information__row"
+
+	return NewSelectorParser("morizon", map[Field][]Rule{
+		FieldTitle:         {{Selector: ".summary__title h1"}, {Selector: "h1"}},
+		FieldPrice:         {{Selector: ".price-row__price"}},
+		FieldAddress:       {{Selector: ".location-row__second_column h2"}},
+		FieldSurface:       {{Selector: params, Label: "Pow. całkowita"}},
+		FieldRooms:         {{Selector: params, Label: "Liczba pokoi"}},
+		FieldFloor:         {{Selector: params, Label: "Piętro"}},
+		FieldAvailableFrom: {{Selector: params, Label: "Dostępne od"}},
+		FieldRent:          {{Selector: params, Label: "Czynsz"}},
+		FieldDeposit:       {{Selector: params, Label: "Kaucja"}},
+		FieldDescription:   {{Selector: ".description"}},
+	}).WithPhotos(Rule{Selector: `[data-cy="gallery"] img`, Attr: "src"})
+}
+
+// Generic creates a parser that relies on common metadata such as Open Graph
+// tags and schema.org microdata. It is used for portals without a dedicated parser.
+func Generic() *SelectorParser {
+	return NewSelectorParser("generic", map[Field][]Rule{
+		FieldTitle:       {{Selector: `meta[property="og:title"]`, Attr: "content"}, {Selector: "h1"}, {Selector: "title"}},
+		FieldPrice:       {{Selector: `[itemprop="price"]`, Attr: "content"}, {Selector: `[itemprop="price"]`}},
+		FieldAddress:     {{Selector: `[itemprop="address"]`}, {Selector: `meta[property="og:street-address"]`, Attr: "content"}},
+		FieldSurface:     {{Selector: `[itemprop="floorSize"]`}},
+		FieldRooms:       {{Selector: `[itemprop="numberOfRooms"]`}},
+		FieldDescription: {{Selector: `meta[property="og:description"]`, Attr: "content"}, {Selector: `meta[name="description"]`, Attr: "content"}},
+	}).WithPhotos(Rule{Selector: `meta[property="og:image"]`, Attr: "content"})
+}
+
+// OtodomSearch are the rules for otodom.pl search results.
+func OtodomSearch() SearchRules {
+	return SearchRules{
+		Listing:   `[data-cy="listing-item-link"]`,
+		PageParam: "page",
+	}
+}
+
+// OLXSearch are the rules for olx.pl search results.
+func OLXSearch() SearchRules {
+	return SearchRules{
+		Listing: `[data-cy="l-card"] a[href*="/d/oferta/"]`,
+		Next:    `[data-testid="pagination-forward"]`,
+	}
+}
+
+// GratkaSearch are the rules for gratka.pl search results.
+func GratkaSearch() SearchRules {
+	return SearchRules{
+		Listing: "article.teaserUnified a.teaserUnified__anchor",
+		Next:    "a.pagination__nextPage",
+	}
+}
+
+// MorizonSearch are the rules for morizon.pl search results.
+func MorizonSearch() SearchRules {
+	return SearchRules{
+		Listing:   `a[data-cy="propertyUrl"]`,
+		PageParam: "page",
+	}
+}
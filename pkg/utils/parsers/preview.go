@@ -0,0 +1,36 @@
+package parsers
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Preview extracts the flat from the listing at url the way Parse does, or
+// from body if it is not nil the way ParsePage does. Nothing is recorded in
+// the monitor and required fields are not enforced, Missing reports which
+// of them are empty.
+func (c *Collector) Preview(ctx context.Context, url string, body []byte) (Result, error) {
+	if body == nil {
+		res, _, err := c.parse(ctx, url)
+		return res, err
+	}
+
+	return c.parsePage(Page{
+		URL:         url,
+		StatusCode:  http.StatusOK,
+		ContentType: "text/html; charset=utf-8",
+		Body:        body,
+		FetchedAt:   time.Now(),
+	})
+}
+
+// Missing returns the fields the monitor requires that res has no value
+// for, none if the collector has no monitor.
+func (c *Collector) Missing(res Result) []Field {
+	if c.monitor == nil {
+		return nil
+	}
+
+	return c.monitor.Missing(res)
+}
@@ -0,0 +1,55 @@
+package parsers
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gocolly/colly/v2"
+)
+
+func Test_Collector_Preview(t *testing.T) {
+	body, err := os.ReadFile("testdata/olx.html")
+	if err != nil {
+		t.Fatalf("failed to read fixture: %v", err)
+	}
+
+	tests := map[string]struct {
+		parser Parser
+		body   []byte
+		// wantMissing is the number of required fields expected to be empty.
+		wantMissing int
+	}{
+		"ok, fetched":            {parser: OLX()},
+		"ok, uploaded":           {parser: OLX(), body: body},
+		"ok, incomplete allowed": {parser: Gratka(), body: body, wantMissing: 3},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			srv := fixtureServer(t)
+
+			registry := NewRegistry()
+			registry.Register(tc.parser, hostOf(t, srv.URL))
+
+			c := NewCollector(colly.NewCollector(), registry, func(err error) {})
+			c.WithMonitor(NewMonitor(HealthConfig{Required: []Field{FieldTitle, FieldPrice, FieldAddress}, Window: time.Hour}, nil))
+
+			res, err := c.Preview(context.Background(), srv.URL+"/olx.html", tc.body)
+			if err != nil {
+				t.Fatalf("failed to preview: %v", err)
+			}
+
+			if res.Portal != tc.parser.Portal() {
+				t.Errorf("got portal %q want %q", res.Portal, tc.parser.Portal())
+			}
+			if got := len(c.Missing(res)); got != tc.wantMissing {
+				t.Errorf("got %d missing fields want %d", got, tc.wantMissing)
+			}
+			if health := c.Health(); len(health) != 0 {
+				t.Errorf("expected nothing recorded in the monitor, got %+v", health)
+			}
+		})
+	}
+}
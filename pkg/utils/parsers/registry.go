@@ -0,0 +1,195 @@
+package parsers
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/gocolly/colly/v2"
+
+	"hestia/pkg/custerrors"
+)
+
+// ErrUnsupportedPortal is returned when no parser is registered for the host of a URL.
+var ErrUnsupportedPortal = errors.New("unsupported portal")
+
+// Registry keeps track of the parsers available for each portal, keyed by host.
+type Registry struct {
+	mu       sync.RWMutex
+	parsers  map[string]Parser
+	fallback Parser
+	// overrides replace the parsers of a portal, keyed by portal name.
+	overrides map[string]Parser
+}
+
+// NewRegistry creates an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		parsers:   make(map[string]Parser),
+		overrides: make(map[string]Parser),
+	}
+}
+
+// DefaultRegistry creates a Registry with the parsers for all supported portals.
+// If fallback is true, the generic parser is used for hosts without a dedicated parser.
+func DefaultRegistry(fallback bool) *Registry {
+	r := NewRegistry()
+	r.Register(WithSearch(Otodom(), OtodomSearch()), "otodom.pl")
+	r.Register(WithSearch(OLX(), OLXSearch()), "olx.pl")
+	r.Register(WithSearch(Gratka(), GratkaSearch()), "gratka.pl")
+	r.Register(WithSearch(Morizon(), MorizonSearch()), "morizon.pl")
+
+	if fallback {
+		r.SetFallback(Generic())
+	}
+
+	return r
+}
+
+// Register registers p for the provided hosts. Subdomains of a host are
+// matched as well, so registering "olx.pl" also covers "www.olx.pl" and "m.olx.pl".
+func (r *Registry) Register(p Parser, hosts ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, h := range hosts {
+		r.parsers[strings.ToLower(h)] = p
+	}
+}
+
+// SetFallback sets the parser used for hosts without a registered parser.
+// A nil parser disables the fallback.
+func (r *Registry) SetFallback(p Parser) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.fallback = p
+}
+
+// Override replaces the parser of portal with p until it is overridden
+// again, without touching the hosts it is registered for. The search rules
+// of the replaced parser are kept. A nil parser restores the registered one.
+func (r *Registry) Override(portal string, p Parser) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if p == nil {
+		delete(r.overrides, portal)
+		return
+	}
+	r.overrides[portal] = p
+}
+
+// Portals returns the names of the registered parsers, the fallback included, sorted.
+func (r *Registry) Portals() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var out []string
+	for _, p := range r.parsers {
+		out = append(out, p.Portal())
+	}
+	if r.fallback != nil {
+		out = append(out, r.fallback.Portal())
+	}
+	slices.Sort(out)
+
+	return slices.Compact(out)
+}
+
+// LimitRules returns a rate limit for each registered host and one shared by
+// all other hosts. Hosts in overrides get their own limit, all others are
+// limited by def. A random pause of up to half the delay is added between requests.
+func (r *Registry) LimitRules(def HostLimit, overrides map[string]HostLimit) []*colly.LimitRule {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	hosts := make([]string, 0, len(r.parsers)+len(overrides))
+	for h := range r.parsers {
+		hosts = append(hosts, h)
+	}
+	for h := range overrides {
+		if _, ok := r.parsers[h]; !ok {
+			hosts = append(hosts, h)
+		}
+	}
+	slices.Sort(hosts)
+
+	rules := make([]*colly.LimitRule, 0, len(hosts)+1)
+	for _, h := range hosts {
+		l, ok := overrides[h]
+		if !ok {
+			l = def
+		}
+		rules = append(rules, limitRule("*"+h, l))
+	}
+
+	return append(rules, limitRule("*", def))
+}
+
+func limitRule(glob string, l HostLimit) *colly.LimitRule {
+	return &colly.LimitRule{
+		DomainGlob:  glob,
+		Parallelism: max(l.Parallelism, 1),
+		Delay:       l.Delay,
+		RandomDelay: l.Delay / 2,
+	}
+}
+
+// Lookup returns the parser registered for the host of rawURL.
+// It errors with ErrUnsupportedPortal if no parser matches and no fallback is set.
+func (r *Registry) Lookup(rawURL string) (Parser, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url %q: %w", rawURL, custerrors.ErrInvalidInput)
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid url %q: %w", rawURL, custerrors.ErrInvalidInput)
+	}
+
+	host := strings.ToLower(u.Hostname())
+	if host == "" {
+		return nil, fmt.Errorf("invalid url %q: %w", rawURL, custerrors.ErrInvalidInput)
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	// Walk up the domain labels so that subdomains match their parent host.
+	for h := host; h != ""; {
+		if p, ok := r.parsers[h]; ok {
+			return r.resolve(p), nil
+		}
+
+		i := strings.IndexByte(h, '.')
+		if i < 0 {
+			break
+		}
+		h = h[i+1:]
+	}
+
+	if r.fallback != nil {
+		return r.resolve(r.fallback), nil
+	}
+
+	return nil, fmt.Errorf("%w %q: %w", ErrUnsupportedPortal, host, custerrors.ErrInvalidInput)
+}
+
+// resolve returns the override of the registered parser p, if any. The
+// caller must hold r.mu.
+func (r *Registry) resolve(p Parser) Parser {
+	o, ok := r.overrides[p.Portal()]
+	if !ok {
+		return p
+	}
+
+	if sp, ok := p.(*searchParser); ok {
+		return WithSearch(o, sp.rules)
+	}
+
+	return o
+}
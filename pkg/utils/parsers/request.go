@@ -0,0 +1,179 @@
+package parsers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gocolly/colly/v2"
+)
+
+// DefaultRequestTimeout is the RequestTimeout of new collectors.
+const DefaultRequestTimeout = 30 * time.Second
+
+var (
+	// ErrBodyTooLarge is returned for pages larger than the MaxBodySize of the collector.
+	ErrBodyTooLarge = errors.New("response body too large")
+	// ErrRobotsDisallowed is returned for pages robots.txt forbids to visit.
+	ErrRobotsDisallowed = errors.New("disallowed by robots.txt")
+)
+
+// callHeader carries the id of the Parse or Crawl call a request belongs to
+// from the colly callbacks to callTransport, which removes it again.
+const callHeader = "X-Hestia-Call"
+
+// call is a Parse or Crawl call in progress.
+type call struct {
+	ctx     context.Context
+	timeout time.Duration
+}
+
+// callTransport sends the requests of a call with the context of the call.
+// colly requests do not carry a context of their own, and all clones of a
+// collector share one HTTP client. Requests colly sends outside a call, such
+// as the ones for robots.txt, are limited to DefaultRequestTimeout.
+type callTransport struct {
+	base  http.RoundTripper
+	calls sync.Map // id -> call
+}
+
+func (t *callTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c := call{ctx: req.Context(), timeout: DefaultRequestTimeout}
+	if v, ok := t.calls.Load(req.Header.Get(callHeader)); ok {
+		c = v.(call)
+	}
+
+	ctx, cancel := c.ctx, context.CancelFunc(func() {})
+	if c.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+	}
+
+	req = req.Clone(ctx)
+	req.Header.Del(callHeader)
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	// The timeout covers reading the body too.
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+
+	return resp, nil
+}
+
+// cancelBody releases the context of a request once its body is closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// session is a collector for a single call. It shares the limits and HTTP
+// client of the Collector it was cloned from, but not its callbacks, so
+// sessions can be used from many goroutines at once.
+type session struct {
+	*colly.Collector
+	ctx context.Context
+	// truncated is set when the body of the last response filled MaxBodySize.
+	truncated bool
+}
+
+// session clones c for a call with ctx. The returned function must be
+// called once the call is done.
+func (c *Collector) session(ctx context.Context) (*session, func()) {
+	id := strconv.FormatUint(c.calls.Add(1), 10)
+	c.transport.calls.Store(id, call{ctx: ctx, timeout: c.RequestTimeout})
+
+	s := &session{
+		Collector: c.Clone(),
+		ctx:       ctx,
+	}
+	s.AllowURLRevisit = true
+
+	s.OnRequest(func(r *colly.Request) {
+		r.Headers.Set(callHeader, id)
+	})
+
+	// colly cuts bodies off at MaxBodySize, refuse them up front when the size is known.
+	s.OnResponseHeaders(func(r *colly.Response) {
+		n, err := strconv.ParseInt(r.Headers.Get("Content-Length"), 10, 64)
+		if err == nil && s.MaxBodySize > 0 && n > int64(s.MaxBodySize) {
+			r.Request.Abort()
+		}
+	})
+
+	// Bodies of unknown size are cut off silently, a full one was likely cut.
+	s.OnResponse(func(r *colly.Response) {
+		s.truncated = s.MaxBodySize > 0 && len(r.Body) >= s.MaxBodySize
+	})
+
+	return s, func() {
+		c.transport.calls.Delete(id)
+	}
+}
+
+// Fetch gets url without parsing it, with the user agent, transport and
+// rate limits of the collector, and returns the status and body of the
+// response. Error statuses are returned without an error. It errors with
+// ErrBodyTooLarge if the body is larger than maxSize bytes, with
+// ErrRobotsDisallowed if robots.txt of the host forbids it and with the
+// error of ctx if it is done first.
+func (c *Collector) Fetch(ctx context.Context, url string, maxSize int) (int, []byte, error) {
+	cc, done := c.session(ctx)
+	defer done()
+
+	if maxSize > 0 {
+		// One byte more tells a body of exactly maxSize from a cut off one.
+		cc.MaxBodySize = maxSize + 1
+	}
+
+	var (
+		status int
+		body   []byte
+	)
+	cc.OnResponse(func(r *colly.Response) {
+		status, body = r.StatusCode, r.Body
+	})
+	cc.OnError(func(r *colly.Response, _ error) {
+		status = r.StatusCode
+	})
+
+	err := cc.visit(url)
+	if err != nil && status < http.StatusBadRequest {
+		return status, nil, err
+	}
+
+	return status, body, nil
+}
+
+// visit visits url and explains why the visit failed, if it did.
+func (s *session) visit(url string) error {
+	s.truncated = false
+	err := s.Visit(url)
+	switch {
+	case err == nil && s.truncated:
+		return fmt.Errorf("%w: %s is at least %d bytes", ErrBodyTooLarge, url, s.MaxBodySize)
+	case err == nil:
+		return nil
+	case errors.Is(err, colly.ErrAbortedAfterHeaders):
+		return fmt.Errorf("%w: %s is larger than %d bytes", ErrBodyTooLarge, url, s.MaxBodySize)
+	case errors.Is(err, colly.ErrRobotsTxtBlocked):
+		return fmt.Errorf("%w: %s", ErrRobotsDisallowed, url)
+	case s.ctx.Err() != nil:
+		return fmt.Errorf("failed to visit %s: %w", url, s.ctx.Err())
+	}
+
+	return err
+}
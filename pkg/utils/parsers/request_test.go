@@ -0,0 +1,151 @@
+package parsers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gocolly/colly/v2"
+)
+
+func Test_Collector_Parse_Concurrent(t *testing.T) {
+	srv := fixtureServer(t)
+
+	registry := NewRegistry()
+	registry.Register(OLX(), hostOf(t, srv.URL))
+
+	c := NewCollector(colly.NewCollector(), registry, func(err error) {})
+
+	want, err := c.Parse(context.Background(), srv.URL+"/olx.html")
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 64)
+	for i := range 64 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			// Every other call asks for a page that is gone, so callbacks of
+			// different calls would mix up the results.
+			if i%2 == 1 {
+				_, err := c.Parse(context.Background(), srv.URL+"/missing.html")
+				if !errors.Is(err, ErrListingGone) {
+					errs <- fmt.Errorf("call %d: expected errors to be %v got %v", i, ErrListingGone, err)
+				}
+				return
+			}
+
+			got, err := c.Parse(context.Background(), srv.URL+"/olx.html")
+			switch {
+			case err != nil:
+				errs <- fmt.Errorf("call %d: failed to parse: %w", i, err)
+			case got.Flat.Title != want.Flat.Title || len(got.Photos) != len(want.Photos):
+				errs <- fmt.Errorf("call %d: got %q want %q", i, got.Flat.Title, want.Flat.Title)
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func Test_Collector_Parse_Limits(t *testing.T) {
+	release := make(chan struct{})
+	t.Cleanup(func() { close(release) })
+
+	mux := http.NewServeMux()
+	mux.Handle("/", http.FileServer(http.Dir("testdata")))
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	})
+	mux.HandleFunc("/large", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "4096")
+		_, _ = w.Write([]byte(strings.Repeat("a", 4096)))
+	})
+	mux.HandleFunc("/chunked", func(w http.ResponseWriter, r *http.Request) {
+		// Flushing before the end leaves the size out and chunks the body.
+		for range 4 {
+			_, _ = w.Write([]byte(strings.Repeat("a", 1024)))
+			w.(http.Flusher).Flush()
+		}
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := map[string]struct {
+		ctx     context.Context
+		path    string
+		timeout time.Duration
+		maxBody int
+		wantErr error
+	}{
+		"ok, within limits": {
+			ctx:     context.Background(),
+			path:    "/olx.html",
+			timeout: time.Second * 5,
+		},
+		"fail, request timeout": {
+			ctx:     context.Background(),
+			path:    "/slow",
+			timeout: time.Millisecond * 50,
+			wantErr: context.DeadlineExceeded,
+		},
+		"fail, context canceled": {
+			ctx:     canceled,
+			path:    "/slow",
+			timeout: time.Second * 5,
+			wantErr: context.Canceled,
+		},
+		"fail, body too large": {
+			ctx:     context.Background(),
+			path:    "/large",
+			timeout: time.Second * 5,
+			maxBody: 1024,
+			wantErr: ErrBodyTooLarge,
+		},
+		"fail, chunked body too large": {
+			ctx:     context.Background(),
+			path:    "/chunked",
+			timeout: time.Second * 5,
+			maxBody: 1024,
+			wantErr: ErrBodyTooLarge,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			registry := NewRegistry()
+			registry.Register(OLX(), hostOf(t, srv.URL))
+
+			c := NewCollector(colly.NewCollector(), registry, func(err error) {})
+			c.RequestTimeout = tc.timeout
+			if tc.maxBody > 0 {
+				c.MaxBodySize = tc.maxBody
+			}
+
+			_, err := c.Parse(tc.ctx, srv.URL+tc.path)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("expected errors to be %v got %v (via errors.Is)", tc.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package parsers
+
+import (
+	"hestia/pkg/models"
+)
+
+// Strategy is the extraction strategy that produced a field value.
+type Strategy string
+
+const (
+	// StrategyJSONLD indicates the value was read from a schema.org JSON-LD block.
+	StrategyJSONLD Strategy = "json-ld"
+	// StrategyNextData indicates the value was read from the Next.js __NEXT_DATA__ payload.
+	StrategyNextData Strategy = "next-data"
+	// StrategyCSS indicates the value was read using a portal specific CSS selector.
+	StrategyCSS Strategy = "css"
+	// StrategyXPath indicates the value was read using a portal specific XPath expression.
+	StrategyXPath Strategy = "xpath"
+)
+
+// Source describes where the value of a field came from.
+type Source struct {
+	Strategy Strategy
+	// Detail is the selector or the path in the structured data that matched.
+	Detail string
+}
+
+// Result is the outcome of parsing a listing page.
+type Result struct {
+	// Portal is the name of the parser that extracted the flat.
+	Portal string
+	Flat   models.Flat
+	// Sources records which strategy produced each non-empty field.
+	Sources map[Field]Source
+	// Photos are the URLs of the gallery images of the listing, in page order.
+	Photos []string
+	// Page is the page the result was parsed from, nil if it could not be fetched.
+	Page *Page
+}
+
+func newResult() Result {
+	return Result{
+		Sources: make(map[Field]Source),
+	}
+}
+
+// set stores v in field f if v is not empty and f has no value yet.
+func (r *Result) set(f Field, v string, src Source) {
+	if v == "" {
+		return
+	}
+
+	if _, ok := r.Sources[f]; ok {
+		return
+	}
+
+	*f.Ptr(&r.Flat) = v
+	r.Sources[f] = src
+}
+
+// merge fills the fields of r that are still empty with the values of other.
+func (r *Result) merge(other Result) {
+	for _, f := range Fields {
+		src, ok := other.Sources[f]
+		if !ok {
+			continue
+		}
+		r.set(f, *f.Ptr(&other.Flat), src)
+	}
+
+	if len(r.Photos) == 0 {
+		r.Photos = other.Photos
+	}
+}
+
+// FieldsBy returns the fields that were produced by strategy s.
+func (r Result) FieldsBy(s Strategy) []Field {
+	var out []Field
+	for _, f := range Fields {
+		if src, ok := r.Sources[f]; ok && src.Strategy == s {
+			out = append(out, f)
+		}
+	}
+
+	return out
+}
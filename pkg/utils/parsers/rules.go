@@ -0,0 +1,336 @@
+package parsers
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/cascadia"
+	"github.com/antchfx/htmlquery"
+	"github.com/antchfx/xpath"
+	"github.com/gocolly/colly/v2"
+
+	"hestia/pkg/custerrors"
+	"hestia/pkg/models"
+)
+
+// Field is the name of a flat field that can be extracted from a listing page.
+type Field string
+
+const (
+	FieldTitle         Field = "title"
+	FieldPrice         Field = "price"
+	FieldAddress       Field = "address"
+	FieldSurface       Field = "surface"
+	FieldRooms         Field = "rooms"
+	FieldFloor         Field = "floor"
+	FieldAvailableFrom Field = "available_from"
+	FieldRent          Field = "rent"
+	FieldDeposit       Field = "deposit"
+	FieldDescription   Field = "description"
+)
+
+// Fields lists all fields in the order they are extracted.
+var Fields = []Field{
+	FieldTitle,
+	FieldPrice,
+	FieldAddress,
+	FieldSurface,
+	FieldRooms,
+	FieldFloor,
+	FieldAvailableFrom,
+	FieldRent,
+	FieldDeposit,
+	FieldDescription,
+}
+
+// Ptr returns a pointer to the field f of flat.
+func (f Field) Ptr(flat *models.Flat) *string {
+	switch f {
+	case FieldTitle:
+		return &flat.Title
+	case FieldPrice:
+		return &flat.Price
+	case FieldAddress:
+		return &flat.Address
+	case FieldSurface:
+		return &flat.Surface
+	case FieldRooms:
+		return &flat.Rooms
+	case FieldFloor:
+		return &flat.Floor
+	case FieldAvailableFrom:
+		return &flat.AvailableFrom
+	case FieldRent:
+		return &flat.Rent
+	case FieldDeposit:
+		return &flat.Deposit
+	case FieldDescription:
+		return &flat.Description
+	}
+
+	return nil
+}
+
+// Rule describes how to extract a single field from a listing page.
+type Rule struct {
+	// Selector is a CSS selector relative to the root of the page.
+	Selector string
+	// XPath is an XPath expression used instead of Selector.
+	XPath string
+	// Attr is the attribute to read. If empty, the text of the element is used.
+	Attr string
+	// Label, if set, selects the first matching element whose text starts with
+	// Label and returns the remaining text, e.g. "Piętro: 3" yields "3".
+	Label string
+	// Pattern, if set, is a regular expression the value must match. The
+	// first capture group is kept, or the whole match if there is none.
+	Pattern string
+
+	// xpath and pattern are compiled by Compile.
+	xpath   *xpath.Expr
+	pattern *regexp.Regexp
+}
+
+// Compile checks the selector, XPath expression and pattern of r and
+// returns r with them compiled. The error wraps custerrors.ErrInvalidInput.
+func (r Rule) Compile() (Rule, error) {
+	switch {
+	case r.Selector == "" && r.XPath == "":
+		return r, fmt.Errorf("rule needs a selector or an xpath: %w", custerrors.ErrInvalidInput)
+	case r.Selector != "" && r.XPath != "":
+		return r, fmt.Errorf("rule has both a selector and an xpath: %w", custerrors.ErrInvalidInput)
+	}
+
+	if r.Selector != "" {
+		if _, err := cascadia.Compile(r.Selector); err != nil {
+			return r, fmt.Errorf("invalid selector %q: %v: %w", r.Selector, err, custerrors.ErrInvalidInput)
+		}
+	}
+
+	if r.XPath != "" {
+		expr, err := xpath.Compile(r.XPath)
+		if err != nil {
+			return r, fmt.Errorf("invalid xpath %q: %v: %w", r.XPath, err, custerrors.ErrInvalidInput)
+		}
+		r.xpath = expr
+	}
+
+	if r.Pattern != "" {
+		re, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return r, fmt.Errorf("invalid pattern %q: %v: %w", r.Pattern, err, custerrors.ErrInvalidInput)
+		}
+		r.pattern = re
+	}
+
+	return r, nil
+}
+
+// Parser extracts flat data from a listing page of a single portal.
+type Parser interface {
+	// Portal returns the name of the portal the parser is written for.
+	Portal() string
+	// Parse extracts the flat from the root element of a listing page.
+	Parse(e *colly.HTMLElement) Result
+}
+
+// SelectorParser is a Parser that extracts fields using CSS selector or
+// XPath rules. Rules for a field are tried in order until one yields a
+// non-empty value.
+type SelectorParser struct {
+	portal string
+	rules  map[Field][]Rule
+	photos []Rule
+}
+
+// NewSelectorParser creates a new SelectorParser.
+func NewSelectorParser(portal string, rules map[Field][]Rule) *SelectorParser {
+	return &SelectorParser{
+		portal: portal,
+		rules:  rules,
+	}
+}
+
+// CompileSelectorParser creates a SelectorParser from rules that are not
+// known to be valid, such as the ones edited by admins. It errors with
+// custerrors.ErrInvalidInput if a field is unknown or a rule does not compile.
+func CompileSelectorParser(portal string, rules map[Field][]Rule, photos []Rule) (*SelectorParser, error) {
+	if strings.TrimSpace(portal) == "" {
+		return nil, fmt.Errorf("missing portal: %w", custerrors.ErrInvalidInput)
+	}
+
+	compiled := make(map[Field][]Rule, len(rules))
+	for f, rs := range rules {
+		if !slices.Contains(Fields, f) {
+			return nil, fmt.Errorf("unknown field %q: %w", f, custerrors.ErrInvalidInput)
+		}
+
+		for _, r := range rs {
+			c, err := r.Compile()
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", f, err)
+			}
+			compiled[f] = append(compiled[f], c)
+		}
+	}
+
+	p := NewSelectorParser(portal, compiled)
+	for _, r := range photos {
+		c, err := r.Compile()
+		if err != nil {
+			return nil, fmt.Errorf("photos: %w", err)
+		}
+		p.photos = append(p.photos, c)
+	}
+
+	return p, nil
+}
+
+// WithPhotos sets the rules for the gallery images of a listing. Rules are
+// tried in order until one matches any image, all of its matches are used.
+func (p *SelectorParser) WithPhotos(rules ...Rule) *SelectorParser {
+	p.photos = rules
+	return p
+}
+
+func (p *SelectorParser) Portal() string {
+	return p.portal
+}
+
+func (p *SelectorParser) Parse(e *colly.HTMLElement) Result {
+	res := newResult()
+
+	for _, f := range Fields {
+		for _, r := range p.rules[f] {
+			v := r.apply(e.DOM)
+			if v != "" {
+				res.set(f, v, r.source())
+				break
+			}
+		}
+	}
+
+	for _, r := range p.photos {
+		res.Photos = r.applyAll(e.DOM)
+		if len(res.Photos) > 0 {
+			break
+		}
+	}
+
+	return res
+}
+
+// source describes the rule as the source of a value.
+func (r Rule) source() Source {
+	if r.XPath != "" {
+		return Source{Strategy: StrategyXPath, Detail: r.XPath}
+	}
+
+	return Source{Strategy: StrategyCSS, Detail: r.Selector}
+}
+
+// apply runs the rule against root and returns the extracted value.
+func (r Rule) apply(root *goquery.Selection) string {
+	var out string
+
+	r.each(root, func(v string) bool {
+		v = cleanText(v)
+
+		if r.Label != "" {
+			rest, ok := strings.CutPrefix(v, r.Label)
+			if !ok {
+				return true
+			}
+			v = strings.TrimSpace(strings.TrimLeft(rest, ": "))
+		}
+
+		out = r.match(v)
+		return out == ""
+	})
+
+	return out
+}
+
+// applyAll runs the rule against root and returns the non-empty values of all matches.
+func (r Rule) applyAll(root *goquery.Selection) []string {
+	var out []string
+
+	r.each(root, func(v string) bool {
+		if v = r.match(cleanText(v)); v != "" {
+			out = append(out, v)
+		}
+		return true
+	})
+
+	return out
+}
+
+// each calls fn with the attribute or the text of the elements matching
+// the rule below root, until fn returns false.
+func (r Rule) each(root *goquery.Selection, fn func(v string) bool) {
+	if r.XPath == "" {
+		root.Find(r.Selector).EachWithBreak(func(_ int, s *goquery.Selection) bool {
+			if r.Attr != "" {
+				v, _ := s.Attr(r.Attr)
+				return fn(v)
+			}
+			return fn(s.Text())
+		})
+		return
+	}
+
+	expr := r.xpath
+	if expr == nil {
+		// Rules built in code are not compiled.
+		var err error
+		if expr, err = xpath.Compile(r.XPath); err != nil {
+			return
+		}
+	}
+
+	for _, top := range root.Nodes {
+		for _, n := range htmlquery.QuerySelectorAll(top, expr) {
+			v := htmlquery.InnerText(n)
+			if r.Attr != "" {
+				v = htmlquery.SelectAttr(n, r.Attr)
+			}
+			if !fn(v) {
+				return
+			}
+		}
+	}
+}
+
+// match applies the pattern of the rule to v. It returns the first capture
+// group or the whole match, and an empty string if v does not match.
+func (r Rule) match(v string) string {
+	if r.Pattern == "" || v == "" {
+		return v
+	}
+
+	re := r.pattern
+	if re == nil {
+		var err error
+		if re, err = regexp.Compile(r.Pattern); err != nil {
+			return ""
+		}
+	}
+
+	m := re.FindStringSubmatch(v)
+	switch {
+	case m == nil:
+		return ""
+	case len(m) > 1:
+		return strings.TrimSpace(m[1])
+	}
+
+	return m[0]
+}
+
+// cleanText collapses runs of whitespace and trims the result.
+func cleanText(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
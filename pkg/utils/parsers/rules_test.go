@@ -0,0 +1,239 @@
+package parsers
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/gocolly/colly/v2"
+
+	"hestia/pkg/custerrors"
+)
+
+func Test_SelectorParser_Parse_Rules(t *testing.T) {
+	body, err := os.ReadFile("testdata/olx.html")
+	if err != nil {
+		t.Fatalf("failed to read fixture: %v", err)
+	}
+
+	tests := map[string]struct {
+		field Field
+		rules []Rule
+		want  string
+		// wantSource is the source of the value, none if it is empty.
+		wantSource Source
+	}{
+		"ok, xpath text": {
+			field:      FieldTitle,
+			rules:      []Rule{{XPath: `//h4[@data-cy="ad_title"]`}},
+			want:       "Kawalerka Krowodrza, blisko AGH",
+			wantSource: Source{Strategy: StrategyXPath, Detail: `//h4[@data-cy="ad_title"]`},
+		},
+		"ok, xpath attribute": {
+			field:      FieldTitle,
+			rules:      []Rule{{XPath: `//html`, Attr: "lang"}},
+			want:       "pl",
+			wantSource: Source{Strategy: StrategyXPath, Detail: `//html`},
+		},
+		"ok, pattern capture group": {
+			field:      FieldPrice,
+			rules:      []Rule{{Selector: `[data-testid="ad-price-container"] h3`, Pattern: `([\d ]+) zł`}},
+			want:       "2 100",
+			wantSource: Source{Strategy: StrategyCSS, Detail: `[data-testid="ad-price-container"] h3`},
+		},
+		"ok, pattern whole match after label": {
+			field:      FieldSurface,
+			rules:      []Rule{{XPath: `//li/p`, Label: "Powierzchnia", Pattern: `\d+`}},
+			want:       "28",
+			wantSource: Source{Strategy: StrategyXPath, Detail: `//li/p`},
+		},
+		"ok, pattern skips elements": {
+			field:      FieldRent,
+			rules:      []Rule{{Selector: "li p", Pattern: `^Czynsz.*?(\d+) zł$`}},
+			want:       "450",
+			wantSource: Source{Strategy: StrategyCSS, Detail: "li p"},
+		},
+		"ok, next rule when pattern does not match": {
+			field:      FieldFloor,
+			rules:      []Rule{{Selector: "li p", Label: "Poziom", Pattern: `\d+`}, {Selector: "li p", Label: "Poziom"}},
+			want:       "parter",
+			wantSource: Source{Strategy: StrategyCSS, Detail: "li p"},
+		},
+		"ok, nothing matches": {
+			field: FieldDeposit,
+			rules: []Rule{{XPath: `//table//td`}},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			p, err := CompileSelectorParser("olx", map[Field][]Rule{tc.field: tc.rules}, nil)
+			if err != nil {
+				t.Fatalf("failed to compile rules: %v", err)
+			}
+
+			res, err := parsePage(Page{URL: "https://www.olx.pl/d/oferta/kawalerka.html", StatusCode: 200, Body: body}, p)
+			if err != nil {
+				t.Fatalf("failed to parse: %v", err)
+			}
+
+			if got := *tc.field.Ptr(&res.Flat); got != tc.want {
+				t.Errorf("got %q want %q", got, tc.want)
+			}
+			if got := res.Sources[tc.field]; got != tc.wantSource {
+				t.Errorf("got source %+v want %+v", got, tc.wantSource)
+			}
+		})
+	}
+}
+
+func Test_SelectorParser_Parse_PhotoRules(t *testing.T) {
+	body, err := os.ReadFile("testdata/olx.html")
+	if err != nil {
+		t.Fatalf("failed to read fixture: %v", err)
+	}
+
+	p, err := CompileSelectorParser("olx", nil, []Rule{
+		{XPath: `//img[@data-testid="swiper-image"]/@src`, Pattern: `^https?://.*`},
+	})
+	if err != nil {
+		t.Fatalf("failed to compile rules: %v", err)
+	}
+
+	res, err := parsePage(Page{URL: "https://www.olx.pl/d/oferta/kawalerka.html", StatusCode: 200, Body: body}, p)
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+
+	want := []string{"https://cdn.olx.example/img/2.jpg"}
+	if !reflect.DeepEqual(res.Photos, want) {
+		t.Errorf("got photos %v want %v", res.Photos, want)
+	}
+}
+
+func Test_CompileSelectorParser(t *testing.T) {
+	tests := map[string]struct {
+		portal  string
+		rules   map[Field][]Rule
+		photos  []Rule
+		wantErr error
+	}{
+		"ok, css and xpath": {
+			portal: "olx",
+			rules: map[Field][]Rule{
+				FieldTitle: {{Selector: "h1"}, {XPath: "//h4"}},
+				FieldPrice: {{Selector: "h3", Pattern: `(\d+)`}},
+			},
+			photos: []Rule{{XPath: "//img/@src"}},
+		},
+		"fail, missing portal":        {rules: map[Field][]Rule{FieldTitle: {{Selector: "h1"}}}, wantErr: custerrors.ErrInvalidInput},
+		"fail, unknown field":         {portal: "olx", rules: map[Field][]Rule{"colour": {{Selector: "h1"}}}, wantErr: custerrors.ErrInvalidInput},
+		"fail, no selector":           {portal: "olx", rules: map[Field][]Rule{FieldTitle: {{Attr: "content"}}}, wantErr: custerrors.ErrInvalidInput},
+		"fail, selector and xpath":    {portal: "olx", rules: map[Field][]Rule{FieldTitle: {{Selector: "h1", XPath: "//h1"}}}, wantErr: custerrors.ErrInvalidInput},
+		"fail, invalid selector":      {portal: "olx", rules: map[Field][]Rule{FieldTitle: {{Selector: "h1["}}}, wantErr: custerrors.ErrInvalidInput},
+		"fail, invalid xpath":         {portal: "olx", rules: map[Field][]Rule{FieldTitle: {{XPath: "//h1["}}}, wantErr: custerrors.ErrInvalidInput},
+		"fail, invalid pattern":       {portal: "olx", rules: map[Field][]Rule{FieldTitle: {{Selector: "h1", Pattern: "(\\d"}}}, wantErr: custerrors.ErrInvalidInput},
+		"fail, invalid photo rule":    {portal: "olx", photos: []Rule{{XPath: "//img/@"}}, wantErr: custerrors.ErrInvalidInput},
+		"fail, invalid fallback rule": {portal: "olx", rules: map[Field][]Rule{FieldTitle: {{Selector: "h1"}, {}}}, wantErr: custerrors.ErrInvalidInput},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			p, err := CompileSelectorParser(tc.portal, tc.rules, tc.photos)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("expected errors to be %v got %v (via errors.Is)", tc.wantErr, err)
+			}
+			if tc.wantErr != nil {
+				return
+			}
+
+			if p.Portal() != tc.portal {
+				t.Errorf("got portal %q want %q", p.Portal(), tc.portal)
+			}
+		})
+	}
+}
+
+func Test_Collector_Override(t *testing.T) {
+	body, err := os.ReadFile("testdata/olx.html")
+	if err != nil {
+		t.Fatalf("failed to read fixture: %v", err)
+	}
+	page := Page{URL: "https://www.olx.pl/d/oferta/kawalerka.html", StatusCode: 200, Body: body}
+
+	registry := NewRegistry()
+	registry.Register(WithSearch(OLX(), OLXSearch()), "olx.pl")
+	registry.Register(Gratka(), "gratka.pl")
+	c := NewCollector(colly.NewCollector(), registry, func(err error) {})
+
+	draft, err := CompileSelectorParser("olx", map[Field][]Rule{FieldTitle: {{XPath: "//title"}}}, nil)
+	if err != nil {
+		t.Fatalf("failed to compile rules: %v", err)
+	}
+
+	t.Run("ok, parse with draft", func(t *testing.T) {
+		res, err := c.ParsePageWith(page, draft)
+		if err != nil {
+			t.Fatalf("failed to parse: %v", err)
+		}
+		if want := "Kawalerka Kraków Krowodrza • OLX.pl"; res.Flat.Title != want {
+			t.Errorf("got title %q want %q", res.Flat.Title, want)
+		}
+
+		// The draft is not used for other pages.
+		res, err = c.ParsePage(page)
+		if err != nil {
+			t.Fatalf("failed to parse: %v", err)
+		}
+		if want := "Kawalerka Krowodrza, blisko AGH"; res.Flat.Title != want {
+			t.Errorf("got title %q want %q", res.Flat.Title, want)
+		}
+	})
+
+	t.Run("fail, draft of another portal", func(t *testing.T) {
+		other, err := CompileSelectorParser("gratka", map[Field][]Rule{FieldTitle: {{Selector: "h1"}}}, nil)
+		if err != nil {
+			t.Fatalf("failed to compile rules: %v", err)
+		}
+
+		_, err = c.ParsePageWith(page, other)
+		if !errors.Is(err, custerrors.ErrInvalidInput) {
+			t.Fatalf("expected errors to be %v got %v (via errors.Is)", custerrors.ErrInvalidInput, err)
+		}
+	})
+
+	t.Run("ok, override and restore", func(t *testing.T) {
+		c.Override("olx", draft)
+
+		p, err := registry.Lookup(page.URL)
+		if err != nil {
+			t.Fatalf("failed to look up parser: %v", err)
+		}
+		if _, ok := p.(SearchParser); !ok {
+			t.Errorf("expected the search rules of the portal to be kept")
+		}
+
+		res, err := c.ParsePage(page)
+		if err != nil {
+			t.Fatalf("failed to parse: %v", err)
+		}
+		if want := "Kawalerka Kraków Krowodrza • OLX.pl"; res.Flat.Title != want {
+			t.Errorf("got title %q want %q", res.Flat.Title, want)
+		}
+
+		c.Override("olx", nil)
+
+		res, err = c.ParsePage(page)
+		if err != nil {
+			t.Fatalf("failed to parse: %v", err)
+		}
+		if want := "Kawalerka Krowodrza, blisko AGH"; res.Flat.Title != want {
+			t.Errorf("got title %q want %q", res.Flat.Title, want)
+		}
+	})
+
+	if got, want := c.Portals(), []string{"gratka", "olx"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got portals %v want %v", got, want)
+	}
+}
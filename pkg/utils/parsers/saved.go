@@ -0,0 +1,136 @@
+package parsers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/mail"
+	"path"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html/charset"
+
+	"hestia/pkg/custerrors"
+)
+
+// ReadSavedPage reads a listing page saved in the browser, either as plain
+// HTML or as an MHTML archive, into a Page with a UTF-8 body. name is the
+// file name, used to tell the formats apart along with the content. The URL
+// of the page is taken from the archive and is empty for plain HTML.
+func ReadSavedPage(name string, data []byte) (Page, error) {
+	page := Page{
+		StatusCode:  http.StatusOK,
+		ContentType: "text/html; charset=utf-8",
+		FetchedAt:   time.Now(),
+	}
+
+	contentType := "text/html"
+	if isMHTML(name, data) {
+		var err error
+		page.URL, contentType, data, err = readMHTML(data)
+		if err != nil {
+			return Page{}, fmt.Errorf("invalid mhtml file %s: %v: %w", name, err, custerrors.ErrInvalidInput)
+		}
+		page.FinalURL = page.URL
+	}
+
+	body, err := toUTF8(data, contentType)
+	if err != nil {
+		return Page{}, fmt.Errorf("failed to decode %s: %w", name, err)
+	}
+	page.Body = body
+
+	return page, nil
+}
+
+// isMHTML reports whether the file name with the content data is an MHTML archive.
+func isMHTML(name string, data []byte) bool {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".mhtml", ".mht":
+		return true
+	case ".html", ".htm":
+		return false
+	}
+
+	// Archives start with MIME headers, pages with markup.
+	head := bytes.TrimSpace(data[:min(len(data), 1024)])
+	return !bytes.HasPrefix(head, []byte("<")) && bytes.Contains(bytes.ToLower(head), []byte("multipart/related"))
+}
+
+// readMHTML returns the URL, the content type and the body of the first
+// HTML document in the MHTML archive data.
+func readMHTML(data []byte) (string, string, []byte, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	location := msg.Header.Get("Snapshot-Content-Location")
+	if !strings.HasPrefix(mediaType, "multipart/") {
+		if !strings.HasPrefix(mediaType, "text/html") {
+			return "", "", nil, fmt.Errorf("unexpected content type %s", mediaType)
+		}
+
+		body, err := decodePart(msg.Body, msg.Header.Get("Content-Transfer-Encoding"))
+		return location, msg.Header.Get("Content-Type"), body, err
+	}
+
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if errors.Is(err, io.EOF) {
+			return "", "", nil, errors.New("no html document found")
+		}
+		if err != nil {
+			return "", "", nil, err
+		}
+
+		partType := part.Header.Get("Content-Type")
+		if !strings.HasPrefix(strings.ToLower(partType), "text/html") {
+			continue
+		}
+
+		// Quoted-printable parts are decoded by the multipart reader.
+		body, err := decodePart(part, part.Header.Get("Content-Transfer-Encoding"))
+		if err != nil {
+			return "", "", nil, err
+		}
+
+		if location == "" {
+			location = part.Header.Get("Content-Location")
+		}
+
+		return location, partType, body, nil
+	}
+}
+
+// decodePart reads r, decoding the transfer encoding enc.
+func decodePart(r io.Reader, enc string) ([]byte, error) {
+	if strings.EqualFold(strings.TrimSpace(enc), "base64") {
+		r = base64.NewDecoder(base64.StdEncoding, r)
+	}
+
+	return io.ReadAll(r)
+}
+
+// toUTF8 converts data to UTF-8, using the charset in contentType or the
+// one declared in the page.
+func toUTF8(data []byte, contentType string) ([]byte, error) {
+	r, err := charset.NewReader(bytes.NewReader(data), contentType)
+	if err != nil {
+		return nil, err
+	}
+
+	return io.ReadAll(r)
+}
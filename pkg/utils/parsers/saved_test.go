@@ -0,0 +1,101 @@
+package parsers
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"hestia/pkg/custerrors"
+)
+
+const savedMHTML = "From: <Saved by Blink>\r\n" +
+	"Snapshot-Content-Location: https://www.olx.pl/d/oferta/kawalerka-CID3-ID1.html\r\n" +
+	"Subject: Kawalerka\r\n" +
+	"MIME-Version: 1.0\r\n" +
+	"Content-Type: multipart/related;\r\n" +
+	"\ttype=\"text/html\";\r\n" +
+	"\tboundary=\"----MultipartBoundary--abc----\"\r\n" +
+	"\r\n" +
+	"------MultipartBoundary--abc----\r\n" +
+	"Content-Type: text/css\r\n" +
+	"Content-Location: https://www.olx.pl/style.css\r\n" +
+	"\r\n" +
+	"body { color: red; }\r\n" +
+	"------MultipartBoundary--abc----\r\n" +
+	"Content-Type: text/html\r\n" +
+	"Content-ID: <frame-1@mhtml.blink>\r\n" +
+	"Content-Transfer-Encoding: quoted-printable\r\n" +
+	"Content-Location: https://www.olx.pl/d/oferta/kawalerka-CID3-ID1.html\r\n" +
+	"\r\n" +
+	"<html><head><meta charset=3D\"utf-8\"></head><body><h1>Kawalerka Krowodrza=\r\n" +
+	", blisko AGH</h1></body></html>\r\n" +
+	"------MultipartBoundary--abc------\r\n"
+
+const savedMHTMLBase64 = "MIME-Version: 1.0\r\n" +
+	"Content-Type: multipart/related; boundary=\"b\"\r\n" +
+	"\r\n" +
+	"--b\r\n" +
+	"Content-Type: text/html; charset=utf-8\r\n" +
+	"Content-Transfer-Encoding: base64\r\n" +
+	"Content-Location: https://gratka.pl/nieruchomosci/mieszkanie/ob/1\r\n" +
+	"\r\n" +
+	"PGh0bWw+PGJvZHk+PGgxPk1p\r\n" +
+	"ZXN6a2FuaWU8L2gxPjwvYm9keT48L2h0bWw+\r\n" +
+	"--b--\r\n"
+
+func Test_ReadSavedPage(t *testing.T) {
+	tests := map[string]struct {
+		name     string
+		data     string
+		wantURL  string
+		wantBody string
+		wantErr  error
+	}{
+		"ok, html": {
+			name:     "listing.html",
+			data:     "<html><body><h1>Kawalerka</h1></body></html>",
+			wantBody: "<h1>Kawalerka</h1>",
+		},
+		"ok, html in windows-1250": {
+			name:     "listing.htm",
+			data:     "<html><head><meta charset=\"windows-1250\"></head><body><h1>Kawalerka \xb3adna</h1></body></html>",
+			wantBody: "<h1>Kawalerka ładna</h1>",
+		},
+		"ok, mhtml quoted-printable": {
+			name:     "listing.mhtml",
+			data:     savedMHTML,
+			wantURL:  "https://www.olx.pl/d/oferta/kawalerka-CID3-ID1.html",
+			wantBody: "<h1>Kawalerka Krowodrza, blisko AGH</h1>",
+		},
+		"ok, mhtml base64 without extension": {
+			name:     "upload",
+			data:     savedMHTMLBase64,
+			wantURL:  "https://gratka.pl/nieruchomosci/mieszkanie/ob/1",
+			wantBody: "<h1>Mieszkanie</h1>",
+		},
+		"fail, mhtml without html": {
+			name:    "listing.mht",
+			data:    "MIME-Version: 1.0\r\nContent-Type: multipart/related; boundary=\"b\"\r\n\r\n--b\r\nContent-Type: text/css\r\n\r\nbody {}\r\n--b--\r\n",
+			wantErr: custerrors.ErrInvalidInput,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			page, err := ReadSavedPage(tc.name, []byte(tc.data))
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("expected errors to be %v got %v (via errors.Is)", tc.wantErr, err)
+			}
+			if tc.wantErr != nil {
+				return
+			}
+
+			if page.URL != tc.wantURL {
+				t.Errorf("got url %q want %q", page.URL, tc.wantURL)
+			}
+			if !strings.Contains(string(page.Body), tc.wantBody) {
+				t.Errorf("got body %q want it to contain %q", page.Body, tc.wantBody)
+			}
+		})
+	}
+}
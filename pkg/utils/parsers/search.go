@@ -0,0 +1,198 @@
+package parsers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/gocolly/colly/v2"
+
+	"hestia/pkg/custerrors"
+)
+
+// ErrSearchUnsupported is returned when crawling search results of a portal
+// without search rules.
+var ErrSearchUnsupported = errors.New("search results not supported")
+
+// SearchRules describe how to read the search results pages of a portal.
+type SearchRules struct {
+	// Listing is a CSS selector matching the links to the listings.
+	Listing string
+	// Next is a CSS selector matching the link to the next page.
+	Next string
+	// PageParam is the query parameter holding the page number. It is
+	// incremented to get the next page when the page has no Next link.
+	PageParam string
+}
+
+// SearchPage holds the links found on a search results page.
+type SearchPage struct {
+	// Listings are the absolute, canonical URLs of the listings.
+	Listings []string
+	// Next is the absolute URL of the next page, if any.
+	Next string
+}
+
+// SearchParser is a Parser that can also read search results pages.
+type SearchParser interface {
+	Parser
+	// ParseSearch extracts the links from the root element of a search results page.
+	ParseSearch(e *colly.HTMLElement) SearchPage
+}
+
+type searchParser struct {
+	Parser
+	rules SearchRules
+}
+
+// WithSearch adds the search rules of a portal to p.
+func WithSearch(p Parser, rules SearchRules) SearchParser {
+	return &searchParser{
+		Parser: p,
+		rules:  rules,
+	}
+}
+
+func (p *searchParser) ParseSearch(e *colly.HTMLElement) SearchPage {
+	var page SearchPage
+
+	e.DOM.Find(p.rules.Listing).Each(func(_ int, s *goquery.Selection) {
+		href, ok := s.Attr("href")
+		if !ok {
+			return
+		}
+
+		if u := CanonicalURL(e.Request.AbsoluteURL(href)); u != "" {
+			page.Listings = append(page.Listings, u)
+		}
+	})
+
+	if p.rules.Next != "" {
+		href, ok := e.DOM.Find(p.rules.Next).First().Attr("href")
+		if ok {
+			page.Next = e.Request.AbsoluteURL(href)
+		}
+	}
+
+	if page.Next == "" && p.rules.PageParam != "" && len(page.Listings) > 0 {
+		page.Next = nextPage(e.Request.URL, p.rules.PageParam)
+	}
+
+	return page
+}
+
+// nextPage returns u with the page number in param incremented.
+func nextPage(u *url.URL, param string) string {
+	q := u.Query()
+
+	n, err := strconv.Atoi(q.Get(param))
+	if err != nil || n < 1 {
+		n = 1
+	}
+	q.Set(param, strconv.Itoa(n+1))
+
+	next := *u
+	next.RawQuery = q.Encode()
+
+	return next.String()
+}
+
+// CanonicalURL strips the query and fragment from a listing URL, which on
+// the supported portals only carry tracking data. It returns an empty string
+// if raw is not an absolute http(s) URL.
+func CanonicalURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ""
+	}
+
+	u.RawQuery = ""
+	u.Fragment = ""
+	u.RawFragment = ""
+
+	return u.String()
+}
+
+// CrawlLimits bound the number of search results pages visited and listings found by Crawl.
+type CrawlLimits struct {
+	MaxPages    int
+	MaxListings int
+}
+
+// Crawl visits the search results page at url and the pages following it and
+// calls found for each listing, skipping listings that were already found.
+// It stops at the limits, on a page without new listings, when found returns
+// an error or when ctx is done, and returns the number of pages visited.
+func (c *Collector) Crawl(ctx context.Context, url string, limits CrawlLimits, found func(listing string) error) (int, error) {
+	p, err := c.registry.Lookup(url)
+	if err != nil {
+		c.errHandler(err)
+		return 0, err
+	}
+
+	sp, ok := p.(SearchParser)
+	if !ok {
+		return 0, fmt.Errorf("%w for %s: %w", ErrSearchUnsupported, p.Portal(), custerrors.ErrInvalidInput)
+	}
+
+	cc, done := c.session(ctx)
+	defer done()
+
+	var page SearchPage
+	cc.OnHTML("html", func(e *colly.HTMLElement) {
+		page = sp.ParseSearch(e)
+	})
+
+	var (
+		pages    int
+		listings int
+		seen     = make(map[string]bool)
+		visited  = make(map[string]bool)
+	)
+
+	for next := url; next != "" && !visited[next] && pages < limits.MaxPages && listings < limits.MaxListings; next = page.Next {
+		err := ctx.Err()
+		if err != nil {
+			return pages, err
+		}
+
+		page = SearchPage{}
+		visited[next] = true
+
+		err = cc.visit(next)
+		if err != nil {
+			c.errHandler(err)
+			return pages, err
+		}
+		pages++
+
+		fresh := 0
+		for _, l := range page.Listings {
+			if seen[l] {
+				continue
+			}
+			seen[l] = true
+			fresh++
+
+			if listings >= limits.MaxListings {
+				return pages, nil
+			}
+			listings++
+
+			err := found(l)
+			if err != nil {
+				return pages, err
+			}
+		}
+
+		// Some portals answer page numbers past the end with the last page.
+		if fresh == 0 {
+			break
+		}
+	}
+
+	return pages, nil
+}
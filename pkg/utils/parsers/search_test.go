@@ -0,0 +1,112 @@
+package parsers
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/gocolly/colly/v2"
+
+	"hestia/pkg/custerrors"
+)
+
+func Test_Collector_Crawl(t *testing.T) {
+	tests := map[string]struct {
+		parser    Parser
+		fixture   string
+		limits    CrawlLimits
+		cancel    bool
+		want      []string
+		wantPages int
+		wantErr   error
+	}{
+		"ok, follows next link": {
+			parser:    WithSearch(OLX(), OLXSearch()),
+			fixture:   "olx_search_1.html",
+			limits:    CrawlLimits{MaxPages: 10, MaxListings: 100},
+			want:      []string{"/d/oferta/kawalerka-krowodrza-CID3-ID1.html", "/d/oferta/2-pokoje-podgorze-CID3-ID2.html", "/d/oferta/3-pokoje-nowa-huta-CID3-ID4.html"},
+			wantPages: 2,
+		},
+		"ok, listing limit": {
+			parser:    WithSearch(OLX(), OLXSearch()),
+			fixture:   "olx_search_1.html",
+			limits:    CrawlLimits{MaxPages: 10, MaxListings: 1},
+			want:      []string{"/d/oferta/kawalerka-krowodrza-CID3-ID1.html"},
+			wantPages: 1,
+		},
+		"ok, page limit": {
+			parser:    WithSearch(OLX(), OLXSearch()),
+			fixture:   "olx_search_1.html",
+			limits:    CrawlLimits{MaxPages: 1, MaxListings: 100},
+			want:      []string{"/d/oferta/kawalerka-krowodrza-CID3-ID1.html", "/d/oferta/2-pokoje-podgorze-CID3-ID2.html"},
+			wantPages: 1,
+		},
+		"ok, page parameter stops without new listings": {
+			parser:    WithSearch(Otodom(), OtodomSearch()),
+			fixture:   "otodom_search.html",
+			limits:    CrawlLimits{MaxPages: 10, MaxListings: 100},
+			want:      []string{"/pl/oferta/mieszkanie-2-pokojowe-mokotow-ID4aB1", "/pl/oferta/kawalerka-wola-ID4aB2"},
+			wantPages: 2,
+		},
+		"fail, portal without search rules": {
+			parser:  OLX(),
+			fixture: "olx_search_1.html",
+			limits:  CrawlLimits{MaxPages: 10, MaxListings: 100},
+			wantErr: custerrors.ErrInvalidInput,
+		},
+		"fail, context canceled": {
+			parser:  WithSearch(OLX(), OLXSearch()),
+			fixture: "olx_search_1.html",
+			limits:  CrawlLimits{MaxPages: 10, MaxListings: 100},
+			cancel:  true,
+			wantErr: context.Canceled,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			srv := fixtureServer(t)
+
+			registry := NewRegistry()
+			registry.Register(tc.parser, hostOf(t, srv.URL))
+
+			c := NewCollector(colly.NewCollector(), registry, func(err error) {})
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tc.cancel {
+				cancel()
+			}
+
+			var got []string
+			pages, err := c.Crawl(ctx, srv.URL+"/"+tc.fixture, tc.limits, func(listing string) error {
+				got = append(got, listing)
+				return nil
+			})
+			if tc.wantErr != nil {
+				if !errors.Is(err, tc.wantErr) {
+					t.Fatalf("expected errors to be %v got %v (via errors.Is)", tc.wantErr, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("failed to crawl: %v", err)
+			}
+
+			want := make([]string, 0, len(tc.want))
+			for _, p := range tc.want {
+				want = append(want, srv.URL+p)
+			}
+
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got\n%#v\nwant\n%#v\n", got, want)
+			}
+
+			if pages != tc.wantPages {
+				t.Errorf("got %d pages want %d", pages, tc.wantPages)
+			}
+		})
+	}
+}
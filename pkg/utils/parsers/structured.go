@@ -0,0 +1,397 @@
+package parsers
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// jsonLDTypes are the schema.org types that describe a listing or the flat itself.
+var jsonLDTypes = map[string]bool{
+	"Accommodation":         true,
+	"Apartment":             true,
+	"House":                 true,
+	"Offer":                 true,
+	"Product":               true,
+	"RealEstateListing":     true,
+	"Residence":             true,
+	"SingleFamilyResidence": true,
+}
+
+// jsonLDNested are the properties that may hold the description of the flat
+// inside a listing node, e.g. Offer.itemOffered.
+var jsonLDNested = []string{"itemOffered", "mainEntity", "about"}
+
+// nextDataAdKeys are the keys under props.pageProps that hold the listing.
+var nextDataAdKeys = []string{"ad", "offer", "advert", "listing"}
+
+// nextDataImageKeys are the keys of the listing that hold its gallery.
+var nextDataImageKeys = []string{"images", "photos"}
+
+// nextDataImageSizes are the keys of an image object holding its URL, largest first.
+var nextDataImageSizes = []string{"large", "url", "src", "medium", "small"}
+
+// nextDataCharacteristics maps the characteristic keys used in __NEXT_DATA__
+// payloads to flat fields.
+var nextDataCharacteristics = map[string]Field{
+	"price":     FieldPrice,
+	"m":         FieldSurface,
+	"area":      FieldSurface,
+	"rooms_num": FieldRooms,
+	"floor_no":  FieldFloor,
+	"floor":     FieldFloor,
+	"rent":      FieldRent,
+	"deposit":   FieldDeposit,
+	"free_from": FieldAvailableFrom,
+}
+
+// parseStructured extracts the flat from the structured data embedded in the page.
+// JSON-LD takes precedence over __NEXT_DATA__.
+func parseStructured(root *goquery.Selection) Result {
+	res := newResult()
+	res.merge(parseJSONLD(root))
+	res.merge(parseNextData(root))
+
+	return res
+}
+
+// parseJSONLD extracts the flat from schema.org JSON-LD blocks.
+func parseJSONLD(root *goquery.Selection) Result {
+	res := newResult()
+
+	root.Find(`script[type="application/ld+json"]`).Each(func(_ int, s *goquery.Selection) {
+		var v any
+		err := json.Unmarshal([]byte(s.Text()), &v)
+		if err != nil {
+			return
+		}
+
+		for _, n := range jsonLDNodes(v) {
+			extractJSONLD(&res, n, "")
+		}
+	})
+
+	return res
+}
+
+// jsonLDNodes returns the relevant top level nodes of a JSON-LD document,
+// unwrapping arrays and @graph containers.
+func jsonLDNodes(v any) []map[string]any {
+	var out []map[string]any
+
+	switch t := v.(type) {
+	case []any:
+		for _, e := range t {
+			out = append(out, jsonLDNodes(e)...)
+		}
+	case map[string]any:
+		if g, ok := t["@graph"]; ok {
+			return jsonLDNodes(g)
+		}
+		if isJSONLDType(t) {
+			out = append(out, t)
+		}
+	}
+
+	return out
+}
+
+func isJSONLDType(n map[string]any) bool {
+	switch t := n["@type"].(type) {
+	case string:
+		return jsonLDTypes[t]
+	case []any:
+		for _, e := range t {
+			if s, ok := e.(string); ok && jsonLDTypes[s] {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func extractJSONLD(res *Result, n map[string]any, path string) {
+	src := func(prop string) Source {
+		return Source{Strategy: StrategyJSONLD, Detail: path + prop}
+	}
+
+	res.set(FieldTitle, scalar(n["name"]), src("name"))
+	res.set(FieldDescription, scalar(n["description"]), src("description"))
+	res.set(FieldAddress, jsonLDAddress(n["address"]), src("address"))
+	res.set(FieldSurface, jsonLDQuantity(n["floorSize"]), src("floorSize"))
+	res.set(FieldRooms, scalar(n["numberOfRooms"]), src("numberOfRooms"))
+	res.set(FieldFloor, scalar(n["floorLevel"]), src("floorLevel"))
+	res.set(FieldPrice, jsonLDPrice(n), src("price"))
+	res.set(FieldAvailableFrom, scalar(n["availabilityStarts"]), src("availabilityStarts"))
+	if len(res.Photos) == 0 {
+		res.Photos = jsonLDImages(n["image"])
+	}
+
+	for i, o := range asSlice(n["offers"]) {
+		offer, ok := o.(map[string]any)
+		if !ok {
+			continue
+		}
+
+		offerPath := path + "offers."
+		if _, isSlice := n["offers"].([]any); isSlice {
+			offerPath = path + "offers[" + strconv.Itoa(i) + "]."
+		}
+		extractJSONLD(res, offer, offerPath)
+	}
+
+	for _, key := range jsonLDNested {
+		nested, ok := n[key].(map[string]any)
+		if !ok {
+			continue
+		}
+		extractJSONLD(res, nested, path+key+".")
+	}
+}
+
+func jsonLDPrice(n map[string]any) string {
+	price := scalar(n["price"])
+	currency := scalar(n["priceCurrency"])
+
+	if price == "" {
+		spec, ok := n["priceSpecification"].(map[string]any)
+		if !ok {
+			return ""
+		}
+		price = scalar(spec["price"])
+		currency = scalar(spec["priceCurrency"])
+	}
+
+	if price == "" || currency == "" {
+		return price
+	}
+
+	return price + " " + currency
+}
+
+func jsonLDAddress(v any) string {
+	switch t := v.(type) {
+	case string:
+		return cleanText(t)
+	case map[string]any:
+		return joinNonEmpty(
+			scalar(t["streetAddress"]),
+			scalar(t["addressLocality"]),
+			scalar(t["addressRegion"]),
+		)
+	}
+
+	return ""
+}
+
+// jsonLDImages returns the URLs of an image property, which may be a URL,
+// an ImageObject or a list of either.
+func jsonLDImages(v any) []string {
+	var out []string
+	for _, e := range asSlice(v) {
+		switch t := e.(type) {
+		case string:
+			out = append(out, cleanText(t))
+		case map[string]any:
+			if u := scalar(t["contentUrl"]); u != "" {
+				out = append(out, u)
+			} else if u := scalar(t["url"]); u != "" {
+				out = append(out, u)
+			}
+		}
+	}
+
+	return out
+}
+
+func jsonLDQuantity(v any) string {
+	q, ok := v.(map[string]any)
+	if !ok {
+		return scalar(v)
+	}
+
+	value := scalar(q["value"])
+	if value == "" {
+		return ""
+	}
+
+	unit := scalar(q["unitText"])
+	if unit == "" && scalar(q["unitCode"]) == "MTK" {
+		unit = "m²"
+	}
+
+	if unit == "" {
+		return value
+	}
+
+	return value + " " + unit
+}
+
+// parseNextData extracts the flat from the Next.js __NEXT_DATA__ payload.
+func parseNextData(root *goquery.Selection) Result {
+	res := newResult()
+
+	s := root.Find("script#__NEXT_DATA__")
+	if s.Length() == 0 {
+		return res
+	}
+
+	var v any
+	err := json.Unmarshal([]byte(s.First().Text()), &v)
+	if err != nil {
+		return res
+	}
+
+	pageProps, ok := dig(v, "props", "pageProps").(map[string]any)
+	if !ok {
+		return res
+	}
+
+	for _, key := range nextDataAdKeys {
+		ad, ok := pageProps[key].(map[string]any)
+		if !ok {
+			continue
+		}
+
+		extractNextData(&res, ad, "props.pageProps."+key+".")
+		break
+	}
+
+	return res
+}
+
+func extractNextData(res *Result, ad map[string]any, path string) {
+	src := func(prop string) Source {
+		return Source{Strategy: StrategyNextData, Detail: path + prop}
+	}
+
+	res.set(FieldTitle, scalar(ad["title"]), src("title"))
+	res.set(FieldDescription, htmlText(scalar(ad["description"])), src("description"))
+
+	for _, key := range nextDataImageKeys {
+		for _, img := range asSlice(ad[key]) {
+			if u := nextDataImage(img); u != "" {
+				res.Photos = append(res.Photos, u)
+			}
+		}
+		if len(res.Photos) > 0 {
+			break
+		}
+	}
+
+	if addr, ok := dig(ad, "location", "address").(map[string]any); ok {
+		res.set(FieldAddress, joinNonEmpty(
+			joinWords(scalar(dig(addr, "street", "name")), scalar(dig(addr, "street", "number"))),
+			scalar(dig(addr, "district", "name")),
+			scalar(dig(addr, "city", "name")),
+			scalar(dig(addr, "province", "name")),
+		), src("location.address"))
+	}
+
+	for _, c := range asSlice(ad["characteristics"]) {
+		ch, ok := c.(map[string]any)
+		if !ok {
+			continue
+		}
+
+		key := scalar(ch["key"])
+		f, ok := nextDataCharacteristics[key]
+		if !ok {
+			continue
+		}
+
+		v := scalar(ch["localizedValue"])
+		if v == "" {
+			v = joinWords(scalar(ch["value"]), scalar(ch["currency"]))
+		}
+		res.set(f, v, src("characteristics["+key+"]"))
+	}
+}
+
+// nextDataImage returns the URL of a gallery entry, which is either a URL or
+// an object with URLs of different sizes.
+func nextDataImage(v any) string {
+	m, ok := v.(map[string]any)
+	if !ok {
+		return scalar(v)
+	}
+
+	for _, size := range nextDataImageSizes {
+		if u := scalar(m[size]); u != "" {
+			return u
+		}
+	}
+
+	return ""
+}
+
+// dig walks the nested maps of v along path and returns the value found, if any.
+func dig(v any, path ...string) any {
+	for _, p := range path {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil
+		}
+		v = m[p]
+	}
+
+	return v
+}
+
+// scalar formats a JSON scalar as a string.
+func scalar(v any) string {
+	switch t := v.(type) {
+	case string:
+		return cleanText(t)
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(t)
+	}
+
+	return ""
+}
+
+func asSlice(v any) []any {
+	switch t := v.(type) {
+	case []any:
+		return t
+	case nil:
+		return nil
+	}
+
+	return []any{v}
+}
+
+// htmlText returns the text content of an HTML fragment.
+func htmlText(s string) string {
+	if !strings.Contains(s, "<") {
+		return s
+	}
+
+	// Pad tags with spaces so that text of adjacent block elements does not run together.
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(strings.ReplaceAll(s, "<", " <")))
+	if err != nil {
+		return s
+	}
+
+	return cleanText(doc.Text())
+}
+
+func joinNonEmpty(parts ...string) string {
+	out := make([]string, 0, len(parts))
+	for _, p := range parts {
+		if p != "" {
+			out = append(out, p)
+		}
+	}
+
+	return strings.Join(out, ", ")
+}
+
+func joinWords(parts ...string) string {
+	return strings.Join(strings.Fields(strings.Join(parts, " ")), " ")
+}
@@ -0,0 +1,322 @@
+package parsers
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// TransportConfig is the configuration for how the collector talks to portals.
+type TransportConfig struct {
+	// UserAgent is sent with requests that do not set their own, such as
+	// the ones for robots.txt.
+	UserAgent string
+	// MaxRetries is the number of times a request answered with 429 or a
+	// 5xx status is tried again.
+	MaxRetries int
+	// RetryBackoff is the delay before the first retry. It doubles with
+	// every further retry, a Retry-After header of the response takes precedence.
+	RetryBackoff time.Duration
+	// MaxBackoff limits the delay before a retry.
+	MaxBackoff time.Duration
+	// Proxies are used in turn for requests. Requests go out directly if empty.
+	Proxies []*url.URL
+	// CacheDir is the directory successful responses are cached in.
+	// Responses are not cached if empty.
+	CacheDir string
+	// CacheTTL is how long cached responses are used.
+	CacheTTL time.Duration
+	// MaxCacheSize is the largest body cached, in bytes.
+	MaxCacheSize int
+}
+
+// NewTransport returns a transport that sends requests as configured by
+// cfg and logs retries, cache hits and the proxies used to logger.
+func NewTransport(cfg TransportConfig, logger *slog.Logger) http.RoundTripper {
+	base := http.DefaultTransport.(*http.Transport).Clone()
+	if len(cfg.Proxies) > 0 {
+		base.Proxy = (&proxyRotation{proxies: cfg.Proxies, logger: logger}).next
+	}
+
+	var rt http.RoundTripper = &retryTransport{
+		base:       base,
+		userAgent:  cfg.UserAgent,
+		maxRetries: cfg.MaxRetries,
+		backoff:    cfg.RetryBackoff,
+		maxBackoff: cfg.MaxBackoff,
+		logger:     logger,
+	}
+
+	if cfg.CacheDir != "" && cfg.CacheTTL > 0 {
+		rt = &cacheTransport{
+			base:    rt,
+			dir:     cfg.CacheDir,
+			ttl:     cfg.CacheTTL,
+			maxSize: cfg.MaxCacheSize,
+			logger:  logger,
+		}
+	}
+
+	return rt
+}
+
+// proxyRotation hands out the proxies round-robin.
+type proxyRotation struct {
+	proxies []*url.URL
+	n       atomic.Uint64
+	logger  *slog.Logger
+}
+
+func (p *proxyRotation) next(req *http.Request) (*url.URL, error) {
+	proxy := p.proxies[(p.n.Add(1)-1)%uint64(len(p.proxies))]
+	p.logger.Debug("request via proxy", "url", req.URL.String(), "proxy", proxy.Redacted())
+
+	return proxy, nil
+}
+
+// retryTransport tries GET and HEAD requests again when the server is
+// overloaded or failing.
+type retryTransport struct {
+	base       http.RoundTripper
+	userAgent  string
+	maxRetries int
+	backoff    time.Duration
+	maxBackoff time.Duration
+	logger     *slog.Logger
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.userAgent != "" && req.Header.Get("User-Agent") == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("User-Agent", t.userAgent)
+	}
+
+	for attempt := 0; ; attempt++ {
+		resp, err := t.base.RoundTrip(req)
+		if err != nil || attempt >= t.maxRetries || !retryable(req, resp) {
+			return resp, err
+		}
+
+		wait := retryDelay(resp, t.backoff<<attempt, t.maxBackoff)
+		t.logger.Info("retrying request", "url", req.URL.String(), "status", resp.StatusCode, "attempt", attempt+1, "wait", wait)
+
+		// Drain the body so the connection can be reused.
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		resp.Body.Close()
+
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// retryable reports whether the request is safe to send again and the
+// response says it may succeed later.
+func retryable(req *http.Request, resp *http.Response) bool {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return false
+	}
+
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+}
+
+// retryDelay returns how long to wait before sending a request again. A
+// Retry-After header in seconds or as a date replaces backoff. The delay is
+// capped at maxDelay if it is positive.
+func retryDelay(resp *http.Response, backoff, maxDelay time.Duration) time.Duration {
+	wait := backoff
+	if v := resp.Header.Get("Retry-After"); v != "" {
+		if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+			wait = time.Duration(secs) * time.Second
+		} else if at, err := http.ParseTime(v); err == nil {
+			wait = max(time.Until(at), 0)
+		}
+	}
+
+	if maxDelay > 0 {
+		wait = min(wait, maxDelay)
+	}
+
+	return wait
+}
+
+// cacheTransport answers GET requests from responses stored on disk, as
+// long as they are younger than ttl. Only 200 responses are stored.
+type cacheTransport struct {
+	base    http.RoundTripper
+	dir     string
+	ttl     time.Duration
+	maxSize int
+	logger  *slog.Logger
+}
+
+func (t *cacheTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet {
+		return t.base.RoundTrip(req)
+	}
+
+	name := t.path(req.URL)
+	resp, err := t.load(name, req)
+	if err == nil {
+		t.logger.Debug("response from cache", "url", req.URL.String())
+		return resp, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		t.logger.Warn("failed to read cached response", "url", req.URL.String(), "error", err)
+	}
+
+	resp, err = t.base.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		return resp, err
+	}
+
+	// Bodies too large to cache are passed on as they are.
+	limit := int64(t.maxSize)
+	if limit <= 0 {
+		limit = 10 << 20
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	if int64(len(body)) > limit {
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+		return resp, nil
+	}
+
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	// DumpResponse puts the body back after reading it.
+	err = t.store(name, resp)
+	if err != nil {
+		t.logger.Warn("failed to cache response", "url", req.URL.String(), "error", err)
+	}
+
+	return resp, nil
+}
+
+// path returns the file the response for u is cached in.
+func (t *cacheTransport) path(u *url.URL) string {
+	sum := sha256.Sum256([]byte(u.String()))
+	name := hex.EncodeToString(sum[:])
+
+	return filepath.Join(t.dir, name[:2], name)
+}
+
+// load reads the cached response from the file name. It errors with
+// os.ErrNotExist if there is none or it expired.
+func (t *cacheTransport) load(name string, req *http.Request) (*http.Response, error) {
+	info, err := os.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	if time.Since(info.ModTime()) > t.ttl {
+		return nil, fmt.Errorf("cached response expired: %w", os.ErrNotExist)
+	}
+
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return http.ReadResponse(bufio.NewReader(bytes.NewReader(data)), req)
+}
+
+// store writes resp to the file name. The file is written to a temporary
+// file first so readers never see a partial response.
+func (t *cacheTransport) store(name string, resp *http.Response) error {
+	data, err := httputil.DumpResponse(resp, true)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(name), 0o750)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".tmp-*")
+	if err != nil {
+		return err
+	}
+
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), name)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+
+	return nil
+}
+
+// HostLimit is the rate limit of requests to a host.
+type HostLimit struct {
+	// Delay is the pause between two requests to the host.
+	Delay time.Duration
+	// Parallelism is the number of requests to the host at the same time.
+	Parallelism int
+}
+
+// ParseHostLimits parses limits for single hosts in the form
+// "host=delay:parallelism,...", e.g. "otodom.pl=2s:1,olx.pl=500ms:2".
+// The parallelism may be left out and defaults to 1.
+func ParseHostLimits(s string) (map[string]HostLimit, error) {
+	limits := make(map[string]HostLimit)
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		host, spec, ok := strings.Cut(entry, "=")
+		host = strings.ToLower(strings.TrimSpace(host))
+		if !ok || host == "" {
+			return nil, fmt.Errorf("host limit %q is not in the form host=delay:parallelism", entry)
+		}
+
+		delay, par, hasPar := strings.Cut(spec, ":")
+		d, err := time.ParseDuration(strings.TrimSpace(delay))
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid delay in host limit %q", entry)
+		}
+
+		p := 1
+		if hasPar {
+			p, err = strconv.Atoi(strings.TrimSpace(par))
+			if err != nil || p < 1 {
+				return nil, fmt.Errorf("invalid parallelism in host limit %q", entry)
+			}
+		}
+
+		limits[host] = HostLimit{Delay: d, Parallelism: p}
+	}
+
+	return limits, nil
+}
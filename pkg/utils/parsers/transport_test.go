@@ -0,0 +1,314 @@
+package parsers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gocolly/colly/v2"
+)
+
+func discardLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+func Test_retryTransport(t *testing.T) {
+	tests := map[string]struct {
+		method     string
+		statuses   []int
+		maxRetries int
+		wantStatus int
+		wantCalls  int32
+	}{
+		"ok, retried after too many requests": {
+			method:     http.MethodGet,
+			statuses:   []int{http.StatusTooManyRequests, http.StatusOK},
+			maxRetries: 2,
+			wantStatus: http.StatusOK,
+			wantCalls:  2,
+		},
+		"ok, retried after server errors": {
+			method:     http.MethodGet,
+			statuses:   []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusOK},
+			maxRetries: 2,
+			wantStatus: http.StatusOK,
+			wantCalls:  3,
+		},
+		"ok, not found not retried": {
+			method:     http.MethodGet,
+			statuses:   []int{http.StatusNotFound},
+			maxRetries: 2,
+			wantStatus: http.StatusNotFound,
+			wantCalls:  1,
+		},
+		"ok, post not retried": {
+			method:     http.MethodPost,
+			statuses:   []int{http.StatusServiceUnavailable, http.StatusOK},
+			maxRetries: 2,
+			wantStatus: http.StatusServiceUnavailable,
+			wantCalls:  1,
+		},
+		"fail, out of retries": {
+			method:     http.MethodGet,
+			statuses:   []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusOK},
+			maxRetries: 1,
+			wantStatus: http.StatusServiceUnavailable,
+			wantCalls:  2,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var calls atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := calls.Add(1)
+				w.WriteHeader(tc.statuses[min(int(n), len(tc.statuses))-1])
+			}))
+			t.Cleanup(srv.Close)
+
+			rt := NewTransport(TransportConfig{MaxRetries: tc.maxRetries, RetryBackoff: time.Millisecond}, discardLogger())
+
+			req, err := http.NewRequest(tc.method, srv.URL, nil)
+			if err != nil {
+				t.Fatalf("failed to create request: %v", err)
+			}
+
+			resp, err := rt.RoundTrip(req)
+			if err != nil {
+				t.Fatalf("failed to send request: %v", err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != tc.wantStatus {
+				t.Errorf("got status %d want %d", resp.StatusCode, tc.wantStatus)
+			}
+			if calls.Load() != tc.wantCalls {
+				t.Errorf("got %d calls want %d", calls.Load(), tc.wantCalls)
+			}
+		})
+	}
+}
+
+func Test_retryDelay(t *testing.T) {
+	tests := map[string]struct {
+		retryAfter string
+		backoff    time.Duration
+		maxDelay   time.Duration
+		want       time.Duration
+	}{
+		"ok, backoff":                 {backoff: time.Second, want: time.Second},
+		"ok, retry after seconds":     {retryAfter: "5", backoff: time.Second, want: 5 * time.Second},
+		"ok, retry after in the past": {retryAfter: "Mon, 02 Jan 2006 15:04:05 GMT", backoff: time.Second, want: 0},
+		"ok, capped":                  {retryAfter: "120", backoff: time.Second, maxDelay: 30 * time.Second, want: 30 * time.Second},
+		"ok, invalid retry after":     {retryAfter: "soon", backoff: time.Second, want: time.Second},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			resp := &http.Response{Header: http.Header{}}
+			if tc.retryAfter != "" {
+				resp.Header.Set("Retry-After", tc.retryAfter)
+			}
+
+			got := retryDelay(resp, tc.backoff, tc.maxDelay)
+			if got != tc.want {
+				t.Errorf("got %s want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func Test_cacheTransport(t *testing.T) {
+	tests := map[string]struct {
+		status    int
+		age       time.Duration
+		wantCalls int32
+	}{
+		"ok, served from cache": {
+			status:    http.StatusOK,
+			wantCalls: 1,
+		},
+		"ok, expired": {
+			status:    http.StatusOK,
+			age:       2 * time.Hour,
+			wantCalls: 2,
+		},
+		"ok, errors not cached": {
+			status:    http.StatusNotFound,
+			wantCalls: 2,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var calls atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls.Add(1)
+				w.WriteHeader(tc.status)
+				_, _ = w.Write([]byte("listing"))
+			}))
+			t.Cleanup(srv.Close)
+
+			dir := t.TempDir()
+			rt := NewTransport(TransportConfig{CacheDir: dir, CacheTTL: time.Hour}, discardLogger())
+
+			get := func() string {
+				req, err := http.NewRequest(http.MethodGet, srv.URL+"/listing", nil)
+				if err != nil {
+					t.Fatalf("failed to create request: %v", err)
+				}
+
+				resp, err := rt.RoundTrip(req)
+				if err != nil {
+					t.Fatalf("failed to send request: %v", err)
+				}
+				defer resp.Body.Close()
+
+				body, err := io.ReadAll(resp.Body)
+				if err != nil {
+					t.Fatalf("failed to read body: %v", err)
+				}
+				return string(body)
+			}
+
+			get()
+
+			if tc.age > 0 {
+				files, _ := filepath.Glob(filepath.Join(dir, "*", "*"))
+				for _, f := range files {
+					old := time.Now().Add(-tc.age)
+					err := os.Chtimes(f, old, old)
+					if err != nil {
+						t.Fatalf("failed to age cache file: %v", err)
+					}
+				}
+			}
+
+			if got := get(); got != "listing" {
+				t.Errorf("got body %q want %q", got, "listing")
+			}
+			if calls.Load() != tc.wantCalls {
+				t.Errorf("got %d calls want %d", calls.Load(), tc.wantCalls)
+			}
+		})
+	}
+}
+
+func Test_NewTransport_Proxies(t *testing.T) {
+	var got []string
+	proxy := func(name string) *url.URL {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = append(got, name+" "+r.Header.Get("User-Agent"))
+		}))
+		t.Cleanup(srv.Close)
+
+		u, err := url.Parse(srv.URL)
+		if err != nil {
+			t.Fatalf("failed to parse url: %v", err)
+		}
+		return u
+	}
+
+	rt := NewTransport(TransportConfig{
+		UserAgent: "hestia-test",
+		Proxies:   []*url.URL{proxy("first"), proxy("second")},
+	}, discardLogger())
+
+	for range 3 {
+		req, err := http.NewRequest(http.MethodGet, "http://listing.example/", nil)
+		if err != nil {
+			t.Fatalf("failed to create request: %v", err)
+		}
+
+		resp, err := rt.RoundTrip(req)
+		if err != nil {
+			t.Fatalf("failed to send request: %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	want := []string{"first hestia-test", "second hestia-test", "first hestia-test"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v want %v", got, want)
+	}
+}
+
+func Test_ParseHostLimits(t *testing.T) {
+	tests := map[string]struct {
+		in      string
+		want    map[string]HostLimit
+		wantErr bool
+	}{
+		"ok, delay and parallelism": {
+			in: "otodom.pl=2s:2, OLX.pl=500ms",
+			want: map[string]HostLimit{
+				"otodom.pl": {Delay: 2 * time.Second, Parallelism: 2},
+				"olx.pl":    {Delay: 500 * time.Millisecond, Parallelism: 1},
+			},
+		},
+		"ok, empty":                 {in: "", want: map[string]HostLimit{}},
+		"fail, missing delay":       {in: "otodom.pl", wantErr: true},
+		"fail, invalid delay":       {in: "otodom.pl=soon", wantErr: true},
+		"fail, invalid parallelism": {in: "otodom.pl=1s:0", wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseHostLimits(tc.in)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("got error %v want error %v", err, tc.wantErr)
+			}
+
+			if !tc.wantErr && !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %v want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func Test_Collector_Parse_Robots(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle("/", http.FileServer(http.Dir("testdata")))
+	mux.HandleFunc("/robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("User-agent: *\nDisallow: /gratka.html\n"))
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	tests := map[string]struct {
+		fixture       string
+		respectRobots bool
+		wantErr       error
+	}{
+		"ok, allowed":                {fixture: "olx.html", respectRobots: true},
+		"ok, robots.txt ignored":     {fixture: "gratka.html"},
+		"fail, disallowed by robots": {fixture: "gratka.html", respectRobots: true, wantErr: ErrRobotsDisallowed},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			registry := NewRegistry()
+			registry.SetFallback(Generic())
+
+			cc := colly.NewCollector()
+			cc.IgnoreRobotsTxt = !tc.respectRobots
+
+			c := NewCollector(cc, registry, func(err error) {})
+
+			_, err := c.Parse(context.Background(), srv.URL+"/"+tc.fixture)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("expected errors to be %v got %v (via errors.Is)", tc.wantErr, err)
+			}
+		})
+	}
+}
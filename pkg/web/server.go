@@ -1,6 +1,7 @@
 package web
 
 import (
+	"encoding/json"
 	"errors"
 	"hestia/pkg/auth"
 	"hestia/pkg/custerrors"
@@ -12,13 +13,26 @@ import (
 
 // ServerDeps are the dependencies for the server.
 type ServerDeps struct {
-	Logger       *slog.Logger
-	UserService  *services.UserService
-	FlatService  *services.FlatService
-	AuthService  *services.AuthService
-	EmailService *services.EmailService
-	JWT          *auth.JWTConfig
-	Interceptor  *auth.Interceptor
+	Logger           *slog.Logger
+	UserService      *services.UserService
+	FlatService      *services.FlatService
+	ScrapeService    *services.ScrapeService
+	CrawlService     *services.CrawlService
+	PhotoService     *services.PhotoService
+	ArchiveService   *services.ArchiveService
+	AuthService      *services.AuthService
+	EmailService     *services.EmailService
+	RulesService     *services.ParserRuleService
+	AddressService   *services.AddressService
+	ShortlistService *services.ShortlistService
+	SearchService    *services.SavedSearchService
+	PipelineService  *services.PipelineService
+	CommentService   *services.CommentService
+	WorkspaceService *services.WorkspaceService
+	JWT              *auth.JWTConfig
+	Interceptor      *auth.Interceptor
+	// CORSOrigins may import listing pages from the browser.
+	CORSOrigins []string
 }
 
 func NewServer(s *ServerDeps) http.Handler {
@@ -95,7 +109,7 @@ func NewServer(s *ServerDeps) http.Handler {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
 	}))
-	mux.Handle("GET /api/v1/flats/{id}", middlewares.CheckJWT(s.Interceptor, func(w http.ResponseWriter, r *http.Request) {
+	mux.Handle("GET /api/v1/flats/{id}", middlewares.CheckJWT(s.Interceptor, s.flatMember(func(w http.ResponseWriter, r *http.Request) {
 		err := s.FlatService.Get(w, r)
 		if err != nil {
 			s.handleError(w, err)
@@ -103,41 +117,467 @@ func NewServer(s *ServerDeps) http.Handler {
 		}
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
-	}))
+	})))
+	mux.Handle("GET /api/v1/flats/{id}/history", middlewares.CheckJWT(s.Interceptor, s.flatMember(func(w http.ResponseWriter, r *http.Request) {
+		err := s.FlatService.History(w, r)
+		if err != nil {
+			s.handleError(w, err)
+			return
+		}
+	})))
+	mux.Handle("GET /api/v1/flats/{id}/duplicates", middlewares.CheckJWT(s.Interceptor, s.flatMember(func(w http.ResponseWriter, r *http.Request) {
+		err := s.FlatService.Duplicates(w, r)
+		if err != nil {
+			s.handleError(w, err)
+			return
+		}
+	})))
+	mux.Handle("POST /api/v1/flats/{id}/merge", middlewares.CheckJWT(s.Interceptor, s.flatMember(func(w http.ResponseWriter, r *http.Request) {
+		err := s.FlatService.Merge(w, r)
+		if err != nil {
+			s.handleError(w, err)
+			return
+		}
+	})))
+	mux.Handle("POST /api/v1/flats/{id}/split", middlewares.CheckJWT(s.Interceptor, s.flatMember(func(w http.ResponseWriter, r *http.Request) {
+		err := s.FlatService.Split(w, r)
+		if err != nil {
+			s.handleError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})))
+	mux.Handle("GET /api/v1/flats/{id}/photos", middlewares.CheckJWT(s.Interceptor, s.flatMember(func(w http.ResponseWriter, r *http.Request) {
+		err := s.PhotoService.List(w, r)
+		if err != nil {
+			s.handleError(w, err)
+			return
+		}
+	})))
+	mux.Handle("GET /api/v1/flats/{id}/photos/{photoID}", middlewares.CheckJWT(s.Interceptor, s.flatMember(func(w http.ResponseWriter, r *http.Request) {
+		err := s.PhotoService.Get(w, r)
+		if err != nil {
+			s.handleError(w, err)
+			return
+		}
+	})))
+	mux.Handle("GET /api/v1/flats/{id}/photos/{photoID}/thumbnail", middlewares.CheckJWT(s.Interceptor, s.flatMember(func(w http.ResponseWriter, r *http.Request) {
+		err := s.PhotoService.Thumbnail(w, r)
+		if err != nil {
+			s.handleError(w, err)
+			return
+		}
+	})))
+	mux.Handle("GET /api/v1/flats/{id}/snapshots", middlewares.CheckJWT(s.Interceptor, s.flatMember(func(w http.ResponseWriter, r *http.Request) {
+		err := s.ArchiveService.List(w, r)
+		if err != nil {
+			s.handleError(w, err)
+			return
+		}
+	})))
 	mux.Handle("POST /api/v1/flats", middlewares.CheckJWT(s.Interceptor, func(w http.ResponseWriter, r *http.Request) {
 		err := s.FlatService.Post(w, r)
 		if err != nil {
 			s.handleError(w, err)
 			return
 		}
-		w.WriteHeader(http.StatusCreated)
 	}))
-	mux.Handle("PUT /api/v1/flats/{id}", middlewares.CheckJWT(s.Interceptor, func(w http.ResponseWriter, r *http.Request) {
+	mux.Handle("POST /api/v1/flats/preview", middlewares.CheckJWT(s.Interceptor, func(w http.ResponseWriter, r *http.Request) {
+		err := s.FlatService.Preview(w, r)
+		if err != nil {
+			s.handleError(w, err)
+			return
+		}
+	}))
+	importCORS := middlewares.CORS(s.CORSOrigins)
+	mux.Handle("OPTIONS /api/v1/flats/import", importCORS(http.NotFoundHandler()))
+	mux.Handle("POST /api/v1/flats/import", importCORS(middlewares.CheckJWT(s.Interceptor, func(w http.ResponseWriter, r *http.Request) {
+		err := s.FlatService.Import(w, r)
+		if err != nil {
+			s.handleError(w, err)
+			return
+		}
+	})))
+	mux.Handle("PUT /api/v1/flats/{id}", middlewares.CheckJWT(s.Interceptor, s.flatMember(func(w http.ResponseWriter, r *http.Request) {
 		err := s.FlatService.Put(w, r)
 		if err != nil {
 			s.handleError(w, err)
 			return
 		}
 		w.WriteHeader(http.StatusNoContent)
-	}))
-	mux.Handle("DELETE /api/v1/flats/{id}", middlewares.CheckJWT(s.Interceptor, func(w http.ResponseWriter, r *http.Request) {
+	})))
+	mux.Handle("DELETE /api/v1/flats/{id}", middlewares.CheckJWT(s.Interceptor, s.flatMember(func(w http.ResponseWriter, r *http.Request) {
 		err := s.FlatService.Delete(w, r)
 		if err != nil {
 			s.handleError(w, err)
 			return
 		}
 		w.WriteHeader(http.StatusNoContent)
+	})))
+
+	mux.Handle("POST /api/v1/crawls", middlewares.CheckJWT(s.Interceptor, func(w http.ResponseWriter, r *http.Request) {
+		err := s.CrawlService.Post(w, r)
+		if err != nil {
+			s.handleError(w, err)
+			return
+		}
+	}))
+	mux.Handle("GET /api/v1/snapshots/{id}", middlewares.CheckJWT(s.Interceptor, func(w http.ResponseWriter, r *http.Request) {
+		err := s.ArchiveService.Get(w, r)
+		if err != nil {
+			s.handleError(w, err)
+			return
+		}
+	}))
+	mux.Handle("POST /api/v1/snapshots/reparse", middlewares.CheckJWT(s.Interceptor, func(w http.ResponseWriter, r *http.Request) {
+		err := s.ArchiveService.Reparse(w, r)
+		if err != nil {
+			s.handleError(w, err)
+			return
+		}
+	}))
+	mux.Handle("GET /api/v1/parsers/health", middlewares.CheckJWT(s.Interceptor, func(w http.ResponseWriter, r *http.Request) {
+		err := s.ScrapeService.ParserHealth(w, r)
+		if err != nil {
+			s.handleError(w, err)
+			return
+		}
+	}))
+	mux.Handle("GET /api/v1/parser-rules", middlewares.CheckJWT(s.Interceptor, func(w http.ResponseWriter, r *http.Request) {
+		err := s.RulesService.List(w, r)
+		if err != nil {
+			s.handleError(w, err)
+			return
+		}
 	}))
+	mux.Handle("POST /api/v1/parser-rules", middlewares.CheckJWT(s.Interceptor, func(w http.ResponseWriter, r *http.Request) {
+		err := s.RulesService.Post(w, r)
+		if err != nil {
+			s.handleError(w, err)
+			return
+		}
+	}))
+	mux.Handle("GET /api/v1/parser-rules/{id}", middlewares.CheckJWT(s.Interceptor, func(w http.ResponseWriter, r *http.Request) {
+		err := s.RulesService.Get(w, r)
+		if err != nil {
+			s.handleError(w, err)
+			return
+		}
+	}))
+	mux.Handle("DELETE /api/v1/parser-rules/{id}", middlewares.CheckJWT(s.Interceptor, func(w http.ResponseWriter, r *http.Request) {
+		err := s.RulesService.Delete(w, r)
+		if err != nil {
+			s.handleError(w, err)
+			return
+		}
+	}))
+	mux.Handle("POST /api/v1/parser-rules/{id}/publish", middlewares.CheckJWT(s.Interceptor, func(w http.ResponseWriter, r *http.Request) {
+		err := s.RulesService.Publish(w, r)
+		if err != nil {
+			s.handleError(w, err)
+			return
+		}
+	}))
+	mux.Handle("POST /api/v1/parser-rules/{id}/test", middlewares.CheckJWT(s.Interceptor, func(w http.ResponseWriter, r *http.Request) {
+		err := s.RulesService.Test(w, r)
+		if err != nil {
+			s.handleError(w, err)
+			return
+		}
+	}))
+	mux.Handle("GET /api/v1/districts", middlewares.CheckJWT(s.Interceptor, func(w http.ResponseWriter, r *http.Request) {
+		err := s.AddressService.Districts(w, r)
+		if err != nil {
+			s.handleError(w, err)
+			return
+		}
+	}))
+	mux.Handle("GET /api/v1/addresses/unrecognized", middlewares.CheckJWT(s.Interceptor, func(w http.ResponseWriter, r *http.Request) {
+		err := s.AddressService.Unrecognized(w, r)
+		if err != nil {
+			s.handleError(w, err)
+			return
+		}
+	}))
+	mux.Handle("POST /api/v1/addresses/reparse", middlewares.CheckJWT(s.Interceptor, func(w http.ResponseWriter, r *http.Request) {
+		err := s.AddressService.Reparse(w, r)
+		if err != nil {
+			s.handleError(w, err)
+			return
+		}
+	}))
+	mux.Handle("GET /api/v1/me/shortlists", middlewares.CheckJWT(s.Interceptor, func(w http.ResponseWriter, r *http.Request) {
+		err := s.ShortlistService.List(w, r)
+		if err != nil {
+			s.handleError(w, err)
+			return
+		}
+	}))
+	mux.Handle("POST /api/v1/me/shortlists", middlewares.CheckJWT(s.Interceptor, func(w http.ResponseWriter, r *http.Request) {
+		err := s.ShortlistService.Post(w, r)
+		if err != nil {
+			s.handleError(w, err)
+			return
+		}
+	}))
+	mux.Handle("GET /api/v1/me/shortlists/{id}", middlewares.CheckJWT(s.Interceptor, func(w http.ResponseWriter, r *http.Request) {
+		err := s.ShortlistService.Get(w, r)
+		if err != nil {
+			s.handleError(w, err)
+			return
+		}
+	}))
+	mux.Handle("DELETE /api/v1/me/shortlists/{id}", middlewares.CheckJWT(s.Interceptor, func(w http.ResponseWriter, r *http.Request) {
+		err := s.ShortlistService.Delete(w, r)
+		if err != nil {
+			s.handleError(w, err)
+			return
+		}
+	}))
+	mux.Handle("POST /api/v1/me/shortlists/{id}/flats", middlewares.CheckJWT(s.Interceptor, func(w http.ResponseWriter, r *http.Request) {
+		err := s.ShortlistService.AddFlat(w, r)
+		if err != nil {
+			s.handleError(w, err)
+			return
+		}
+	}))
+	mux.Handle("PUT /api/v1/me/shortlists/{id}/flats", middlewares.CheckJWT(s.Interceptor, func(w http.ResponseWriter, r *http.Request) {
+		err := s.ShortlistService.Reorder(w, r)
+		if err != nil {
+			s.handleError(w, err)
+			return
+		}
+	}))
+	mux.Handle("DELETE /api/v1/me/shortlists/{id}/flats/{flatID}", middlewares.CheckJWT(s.Interceptor, func(w http.ResponseWriter, r *http.Request) {
+		err := s.ShortlistService.RemoveFlat(w, r)
+		if err != nil {
+			s.handleError(w, err)
+			return
+		}
+	}))
+	mux.Handle("GET /api/v1/me/searches", middlewares.CheckJWT(s.Interceptor, func(w http.ResponseWriter, r *http.Request) {
+		err := s.SearchService.List(w, r)
+		if err != nil {
+			s.handleError(w, err)
+			return
+		}
+	}))
+	mux.Handle("POST /api/v1/me/searches", middlewares.CheckJWT(s.Interceptor, func(w http.ResponseWriter, r *http.Request) {
+		err := s.SearchService.Post(w, r)
+		if err != nil {
+			s.handleError(w, err)
+			return
+		}
+	}))
+	mux.Handle("GET /api/v1/me/searches/{id}", middlewares.CheckJWT(s.Interceptor, func(w http.ResponseWriter, r *http.Request) {
+		err := s.SearchService.Get(w, r)
+		if err != nil {
+			s.handleError(w, err)
+			return
+		}
+	}))
+	mux.Handle("PUT /api/v1/me/searches/{id}", middlewares.CheckJWT(s.Interceptor, func(w http.ResponseWriter, r *http.Request) {
+		err := s.SearchService.Put(w, r)
+		if err != nil {
+			s.handleError(w, err)
+			return
+		}
+	}))
+	mux.Handle("DELETE /api/v1/me/searches/{id}", middlewares.CheckJWT(s.Interceptor, func(w http.ResponseWriter, r *http.Request) {
+		err := s.SearchService.Delete(w, r)
+		if err != nil {
+			s.handleError(w, err)
+			return
+		}
+	}))
+	mux.Handle("GET /api/v1/me/pipeline", middlewares.CheckJWT(s.Interceptor, func(w http.ResponseWriter, r *http.Request) {
+		err := s.PipelineService.Board(w, r)
+		if err != nil {
+			s.handleError(w, err)
+			return
+		}
+	}))
+	mux.Handle("GET /api/v1/me/pipeline/{id}", middlewares.CheckJWT(s.Interceptor, s.flatMember(func(w http.ResponseWriter, r *http.Request) {
+		err := s.PipelineService.Get(w, r)
+		if err != nil {
+			s.handleError(w, err)
+			return
+		}
+	})))
+	mux.Handle("PUT /api/v1/me/pipeline/{id}", middlewares.CheckJWT(s.Interceptor, s.flatMember(func(w http.ResponseWriter, r *http.Request) {
+		err := s.PipelineService.Move(w, r)
+		if err != nil {
+			s.handleError(w, err)
+			return
+		}
+	})))
+	mux.Handle("DELETE /api/v1/me/pipeline/{id}", middlewares.CheckJWT(s.Interceptor, s.flatMember(func(w http.ResponseWriter, r *http.Request) {
+		err := s.PipelineService.Delete(w, r)
+		if err != nil {
+			s.handleError(w, err)
+			return
+		}
+	})))
+	mux.Handle("GET /api/v1/flats/{id}/notes", middlewares.CheckJWT(s.Interceptor, s.flatMember(func(w http.ResponseWriter, r *http.Request) {
+		err := s.CommentService.GetNote(w, r)
+		if err != nil {
+			s.handleError(w, err)
+			return
+		}
+	})))
+	mux.Handle("PUT /api/v1/flats/{id}/notes", middlewares.CheckJWT(s.Interceptor, s.flatMember(func(w http.ResponseWriter, r *http.Request) {
+		err := s.CommentService.PutNote(w, r)
+		if err != nil {
+			s.handleError(w, err)
+			return
+		}
+	})))
+	mux.Handle("DELETE /api/v1/flats/{id}/notes", middlewares.CheckJWT(s.Interceptor, s.flatMember(func(w http.ResponseWriter, r *http.Request) {
+		err := s.CommentService.DeleteNote(w, r)
+		if err != nil {
+			s.handleError(w, err)
+			return
+		}
+	})))
+	mux.Handle("GET /api/v1/flats/{id}/comments", middlewares.CheckJWT(s.Interceptor, s.flatMember(func(w http.ResponseWriter, r *http.Request) {
+		err := s.CommentService.List(w, r)
+		if err != nil {
+			s.handleError(w, err)
+			return
+		}
+	})))
+	mux.Handle("POST /api/v1/flats/{id}/comments", middlewares.CheckJWT(s.Interceptor, s.flatMember(func(w http.ResponseWriter, r *http.Request) {
+		err := s.CommentService.Post(w, r)
+		if err != nil {
+			s.handleError(w, err)
+			return
+		}
+	})))
+	mux.Handle("GET /api/v1/flats/{id}/comments/{commentID}", middlewares.CheckJWT(s.Interceptor, s.flatMember(func(w http.ResponseWriter, r *http.Request) {
+		err := s.CommentService.Get(w, r)
+		if err != nil {
+			s.handleError(w, err)
+			return
+		}
+	})))
+	mux.Handle("PUT /api/v1/flats/{id}/comments/{commentID}", middlewares.CheckJWT(s.Interceptor, s.flatMember(func(w http.ResponseWriter, r *http.Request) {
+		err := s.CommentService.Put(w, r)
+		if err != nil {
+			s.handleError(w, err)
+			return
+		}
+	})))
+	mux.Handle("DELETE /api/v1/flats/{id}/comments/{commentID}", middlewares.CheckJWT(s.Interceptor, s.flatMember(func(w http.ResponseWriter, r *http.Request) {
+		err := s.CommentService.Delete(w, r)
+		if err != nil {
+			s.handleError(w, err)
+			return
+		}
+	})))
+	mux.Handle("GET /api/v1/me/workspace", middlewares.CheckJWT(s.Interceptor, func(w http.ResponseWriter, r *http.Request) {
+		err := s.WorkspaceService.Get(w, r)
+		if err != nil {
+			s.handleError(w, err)
+			return
+		}
+	}))
+	mux.Handle("PUT /api/v1/me/workspace", middlewares.CheckJWT(s.Interceptor, func(w http.ResponseWriter, r *http.Request) {
+		err := s.WorkspaceService.Put(w, r)
+		if err != nil {
+			s.handleError(w, err)
+			return
+		}
+	}))
+	mux.Handle("POST /api/v1/me/workspace/invitations", middlewares.CheckJWT(s.Interceptor, func(w http.ResponseWriter, r *http.Request) {
+		err := s.WorkspaceService.Invite(w, r)
+		if err != nil {
+			s.handleError(w, err)
+			return
+		}
+	}))
+	mux.Handle("POST /api/v1/me/workspace/join", middlewares.CheckJWT(s.Interceptor, func(w http.ResponseWriter, r *http.Request) {
+		err := s.WorkspaceService.Join(w, r)
+		if err != nil {
+			s.handleError(w, err)
+			return
+		}
+	}))
+	mux.Handle("DELETE /api/v1/me/workspace/members/{id}", middlewares.CheckJWT(s.Interceptor, func(w http.ResponseWriter, r *http.Request) {
+		err := s.WorkspaceService.RemoveMember(w, r)
+		if err != nil {
+			s.handleError(w, err)
+			return
+		}
+	}))
+	mux.Handle("GET /api/v1/scrape-jobs/{id}", middlewares.CheckJWT(s.Interceptor, s.scrapeJobMember(func(w http.ResponseWriter, r *http.Request) {
+		err := s.ScrapeService.Get(w, r)
+		if err != nil {
+			s.handleError(w, err)
+			return
+		}
+	})))
 
 	return mux
 }
 
+// flatMember runs handler only if the flat with the id in the path belongs
+// to the workspace of the user. Flats of other workspaces are not found.
+func (s *ServerDeps) flatMember(handler func(w http.ResponseWriter, r *http.Request)) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := s.WorkspaceService.CheckFlat(r)
+		if err != nil {
+			s.handleError(w, err)
+			return
+		}
+
+		handler(w, r)
+	}
+}
+
+// scrapeJobMember runs handler only if the scrape job with the id in the path
+// was queued by a member of the workspace of the user. Other jobs are not found.
+func (s *ServerDeps) scrapeJobMember(handler func(w http.ResponseWriter, r *http.Request)) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := s.WorkspaceService.CheckScrapeJob(r)
+		if err != nil {
+			s.handleError(w, err)
+			return
+		}
+
+		handler(w, r)
+	}
+}
+
 func (s *ServerDeps) handleError(w http.ResponseWriter, err error) {
 	if errors.Is(err, custerrors.ErrNotFound) {
 		http.Error(w, "not found", http.StatusNotFound)
 		return
 	}
 
+	var dup *services.DuplicateFlatError
+	if errors.As(err, &dup) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Location", "/api/v1/flats/"+dup.ID)
+		w.WriteHeader(http.StatusConflict)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": dup.Error(), "id": dup.ID})
+		return
+	}
+
+	if errors.Is(err, custerrors.ErrConstraintViolated) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	if errors.Is(err, custerrors.ErrInvalidInput) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if errors.Is(err, custerrors.ErrForbidden) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
 	http.Error(w, err.Error(), http.StatusInternalServerError)
 	return
 }